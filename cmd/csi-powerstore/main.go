@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/dell/csi-powerstore/v2/core"
+	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/controller"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers/fs"
@@ -127,6 +128,12 @@ func main() {
 		}
 
 		controllerService = cs
+		if interval := array.EndpointResolveInterval(); interval > 0 {
+			go cs.ResolveEndpointsPeriodically(context.Background(), interval, nil)
+		}
+		if address, ok := csictx.LookupEnv(context.Background(), identifiers.EnvMetricsAddress); ok && address != "" {
+			go controller.ServeMetrics(address)
+		}
 	} else if strings.EqualFold(mode, "node") {
 		ns := &node.Service{
 			Fs: f,
@@ -142,26 +149,22 @@ func main() {
 			log.Fatalf("couldn't create node service: %s", err.Error())
 		}
 		nodeService = ns
+		if interval := array.EndpointResolveInterval(); interval > 0 {
+			go ns.ResolveEndpointsPeriodically(context.Background(), interval, nil)
+		}
 	}
 
-	viper.SetConfigFile(configPath)
-	viper.SetConfigType("yaml")
-	viper.WatchConfig()
-	viper.OnConfigChange(func(e fsnotify.Event) {
-		log.Println("Config file changed:", e.Name)
-
-		if strings.EqualFold(mode, "controller") {
-			err := controllerService.UpdateArrays(configPath, f)
-			if err != nil {
-				log.Fatalf("couldn't initialize arrays in controller service: %s", err.Error())
-			}
-		} else if strings.EqualFold(mode, "node") {
-			err := nodeService.UpdateArrays(configPath, f)
-			if err != nil {
-				log.Fatalf("couldn't initialize arrays in node service: %s", err.Error())
-			}
+	var arrays *array.Locker
+	if strings.EqualFold(mode, "controller") {
+		arrays = &controllerService.Locker
+	} else if strings.EqualFold(mode, "node") {
+		arrays = &nodeService.Locker
+	}
+	if arrays != nil {
+		if _, err := arrays.WatchConfig(configPath, f); err != nil {
+			log.Fatalf("couldn't watch array config: %s", err.Error())
 		}
-	})
+	}
 
 	InterceptorsList := []grpc.UnaryServerInterceptor{
 		interceptors.NewCustomSerialLock(mode),
@@ -182,6 +185,11 @@ func main() {
 
 	storageProvider := provider.New(controllerService, identityService, nodeService, InterceptorsList)
 	runCSIPlugin(storageProvider)
+
+	if controllerService != nil {
+		// the server has stopped gracefully, wait for any IO checks still in flight
+		controllerService.Shutdown()
+	}
 }
 
 var runCSIPlugin = func(storageProvider *gocsi.StoragePlugin) {
@@ -0,0 +1,296 @@
+/*
+ *
+ * Copyright © 2025 Dell Inc. or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package array
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
+	"github.com/dell/gopowerstore"
+	"github.com/dell/gopowerstore/api"
+)
+
+const (
+	// apiTokenHeader carries the session token returned by the array
+	apiTokenHeader = "DELL-EMC-TOKEN" // #nosec G101
+	// apiPaginationHeader reports the range of a partial response, e.g. 0-99/250
+	apiPaginationHeader = "content-range"
+)
+
+// apiClient is a PowerStore API client sending its requests through an HTTP client built by the driver.
+// gopowerstore doesn't accept an HTTP client or a TLS config, so apiClient is used instead of the client of
+// gopowerstore when the array is verified against a custom CA or requires a min TLS version.
+// It follows the gopowerstore client: requests use basic auth and the session token of the last successful
+// response, and a forbidden request logs in again before being retried once.
+type apiClient struct {
+	apiURL         string
+	username       string
+	password       string
+	httpClient     *http.Client
+	defaultTimeout time.Duration
+	requestIDKey   api.ContextKey
+	headers        *api.SafeHeader
+	throttle       api.TimeoutSemaphoreInterface
+
+	loggerMux sync.RWMutex
+	logger    api.Logger
+
+	tokenMux sync.RWMutex
+	token    string
+}
+
+// newAPIClient returns an apiClient for apiURL whose connections use tlsConfig. Unlike the gopowerstore client,
+// it doesn't log in when created, the session is created by the first request.
+func newAPIClient(apiURL, username, password string, options *gopowerstore.ClientOptions, tlsConfig *tls.Config) (*apiClient, error) {
+	if apiURL == "" || username == "" || password == "" {
+		return nil, errors.New("API client can't be initialized: missing endpoint, username, or password param")
+	}
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+	// the array keeps the session in the auth_cookie cookie
+	if jar, err := cookiejar.New(nil); err == nil {
+		httpClient.Jar = jar
+	}
+	logger := &identifiers.CustomLogger{}
+	return &apiClient{
+		apiURL:         apiURL,
+		username:       username,
+		password:       password,
+		httpClient:     httpClient,
+		defaultTimeout: options.DefaultTimeout(),
+		requestIDKey:   options.RequestIDKey(),
+		headers:        api.NewSafeHeader(),
+		throttle:       api.NewTimeoutSemaphore(options.DefaultTimeout(), options.RateLimit(), logger),
+		logger:         logger,
+	}, nil
+}
+
+// clientTLSConfig returns the TLS config of the connections to an array. It matches the config of the gopowerstore
+// client, verifying the array against rootCAs, or the system cert pool when nil, and negotiating at least minVersion.
+func clientTLSConfig(insecure bool, rootCAs *x509.CertPool, minVersion uint16) *tls.Config {
+	if insecure {
+		return &tls.Config{
+			InsecureSkipVerify: true, // #nosec G402
+			MinVersion:         minVersion,
+		}
+	}
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	return &tls.Config{
+		RootCAs:      rootCAs,
+		CipherSuites: api.GetSecuredCipherSuites(),
+		MinVersion:   minVersion,
+	}
+}
+
+// SetTraceID sets the tracing ID of the requests made with ctx
+func (c *apiClient) SetTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, c.requestIDKey, traceID)
+}
+
+// TraceID returns the tracing ID of the requests made with ctx
+func (c *apiClient) TraceID(ctx context.Context) string {
+	traceID, _ := ctx.Value(c.requestIDKey).(string)
+	return traceID
+}
+
+// QueryParams returns an empty QueryParamsEncoder
+func (c *apiClient) QueryParams() api.QueryParamsEncoder {
+	return &api.QueryParams{}
+}
+
+// QueryParamsWithFields returns a QueryParamsEncoder selecting the fields of provider
+func (c *apiClient) QueryParamsWithFields(provider api.FieldProvider) api.QueryParamsEncoder {
+	return c.QueryParams().Select(provider.Fields()...)
+}
+
+// GetCustomHTTPHeaders returns the headers sent with every request
+func (c *apiClient) GetCustomHTTPHeaders() http.Header {
+	return c.headers.GetHeader()
+}
+
+// SetCustomHTTPHeaders sets the headers sent with every request
+func (c *apiClient) SetCustomHTTPHeaders(headers http.Header) {
+	c.headers.SetHeader(headers)
+}
+
+// SetLogger sets the logger of the client
+func (c *apiClient) SetLogger(logger api.Logger) {
+	c.loggerMux.Lock()
+	defer c.loggerMux.Unlock()
+	c.logger = logger
+	c.throttle.SetLogger(logger)
+}
+
+func (c *apiClient) getLogger() api.Logger {
+	c.loggerMux.RLock()
+	defer c.loggerMux.RUnlock()
+	return c.logger
+}
+
+// Query performs the request described by cfg and decodes the response body into resp
+func (c *apiClient) Query(ctx context.Context, cfg api.RequestConfigRenderer, resp interface{}) (api.RespMeta, error) {
+	return c.query(ctx, cfg, resp, true)
+}
+
+func (c *apiClient) query(ctx context.Context, cfg api.RequestConfigRenderer, resp interface{}, relogin bool) (api.RespMeta, error) {
+	config := cfg.RenderRequestConfig()
+	meta := api.RespMeta{}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.defaultTimeout)
+		defer cancel()
+	}
+
+	req, err := c.newRequest(ctx, config)
+	if err != nil {
+		return meta, err
+	}
+
+	c.getLogger().Debug(ctx, "Requesting a lock for API : [%s %s]\n", config.Method, req.URL)
+	if err := c.throttle.Acquire(ctx); err != nil {
+		return meta, err
+	}
+	r, err := c.httpClient.Do(req)
+	c.throttle.Release(ctx)
+	if err != nil {
+		return meta, err
+	}
+	defer r.Body.Close() // #nosec G307
+
+	meta.Status = r.StatusCode
+	switch {
+	case resp == nil:
+		return meta, nil
+	case r.StatusCode >= 200 && r.StatusCode < 300:
+		if token := r.Header.Get(apiTokenHeader); token != "" {
+			c.tokenMux.Lock()
+			c.token = token
+			c.tokenMux.Unlock()
+		}
+		meta.Pagination = parsePagination(r)
+		err = json.NewDecoder(r.Body).Decode(resp)
+		if err == io.EOF {
+			return meta, nil
+		}
+		return meta, err
+	case r.StatusCode == http.StatusForbidden && relogin:
+		// the session expired, log in again and retry the request once
+		var sessions []struct {
+			ID string `json:"id"`
+		}
+		loginMeta, err := c.query(ctx, api.RequestConfig{Method: http.MethodGet, Endpoint: "login_session"}, &sessions, false)
+		if err != nil || loginMeta.Status == http.StatusUnauthorized {
+			return meta, apiError(r)
+		}
+		return c.query(ctx, cfg, resp, false)
+	default:
+		return meta, apiError(r)
+	}
+}
+
+// newRequest returns the HTTP request for config, authenticated with the credentials and the session token
+func (c *apiClient) newRequest(ctx context.Context, config api.RequestConfig) (*http.Request, error) {
+	requestURL, err := url.Parse(c.apiURL)
+	if err != nil {
+		return nil, err
+	}
+	requestURL.Path = path.Join(requestURL.Path, config.Endpoint, config.ID, config.Action)
+	if config.QueryParams != nil {
+		requestURL.RawQuery = config.QueryParams.Encode()
+	}
+
+	var body io.Reader
+	if config.Body != nil && !(reflect.ValueOf(config.Body).Kind() == reflect.Ptr && reflect.ValueOf(config.Body).IsNil()) {
+		data, err := json.Marshal(config.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewBuffer(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, config.Method, requestURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	c.tokenMux.RLock()
+	if c.token != "" {
+		req.Header.Add(apiTokenHeader, c.token)
+	}
+	c.tokenMux.RUnlock()
+	for key, values := range c.headers.GetHeader() {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	// bodies can carry metadata sent as headers
+	if withMetaData, ok := config.Body.(interface{ MetaData() http.Header }); ok {
+		for key := range withMetaData.MetaData() {
+			req.Header.Add(key, withMetaData.MetaData().Get(key))
+		}
+	}
+	return req, nil
+}
+
+// parsePagination returns the pagination of a partial response, the zero value for complete responses
+func parsePagination(r *http.Response) api.PaginationInfo {
+	if r.StatusCode != http.StatusPartialContent {
+		return api.PaginationInfo{}
+	}
+	var first, last, total int
+	if _, err := fmt.Sscanf(r.Header.Get(apiPaginationHeader), "%d-%d/%d", &first, &last, &total); err != nil {
+		return api.PaginationInfo{}
+	}
+	return api.PaginationInfo{First: first, Last: last, Total: total, IsPaginate: true}
+}
+
+// apiError returns the first error message of the response body, in the form gopowerstore reports API errors
+func apiError(r *http.Response) error {
+	var body struct {
+		Messages []api.ErrorMsg `json:"messages"`
+	}
+	data, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil || len(body.Messages) == 0 {
+		return &api.ErrorMsg{
+			StatusCode: r.StatusCode,
+			Severity:   "Error",
+			Message:    strings.TrimSpace("Unknown error: " + string(data)),
+		}
+	}
+	errMsg := body.Messages[0]
+	errMsg.StatusCode = r.StatusCode
+	return &errMsg
+}
+
+var _ api.Client = (*apiClient)(nil)
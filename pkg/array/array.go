@@ -21,9 +21,13 @@ package array
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -31,13 +35,17 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/dell/csi-powerstore/v2/core"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers/fs"
+	"github.com/dell/csm-sharednfs/nfs"
 	csictx "github.com/dell/gocsi/context"
 	"github.com/dell/gopowerstore"
+	"github.com/dell/gopowerstore/api"
+	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -61,25 +69,25 @@ type Consumer interface {
 	UpdateArrays(string, fs.Interface) error
 }
 
-// Locker provides implementation for safe management of arrays
+// Locker provides implementation for safe management of arrays.
+// A single lock guards both the arrays and the default array so that a reload swaps them atomically.
 type Locker struct {
-	arraysLock       sync.Mutex
-	defaultArrayLock sync.Mutex
-	arrays           map[string]*PowerStoreArray
-	defaultArray     *PowerStoreArray
+	lock         sync.RWMutex
+	arrays       map[string]*PowerStoreArray
+	defaultArray *PowerStoreArray
 }
 
 // Arrays is a getter for list of arrays
 func (s *Locker) Arrays() map[string]*PowerStoreArray {
-	s.arraysLock.Lock()
-	defer s.arraysLock.Unlock()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	return s.arrays
 }
 
 // GetOneArray is a getter for an arrays based on globalID
 func (s *Locker) GetOneArray(globalID string) (*PowerStoreArray, error) {
-	s.arraysLock.Lock()
-	defer s.arraysLock.Unlock()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	if arrayConfig, ok := s.arrays[globalID]; ok {
 		return arrayConfig, nil
 	}
@@ -89,25 +97,33 @@ func (s *Locker) GetOneArray(globalID string) (*PowerStoreArray, error) {
 
 // SetArrays adds an array
 func (s *Locker) SetArrays(arrays map[string]*PowerStoreArray) {
-	s.arraysLock.Lock()
-	defer s.arraysLock.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	s.arrays = arrays
 }
 
 // DefaultArray is a getter for default array
 func (s *Locker) DefaultArray() *PowerStoreArray {
-	s.defaultArrayLock.Lock()
-	defer s.defaultArrayLock.Unlock()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
 	return s.defaultArray
 }
 
 // SetDefaultArray sets default array
 func (s *Locker) SetDefaultArray(array *PowerStoreArray) {
-	s.defaultArrayLock.Lock()
-	defer s.defaultArrayLock.Unlock()
+	s.lock.Lock()
+	defer s.lock.Unlock()
 	s.defaultArray = array
 }
 
+// ArraysWithDefault returns the arrays and the default array as a consistent pair,
+// i.e. both are guaranteed to come from the same reload.
+func (s *Locker) ArraysWithDefault() (map[string]*PowerStoreArray, *PowerStoreArray) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.arrays, s.defaultArray
+}
+
 // setIPToArray safely updates the IPToArray matcher.
 func setIPToArray(matcher map[string]string) {
 	ipToArrayMux.Lock()
@@ -115,6 +131,13 @@ func setIPToArray(matcher map[string]string) {
 	IPToArray = matcher
 }
 
+// arrayByIP safely looks up the globalID of the array with the given IP in the IPToArray matcher.
+func arrayByIP(ip string) string {
+	ipToArrayMux.Lock()
+	defer ipToArrayMux.Unlock()
+	return IPToArray[ip]
+}
+
 // UpdateArrays updates array info
 func (s *Locker) UpdateArrays(configPath string, fs fs.Interface) error {
 	log.Info("updating array info")
@@ -122,12 +145,211 @@ func (s *Locker) UpdateArrays(configPath string, fs fs.Interface) error {
 	if err != nil {
 		return fmt.Errorf("can't get config for arrays: %s", err.Error())
 	}
-	s.SetArrays(arrays)
+	// swap arrays, matcher and default array under a single lock so readers never observe a mix of old and new config
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.arrays = arrays
 	setIPToArray(matcher)
-	s.SetDefaultArray(defaultArray)
+	s.defaultArray = defaultArray
 	return nil
 }
 
+// configWatchDebounce is how long WatchConfig waits for further edits before reloading the config.
+const configWatchDebounce = 500 * time.Millisecond
+
+// WatchConfig reloads the arrays whenever the config file at configPath changes, until stop is called.
+// Rapid edits are coalesced into a single reload. A config that can't be parsed is logged and the previous arrays are kept.
+func (s *Locker) WatchConfig(configPath string, fs fs.Interface) (stop func(), err error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("can't create config watcher: %s", err.Error())
+	}
+	configPath = filepath.Clean(configPath)
+	// watch the directory rather than the file to also catch atomic replacements, e.g. Kubernetes secret updates
+	if err := watcher.Add(filepath.Dir(configPath)); err != nil {
+		_ = watcher.Close()
+		return nil, fmt.Errorf("can't watch config %s: %s", configPath, err.Error())
+	}
+	realPath, _ := filepath.EvalSymlinks(configPath)
+
+	done := make(chan struct{})
+	go func() {
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				changed := filepath.Clean(event.Name) == configPath && event.Op&(fsnotify.Write|fsnotify.Create) != 0
+				if currentPath, _ := filepath.EvalSymlinks(configPath); currentPath != "" && currentPath != realPath {
+					realPath = currentPath
+					changed = true
+				}
+				if changed {
+					reload = time.After(configWatchDebounce)
+				}
+			case <-reload:
+				reload = nil
+				s.reloadConfig(configPath, fs)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warnf("config watcher error: %s", err.Error())
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			_ = watcher.Close()
+		})
+	}, nil
+}
+
+// reloadConfig updates the arrays from configPath and logs which arrays were added and removed.
+func (s *Locker) reloadConfig(configPath string, fs fs.Interface) {
+	previous := s.Arrays()
+	if err := s.UpdateArrays(configPath, fs); err != nil {
+		log.Errorf("couldn't reload config %s, keeping the previous arrays: %s", configPath, err.Error())
+		return
+	}
+	current := s.Arrays()
+	log.Infof("reloaded config %s, added arrays: %v, removed arrays: %v",
+		configPath, missingGlobalIDs(current, previous), missingGlobalIDs(previous, current))
+}
+
+// missingGlobalIDs returns the sorted global IDs of arrays that are in from but not in other.
+func missingGlobalIDs(from, other map[string]*PowerStoreArray) []string {
+	var ids []string
+	for globalID := range from {
+		if _, ok := other[globalID]; !ok {
+			ids = append(ids, globalID)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Resolver resolves a host name to its IP addresses
+type Resolver func(ctx context.Context, host string) ([]string, error)
+
+// EndpointResolveInterval returns the interval for re-resolving FQDN endpoints, 0 means re-resolution is disabled
+func EndpointResolveInterval() time.Duration {
+	value, ok := csictx.LookupEnv(context.Background(), identifiers.EnvEndpointResolveInterval)
+	if !ok || value == "" {
+		return 0
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		log.Warnf("can't parse endpoint resolve interval %s, re-resolution is disabled", value)
+		return 0
+	}
+	return interval
+}
+
+// ResolveEndpointsPeriodically re-resolves FQDN endpoints every interval until ctx is done.
+// A nil resolve uses the default net resolver.
+func (s *Locker) ResolveEndpointsPeriodically(ctx context.Context, interval time.Duration, resolve Resolver) {
+	if resolve == nil {
+		resolve = net.DefaultResolver.LookupHost
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.ResolveEndpoints(ctx, resolve)
+		}
+	}
+}
+
+// ResolveEndpoints resolves the FQDN endpoints of all arrays and, when the resolved address changed,
+// updates the resolved IP of the array and the IPToArray matcher. The array IP, which keeps the configured
+// host name and is the topology key of the array, is left untouched. Arrays configured with a literal IP are skipped.
+func (s *Locker) ResolveEndpoints(ctx context.Context, resolve Resolver) {
+	arrays := s.Arrays()
+
+	updated := make(map[string]*PowerStoreArray)
+	for globalID, arr := range arrays {
+		if identifiers.GetIPListFromString(arr.Endpoint) != nil {
+			continue
+		}
+		u, err := url.Parse(arr.Endpoint)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		ips, err := resolve(ctx, u.Hostname())
+		if err != nil || len(ips) == 0 {
+			log.Warnf("can't resolve endpoint %s of array %s: %v", u.Hostname(), globalID, err)
+			continue
+		}
+		if ips[0] == arr.GetResolvedIP() {
+			continue
+		}
+		log.Infof("endpoint %s of array %s resolved to %s, was %s", u.Hostname(), globalID, ips[0], arr.GetResolvedIP())
+		newArr := *arr
+		newArr.ResolvedIP = ips[0]
+		updated[globalID] = &newArr
+	}
+	if len(updated) == 0 {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	// the config may have been reloaded while resolving
+	if !sameArrays(s.arrays, arrays) {
+		return
+	}
+	newArrays := make(map[string]*PowerStoreArray, len(s.arrays))
+	for globalID, arr := range s.arrays {
+		newArrays[globalID] = arr
+	}
+
+	ipToArrayMux.Lock()
+	matcher := make(map[string]string, len(IPToArray)+len(updated))
+	for ip, globalID := range IPToArray {
+		matcher[ip] = globalID
+	}
+	ipToArrayMux.Unlock()
+
+	for globalID, arr := range updated {
+		old := newArrays[globalID]
+		// keep the configured host name, only a previously resolved address goes stale
+		if old.ResolvedIP != "" && old.ResolvedIP != old.IP {
+			delete(matcher, old.ResolvedIP)
+		}
+		matcher[arr.ResolvedIP] = globalID
+		newArrays[globalID] = arr
+		if s.defaultArray == old {
+			s.defaultArray = arr
+		}
+	}
+	s.arrays = newArrays
+	setIPToArray(matcher)
+}
+
+// sameArrays returns true when both maps hold the same array objects
+func sameArrays(a, b map[string]*PowerStoreArray) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for globalID, arr := range a {
+		if b[globalID] != arr {
+			return false
+		}
+	}
+	return true
+}
+
 type NASCooldownTracker interface {
 	MarkFailure(nas string)
 	IsInCooldown(nas string) bool
@@ -247,13 +469,17 @@ type PowerStoreArray struct {
 	NasName       string                    `yaml:"nasName"`
 	BlockProtocol identifiers.TransportType `yaml:"blockProtocol"`
 	Insecure      bool                      `yaml:"skipCertificateValidation"`
+	CertFile      string                    `yaml:"certFile"`
 	IsDefault     bool                      `yaml:"isDefault"`
 	NfsAcls       string                    `yaml:"nfsAcls"`
 	MetroTopology string                    `yaml:"metroTopology"`
 	Labels        map[string]string         `yaml:"labels"`
+	RateLimit     int                       `yaml:"rateLimit"`
 
-	Client             gopowerstore.Client
-	IP                 string
+	Client gopowerstore.Client
+	IP     string
+	// ResolvedIP is the address the FQDN endpoint last resolved to, empty until it is re-resolved
+	ResolvedIP         string
 	NASCooldownTracker NASCooldownTracker
 }
 
@@ -272,11 +498,157 @@ func (psa *PowerStoreArray) GetIP() string {
 	return psa.IP
 }
 
+// GetResolvedIP returns the address to connect to the array: the address its FQDN endpoint last resolved to,
+// or its IP when the endpoint hasn't been re-resolved. Unlike GetIP it may change, so it is not a topology key.
+func (psa *PowerStoreArray) GetResolvedIP() string {
+	if psa.ResolvedIP != "" {
+		return psa.ResolvedIP
+	}
+	return psa.IP
+}
+
 // GetGlobalID is a getter that returns GlobalID address of the array
 func (psa *PowerStoreArray) GetGlobalID() string {
 	return psa.GlobalID
 }
 
+// userAgent returns the User-Agent header value sent to the arrays, overridable with EnvUserAgent.
+// Characters that aren't allowed in header values are dropped.
+func userAgent() string {
+	defaultUserAgent := fmt.Sprintf("%s/%s", identifiers.VerboseName, core.SemVer)
+	value, ok := csictx.LookupEnv(context.Background(), identifiers.EnvUserAgent)
+	if !ok {
+		return defaultUserAgent
+	}
+	value = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || r > unicode.MaxASCII {
+			return -1
+		}
+		return r
+	}, value))
+	if value == "" {
+		return defaultUserAgent
+	}
+	return value
+}
+
+// metricsEndpoint is the endpoint of the PowerStore performance metric requests
+const metricsEndpoint = "metrics"
+
+// limitedAPIClient bounds the number of concurrent requests of an array client in the driver, with separate
+// limits for performance metric requests and for the other, control plane, requests. A nil semaphore is unbounded.
+type limitedAPIClient struct {
+	api.Client
+	metrics chan struct{}
+	control chan struct{}
+}
+
+func newLimitedAPIClient(client api.Client, maxMetricsRequests, maxControlRequests int) *limitedAPIClient {
+	c := &limitedAPIClient{Client: client}
+	if maxMetricsRequests > 0 {
+		c.metrics = make(chan struct{}, maxMetricsRequests)
+	}
+	if maxControlRequests > 0 {
+		c.control = make(chan struct{}, maxControlRequests)
+	}
+	return c
+}
+
+// Query waits for a free slot of the kind of the request before performing it
+func (c *limitedAPIClient) Query(ctx context.Context, cfg api.RequestConfigRenderer, resp interface{}) (api.RespMeta, error) {
+	sem := c.control
+	if cfg.RenderRequestConfig().Endpoint == metricsEndpoint {
+		sem = c.metrics
+	}
+	if sem != nil {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-ctx.Done():
+			return api.RespMeta{}, ctx.Err()
+		}
+	}
+	return c.Client.Query(ctx, cfg, resp)
+}
+
+// tlsVersions are the TLS versions accepted in EnvArrayTLSMinVersion. Older versions are never negotiated by
+// the PowerStore clients.
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// lookupTLSMinVersion returns the min TLS version set in EnvArrayTLSMinVersion, or 0 to keep the default of the
+// PowerStore clients
+func lookupTLSMinVersion() (uint16, error) {
+	value, ok := csictx.LookupEnv(context.Background(), identifiers.EnvArrayTLSMinVersion)
+	if !ok || value == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[strings.TrimSpace(value)]
+	if !ok {
+		return 0, fmt.Errorf("invalid value %q of %s, supported values are \"1.2\" and \"1.3\"",
+			value, identifiers.EnvArrayTLSMinVersion)
+	}
+	return version, nil
+}
+
+// loadCertPool returns the system cert pool extended with the PEM encoded CA certificates in certFile.
+func loadCertPool(fs fs.Interface, certFile string) (*x509.CertPool, error) {
+	data, err := fs.ReadFile(filepath.Clean(certFile))
+	if err != nil {
+		return nil, fmt.Errorf("can't read certFile %s: %s", certFile, err.Error())
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		log.Warnf("can't load system cert pool, trusting only certFile %s: %s", certFile, err.Error())
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM encoded certificate found in certFile %s", certFile)
+	}
+	return pool, nil
+}
+
+// throttlingRateLimit returns the rate limit of the client of arr: the rateLimit of the array if set, otherwise the
+// one set in EnvThrottlingRateLimit. It returns false if neither is valid, to keep the default of gopowerstore.
+func throttlingRateLimit(arr *PowerStoreArray) (int, bool) {
+	if arr.RateLimit > 0 {
+		return arr.RateLimit, true
+	}
+	if arr.RateLimit < 0 {
+		log.Errorf("throttling rate limit of array %s is negative, ignoring it", arr.GlobalID)
+	}
+	value, ok := csictx.LookupEnv(context.Background(), identifiers.EnvThrottlingRateLimit)
+	if !ok {
+		return 0, false
+	}
+	rateLimit, err := strconv.Atoi(value)
+	if err != nil {
+		log.Errorf("can't get throttling rate limit, using default")
+		return 0, false
+	}
+	if rateLimit < 0 {
+		log.Errorf("throttling rate limit is negative, using default")
+		return 0, false
+	}
+	return rateLimit, true
+}
+
+// lookupMaxConcurrentRequests returns the positive request limit set in env, or 0 for no limit
+func lookupMaxConcurrentRequests(env string) int {
+	value, ok := csictx.LookupEnv(context.Background(), env)
+	if !ok {
+		return 0
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		log.Errorf("invalid value %q of %s, requests are not limited", value, env)
+		return 0
+	}
+	return limit
+}
+
 // GetPowerStoreArrays parses config.yaml file, initializes gopowerstore Clients and composes map of arrays for ease of access.
 // It will return array that can be used as default as a second return parameter.
 // If config does not have any array as a default then the first will be returned as a default.
@@ -307,6 +679,11 @@ func GetPowerStoreArrays(fs fs.Interface, filePath string) (map[string]*PowerSto
 		return arrayMap, mapper, defaultArray, nil
 	}
 
+	tlsMinVersion, err := lookupTLSMinVersion()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
 	// Safeguard if user doesn't set any array as default, we just use first one
 	defaultArray = cfg.Arrays[0]
 
@@ -319,33 +696,57 @@ func GetPowerStoreArrays(fs fs.Interface, filePath string) (map[string]*PowerSto
 		if array.GlobalID == "" {
 			return nil, nil, nil, errors.New("no GlobalID field found in config.yaml - update config.yaml according to the documentation")
 		}
+		if existing, ok := arrayMap[array.GlobalID]; ok {
+			return nil, nil, nil, fmt.Errorf("GlobalID %s is used by more than one array in config.yaml: %s and %s",
+				array.GlobalID, existing.Endpoint, array.Endpoint)
+		}
+		if array.IsDefault && foundDefault {
+			return nil, nil, nil, fmt.Errorf("more than one array is marked as default in config.yaml: %s and %s",
+				defaultArray.GlobalID, array.GlobalID)
+		}
+		var rootCAs *x509.CertPool
+		if array.CertFile != "" {
+			if array.Insecure {
+				log.Warnf("array %s: skipCertificateValidation is set, certFile %s is ignored", array.GlobalID, array.CertFile)
+			} else if rootCAs, err = loadCertPool(fs, array.CertFile); err != nil {
+				return nil, nil, nil, fmt.Errorf("array %s: %s", array.GlobalID, err.Error())
+			}
+		}
 		clientOptions := gopowerstore.NewClientOptions()
 		log.Debugf("PowerStore REST API timeout set to %s", identifiers.PowerstoreRESTApiTimeout)
 		clientOptions.SetDefaultTimeout(identifiers.PowerstoreRESTApiTimeout)
 		clientOptions.SetInsecure(array.Insecure)
 
-		if throttlingRateLimit, ok := csictx.LookupEnv(context.Background(), identifiers.EnvThrottlingRateLimit); ok {
-			rateLimit, err := strconv.Atoi(throttlingRateLimit)
-			if err != nil {
-				log.Errorf("can't get throttling rate limit, using default")
-			} else if rateLimit < 0 {
-				log.Errorf("throttling rate limit is negative, using default")
-			} else {
-				clientOptions.SetRateLimit(rateLimit)
-			}
+		if rateLimit, ok := throttlingRateLimit(array); ok {
+			clientOptions.SetRateLimit(rateLimit)
 		}
 
-		c, err := gopowerstore.NewClientWithArgs(
-			array.Endpoint, array.Username, array.Password, clientOptions)
+		var c gopowerstore.Client
+		if rootCAs != nil || tlsMinVersion != 0 {
+			// gopowerstore has no option for the TLS config, its requests go through an HTTP client of the driver
+			var client *apiClient
+			client, err = newAPIClient(array.Endpoint, array.Username, array.Password, clientOptions,
+				clientTLSConfig(array.Insecure, rootCAs, tlsMinVersion))
+			c = &gopowerstore.ClientIMPL{API: client}
+		} else {
+			c, err = gopowerstore.NewClientWithArgs(
+				array.Endpoint, array.Username, array.Password, clientOptions)
+		}
 		if err != nil {
 			return nil, nil, nil, status.Errorf(codes.FailedPrecondition,
 				"unable to create PowerStore client: %s", err.Error())
 		}
 		c.SetCustomHTTPHeaders(http.Header{
 			"Application-Type": {fmt.Sprintf("%s/%s", identifiers.VerboseName, core.SemVer)},
+			"User-Agent":       {userAgent()},
 		})
 
 		c.SetLogger(&identifiers.CustomLogger{})
+		maxMetricsRequests := lookupMaxConcurrentRequests(identifiers.EnvMaxConcurrentMetricsRequests)
+		maxControlRequests := lookupMaxConcurrentRequests(identifiers.EnvMaxConcurrentControlRequests)
+		if impl, ok := c.(*gopowerstore.ClientIMPL); ok && (maxMetricsRequests > 0 || maxControlRequests > 0) {
+			impl.API = newLimitedAPIClient(impl.API, maxMetricsRequests, maxControlRequests)
+		}
 		array.Client = c
 
 		if array.BlockProtocol == "" {
@@ -372,7 +773,7 @@ func GetPowerStoreArrays(fs fs.Interface, filePath string) (map[string]*PowerSto
 		log.Infof("%s,%s,%s,%s,%t,%t,%s,%s", array.Endpoint, array.GlobalID, array.Username, array.NasName, array.Insecure, array.IsDefault, array.BlockProtocol, ip)
 		arrayMap[array.GlobalID] = array
 		mapper[ip] = array.GlobalID
-		if array.IsDefault && !foundDefault {
+		if array.IsDefault {
 			defaultArray = array
 			foundDefault = true
 		}
@@ -421,6 +822,9 @@ type VolumeHandle struct {
 	RemoteArrayGlobalID string
 	// One of "scsi" or "nfs"
 	Protocol string
+	// The ID of the NAS server hosting an nfs volume. Optional, only present when encoded
+	// as the fourth segment of an nfs volume handle, e.g. uuid/globalID/nfs/nasServerID.
+	NASServerID string
 }
 
 // ParseVolumeID parses a volume id from the CO (Kubernetes) and tries to extract local and remote PowerStore volume UUID, Global ID, and protocol.
@@ -447,6 +851,16 @@ type VolumeHandle struct {
 //			Protocol: "scsi",
 //		}, nil
 //
+// Example:
+//
+//	ParseVolumeID("1cd254s/PSabcdef0123/nfs/6579d9a4-0d36-4a1c-b3c4-7b1a3c8e2f10") returns
+//		VolumeHandle{
+//			LocalUUID: "1cd254s",
+//			LocalArrayGlobalID: "PSabcdef0123",
+//			Protocol: "nfs",
+//			NASServerID: "6579d9a4-0d36-4a1c-b3c4-7b1a3c8e2f10",
+//		}, nil
+//
 // This function is backwards compatible and will try to understand volume protocol even if there is no such information in volume id.
 // It will do that by querying default powerstore array passed as one of the arguments
 func ParseVolumeID(ctx context.Context, volumeHandleRaw string,
@@ -460,6 +874,106 @@ func ParseVolumeID(ctx context.Context, volumeHandleRaw string,
 			"unable to parse volume handle. volumeHandle is empty")
 	}
 
+	if !IsLegacyVolumeHandle(volumeHandleRaw) {
+		return ParseVolumeHandle(volumeHandleRaw)
+	}
+
+	if isStrictVolumeHandles(ctx) {
+		return volumeHandle, status.Errorf(codes.InvalidArgument,
+			"unable to parse volume handle %s. legacy volume handles are disabled", volumeHandleRaw)
+	}
+
+	// Legacy support where the volume name consists of only the volume ID.
+
+	// We've got a volume from previous version
+	// We assume that we should use default array for that
+	// Try to understand whether it is an nfs or scsi based volume
+
+	volumeHandle.LocalUUID = volumeHandleRaw
+	volumeHandle.LocalArrayGlobalID = defaultArray.GetGlobalID()
+
+	// If we have volume capability in request we can check FsType
+	if vc != nil && vc.GetMount() != nil {
+		if vc.GetMount().GetFsType() == "nfs" {
+			volumeHandle.Protocol = "nfs"
+		} else {
+			volumeHandle.Protocol = "scsi"
+		}
+	} else {
+		// Try to just find out volume type by querying it's id from array
+		probes := []struct {
+			protocol string
+			probe    func() error
+		}{
+			{protocol: "scsi", probe: func() error {
+				_, err := defaultArray.GetClient().GetVolume(ctx, volumeHandle.LocalUUID)
+				return err
+			}},
+			{protocol: "nfs", probe: func() error {
+				_, err := defaultArray.GetClient().GetFS(ctx, volumeHandle.LocalUUID)
+				return err
+			}},
+		}
+		if isFileFirstProbe(ctx) {
+			probes[0], probes[1] = probes[1], probes[0]
+		}
+		for _, p := range probes {
+			if err = p.probe(); err == nil {
+				volumeHandle.Protocol = p.protocol
+				break
+			}
+		}
+		// the error of the last lookup is reported when the volume was found by neither
+		if err != nil {
+			if apiError, ok := err.(gopowerstore.APIError); ok && apiError.NotFound() {
+				return volumeHandle, apiError
+			}
+			return volumeHandle, status.Errorf(codes.Unknown, "failure checking volume status: %s", err.Error())
+		}
+	}
+
+	log.Debugf("ParseVolumeID: legacy volumeID: %s, arrayID: %s, protocol: %s",
+		volumeHandle.LocalUUID, volumeHandle.LocalArrayGlobalID, volumeHandle.Protocol)
+	return volumeHandle, nil
+}
+
+// ParseVolumeIDLegacy works like ParseVolumeID, returning the components of the volume handle as positional values.
+//
+// Deprecated: use ParseVolumeID, whose VolumeHandle names the local and remote components.
+func ParseVolumeIDLegacy(ctx context.Context, volumeHandleRaw string, defaultArray *PowerStoreArray, vc *csi.VolumeCapability,
+) (localVolumeID, arrayID, protocol, remoteVolumeID, remoteArrayID string, err error) {
+	volumeHandle, err := ParseVolumeID(ctx, volumeHandleRaw, defaultArray, vc)
+	return volumeHandle.LocalUUID, volumeHandle.LocalArrayGlobalID, volumeHandle.Protocol,
+		volumeHandle.RemoteUUID, volumeHandle.RemoteArrayGlobalID, err
+}
+
+// IsLegacyVolumeHandle returns true for legacy volume handles consisting of only the volume ID,
+// whose array and protocol can only be determined by querying the default array
+func IsLegacyVolumeHandle(volumeHandleRaw string) bool {
+	return !strings.Contains(strings.Split(volumeHandleRaw, ":")[0], "/")
+}
+
+// NormalizeProtocol returns the volume handle protocol in the lower case form used by the driver, e.g. "scsi" for "SCSI"
+func NormalizeProtocol(protocol string) string {
+	return strings.ToLower(strings.TrimSpace(protocol))
+}
+
+// IsBlockProtocol returns true if protocol, in any case, is the block protocol "scsi" rather than the file protocol "nfs"
+func IsBlockProtocol(protocol string) bool {
+	return NormalizeProtocol(protocol) == "scsi"
+}
+
+// ParseVolumeHandle parses the id/globalID/protocol[/nasServerID] form of a volume handle, followed by
+// ":remoteID/remoteGlobalID" for metro volumes, without querying any array. It is the single place
+// volume handles are interpreted, by ParseVolumeID as well as by the node and controller services.
+// The prefix of host-based NFS volumes, e.g. "nfs-", is kept in LocalUUID.
+// Legacy handles consisting of only the volume ID are rejected, use ParseVolumeID to resolve them.
+func ParseVolumeHandle(volumeHandleRaw string) (volumeHandle VolumeHandle, err error) {
+	if volumeHandleRaw == "" {
+		return volumeHandle, status.Errorf(codes.FailedPrecondition,
+			"unable to parse volume handle. volumeHandle is empty")
+	}
+
 	// metro volume handles will have a colon separating the local
 	// volume handle and remote volume handle
 	// e.g. 9f840c56-96e6-4de9-b5a3-27e7c20eaa77/PSabcdef0123/scsi:9f840c56-96e6-4de9-b5a3-27e7c20eaa77/PS0123abcdef
@@ -467,68 +981,143 @@ func ParseVolumeID(ctx context.Context, volumeHandleRaw string,
 
 	// parse the first (potentially only) volume handle
 	localVolumeHandle := strings.Split(volumeHandles[0], "/")
-	volumeHandle.LocalUUID = localVolumeHandle[0]
-	log.Debugf("ParseVolumeID: local volume handle: %s", localVolumeHandle)
+	log.Debugf("ParseVolumeHandle: local volume handle: %s", localVolumeHandle)
 
 	if len(localVolumeHandle) == 1 {
-		// Legacy support where the volume name consists of only the volume ID.
-
-		// We've got a volume from previous version
-		// We assume that we should use default array for that
-		// Try to understand whether it is an nfs or scsi based volume
-
-		volumeHandle.LocalArrayGlobalID = defaultArray.GetGlobalID()
+		return volumeHandle, status.Errorf(codes.InvalidArgument,
+			"unable to parse volume handle %s. legacy volume handles can only be resolved by querying the array", volumeHandleRaw)
+	}
+	if len(localVolumeHandle) < 3 {
+		return volumeHandle, status.Errorf(codes.InvalidArgument,
+			"unable to parse volume handle %s. expected format is id/globalID/protocol", volumeHandleRaw)
+	}
+	volumeHandle.LocalUUID = localVolumeHandle[0]
 
-		// If we have volume capability in request we can check FsType
-		if vc != nil && vc.GetMount() != nil {
-			if vc.GetMount().GetFsType() == "nfs" {
-				volumeHandle.Protocol = "nfs"
-			} else {
-				volumeHandle.Protocol = "scsi"
-			}
-		} else {
-			// Try to just find out volume type by querying it's id from array
-			_, err := defaultArray.GetClient().GetVolume(ctx, volumeHandle.LocalUUID)
-			if err == nil {
-				volumeHandle.Protocol = "scsi"
-			} else {
-				_, err := defaultArray.GetClient().GetFS(ctx, volumeHandle.LocalUUID)
-				if err == nil {
-					volumeHandle.Protocol = "nfs"
-				} else {
-					if apiError, ok := err.(gopowerstore.APIError); ok && apiError.NotFound() {
-						return volumeHandle, apiError
-					}
-					return volumeHandle, status.Errorf(codes.Unknown, "failure checking volume status: %s", err.Error())
-				}
-			}
+	if ips := identifiers.GetIPListFromString(localVolumeHandle[1]); ips != nil {
+		// Legacy support where IP is used in the volume name in place of a PowerStore Global ID.
+		globalID := arrayByIP(ips[0])
+		if globalID == "" {
+			return volumeHandle, status.Errorf(codes.InvalidArgument,
+				"legacy handle references unknown array IP %s", ips[0])
 		}
+		volumeHandle.LocalArrayGlobalID = globalID
 	} else {
-		if ips := identifiers.GetIPListFromString(localVolumeHandle[1]); ips != nil {
-			// Legacy support where IP is used in the volume name in place of a PowerStore Global ID.
-			volumeHandle.LocalArrayGlobalID = IPToArray[ips[0]]
-		} else {
-			volumeHandle.LocalArrayGlobalID = localVolumeHandle[1]
-		}
-		volumeHandle.Protocol = localVolumeHandle[2]
+		volumeHandle.LocalArrayGlobalID = localVolumeHandle[1]
+	}
+	volumeHandle.Protocol = NormalizeProtocol(localVolumeHandle[2])
+	if volumeHandle.Protocol == "" {
+		// an empty protocol would otherwise be handled as nfs by the callers that only check for scsi
+		return VolumeHandle{}, status.Errorf(codes.InvalidArgument,
+			"unable to parse volume handle %s. protocol is empty", volumeHandleRaw)
+	}
+	if volumeHandle.Protocol == "nfs" && len(localVolumeHandle) > 3 {
+		volumeHandle.NASServerID = localVolumeHandle[3]
 	}
 
 	// Parse the second portion of a metro volume handle
 	if len(volumeHandles) > 1 {
 		remoteVolumeHandle := strings.Split(volumeHandles[1], "/")
-		log.Debugf("ParseVolumeID: remote volume handle: %s", remoteVolumeHandle)
+		log.Debugf("ParseVolumeHandle: remote volume handle: %s", remoteVolumeHandle)
+		if len(remoteVolumeHandle) < 2 {
+			return VolumeHandle{}, status.Errorf(codes.InvalidArgument,
+				"unable to parse volume handle %s. expected format of the remote volume is id/globalID", volumeHandleRaw)
+		}
 
 		volumeHandle.RemoteUUID = remoteVolumeHandle[0]
 		volumeHandle.RemoteArrayGlobalID = remoteVolumeHandle[1]
 	}
 
 	log.Debugf(
-		"ParseVolumeID: volumeID: %s, arrayID: %s, protocol: %s, remoteVolumeID: %s, remoteArrayID: %s",
+		"ParseVolumeHandle: volumeID: %s, arrayID: %s, protocol: %s, remoteVolumeID: %s, remoteArrayID: %s, nasServerID: %s",
 		volumeHandle.LocalUUID, volumeHandle.LocalArrayGlobalID, volumeHandle.Protocol, volumeHandle.RemoteUUID, volumeHandle.RemoteArrayGlobalID,
+		volumeHandle.NASServerID,
 	)
 	return volumeHandle, nil
 }
 
+// VolumeHandleError is returned by ValidateVolumeHandle for volume handles that are malformed or reference an
+// array that isn't configured. It converts to an InvalidArgument gRPC status.
+type VolumeHandleError struct {
+	Handle string
+	Reason string
+}
+
+func (e *VolumeHandleError) Error() string {
+	return fmt.Sprintf("invalid volume handle %q: %s", e.Handle, e.Reason)
+}
+
+// GRPCStatus returns the InvalidArgument status of the error
+func (e *VolumeHandleError) GRPCStatus() *status.Status {
+	return status.New(codes.InvalidArgument, e.Error())
+}
+
+// ValidateVolumeHandle checks that volumeHandleRaw is well-formed and that its local array is configured, without
+// querying any array. Legacy handles consisting of only the volume ID are reported by needsProbing, as their array
+// and protocol can only be determined by ParseVolumeID querying the default array.
+// The remote array of metro volume handles isn't checked, as it may legitimately be unmanaged by this driver.
+func (s *Locker) ValidateVolumeHandle(volumeHandleRaw string) (volumeHandle VolumeHandle, needsProbing bool, err error) {
+	if volumeHandleRaw == "" {
+		return volumeHandle, false, &VolumeHandleError{Handle: volumeHandleRaw, Reason: "volume handle is empty"}
+	}
+	if IsLegacyVolumeHandle(volumeHandleRaw) {
+		return VolumeHandle{LocalUUID: volumeHandleRaw}, true, nil
+	}
+
+	volumeHandle, err = ParseVolumeHandle(volumeHandleRaw)
+	if err != nil {
+		return VolumeHandle{}, false, &VolumeHandleError{Handle: volumeHandleRaw, Reason: status.Convert(err).Message()}
+	}
+	if volumeHandle.LocalUUID == "" {
+		return VolumeHandle{}, false, &VolumeHandleError{Handle: volumeHandleRaw, Reason: "volume ID is empty"}
+	}
+	if volumeHandle.Protocol != "scsi" && volumeHandle.Protocol != "nfs" {
+		return VolumeHandle{}, false, &VolumeHandleError{Handle: volumeHandleRaw,
+			Reason: fmt.Sprintf("unknown protocol %q, expected scsi or nfs", volumeHandle.Protocol)}
+	}
+	if _, ok := s.Arrays()[volumeHandle.LocalArrayGlobalID]; !ok {
+		return VolumeHandle{}, false, &VolumeHandleError{Handle: volumeHandleRaw,
+			Reason: fmt.Sprintf("array %s is not configured", volumeHandle.LocalArrayGlobalID)}
+	}
+	return volumeHandle, false, nil
+}
+
+// IsHostBasedNFS returns true for volumes exported by the host-based NFS server, whose UUID carries the "nfs-" prefix
+func (h VolumeHandle) IsHostBasedNFS() bool {
+	return nfs.IsNFSVolumeID(h.LocalUUID)
+}
+
+// ArrayLocalUUID returns the UUID of the local volume on the array, i.e. without the host-based NFS prefix
+func (h VolumeHandle) ArrayLocalUUID() string {
+	return nfs.ToArrayVolumeID(h.LocalUUID)
+}
+
+// isStrictVolumeHandles returns true when legacy single-segment volume handles must be rejected
+func isStrictVolumeHandles(ctx context.Context) bool {
+	value, ok := csictx.LookupEnv(ctx, identifiers.EnvStrictVolumeHandles)
+	if !ok {
+		return false
+	}
+	strict, _ := strconv.ParseBool(value)
+	return strict
+}
+
+// isFileFirstProbe returns true when legacy volume handles must be looked up as file systems before block volumes
+func isFileFirstProbe(ctx context.Context) bool {
+	value, ok := csictx.LookupEnv(ctx, identifiers.EnvLegacyVolumeProbeOrder)
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case identifiers.LegacyVolumeProbeFileFirst:
+		return true
+	case identifiers.LegacyVolumeProbeBlockFirst, "":
+		return false
+	default:
+		log.Warnf("invalid value %q of %s, looking up block volumes first", value, identifiers.EnvLegacyVolumeProbeOrder)
+		return false
+	}
+}
+
 // GetVolumeUUIDPrefix extracts the prefix, if any exists, from a volume ID with a UUID format.
 // The prefix is assumed to be all characters preceding the volume UUID including separators/delimiters,
 // e.g. '-'. If no prefix is found, or the volume ID is not of the UUID format, the function returns an
@@ -20,14 +20,22 @@ package array_test
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/dell/csi-powerstore/v2/core"
 	"github.com/dell/csi-powerstore/v2/mocks"
 	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
@@ -40,6 +48,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -194,6 +204,29 @@ func TestGetPowerStoreArrays(t *testing.T) {
 		assert.Contains(t, err.Error(), "no GlobalID field found in config.yaml")
 	})
 
+	t.Run("duplicate global ID", func(t *testing.T) {
+		f := &fs.Fs{Util: &gofsutil.FS{}}
+		_, _, _, err := array.GetPowerStoreArrays(f, "./testdata/duplicate-globalID.yaml")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "GlobalID gid1 is used by more than one array in config.yaml: https://127.0.0.1/api/rest and https://127.0.0.2/api/rest")
+	})
+
+	t.Run("duplicate default", func(t *testing.T) {
+		f := &fs.Fs{Util: &gofsutil.FS{}}
+		_, _, _, err := array.GetPowerStoreArrays(f, "./testdata/duplicate-default.yaml")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "more than one array is marked as default in config.yaml: gid1 and gid2")
+	})
+
+	t.Run("default array is not the first", func(t *testing.T) {
+		f := &fs.Fs{Util: &gofsutil.FS{}}
+		got, matcher, defaultArray, err := array.GetPowerStoreArrays(f, "./testdata/three-arr.yaml")
+		assert.NoError(t, err)
+		assert.Len(t, got, 3)
+		assert.Equal(t, map[string]string{"127.0.0.1": "gid1", "127.0.0.2": "gid2", "127.0.0.3": "gid3"}, matcher)
+		assert.Same(t, got["gid2"], defaultArray)
+	})
+
 	t.Run("incorrect throttling limit", func(t *testing.T) {
 		_ = os.Setenv(identifiers.EnvThrottlingRateLimit, "abc")
 		f := &fs.Fs{Util: &gofsutil.FS{}}
@@ -238,6 +271,154 @@ type LegacyParseVolumeTestSuite struct {
 	psArray *array.PowerStoreArray
 }
 
+func TestGetPowerStoreArraysUserAgent(t *testing.T) {
+	defaultUserAgent := fmt.Sprintf("%s/%s", identifiers.VerboseName, core.SemVer)
+	tests := []struct {
+		name      string
+		set       bool
+		userAgent string
+		want      string
+	}{
+		{name: "not set", want: defaultUserAgent},
+		{name: "override", set: true, userAgent: "csi-powerstore-controller/2.15", want: "csi-powerstore-controller/2.15"},
+		{name: "control characters are dropped", set: true, userAgent: " my-agent\r\nX-Injected: true ", want: "my-agentX-Injected: true"},
+		{name: "empty after sanitizing", set: true, userAgent: "\r\n", want: defaultUserAgent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.set {
+				t.Setenv(identifiers.EnvUserAgent, tt.userAgent)
+			} else {
+				os.Unsetenv(identifiers.EnvUserAgent)
+			}
+			arrays, _, _, err := array.GetPowerStoreArrays(&fs.Fs{Util: &gofsutil.FS{}}, "./testdata/one-arr.yaml")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, arrays["gid1"].GetClient().GetCustomHTTPHeaders().Get("User-Agent"))
+		})
+	}
+}
+
+func TestGetPowerStoreArraysRequestConcurrency(t *testing.T) {
+	const maxMetricsRequests, maxControlRequests, requests = 2, 4, 6
+
+	var mu sync.Mutex
+	inFlight := map[string]int{}
+	maxInFlight := map[string]int{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		kind, body := "control", "{}"
+		if strings.HasPrefix(r.URL.Path, "/api/rest/metrics/") {
+			kind, body = "metrics", "[]"
+		}
+		mu.Lock()
+		inFlight[kind]++
+		maxInFlight[kind] = max(maxInFlight[kind], inFlight[kind])
+		mu.Unlock()
+
+		time.Sleep(100 * time.Millisecond)
+
+		mu.Lock()
+		inFlight[kind]--
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(config, []byte(fmt.Sprintf(`arrays:
+  - endpoint: "%s/api/rest"
+    username: "admin"
+    password: "password"
+    globalID: "gid1"
+    skipCertificateValidation: true
+    isDefault: true
+`, server.URL)), 0o600)
+	assert.NoError(t, err)
+	t.Setenv(identifiers.EnvMaxConcurrentMetricsRequests, fmt.Sprint(maxMetricsRequests))
+	t.Setenv(identifiers.EnvMaxConcurrentControlRequests, fmt.Sprint(maxControlRequests))
+
+	arrays, _, _, err := array.GetPowerStoreArrays(&fs.Fs{Util: &gofsutil.FS{}}, config)
+	assert.NoError(t, err)
+	client := arrays["gid1"].GetClient()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < requests; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := client.PerformanceMetricsByVolume(context.Background(), "vol-id", gopowerstore.TwentySec)
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := client.GetVolume(context.Background(), "vol-id")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, maxMetricsRequests, maxInFlight["metrics"])
+	assert.Equal(t, maxControlRequests, maxInFlight["control"])
+}
+
+func TestGetPowerStoreArraysTLSMinVersion(t *testing.T) {
+	// the array only supports TLS 1.2
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(config, []byte(fmt.Sprintf(`arrays:
+  - endpoint: "%s/api/rest"
+    username: "admin"
+    password: "password"
+    globalID: "gid1"
+    skipCertificateValidation: true
+    isDefault: true
+`, server.URL)), 0o600)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		minVersion string
+		wantErr    string
+	}{
+		{name: "default", minVersion: ""},
+		{name: "TLS 1.2", minVersion: "1.2"},
+		{name: "TLS 1.3 not supported by the array", minVersion: "1.3", wantErr: "protocol version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(identifiers.EnvArrayTLSMinVersion, tt.minVersion)
+
+			arrays, _, _, err := array.GetPowerStoreArrays(&fs.Fs{Util: &gofsutil.FS{}}, config)
+			assert.NoError(t, err)
+
+			_, err = arrays["gid1"].GetClient().GetVolume(context.Background(), "vol-id")
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetPowerStoreArraysInvalidTLSMinVersion(t *testing.T) {
+	for _, value := range []string{"1.1", "1.0", "TLS1.2", "abc"} {
+		t.Run(value, func(t *testing.T) {
+			t.Setenv(identifiers.EnvArrayTLSMinVersion, value)
+
+			_, _, _, err := array.GetPowerStoreArrays(&fs.Fs{Util: &gofsutil.FS{}}, "./testdata/one-arr.yaml")
+			assert.ErrorContains(t, err, identifiers.EnvArrayTLSMinVersion)
+		})
+	}
+}
+
 func TestLegacyParseVolumeSuite(t *testing.T) {
 	suite.Run(t, new(LegacyParseVolumeTestSuite))
 }
@@ -396,6 +577,147 @@ func (s *LegacyParseVolumeTestSuite) TestIPAsArrayID() {
 	assert.Equal(s.T(), scsi, id.Protocol)
 }
 
+func (s *LegacyParseVolumeTestSuite) TestUnknownIPAsArrayID() {
+	// When a volume name contains an IP that isn't mapped to any configured array,
+	// ParseVolumeID should fail instead of returning an empty Global ID.
+	array.IPToArray = map[string]string{validPowerStoreIP: validGlobalID}
+
+	unknownIP := "10.10.10.10"
+	volID := buildVolumeName(validBlockVolumeUUID, unknownIP, scsi)
+
+	id, err := array.ParseVolumeID(context.Background(), volID, nil, nil)
+	assert.ErrorContains(s.T(), err, "legacy handle references unknown array IP "+unknownIP)
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+	assert.Empty(s.T(), id.LocalArrayGlobalID)
+}
+
+func (s *LegacyParseVolumeTestSuite) TestStrictModeRejectsSingleSegment() {
+	// When strict volume handles are enabled, a single-segment volume name
+	// should be rejected without querying the array.
+	s.T().Setenv(identifiers.EnvStrictVolumeHandles, "true")
+
+	client := new(gopowerstoremock.Client)
+	psArray := &array.PowerStoreArray{Client: client, GlobalID: validGlobalID}
+
+	_, err := array.ParseVolumeID(context.Background(), validBlockVolumeUUID, psArray, nil)
+	assert.ErrorContains(s.T(), err, "legacy volume handles are disabled")
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+	client.AssertNotCalled(s.T(), "GetVolume", mock.Anything, mock.Anything)
+	client.AssertNotCalled(s.T(), "GetFS", mock.Anything, mock.Anything)
+}
+
+func (s *LegacyParseVolumeTestSuite) TestStrictModeAcceptsFullHandle() {
+	// Strict volume handles only affect legacy volume names.
+	s.T().Setenv(identifiers.EnvStrictVolumeHandles, "true")
+
+	id, err := array.ParseVolumeID(context.Background(), buildVolumeName(validBlockVolumeUUID, validGlobalID, scsi), nil, nil)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), validGlobalID, id.LocalArrayGlobalID)
+	assert.Equal(s.T(), scsi, id.Protocol)
+}
+
+func (s *LegacyParseVolumeTestSuite) TestLenientModeProbesSingleSegment() {
+	// When strict volume handles are disabled, a single-segment volume name
+	// is resolved by querying the array.
+	s.T().Setenv(identifiers.EnvStrictVolumeHandles, "false")
+	s.mockAPI.GetVolume.Return(gopowerstore.Volume{ID: validBlockVolumeUUID}, nil)
+
+	id, err := array.ParseVolumeID(context.Background(), validBlockVolumeUUID, s.psArray, nil)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), scsi, id.Protocol)
+}
+
+func (s *LegacyParseVolumeTestSuite) TestLegacyVolumeProbeOrder() {
+	// The protocol of a legacy volume name is resolved by looking up block volumes or file systems first,
+	// as configured, and the other kind is only looked up when the first lookup fails.
+	tests := []struct {
+		name         string
+		order        string
+		isFS         bool
+		wantProtocol string
+		wantCalls    []string
+	}{
+		{name: "block first by default", isFS: false, wantProtocol: scsi, wantCalls: []string{"GetVolume"}},
+		{name: "block first finds file system", order: identifiers.LegacyVolumeProbeBlockFirst, isFS: true, wantProtocol: nfs, wantCalls: []string{"GetVolume", "GetFS"}},
+		{name: "file first finds file system", order: identifiers.LegacyVolumeProbeFileFirst, isFS: true, wantProtocol: nfs, wantCalls: []string{"GetFS"}},
+		{name: "file first finds block volume", order: "File-First", isFS: false, wantProtocol: scsi, wantCalls: []string{"GetFS", "GetVolume"}},
+		{name: "invalid order falls back to block first", order: "nfs-first", isFS: true, wantProtocol: nfs, wantCalls: []string{"GetVolume", "GetFS"}},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			if tt.order != "" {
+				s.T().Setenv(identifiers.EnvLegacyVolumeProbeOrder, tt.order)
+			} else {
+				os.Unsetenv(identifiers.EnvLegacyVolumeProbeOrder)
+			}
+
+			var calls []string
+			record := func(name string) func(mock.Arguments) {
+				return func(mock.Arguments) { calls = append(calls, name) }
+			}
+			notFound := gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}}
+			client := new(gopowerstoremock.Client)
+			if tt.isFS {
+				client.On("GetVolume", mock.Anything, validBlockVolumeUUID).Run(record("GetVolume")).Return(gopowerstore.Volume{}, notFound)
+				client.On("GetFS", mock.Anything, validBlockVolumeUUID).Run(record("GetFS")).Return(gopowerstore.FileSystem{}, nil)
+			} else {
+				client.On("GetVolume", mock.Anything, validBlockVolumeUUID).Run(record("GetVolume")).Return(gopowerstore.Volume{}, nil)
+				client.On("GetFS", mock.Anything, validBlockVolumeUUID).Run(record("GetFS")).Return(gopowerstore.FileSystem{}, notFound)
+			}
+			psArray := &array.PowerStoreArray{Client: client, GlobalID: validGlobalID}
+
+			id, err := array.ParseVolumeID(context.Background(), validBlockVolumeUUID, psArray, nil)
+			assert.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.wantProtocol, id.Protocol)
+			assert.Equal(s.T(), tt.wantCalls, calls)
+		})
+	}
+}
+
+func (s *LegacyParseVolumeTestSuite) TestParseVolumeIDLegacyTuple() {
+	// The deprecated tuple form returns the same components as the VolumeHandle of ParseVolumeID.
+	remoteVolumeUUID := "bbbbbbbb-0000-cccc-1111-dddddddddddd"
+	s.mockAPI.GetVolume.Return(gopowerstore.Volume{ID: validBlockVolumeUUID}, nil)
+
+	tests := []struct {
+		name         string
+		volumeHandle string
+		want         [5]string
+	}{
+		{
+			name:         "legacy single segment",
+			volumeHandle: validBlockVolumeUUID,
+			want:         [5]string{validBlockVolumeUUID, validGlobalID, scsi, "", ""},
+		},
+		{
+			name:         "three segments",
+			volumeHandle: buildVolumeName(validBlockVolumeUUID, validGlobalID, "nfs"),
+			want:         [5]string{validBlockVolumeUUID, validGlobalID, "nfs", "", ""},
+		},
+		{
+			name:         "metro",
+			volumeHandle: buildVolumeName(validBlockVolumeUUID, validGlobalID, scsi) + ":" + remoteVolumeUUID + "/remote-global-id",
+			want:         [5]string{validBlockVolumeUUID, validGlobalID, scsi, remoteVolumeUUID, "remote-global-id"},
+		},
+	}
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			localID, arrayID, protocol, remoteID, remoteArrayID, err := array.ParseVolumeIDLegacy(
+				context.Background(), tt.volumeHandle, s.psArray, nil)
+			assert.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.want, [5]string{localID, arrayID, protocol, remoteID, remoteArrayID})
+
+			volumeHandle, err := array.ParseVolumeID(context.Background(), tt.volumeHandle, s.psArray, nil)
+			assert.NoError(s.T(), err)
+			assert.Equal(s.T(), tt.want, [5]string{volumeHandle.LocalUUID, volumeHandle.LocalArrayGlobalID,
+				volumeHandle.Protocol, volumeHandle.RemoteUUID, volumeHandle.RemoteArrayGlobalID})
+		})
+	}
+
+	_, _, _, _, _, err := array.ParseVolumeIDLegacy(context.Background(), "", s.psArray, nil)
+	assert.Error(s.T(), err)
+}
+
 func TestParseVolumeID(t *testing.T) {
 	t.Run("parse volume name", func(t *testing.T) {
 		id, err := array.ParseVolumeID(context.Background(), validBlockVolumeNameSCSI, nil, nil)
@@ -452,6 +774,55 @@ func TestParseVolumeID(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "parse nfs volume handle without nas server",
+			args: args{
+				ctx:          context.Background(),
+				volumeHandle: localVolUUID + "/" + powerstoreLocalSystemID + "/nfs",
+			},
+			want: array.VolumeHandle{
+				LocalUUID:          localVolUUID,
+				LocalArrayGlobalID: powerstoreLocalSystemID,
+				Protocol:           "nfs",
+			},
+			wantErr: false,
+		},
+		{
+			name: "parse nfs volume handle with nas server",
+			args: args{
+				ctx:          context.Background(),
+				volumeHandle: localVolUUID + "/" + powerstoreLocalSystemID + "/nfs/" + "nas-server-id",
+			},
+			want: array.VolumeHandle{
+				LocalUUID:          localVolUUID,
+				LocalArrayGlobalID: powerstoreLocalSystemID,
+				Protocol:           "nfs",
+				NASServerID:        "nas-server-id",
+			},
+			wantErr: false,
+		},
+		{
+			name: "empty protocol",
+			args: args{
+				ctx:          context.Background(),
+				volumeHandle: localVolUUID + "/" + powerstoreLocalSystemID + "/",
+			},
+			want:    array.VolumeHandle{},
+			wantErr: true,
+		},
+		{
+			name: "ignore extra segment for scsi volume handle",
+			args: args{
+				ctx:          context.Background(),
+				volumeHandle: localVolUUID + "/" + powerstoreLocalSystemID + "/" + scsi + "/extra",
+			},
+			want: array.VolumeHandle{
+				LocalUUID:          localVolUUID,
+				LocalArrayGlobalID: powerstoreLocalSystemID,
+				Protocol:           scsi,
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -467,6 +838,180 @@ func TestParseVolumeID(t *testing.T) {
 	}
 }
 
+func TestIsBlockProtocol(t *testing.T) {
+	for protocol, want := range map[string]bool{
+		"scsi":   true,
+		"SCSI":   true,
+		" Scsi ": true,
+		"nfs":    false,
+		"NFS":    false,
+		"":       false,
+	} {
+		assert.Equal(t, want, array.IsBlockProtocol(protocol), protocol)
+	}
+}
+
+func TestParseVolumeHandle(t *testing.T) {
+	array.IPToArray = map[string]string{validPowerStoreIP: validGlobalID}
+	localVolUUID := "aaaaaaaa-0000-bbbb-1111-cccccccccccc"
+
+	tests := []struct {
+		name         string
+		volumeHandle string
+		want         array.VolumeHandle
+		wantCode     codes.Code
+	}{
+		{
+			name:         "three segments",
+			volumeHandle: localVolUUID + "/" + validGlobalID + "/" + scsi,
+			want:         array.VolumeHandle{LocalUUID: localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: scsi},
+		},
+		{
+			name:         "array IP in place of the global ID",
+			volumeHandle: localVolUUID + "/" + validPowerStoreIP + "/" + scsi,
+			want:         array.VolumeHandle{LocalUUID: localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: scsi},
+		},
+		{
+			name:         "nfs with nas server",
+			volumeHandle: localVolUUID + "/" + validGlobalID + "/nfs/nas-server-id",
+			want:         array.VolumeHandle{LocalUUID: localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: "nfs", NASServerID: "nas-server-id"},
+		},
+		{
+			name:         "host-based nfs prefix",
+			volumeHandle: sharednfs.CsiNfsPrefixDash + localVolUUID + "/" + validGlobalID + "/" + scsi,
+			want:         array.VolumeHandle{LocalUUID: sharednfs.CsiNfsPrefixDash + localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: scsi},
+		},
+		{
+			name:         "metro",
+			volumeHandle: validMetroBlockVolumeNameSCSI,
+			want: array.VolumeHandle{
+				LocalUUID: validBlockVolumeUUID, LocalArrayGlobalID: validGlobalID, Protocol: scsi,
+				RemoteUUID: validRemoteBlockVolumeUUID, RemoteArrayGlobalID: validRemoteGlobalID,
+			},
+		},
+		{
+			name:         "upper case protocol",
+			volumeHandle: localVolUUID + "/" + validGlobalID + "/NFS/nas-server-id",
+			want:         array.VolumeHandle{LocalUUID: localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: "nfs", NASServerID: "nas-server-id"},
+		},
+		{name: "empty", volumeHandle: "", wantCode: codes.FailedPrecondition},
+		{name: "legacy", volumeHandle: localVolUUID, wantCode: codes.InvalidArgument},
+		{name: "missing protocol", volumeHandle: localVolUUID + "/" + validGlobalID, wantCode: codes.InvalidArgument},
+		{name: "empty protocol", volumeHandle: localVolUUID + "/" + validGlobalID + "/", wantCode: codes.InvalidArgument},
+		{name: "blank protocol with nas server", volumeHandle: localVolUUID + "/" + validGlobalID + "/ /nas-server-id", wantCode: codes.InvalidArgument},
+		{name: "metro without remote global ID", volumeHandle: validBlockVolumeNameSCSI + ":" + validRemoteBlockVolumeUUID, wantCode: codes.InvalidArgument},
+		{name: "unknown array IP", volumeHandle: localVolUUID + "/10.0.0.99/" + scsi, wantCode: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := array.ParseVolumeHandle(tt.volumeHandle)
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+
+			// ParseVolumeID must interpret every non-legacy handle the same way
+			parsed, err := array.ParseVolumeID(context.Background(), tt.volumeHandle, nil, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, got, parsed)
+		})
+	}
+}
+
+func TestValidateVolumeHandle(t *testing.T) {
+	array.IPToArray = map[string]string{validPowerStoreIP: validGlobalID}
+	localVolUUID := "aaaaaaaa-0000-bbbb-1111-cccccccccccc"
+	// no expectations, any query to the array fails the test
+	clientMock := new(gopowerstoremock.Client)
+	locker := &array.Locker{}
+	locker.SetArrays(map[string]*array.PowerStoreArray{
+		validGlobalID: {GlobalID: validGlobalID, IP: validPowerStoreIP, Client: clientMock},
+	})
+
+	tests := []struct {
+		name             string
+		volumeHandle     string
+		want             array.VolumeHandle
+		wantNeedsProbing bool
+		wantErr          bool
+	}{
+		{
+			name:         "block volume",
+			volumeHandle: localVolUUID + "/" + validGlobalID + "/" + scsi,
+			want:         array.VolumeHandle{LocalUUID: localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: scsi},
+		},
+		{
+			name:         "array IP in place of the global ID",
+			volumeHandle: localVolUUID + "/" + validPowerStoreIP + "/" + scsi,
+			want:         array.VolumeHandle{LocalUUID: localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: scsi},
+		},
+		{
+			name:         "nfs volume with nas server",
+			volumeHandle: localVolUUID + "/" + validGlobalID + "/nfs/nas-server-id",
+			want:         array.VolumeHandle{LocalUUID: localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: "nfs", NASServerID: "nas-server-id"},
+		},
+		{
+			name:         "metro volume with an unmanaged remote array",
+			volumeHandle: localVolUUID + "/" + validGlobalID + "/" + scsi + ":" + localVolUUID + "/remote-global-id",
+			want: array.VolumeHandle{
+				LocalUUID: localVolUUID, LocalArrayGlobalID: validGlobalID, Protocol: scsi,
+				RemoteUUID: localVolUUID, RemoteArrayGlobalID: "remote-global-id",
+			},
+		},
+		{
+			name:             "legacy volume ID",
+			volumeHandle:     localVolUUID,
+			want:             array.VolumeHandle{LocalUUID: localVolUUID},
+			wantNeedsProbing: true,
+		},
+		{name: "empty", volumeHandle: "", wantErr: true},
+		{name: "two segments", volumeHandle: localVolUUID + "/" + validGlobalID, wantErr: true},
+		{name: "empty volume ID", volumeHandle: "/" + validGlobalID + "/" + scsi, wantErr: true},
+		{name: "unknown protocol", volumeHandle: localVolUUID + "/" + validGlobalID + "/iscsi", wantErr: true},
+		{name: "unknown array", volumeHandle: localVolUUID + "/unknown-global-id/" + scsi, wantErr: true},
+		{name: "unknown array IP", volumeHandle: localVolUUID + "/10.0.0.99/" + scsi, wantErr: true},
+		{name: "malformed metro volume", volumeHandle: localVolUUID + "/" + validGlobalID + "/" + scsi + ":" + localVolUUID, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, needsProbing, err := locker.ValidateVolumeHandle(tt.volumeHandle)
+			if tt.wantErr {
+				var handleErr *array.VolumeHandleError
+				assert.ErrorAs(t, err, &handleErr)
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantNeedsProbing, needsProbing)
+		})
+	}
+	clientMock.AssertNotCalled(t, "GetVolume", mock.Anything, mock.Anything)
+	clientMock.AssertNotCalled(t, "GetFS", mock.Anything, mock.Anything)
+}
+
+func TestVolumeHandleHostBasedNFS(t *testing.T) {
+	localVolUUID := "aaaaaaaa-0000-bbbb-1111-cccccccccccc"
+
+	handle, err := array.ParseVolumeHandle(sharednfs.CsiNfsPrefixDash + localVolUUID + "/" + validGlobalID + "/" + scsi)
+	assert.NoError(t, err)
+	assert.True(t, handle.IsHostBasedNFS())
+	assert.Equal(t, localVolUUID, handle.ArrayLocalUUID())
+
+	handle, err = array.ParseVolumeHandle(localVolUUID + "/" + validGlobalID + "/" + scsi)
+	assert.NoError(t, err)
+	assert.False(t, handle.IsHostBasedNFS())
+	assert.Equal(t, localVolUUID, handle.ArrayLocalUUID())
+}
+
+func TestIsLegacyVolumeHandle(t *testing.T) {
+	assert.True(t, array.IsLegacyVolumeHandle(validBlockVolumeUUID))
+	assert.False(t, array.IsLegacyVolumeHandle(validBlockVolumeNameSCSI))
+	assert.False(t, array.IsLegacyVolumeHandle(validMetroBlockVolumeNameSCSI))
+}
+
 func TestLocker_UpdateArrays(t *testing.T) {
 	lck := array.Locker{}
 	err := lck.UpdateArrays("./testdata/one-arr.yaml", &fs.Fs{Util: &gofsutil.FS{}})
@@ -474,6 +1019,169 @@ func TestLocker_UpdateArrays(t *testing.T) {
 	assert.Equal(t, lck.DefaultArray().Endpoint, "https://127.0.0.1/api/rest")
 }
 
+func TestLocker_UpdateArraysConcurrentReaders(t *testing.T) {
+	lck := array.Locker{}
+	fsys := &fs.Fs{Util: &gofsutil.FS{}}
+	assert.NoError(t, lck.UpdateArrays("./testdata/one-arr.yaml", fsys))
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				arrays, defaultArray := lck.ArraysWithDefault()
+				if assert.NotNil(t, defaultArray) {
+					// the default array must always belong to the same config load as the arrays
+					assert.Same(t, arrays[defaultArray.GlobalID], defaultArray)
+				}
+			}
+		}()
+	}
+
+	configs := []string{"./testdata/two-arr.yaml", "./testdata/one-arr.yaml"}
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, lck.UpdateArrays(configs[i%len(configs)], fsys))
+	}
+	close(done)
+	wg.Wait()
+
+	arrays, defaultArray := lck.ArraysWithDefault()
+	assert.Equal(t, arrays, lck.Arrays())
+	assert.Equal(t, defaultArray, lck.DefaultArray())
+}
+
+func TestLocker_ResolveEndpoints(t *testing.T) {
+	fqdnArray := &array.PowerStoreArray{
+		Endpoint: "https://powerstore.example.com:8443/api/rest",
+		GlobalID: "PS000000000001",
+		IP:       "powerstore.example.com",
+	}
+	ipArray := &array.PowerStoreArray{
+		Endpoint: "https://10.0.0.5/api/rest",
+		GlobalID: "PS000000000002",
+		IP:       "10.0.0.5",
+	}
+	lck := array.Locker{}
+	lck.SetArrays(map[string]*array.PowerStoreArray{fqdnArray.GlobalID: fqdnArray, ipArray.GlobalID: ipArray})
+	lck.SetDefaultArray(fqdnArray)
+	array.IPToArray = map[string]string{fqdnArray.IP: fqdnArray.GlobalID, ipArray.IP: ipArray.GlobalID}
+
+	answers := []string{"10.1.1.1", "10.1.1.1", "10.1.1.2"}
+	var lookups []string
+	resolve := func(_ context.Context, host string) ([]string, error) {
+		lookups = append(lookups, host)
+		ip := answers[0]
+		answers = answers[1:]
+		return []string{ip}, nil
+	}
+
+	lck.ResolveEndpoints(context.Background(), resolve)
+	first, _ := lck.GetOneArray(fqdnArray.GlobalID)
+	assert.Equal(t, "10.1.1.1", first.GetResolvedIP())
+	// the IP is the topology key of the array and must not change
+	assert.Equal(t, "powerstore.example.com", first.GetIP())
+	assert.Same(t, first, lck.DefaultArray())
+	assert.Equal(t, map[string]string{
+		"powerstore.example.com": fqdnArray.GlobalID,
+		"10.1.1.1":               fqdnArray.GlobalID,
+		"10.0.0.5":               ipArray.GlobalID,
+	}, array.IPToArray)
+
+	// unchanged address leaves the arrays untouched
+	lck.ResolveEndpoints(context.Background(), resolve)
+	unchanged, _ := lck.GetOneArray(fqdnArray.GlobalID)
+	assert.Same(t, first, unchanged)
+
+	lck.ResolveEndpoints(context.Background(), resolve)
+	second, _ := lck.GetOneArray(fqdnArray.GlobalID)
+	assert.Equal(t, "10.1.1.2", second.GetResolvedIP())
+	assert.Equal(t, "powerstore.example.com", second.GetIP())
+	assert.Equal(t, map[string]string{
+		"powerstore.example.com": fqdnArray.GlobalID,
+		"10.1.1.2":               fqdnArray.GlobalID,
+		"10.0.0.5":               ipArray.GlobalID,
+	}, array.IPToArray)
+
+	// literal IP endpoints are never resolved
+	assert.Equal(t, []string{"powerstore.example.com", "powerstore.example.com", "powerstore.example.com"}, lookups)
+	unresolved, _ := lck.GetOneArray(ipArray.GlobalID)
+	assert.Same(t, ipArray, unresolved)
+	assert.Equal(t, "10.0.0.5", unresolved.GetResolvedIP())
+}
+
+func TestLocker_ResolveEndpointsFailure(t *testing.T) {
+	fqdnArray := &array.PowerStoreArray{
+		Endpoint: "https://powerstore.example.com/api/rest",
+		GlobalID: "PS000000000001",
+		IP:       "powerstore.example.com",
+	}
+	lck := array.Locker{}
+	lck.SetArrays(map[string]*array.PowerStoreArray{fqdnArray.GlobalID: fqdnArray})
+	array.IPToArray = map[string]string{fqdnArray.IP: fqdnArray.GlobalID}
+
+	lck.ResolveEndpoints(context.Background(), func(_ context.Context, _ string) ([]string, error) {
+		return nil, errors.New("no such host")
+	})
+
+	got, _ := lck.GetOneArray(fqdnArray.GlobalID)
+	assert.Same(t, fqdnArray, got)
+	assert.Equal(t, map[string]string{fqdnArray.IP: fqdnArray.GlobalID}, array.IPToArray)
+}
+
+func TestParseVolumeHandleDuringEndpointResolution(t *testing.T) {
+	fqdnArray := &array.PowerStoreArray{
+		Endpoint: "https://powerstore.example.com/api/rest",
+		GlobalID: "PS000000000001",
+		IP:       "powerstore.example.com",
+	}
+	lck := array.Locker{}
+	lck.SetArrays(map[string]*array.PowerStoreArray{fqdnArray.GlobalID: fqdnArray})
+	array.IPToArray = map[string]string{"10.0.0.5": fqdnArray.GlobalID}
+
+	// the matcher is swapped while legacy handles are parsed, run with -race to detect unsynchronized access
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			ip := fmt.Sprintf("10.1.1.%d", i)
+			lck.ResolveEndpoints(context.Background(), func(_ context.Context, _ string) ([]string, error) {
+				return []string{ip}, nil
+			})
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		handle, err := array.ParseVolumeHandle("39bb1b5f-5624-490d-9ece-18f7b28a904e/10.0.0.5/scsi")
+		assert.NoError(t, err)
+		assert.Equal(t, fqdnArray.GlobalID, handle.LocalArrayGlobalID)
+	}
+	<-done
+}
+
+func TestEndpointResolveInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "5m", want: 5 * time.Minute},
+		{value: "invalid", want: 0},
+		{value: "-1m", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			t.Setenv(identifiers.EnvEndpointResolveInterval, tt.value)
+			assert.Equal(t, tt.want, array.EndpointResolveInterval())
+		})
+	}
+}
+
 func TestLocker_GetOneArray(t *testing.T) {
 	lck := array.Locker{}
 	arrayMap := make(map[string]*array.PowerStoreArray)
@@ -816,3 +1524,228 @@ func Test_getVolumeIDPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestLocker_WatchConfig(t *testing.T) {
+	oneArr, err := os.ReadFile("./testdata/one-arr.yaml")
+	assert.NoError(t, err)
+	twoArr, err := os.ReadFile("./testdata/two-arr.yaml")
+	assert.NoError(t, err)
+
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(config, oneArr, 0o600))
+	fsys := &fs.Fs{Util: &gofsutil.FS{}}
+	lck := array.Locker{}
+	assert.NoError(t, lck.UpdateArrays(config, fsys))
+
+	stop, err := lck.WatchConfig(config, fsys)
+	assert.NoError(t, err)
+	defer stop()
+
+	assert.NoError(t, os.WriteFile(config, twoArr, 0o600))
+	assert.Eventually(t, func() bool { return len(lck.Arrays()) == 2 }, 5*time.Second, 50*time.Millisecond)
+	assert.Contains(t, lck.Arrays(), "gid2")
+
+	// a broken config keeps the previous arrays
+	assert.NoError(t, os.WriteFile(config, []byte("arrays: ["), 0o600))
+	time.Sleep(time.Second)
+	assert.Len(t, lck.Arrays(), 2)
+}
+
+func TestGetPowerStoreArraysCertFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	notPEM := filepath.Join(dir, "not-a-cert.pem")
+	assert.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	tests := []struct {
+		name       string
+		insecure   bool
+		certFile   string
+		wantErr    string
+		wantReqErr string
+	}{
+		{name: "trusted CA", certFile: certFile},
+		{name: "no CA", wantReqErr: "certificate"},
+		{name: "insecure wins over certFile", insecure: true, certFile: notPEM},
+		{name: "missing certFile", certFile: filepath.Join(dir, "missing.pem"), wantErr: "can't read certFile"},
+		{name: "invalid certFile", certFile: notPEM, wantErr: "no PEM encoded certificate found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := filepath.Join(t.TempDir(), "config.yaml")
+			err := os.WriteFile(config, []byte(fmt.Sprintf(`arrays:
+  - endpoint: "%s/api/rest"
+    username: "admin"
+    password: "password"
+    globalID: "gid1"
+    skipCertificateValidation: %t
+    certFile: "%s"
+    isDefault: true
+`, server.URL, tt.insecure, tt.certFile)), 0o600)
+			assert.NoError(t, err)
+
+			arrays, _, _, err := array.GetPowerStoreArrays(&fs.Fs{Util: &gofsutil.FS{}}, config)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.certFile, arrays["gid1"].CertFile)
+
+			_, err = arrays["gid1"].GetClient().GetVolume(context.Background(), "vol-id")
+			if tt.wantReqErr != "" {
+				assert.ErrorContains(t, err, tt.wantReqErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetPowerStoreArraysCertFileClient(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/login_session"):
+			logins++
+			w.Header().Set("DELL-EMC-TOKEN", "token")
+			_, _ = w.Write([]byte(`[{"id":"session"}]`))
+		case r.Header.Get("DELL-EMC-TOKEN") != "token":
+			// the session expired
+			w.WriteHeader(http.StatusForbidden)
+		case strings.HasSuffix(r.URL.Path, "/volume/vol-id"):
+			assert.Equal(t, "csi-powerstore-test", r.Header.Get("User-Agent"))
+			_, _ = w.Write([]byte(`{"id":"vol-id","name":"vol"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"messages":[{"code":"0xE04040010005","severity":"Error","message_l10n":"not found"}]}`))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	config := filepath.Join(dir, "config.yaml")
+	assert.NoError(t, os.WriteFile(config, []byte(fmt.Sprintf(`arrays:
+  - endpoint: "%s/api/rest"
+    username: "admin"
+    password: "password"
+    globalID: "gid1"
+    certFile: "%s"
+    isDefault: true
+`, server.URL, certFile)), 0o600))
+	t.Setenv(identifiers.EnvUserAgent, "csi-powerstore-test")
+
+	arrays, _, _, err := array.GetPowerStoreArrays(&fs.Fs{Util: &gofsutil.FS{}}, config)
+	assert.NoError(t, err)
+	client := arrays["gid1"].GetClient()
+	assert.Equal(t, 0, logins, "the client must not log in when created")
+
+	volume, err := client.GetVolume(context.Background(), "vol-id")
+	assert.NoError(t, err)
+	assert.Equal(t, "vol", volume.Name)
+	assert.Equal(t, 1, logins)
+
+	_, err = client.GetVolume(context.Background(), "vol-id")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, logins, "the session token must be reused")
+
+	_, err = client.GetVolume(context.Background(), "missing")
+	var apiError gopowerstore.APIError
+	assert.ErrorAs(t, err, &apiError)
+	assert.True(t, apiError.NotFound())
+	assert.Equal(t, "not found", apiError.Message)
+}
+
+func TestGetPowerStoreArraysRateLimit(t *testing.T) {
+	const requests = 6
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+
+		time.Sleep(100 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		globalLimit string
+		arrayLimit  int
+		want        int
+	}{
+		{name: "global only", globalLimit: "2", want: 2},
+		{name: "array overrides global", globalLimit: "2", arrayLimit: 3, want: 3},
+		{name: "array without global", arrayLimit: 3, want: 3},
+		{name: "negative array limit falls back to global", globalLimit: "2", arrayLimit: -1, want: 2},
+		{name: "invalid global limit uses default", globalLimit: "abc", want: requests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(identifiers.EnvThrottlingRateLimit, tt.globalLimit)
+			if tt.globalLimit == "" {
+				_ = os.Unsetenv(identifiers.EnvThrottlingRateLimit)
+			}
+			config := filepath.Join(t.TempDir(), "config.yaml")
+			err := os.WriteFile(config, []byte(fmt.Sprintf(`arrays:
+  - endpoint: "%s/api/rest"
+    username: "admin"
+    password: "password"
+    globalID: "gid1"
+    skipCertificateValidation: true
+    rateLimit: %d
+    isDefault: true
+`, server.URL, tt.arrayLimit)), 0o600)
+			assert.NoError(t, err)
+
+			arrays, _, _, err := array.GetPowerStoreArrays(&fs.Fs{Util: &gofsutil.FS{}}, config)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.arrayLimit, arrays["gid1"].RateLimit)
+			client := arrays["gid1"].GetClient()
+
+			mu.Lock()
+			maxInFlight = 0
+			mu.Unlock()
+			wg := sync.WaitGroup{}
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := client.GetVolume(context.Background(), "vol-id")
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tt.want, maxInFlight)
+		})
+	}
+}
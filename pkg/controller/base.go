@@ -20,6 +20,7 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"unicode/utf8"
 
@@ -44,6 +45,10 @@ const (
 	MaxVolumeNameLength = 128
 	// ReplicationPrefix represents replication prefix
 	ReplicationPrefix = "replication.storage.dell.com"
+	// RemoteSizeCheckWarn logs a warning when remote and source volume sizes differ
+	RemoteSizeCheckWarn = "warn"
+	// RemoteSizeCheckError fails the request when remote and source volume sizes differ
+	RemoteSizeCheckError = "error"
 	// ErrUnknownAccessType represents error message for unknown access type
 	ErrUnknownAccessType = "unknown access type is not Block or Mount"
 	// ErrUnknownAccessMode represents error message for unknown access mode
@@ -60,20 +65,67 @@ const (
 	KeyReplicationMode = "mode"
 	// KeyReplicationRPO represents key for replication RPO
 	KeyReplicationRPO = "rpo"
+	// KeyReplicationAlertThreshold represents key for the minutes the RPO of a replication rule can be exceeded before alerting
+	KeyReplicationAlertThreshold = "alertThreshold"
 	// KeyReplicationRemoteSystem represents key for replication remote system
 	KeyReplicationRemoteSystem = "remoteSystem"
 	// KeyReplicationIgnoreNamespaces represents key for replication ignore namespaces
 	KeyReplicationIgnoreNamespaces = "ignoreNamespaces"
 	// KeyReplicationVGPrefix represents key for replication vg prefix
 	KeyReplicationVGPrefix = "volumeGroupPrefix"
+	// KeyReplicationRemoteSizeCheck represents key for checking remote volume size against the source volume size
+	KeyReplicationRemoteSizeCheck = "remoteVolumeSizeCheck"
+	// KeyReplicationForceDelete represents key for removing a local volume from its volume groups and protection policy before deleting it
+	KeyReplicationForceDelete = "forceDelete"
+	// KeyReplicationDryRun represents key for reporting the steps of deleting a local volume without executing them
+	KeyReplicationDryRun = "dryRun"
+	// DryRunStepsTrailer is the gRPC trailer of a DeleteLocalVolume dry run holding the steps of the deletion, in order
+	DryRunStepsTrailer = "powerstore-dry-run-steps"
 	// KeyNasName represents key for nas name
 	KeyNasName = "nasName"
 	// KeyCSIPVCNamespace represents key for csi pvc namespace
 	KeyCSIPVCNamespace = "csi.storage.k8s.io/pvc/namespace"
 	// KeyCSIPVCName represents key for csi pvc name
 	KeyCSIPVCName = "csi.storage.k8s.io/pvc/name"
+	// KeySnapshotNameTemplate represents key for volume group snapshot member name template
+	KeySnapshotNameTemplate = "snapshotNameTemplate"
 )
 
+const (
+	// driverVolumeGroupTag prefixes the description of every volume group created by the driver
+	driverVolumeGroupTag = "csi-powerstore:managed"
+	// maxVolumeGroupDescriptionLength is the longest description PowerStore accepts for a volume group
+	maxVolumeGroupDescriptionLength = 256
+)
+
+// apiErrorDetails formats err for wrapping into a returned error. For a gopowerstore.APIError it includes
+// the HTTP status code, the array-side message and its arguments, which err.Error() alone would drop.
+func apiErrorDetails(err error) string {
+	var apiErr gopowerstore.APIError
+	switch e := err.(type) {
+	case gopowerstore.APIError:
+		apiErr = e
+	case *gopowerstore.APIError:
+		if e != nil {
+			apiErr = *e
+		}
+	default:
+		return err.Error()
+	}
+	if apiErr.ErrorMsg == nil {
+		return "array returned an unknown error"
+	}
+
+	details := fmt.Sprintf("array returned HTTP %d", apiErr.StatusCode)
+	if apiErr.Message != "" {
+		details += ": " + apiErr.Message
+	}
+	if len(apiErr.Arguments) != 0 {
+		details += " (arguments: " + strings.Join(apiErr.Arguments, ", ") + ")"
+	}
+	return details
+}
+
 func volumeNameValidation(volumeName string) error {
 	if volumeName == "" {
 		return status.Errorf(codes.InvalidArgument, "name cannot be empty")
@@ -184,3 +236,45 @@ func getDescription(params map[string]string) string {
 	}
 	return params[KeyCSIPVCName] + "-" + params[KeyCSIPVCNamespace]
 }
+
+// driverVolumeGroupDescription tags description so the volume group can later be recognized as driver-created
+func driverVolumeGroupDescription(description string) string {
+	tagged := driverVolumeGroupTag
+	if description != "" {
+		tagged += " " + description
+	}
+	if len(tagged) > maxVolumeGroupDescriptionLength {
+		tagged = tagged[:maxVolumeGroupDescriptionLength]
+	}
+	return tagged
+}
+
+// isDriverCreatedVolumeGroup reports whether vg carries the driver tag in its description
+func isDriverCreatedVolumeGroup(vg gopowerstore.VolumeGroup) bool {
+	return strings.HasPrefix(vg.Description, driverVolumeGroupTag)
+}
+
+// isVolumeGroupDeletable reports whether the driver may delete vg, replicated to remoteSystemName.
+// Groups created before tagging was introduced have no description and are named after the replication
+// settings, any other untagged group is considered to be user-owned.
+func isVolumeGroupDeletable(vg gopowerstore.VolumeGroup, remoteSystemName string) bool {
+	if isDriverCreatedVolumeGroup(vg) {
+		return true
+	}
+	return vg.Description == "" && isReplicationVolumeGroupName(vg.Name, remoteSystemName)
+}
+
+// isReplicationVolumeGroupName reports whether name follows the <prefix>-[<namespace>-]<remote system>-<rpo>
+// naming scheme of the volume groups created by the driver for replication
+func isReplicationVolumeGroupName(name, remoteSystemName string) bool {
+	if remoteSystemName == "" {
+		return false
+	}
+	for _, rpo := range knownRPOs {
+		suffix := "-" + remoteSystemName + "-" + string(rpo)
+		if len(name) > len(suffix) && strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -158,3 +158,75 @@ func TestDetachVolumeFromHost(t *testing.T) {
 		assert.Contains(t, err.Error(), "unexpected api error when detaching volume from host")
 	})
 }
+
+func TestAPIErrorDetails(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "plain error",
+			err:  errors.New("connection refused"),
+			want: "connection refused",
+		},
+		{
+			name: "api error with message and arguments",
+			err: gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{
+				StatusCode: http.StatusUnprocessableEntity,
+				Message:    "The volume group is in use.",
+				Arguments:  []string{"vg-1"},
+			}},
+			want: "array returned HTTP 422: The volume group is in use. (arguments: vg-1)",
+		},
+		{
+			name: "api error pointer without message",
+			err:  &gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusBadRequest}},
+			want: "array returned HTTP 400",
+		},
+		{
+			name: "api error without body",
+			err:  gopowerstore.APIError{},
+			want: "array returned an unknown error",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, apiErrorDetails(test.err))
+		})
+	}
+}
+
+func TestDriverVolumeGroupDescription(t *testing.T) {
+	assert.Equal(t, driverVolumeGroupTag, driverVolumeGroupDescription(""))
+	assert.Equal(t, driverVolumeGroupTag+" snap of app", driverVolumeGroupDescription("snap of app"))
+
+	long := driverVolumeGroupDescription(strings.Repeat("x", maxVolumeGroupDescriptionLength))
+	assert.Len(t, long, maxVolumeGroupDescriptionLength)
+	assert.True(t, strings.HasPrefix(long, driverVolumeGroupTag))
+}
+
+func TestIsVolumeGroupDeletable(t *testing.T) {
+	tests := []struct {
+		name   string
+		vg     gopowerstore.VolumeGroup
+		remote string
+		want   bool
+	}{
+		{name: "tagged", vg: gopowerstore.VolumeGroup{Name: "vgs", Description: driverVolumeGroupDescription("vgs")}, want: true},
+		{name: "legacy replication group", vg: gopowerstore.VolumeGroup{Name: "csi-default-pstore2-Five_Minutes"}, remote: "pstore2", want: true},
+		{name: "legacy synchronous group", vg: gopowerstore.VolumeGroup{Name: "csi-pstore2-Zero"}, remote: "pstore2", want: true},
+		{name: "untagged without description", vg: gopowerstore.VolumeGroup{Name: "database"}, remote: "pstore2", want: false},
+		{name: "replicated to another system", vg: gopowerstore.VolumeGroup{Name: "csi-pstore3-Five_Minutes"}, remote: "pstore2", want: false},
+		{name: "unknown remote system", vg: gopowerstore.VolumeGroup{Name: "csi-pstore2-Five_Minutes"}, want: false},
+		{name: "suffix only", vg: gopowerstore.VolumeGroup{Name: "-pstore2-Five_Minutes"}, remote: "pstore2", want: false},
+		{name: "user-owned", vg: gopowerstore.VolumeGroup{Name: "csi-pstore2-Five_Minutes", Description: "database volumes"}, remote: "pstore2", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, isVolumeGroupDeletable(test.vg, test.remote))
+		})
+	}
+}
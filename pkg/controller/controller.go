@@ -23,16 +23,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"github.com/dell/csi-powerstore/v2/core"
 	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers/fs"
+	"github.com/dell/csi-powerstore/v2/pkg/identifiers/k8sutils"
+	"github.com/dell/csm-sharednfs/nfs"
 	commonext "github.com/dell/dell-csi-extensions/common"
 	podmon "github.com/dell/dell-csi-extensions/podmon"
 	csiext "github.com/dell/dell-csi-extensions/replication"
@@ -45,6 +49,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+	corev1 "k8s.io/api/core/v1"
 )
 
 // Interface provides most important controller methods.
@@ -69,6 +74,59 @@ type Service struct {
 	replicationPrefix           string
 	isHealthMonitorEnabled      bool
 	isAutoRoundOffFsSizeEnabled bool
+	isPodmonEnabled             bool
+	isHostBasedNFSEnabled       bool
+	maxConcurrentIOChecks       int
+
+	maxConcurrentConnectivityChecks int
+	maxConnectivityMessages         int
+	arrayStatusPathPrefix           string
+	arrayStatusRetryBackoff         time.Duration
+	arrayStatusScheme               string
+	arrayStatusTokenFile            string
+	// transport of the node status queries, http.DefaultTransport when nil
+	arrayStatusTransport            http.RoundTripper
+	vgsMemberBatchSize              int
+	blockMetricsMaxAge              time.Duration
+	nfsMetricsMaxAge                time.Duration
+	metricsInterval                 gopowerstore.MetricsIntervalEnum
+	metricsSampleCount              int
+	vgsSnapshotReadyTimeout         time.Duration
+	vgsSnapshotPollInterval         time.Duration
+	replicationActionTimeout        time.Duration
+	replicationActionPollInterval   time.Duration
+	verifyReprotect                 bool
+	maxConcurrentLocalVolumeDeletes int
+	reportIOOnCheckTimeout          bool
+	probeArrayConnectivity          bool
+	// overall time a ValidateVolumeHostConnectivity call may take, unbounded when not positive
+	validationBudget time.Duration
+
+	// last known node to array connectivity status, keyed by node ID and array globalID
+	connectivityCache sync.Map
+	// names of the PowerStore clusters, keyed by array globalID
+	clusterNames sync.Map
+	// source of the IPs the node status endpoints are served on, the node ID itself when nil
+	nodeIPs nodeIPSource
+	// last summary of the arrays reachable from the controller, guarded by reachabilityMu
+	reachabilityMu sync.Mutex
+	reachability   *ArrayReachabilitySummary
+
+	// pool bounding the IO checks of all ValidateVolumeHostConnectivity calls, created on first use
+	ioCheckPoolOnce sync.Once
+	ioCheckPool     *ioCheckPool
+}
+
+// DriverCapabilities reports which driver extensions are enabled in the current deployment
+type DriverCapabilities struct {
+	// Replication is enabled when the replication sidecar prefix is configured
+	Replication bool
+	// VolumeGroupSnapshot is always served by the controller
+	VolumeGroupSnapshot bool
+	// ConnectivityChecks is enabled when podmon is enabled
+	ConnectivityChecks bool
+	// HostBasedNFS is enabled when the shared NFS server port is configured
+	HostBasedNFS bool
 }
 
 // maxVolumesSizeForArray -  store the maxVolumesSizeForArray
@@ -84,7 +142,7 @@ func (s *Service) Init() error {
 	}
 
 	if replicationContextPrefix, ok := csictx.LookupEnv(ctx, identifiers.EnvReplicationContextPrefix); ok {
-		s.replicationContextPrefix = replicationContextPrefix + "/"
+		s.replicationContextPrefix = normalizeContextPrefix(replicationContextPrefix)
 	}
 
 	if replicationPrefix, ok := csictx.LookupEnv(ctx, identifiers.EnvReplicationPrefix); ok {
@@ -107,9 +165,108 @@ func (s *Service) Init() error {
 		s.isAutoRoundOffFsSizeEnabled, _ = strconv.ParseBool(isAutoRoundOffFsSizeEnabled)
 	}
 
+	if isPodmonEnabled, ok := csictx.LookupEnv(ctx, identifiers.EnvPodmonEnabled); ok {
+		s.isPodmonEnabled, _ = strconv.ParseBool(isPodmonEnabled)
+	}
+
+	if reportIOOnCheckTimeout, ok := csictx.LookupEnv(ctx, identifiers.EnvPodmonReportIOOnCheckTimeout); ok {
+		s.reportIOOnCheckTimeout, _ = strconv.ParseBool(reportIOOnCheckTimeout)
+	}
+
+	if probeArrayConnectivity, ok := csictx.LookupEnv(ctx, identifiers.EnvProbeArrayConnectivity); ok {
+		s.probeArrayConnectivity, _ = strconv.ParseBool(probeArrayConnectivity)
+	}
+
+	if verifyReprotect, ok := csictx.LookupEnv(ctx, identifiers.EnvReplicationVerifyReprotect); ok {
+		s.verifyReprotect, _ = strconv.ParseBool(verifyReprotect)
+	}
+
+	if nfsServerPort, ok := csictx.LookupEnv(ctx, nfs.EnvNFSServerPort); ok {
+		s.isHostBasedNFSEnabled = nfsServerPort != ""
+	}
+
+	s.maxConcurrentIOChecks = lookupPositiveInt(ctx, identifiers.EnvPodmonMaxConcurrentIOChecks,
+		identifiers.DefaultPodmonMaxConcurrentIOChecks)
+	s.maxConcurrentConnectivityChecks = lookupPositiveInt(ctx, identifiers.EnvPodmonMaxConcurrentConnectivityChecks,
+		identifiers.DefaultPodmonMaxConcurrentConnectivityChecks)
+	s.maxConnectivityMessages = lookupPositiveInt(ctx, identifiers.EnvPodmonMaxConnectivityMessages,
+		identifiers.DefaultPodmonMaxConnectivityMessages)
+	s.vgsMemberBatchSize = lookupPositiveInt(ctx, identifiers.EnvVGSMemberBatchSize,
+		identifiers.DefaultVGSMemberBatchSize)
+	s.blockMetricsMaxAge = lookupPositiveDuration(ctx, identifiers.EnvPodmonBlockMetricsMaxAge,
+		identifiers.DefaultPodmonMetricsMaxAge)
+	s.nfsMetricsMaxAge = lookupPositiveDuration(ctx, identifiers.EnvPodmonNfsMetricsMaxAge,
+		identifiers.DefaultPodmonMetricsMaxAge)
+	s.metricsSampleCount = lookupPositiveInt(ctx, identifiers.EnvPodmonMetricsSampleCount,
+		identifiers.DefaultPodmonMetricsSampleCount)
+	s.metricsInterval = identifiers.DefaultPodmonMetricsInterval
+	if value, ok := csictx.LookupEnv(ctx, identifiers.EnvPodmonMetricsInterval); ok {
+		interval, err := parseMetricsInterval(value)
+		if err != nil {
+			log.Warnf("%s, using default %s", err.Error(), identifiers.DefaultPodmonMetricsInterval)
+		} else {
+			s.metricsInterval = interval
+		}
+	}
+	if value, ok := csictx.LookupEnv(ctx, identifiers.EnvPodmonArrayStatusPathPrefix); ok {
+		prefix, err := parseArrayStatusPathPrefix(value)
+		if err != nil {
+			log.Warnf("%s, using no prefix", err.Error())
+		} else {
+			s.arrayStatusPathPrefix = prefix
+		}
+	}
+	s.initArrayStatusClient(ctx)
+	if value, ok := csictx.LookupEnv(ctx, identifiers.EnvPodmonNodeIPSource); ok &&
+		strings.EqualFold(strings.TrimSpace(value), identifiers.NodeIPSourceKubernetes) {
+		kubeConfigPath, _ := csictx.LookupEnv(ctx, identifiers.EnvKubeConfigPath)
+		s.nodeIPs = newK8sNodeIPSource(func(ctx context.Context) ([]corev1.Node, error) {
+			return k8sutils.ListNodes(ctx, kubeConfigPath)
+		})
+	}
+	s.validationBudget = lookupPositiveDuration(ctx, identifiers.EnvPodmonValidationBudget, 0)
+	s.vgsSnapshotReadyTimeout = lookupPositiveDuration(ctx, identifiers.EnvVGSSnapshotReadyTimeout,
+		identifiers.DefaultVGSSnapshotReadyTimeout)
+	s.replicationActionTimeout = lookupPositiveDuration(ctx, identifiers.EnvReplicationActionTimeout,
+		identifiers.DefaultReplicationActionTimeout)
+	s.replicationActionPollInterval = lookupPositiveDuration(ctx, identifiers.EnvReplicationActionPollInterval,
+		identifiers.DefaultReplicationActionPollInterval)
+	s.maxConcurrentLocalVolumeDeletes = lookupPositiveInt(ctx, identifiers.EnvMaxConcurrentLocalVolumeDeletes,
+		identifiers.DefaultMaxConcurrentLocalVolumeDeletes)
+
+	registerMetrics()
+
 	return nil
 }
 
+// lookupPositiveInt reads a positive integer from the env variable name, falling back to def
+func lookupPositiveInt(ctx context.Context, name string, def int) int {
+	value, ok := csictx.LookupEnv(ctx, name)
+	if !ok {
+		return def
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		log.Warnf("invalid value %s for %s, using default %d", value, name, def)
+		return def
+	}
+	return limit
+}
+
+// lookupPositiveDuration reads a positive duration from the env variable name, falling back to def
+func lookupPositiveDuration(ctx context.Context, name string, def time.Duration) time.Duration {
+	value, ok := csictx.LookupEnv(ctx, name)
+	if !ok {
+		return def
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Warnf("invalid value %s for %s, using default %v", value, name, def)
+		return def
+	}
+	return duration
+}
+
 // CreateVolume creates either FileSystem or Volume on storage array.
 func (s *Service) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
 	params := req.GetParameters()
@@ -355,6 +512,16 @@ func (s *Service) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest
 			if repMode == identifiers.SyncMode && rpo != identifiers.Zero {
 				return nil, status.Error(codes.InvalidArgument, "replication mode SYNC requires RPO value to be Zero")
 			}
+
+			// the alert threshold is optional, the array applies its default when it isn't set
+			alertThreshold := 0
+			if value, ok := params[s.WithRP(KeyReplicationAlertThreshold)]; ok {
+				threshold, err := parseReplicationAlertThreshold(value, rpoEnum)
+				if err != nil {
+					return nil, err
+				}
+				alertThreshold = threshold
+			}
 			namespace := ""
 			if ignoreNS, ok := params[s.WithRP(KeyReplicationIgnoreNamespaces)]; ok && ignoreNS == "false" {
 				pvcNS, ok := params[KeyCSIPVCNamespace]
@@ -374,16 +541,21 @@ func (s *Service) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest
 					log.Infof("Volume group with name %s not found, creating it", vgName)
 
 					// ensure protection policy exists
-					pp, err := EnsureProtectionPolicyExists(ctx, arr, vgName, remoteSystemName, rpoEnum)
+					pp, rollback, err := ensureProtectionPolicy(ctx, arr, vgName, remoteSystemName, rpoEnum, alertThreshold)
 					if err != nil {
+						if status.Code(err) == codes.InvalidArgument {
+							return nil, err
+						}
 						return nil, status.Errorf(codes.Internal, "can't ensure protection policy exists %s", err.Error())
 					}
 
 					group, err := arr.Client.CreateVolumeGroup(ctx, &gopowerstore.VolumeGroupCreate{
 						Name:               vgName,
+						Description:        driverVolumeGroupDescription(""),
 						ProtectionPolicyID: pp,
 					})
 					if err != nil {
+						rollback(ctx)
 						return nil, status.Errorf(codes.Internal, "can't create volume group: %s", err.Error())
 					}
 
@@ -404,13 +576,17 @@ func (s *Service) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest
 				}
 				// group exists, check that protection policy applied
 				if vg.ProtectionPolicyID == "" {
-					pp, err := EnsureProtectionPolicyExists(ctx, arr, vgName, remoteSystemName, rpoEnum)
+					pp, rollback, err := ensureProtectionPolicy(ctx, arr, vgName, remoteSystemName, rpoEnum, alertThreshold)
 					if err != nil {
+						if status.Code(err) == codes.InvalidArgument {
+							return nil, err
+						}
 						return nil, status.Errorf(codes.Internal, "can't ensure protection policy exists %s", err.Error())
 					}
 					policyUpdate := gopowerstore.VolumeGroupChangePolicy{ProtectionPolicyID: pp}
 					_, err = arr.Client.UpdateVolumeGroupProtectionPolicy(ctx, vg.ID, &policyUpdate)
 					if err != nil {
+						rollback(ctx)
 						return nil, status.Errorf(codes.Internal, "can't update volume group policy %s", err.Error())
 					}
 				}
@@ -426,8 +602,11 @@ func (s *Service) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest
 			log.Info("Metro replication mode requested")
 
 			// Get specified remote system object for its ID
-			remoteSystem, err = arr.Client.GetRemoteSystemByName(ctx, remoteSystemName)
+			remoteSystem, err = getRemoteSystem(ctx, arr, remoteSystemName)
 			if err != nil {
+				if _, ok := status.FromError(err); ok {
+					return nil, err
+				}
 				return nil, status.Errorf(codes.Internal, "can't query remote system by name: %s", err.Error())
 			}
 
@@ -1594,6 +1773,16 @@ func (s *Service) ControllerGetVolume(ctx context.Context, req *csi.ControllerGe
 	return resp, nil
 }
 
+// GetDriverCapabilities returns the set of driver extensions enabled by the current configuration
+func (s *Service) GetDriverCapabilities() DriverCapabilities {
+	return DriverCapabilities{
+		Replication:         s.replicationPrefix != "",
+		VolumeGroupSnapshot: true,
+		ConnectivityChecks:  s.isPodmonEnabled,
+		HostBasedNFS:        s.isHostBasedNFSEnabled,
+	}
+}
+
 // RegisterAdditionalServers registers replication extension
 func (s *Service) RegisterAdditionalServers(server *grpc.Server) {
 	csiext.RegisterReplicationServer(server, s)
@@ -1602,9 +1791,12 @@ func (s *Service) RegisterAdditionalServers(server *grpc.Server) {
 }
 
 // ProbeController probes the controller service
-func (s *Service) ProbeController(_ context.Context, _ *commonext.ProbeControllerRequest) (*commonext.ProbeControllerResponse, error) {
+func (s *Service) ProbeController(ctx context.Context, _ *commonext.ProbeControllerRequest) (*commonext.ProbeControllerResponse, error) {
 	ready := new(wrapperspb.BoolValue)
 	ready.Value = true
+	if s.probeArrayConnectivity {
+		ready.Value = s.ArrayReachability(ctx).Ready()
+	}
 	rep := new(commonext.ProbeControllerResponse)
 	rep.Ready = ready
 	rep.Name = identifiers.Name
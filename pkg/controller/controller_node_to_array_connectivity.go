@@ -21,50 +21,273 @@ package controller
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	csictx "github.com/dell/gocsi/context"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 
+	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 )
 
+// PingArrayResult is the result of an on-demand connectivity check from the controller to an array
+type PingArrayResult struct {
+	GlobalID  string
+	Reachable bool
+	Latency   time.Duration
+	Error     string
+}
+
+// PingArray checks if the controller can reach the array with the given globalID right now
+// by performing a timed GetCluster call, independent of any volume.
+func (s *Service) PingArray(ctx context.Context, globalID string) (*PingArrayResult, error) {
+	arr, err := s.GetOneArray(globalID)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "array %s not found", globalID)
+	}
+
+	latency, err := pingArray(ctx, globalID, arr)
+	result := &PingArrayResult{
+		GlobalID: globalID,
+		Latency:  latency,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Reachable = true
+	return result, nil
+}
+
+// pingArray performs a GetCluster call to the array, bounded by PodmonArrayConnectivityTimeout,
+// and returns how long it took along with its error
+func pingArray(ctx context.Context, globalID string, arr *array.PowerStoreArray) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, identifiers.PodmonArrayConnectivityTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := arr.GetClient().GetCluster(ctx)
+	latency := time.Since(start)
+	if err != nil {
+		log.Warnf("array %s is not reachable from the controller after %s: %s", globalID, latency, err.Error())
+		return latency, err
+	}
+	log.Infof("array %s is reachable from the controller, latency %s", globalID, latency)
+	return latency, nil
+}
+
+// CheckArrayConnectivity checks whether the client of every configured array can reach its array, in parallel
+// bounded by the max number of concurrent connectivity checks. It returns the error of each array by globalID,
+// nil for reachable arrays. Unlike ArrayReachability, the arrays are queried on every call.
+func (s *Service) CheckArrayConnectivity(ctx context.Context) map[string]error {
+	arrays := s.Arrays()
+	type result struct {
+		globalID string
+		err      error
+	}
+	results := make(chan result, len(arrays))
+	sem := make(chan struct{}, s.getMaxConcurrentConnectivityChecks())
+	var wg sync.WaitGroup
+	for globalID, arr := range arrays {
+		wg.Add(1)
+		go func(globalID string, arr *array.PowerStoreArray) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			_, err := pingArray(ctx, globalID, arr)
+			results <- result{globalID: globalID, err: err}
+		}(globalID, arr)
+	}
+	wg.Wait()
+	close(results)
+
+	errs := make(map[string]error, len(arrays))
+	for r := range results {
+		errs[r.globalID] = r.err
+	}
+	return errs
+}
+
+// ArrayReachabilitySummary summarizes which of the configured arrays the controller itself can reach
+type ArrayReachabilitySummary struct {
+	Total          int
+	Reachable      int
+	UnreachableIDs []string
+	CheckedAt      time.Time
+}
+
+// Ready returns true when the controller can reach every configured array
+func (r ArrayReachabilitySummary) Ready() bool {
+	return r.Reachable == r.Total
+}
+
+// ArrayReachability checks the connectivity of all configured arrays with CheckArrayConnectivity and summarizes
+// which ones the controller can reach. It backs the controller readiness probe: the summary is reused for
+// ArrayReachabilityCacheTTL, and concurrent callers wait for a single round of pings.
+func (s *Service) ArrayReachability(ctx context.Context) ArrayReachabilitySummary {
+	s.reachabilityMu.Lock()
+	defer s.reachabilityMu.Unlock()
+	if s.reachability != nil && time.Since(s.reachability.CheckedAt) < identifiers.ArrayReachabilityCacheTTL {
+		observeCacheLookup(cacheArrayReachability, true)
+		return *s.reachability
+	}
+	observeCacheLookup(cacheArrayReachability, false)
+
+	errs := s.CheckArrayConnectivity(ctx)
+	summary := ArrayReachabilitySummary{Total: len(errs), UnreachableIDs: make([]string, 0), CheckedAt: time.Now()}
+	for globalID, err := range errs {
+		if err == nil {
+			summary.Reachable++
+		} else {
+			summary.UnreachableIDs = append(summary.UnreachableIDs, globalID)
+		}
+	}
+	sort.Strings(summary.UnreachableIDs)
+	log.Infof("%d of %d arrays are reachable from the controller, unreachable arrays: %v",
+		summary.Reachable, summary.Total, summary.UnreachableIDs)
+
+	s.reachability = &summary
+	cacheEntries.WithLabelValues(cacheArrayReachability).Set(1)
+	return summary
+}
+
+// getArrayClusterName returns the name of the PowerStore cluster with the given globalID as shown in the
+// PowerStore Manager UI, which ties the globalID to a physical array. The cluster reported by GetCluster
+// has no serial number, so its name is used instead. Names are cached once found; an empty string is
+// returned when the array is unknown or the lookup fails, and the lookup is retried on the next call.
+func (s *Service) getArrayClusterName(ctx context.Context, globalID string) string {
+	if name, ok := s.clusterNames.Load(globalID); ok {
+		observeCacheLookup(cacheClusterName, true)
+		return name.(string)
+	}
+	observeCacheLookup(cacheClusterName, false)
+	arr, ok := s.Arrays()[globalID]
+	if !ok {
+		return ""
+	}
+	cluster, err := arr.GetClient().GetCluster(ctx)
+	if err != nil || cluster.Name == "" {
+		log.Debugf("unable to get cluster name of array %s: %v", globalID, err)
+		return ""
+	}
+	s.clusterNames.Store(globalID, cluster.Name)
+	setCacheEntries(cacheClusterName, &s.clusterNames)
+	return cluster.Name
+}
+
+// ConnectivityStatus is the last known connectivity status of a node to an array
+type ConnectivityStatus struct {
+	Connected bool
+	CheckedAt time.Time
+	// Age is the time elapsed since the status was checked
+	Age   time.Duration
+	Error string
+}
+
+func connectivityCacheKey(nodeID, globalID string) string {
+	return nodeID + "/" + globalID
+}
+
+// storeConnectivity records the result of a node to array connectivity check
+func (s *Service) storeConnectivity(nodeID, globalID string, connected bool, err error) {
+	result := ConnectivityStatus{
+		Connected: connected,
+		CheckedAt: time.Now(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	s.connectivityCache.Store(connectivityCacheKey(nodeID, globalID), result)
+	setCacheEntries(cacheConnectivity, &s.connectivityCache)
+}
+
+// GetCachedConnectivity returns the last known connectivity status of the node to the array along with its age,
+// without querying the node. The returned bool is false when no status has been recorded yet.
+func (s *Service) GetCachedConnectivity(nodeID, globalID string) (ConnectivityStatus, bool) {
+	value, ok := s.connectivityCache.Load(connectivityCacheKey(nodeID, globalID))
+	observeCacheLookup(cacheConnectivity, ok)
+	if !ok {
+		return ConnectivityStatus{}, false
+	}
+	result := value.(ConnectivityStatus)
+	result.Age = time.Since(result.CheckedAt)
+	return result, true
+}
+
 // QueryArrayStatus make API call to the specified url to retrieve connection status
 func (s *Service) QueryArrayStatus(ctx context.Context, url string) (bool, error) {
+	connected, _, err := s.queryArrayStatusWithReason(ctx, url)
+	return connected, err
+}
+
+// queryArrayStatusWithReason works like QueryArrayStatus, but when the status endpoint reports
+// the array as not connected it also returns the reason, e.g. how long ago the last success was.
+// When the node reports its active data paths, a connected array is described by the path count
+// and an array without any active data path is reported as not connected.
+func (s *Service) queryArrayStatusWithReason(ctx context.Context, url string) (bool, string, error) {
 	defer func() {
 		if err := recover(); err != nil {
 			log.Println("panic occurred in queryStatus:", err)
 		}
 	}()
 	client := http.Client{
-		Timeout: identifiers.PodmonArrayConnectivityTimeout,
-	}
-
-	resp, err := client.Get(url)
-
-	log.Debugf("Received response %+v for url %s", resp, url)
-	if err != nil {
-		log.Errorf("failed to call API %s due to %s ", url, err.Error())
-		return false, err
+		Timeout:   identifiers.PodmonArrayConnectivityTimeout,
+		Transport: s.arrayStatusTransport,
 	}
-	defer resp.Body.Close() // #nosec G307
-	bodyBytes, err := io.ReadAll(resp.Body)
+	token, err := s.arrayStatusToken()
 	if err != nil {
-		log.Errorf("failed to read API response due to %s ", err.Error())
-		return false, err
+		log.Errorf("failed to query %s: %s", url, err.Error())
+		return false, "", err
 	}
-	if resp.StatusCode != 200 {
-		log.Errorf("Found unexpected response from the server while fetching array status %d ", resp.StatusCode)
-		return false, fmt.Errorf("unexpected response from the server")
+
+	// the endpoint is unavailable for a moment while the node pod restarts, so transport failures and
+	// server errors are retried; any other response is definitive
+	backoff := s.getArrayStatusRetryBackoff()
+	var bodyBytes []byte
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		bodyBytes, retry, err = getArrayStatus(ctx, &client, url, token)
+		if err == nil {
+			break
+		}
+		if !retry || attempt == identifiers.PodmonArrayStatusAttempts {
+			return false, "", err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+			return false, "", err
+		}
+		log.Warnf("attempt %d of %d to query %s failed, retrying in %s", attempt, identifiers.PodmonArrayStatusAttempts, url, backoff)
+		select {
+		case <-ctx.Done():
+			return false, "", err
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, identifiers.PodmonArrayStatusMaxRetryBackoff)
 	}
+
 	var statusResponse identifiers.ArrayConnectivityStatus
 	err = json.Unmarshal(bodyBytes, &statusResponse)
 	if err != nil {
 		log.Errorf("unable to unmarshal and determine connectivity due to %s ", err)
-		return false, err
+		return false, "", err
 	}
 	log.Infof("API Response received is %+v\n", statusResponse)
 	// responseObject has last success and last attempt timestamp in Unix format
@@ -76,12 +299,214 @@ func (s *Service) QueryArrayStatus(ctx context.Context, url string) (bool, error
 	if (currTime - statusResponse.LastAttempt) > tolerance*2 {
 		log.Errorf("seems like connectivity test is not being run, current time is %d and last run was at %d", currTime, statusResponse.LastAttempt)
 		// considering connectivity is broken
-		return false, nil
+		return false, fmt.Sprintf("connectivity test last ran %d seconds ago", currTime-statusResponse.LastAttempt), nil
 	}
 	log.Debugf("last connectivity was  %d sec back, tolerance is %d sec", timeDiff, tolerance)
 	// give 2s leeway for tolerance check
 	if timeDiff <= tolerance+2 {
-		return true, nil
+		if statusResponse.ActiveDataPaths == nil {
+			return true, "", nil
+		}
+		if *statusResponse.ActiveDataPaths == 0 {
+			return false, "no active data paths to the array", nil
+		}
+		return true, fmt.Sprintf("%d active data paths", *statusResponse.ActiveDataPaths), nil
+	}
+	return false, fmt.Sprintf("last successful connectivity test was %d seconds ago, tolerance is %d seconds",
+		currTime-statusResponse.LastSuccess, tolerance), nil
+}
+
+// getArrayStatus gets the body of the response of a node status endpoint, authenticating with token when it
+// isn't empty. It returns true along with the error when the request may succeed if retried.
+func getArrayStatus(ctx context.Context, client *http.Client, url string, token string) ([]byte, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	log.Debugf("Received response %+v for url %s", resp, url)
+	if err != nil {
+		log.Errorf("failed to call API %s due to %s ", url, err.Error())
+		// a node that doesn't answer in time isn't restarting, waiting for it again would only delay the check
+		var netErr net.Error
+		timedOut := errors.As(err, &netErr) && netErr.Timeout()
+		return nil, ctx.Err() == nil && !timedOut, err
+	}
+	defer resp.Body.Close() // #nosec G307
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("failed to read API response due to %s ", err.Error())
+		return nil, ctx.Err() == nil, err
+	}
+	if resp.StatusCode != 200 {
+		log.Errorf("Found unexpected response from the server while fetching array status %d ", resp.StatusCode)
+		return nil, resp.StatusCode >= 500, fmt.Errorf("unexpected response from the server")
+	}
+	return bodyBytes, false, nil
+}
+
+// initArrayStatusClient configures the scheme, the CAs and the token of the node status queries from the env.
+// Invalid settings are ignored, falling back to plain HTTP and the system CAs.
+func (s *Service) initArrayStatusClient(ctx context.Context) {
+	s.arrayStatusScheme = "http"
+	if value, ok := csictx.LookupEnv(ctx, identifiers.EnvPodmonArrayStatusScheme); ok {
+		switch scheme := strings.ToLower(strings.TrimSpace(value)); scheme {
+		case "http", "https":
+			s.arrayStatusScheme = scheme
+		default:
+			log.Warnf("invalid value %s for %s, using http", value, identifiers.EnvPodmonArrayStatusScheme)
+		}
+	}
+	if caFile, ok := csictx.LookupEnv(ctx, identifiers.EnvPodmonArrayStatusCAFile); ok && caFile != "" {
+		transport, err := newArrayStatusTransport(caFile)
+		if err != nil {
+			log.Warnf("%s, verifying node status endpoints against the system CAs", err.Error())
+		} else {
+			s.arrayStatusTransport = transport
+		}
+	}
+	if tokenFile, ok := csictx.LookupEnv(ctx, identifiers.EnvPodmonArrayStatusTokenFile); ok {
+		s.arrayStatusTokenFile = strings.TrimSpace(tokenFile)
+	}
+}
+
+// newArrayStatusTransport returns a transport verifying the certificates of the node status endpoints against
+// the CAs in the PEM bundle caFile
+func newArrayStatusTransport(caFile string) (*http.Transport, error) {
+	pem, err := os.ReadFile(filepath.Clean(caFile))
+	if err != nil {
+		return nil, fmt.Errorf("can't read CA bundle %s: %w", caFile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in CA bundle %s", caFile)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	return transport, nil
+}
+
+// arrayStatusToken returns the bearer token sent to the node status endpoints, or "" when none is configured
+func (s *Service) arrayStatusToken() (string, error) {
+	if s.arrayStatusTokenFile == "" {
+		return "", nil
+	}
+	token, err := os.ReadFile(filepath.Clean(s.arrayStatusTokenFile))
+	if err != nil {
+		return "", fmt.Errorf("can't read node status token: %w", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// getArrayStatusRetryBackoff returns the time to wait before the first retry of a node status query
+func (s *Service) getArrayStatusRetryBackoff() time.Duration {
+	if s.arrayStatusRetryBackoff > 0 {
+		return s.arrayStatusRetryBackoff
+	}
+	return identifiers.DefaultPodmonArrayStatusRetryBackoff
+}
+
+// parseArrayStatusPathPrefix validates a path prefix of the array status path, which must be a clean
+// absolute URL path without a trailing slash, e.g. "/node-status". An empty prefix is valid.
+func parseArrayStatusPathPrefix(value string) (string, error) {
+	prefix := strings.TrimSpace(value)
+	if prefix == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(prefix, "/") || path.Clean(prefix) != prefix || prefix == "/" ||
+		strings.ContainsAny(prefix, "?#%") {
+		return "", fmt.Errorf("invalid array status path prefix %q, must be a clean absolute path such as /node-status", value)
+	}
+	return prefix, nil
+}
+
+// arrayStatusURL returns the URL of the status of the array with the given arrayID on the node with the given host
+func (s *Service) arrayStatusURL(host string, arrayID string) string {
+	if strings.Contains(host, ":") {
+		// IPv6 addresses must be bracketed in URLs
+		host = "[" + host + "]"
+	}
+	scheme := s.arrayStatusScheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	return scheme + "://" + host + identifiers.APIPort + s.arrayStatusPathPrefix + identifiers.ArrayStatusEndpoint(arrayID)
+}
+
+// nodeIPSource resolves the IP the array status endpoint of a node is served on
+type nodeIPSource interface {
+	nodeIP(ctx context.Context, nodeID string) (string, error)
+}
+
+// nodeIDIPSource takes the IP from the node ID, formatted as <prefix>-<uuid>-<ip>
+type nodeIDIPSource struct{}
+
+func (nodeIDIPSource) nodeIP(_ context.Context, nodeID string) (string, error) {
+	node, err := identifiers.ParseNodeID(nodeID)
+	if err != nil {
+		return "", err
+	}
+	return node.IP, nil
+}
+
+// k8sNodeIPSource looks up the addresses of the Kubernetes node whose machine ID is the host ID in the node ID,
+// preferring its InternalIP over its Hostname. It falls back to the IP in the node ID when the Kubernetes API
+// isn't available or doesn't know the node.
+type k8sNodeIPSource struct {
+	listNodes func(ctx context.Context) ([]corev1.Node, error)
+	fallback  nodeIPSource
+}
+
+func newK8sNodeIPSource(listNodes func(ctx context.Context) ([]corev1.Node, error)) *k8sNodeIPSource {
+	return &k8sNodeIPSource{listNodes: listNodes, fallback: nodeIDIPSource{}}
+}
+
+func (k *k8sNodeIPSource) nodeIP(ctx context.Context, nodeID string) (string, error) {
+	nodes, err := k.listNodes(ctx)
+	if err != nil {
+		log.Warnf("unable to list kubernetes nodes, using the IP of node ID %s: %s", nodeID, err.Error())
+		return k.fallback.nodeIP(ctx, nodeID)
+	}
+	// machine IDs are plain hex strings, while host IDs in node IDs may be dashed UUIDs
+	normalizedID := strings.ToLower(strings.ReplaceAll(nodeID, "-", ""))
+	for _, node := range nodes {
+		machineID := strings.ToLower(strings.ReplaceAll(node.Status.NodeInfo.MachineID, "-", ""))
+		if machineID == "" || !strings.Contains(normalizedID, machineID) {
+			continue
+		}
+		if ip := nodeAddress(node, corev1.NodeInternalIP); ip != "" {
+			return ip, nil
+		}
+		if hostname := nodeAddress(node, corev1.NodeHostName); hostname != "" {
+			return hostname, nil
+		}
+		log.Warnf("kubernetes node %s of node ID %s has no address, using the IP of the node ID", node.Name, nodeID)
+		break
+	}
+	log.Debugf("no kubernetes node found for node ID %s, using the IP of the node ID", nodeID)
+	return k.fallback.nodeIP(ctx, nodeID)
+}
+
+// nodeAddress returns the first address of the given type of the node, or "" when it has none
+func nodeAddress(node corev1.Node, addressType corev1.NodeAddressType) string {
+	for _, address := range node.Status.Addresses {
+		if address.Type == addressType && address.Address != "" {
+			return address.Address
+		}
+	}
+	return ""
+}
+
+// getNodeIPSource returns the source of node IPs, taking them from the node ID unless configured otherwise
+func (s *Service) getNodeIPSource() nodeIPSource {
+	if s.nodeIPs == nil {
+		return nodeIDIPSource{}
 	}
-	return false, nil
+	return s.nodeIPs
 }
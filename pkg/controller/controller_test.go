@@ -44,6 +44,8 @@ import (
 	"github.com/onsi/ginkgo/reporters"
 	gomega "github.com/onsi/gomega"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -102,8 +104,11 @@ var (
 	// should expect not to find this global ID in the list of arrays
 	invalidBlockVolumeID = filepath.Join(validBaseVolID, "globalvolid3", "scsi")
 
+	// IP the node status endpoint of validNodeID is served on
+	validNodeIP = "127.0.0.1"
+
 	// format: csi-node-<uuid>-127.0.0.1
-	validNodeID = strings.Join([]string{validHostName, "127.0.0.1"}, "-")
+	validNodeID = strings.Join([]string{validHostName, validNodeIP}, "-")
 
 	// format: csi-<powerstore-name>-<rpo>
 	validGroupName = strings.Join([]string{"csi", validRemoteSystemName, validRPO}, "-")
@@ -300,7 +305,7 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 
 			EnsureProtectionPolicyExistsMock()
 
-			createGroupRequest := &gopowerstore.VolumeGroupCreate{Name: validGroupName, ProtectionPolicyID: validPolicyID}
+			createGroupRequest := &gopowerstore.VolumeGroupCreate{Name: validGroupName, Description: driverVolumeGroupDescription(""), ProtectionPolicyID: validPolicyID}
 			clientMock.On("CreateVolumeGroup", mock.Anything, createGroupRequest).Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
 			clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.VolumeGroup{ID: validGroupID, ProtectionPolicyID: validPolicyID}, nil)
 
@@ -332,6 +337,33 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 			}))
 		})
 
+		ginkgo.It("should roll back created policy and rule if volume group creation fails", func() {
+			clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+				Return(gopowerstore.VolumeGroup{}, gopowerstore.NewNotFoundError())
+			clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
+				Return(gopowerstore.RemoteSystem{ID: validRemoteSystemID, Name: validRemoteSystemName}, nil)
+			clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyName).
+				Return(gopowerstore.ProtectionPolicy{}, gopowerstore.NewNotFoundError())
+			clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
+				Return(gopowerstore.ReplicationRule{}, gopowerstore.NewNotFoundError())
+			clientMock.On("CreateReplicationRule", mock.Anything, mock.Anything).
+				Return(gopowerstore.CreateResponse{ID: validRuleID}, nil)
+			clientMock.On("CreateProtectionPolicy", mock.Anything, mock.Anything).
+				Return(gopowerstore.CreateResponse{ID: validPolicyID}, nil)
+			clientMock.On("CreateVolumeGroup", mock.Anything, mock.Anything).
+				Return(gopowerstore.CreateResponse{}, errors.New("injected error"))
+			clientMock.On("DeleteProtectionPolicy", mock.Anything, validPolicyID).
+				Return(gopowerstore.EmptyResponse(""), nil)
+			clientMock.On("DeleteReplicationRule", mock.Anything, validRuleID).
+				Return(gopowerstore.EmptyResponse(""), nil)
+
+			res, err := ctrlSvc.CreateVolume(context.Background(), req)
+			gomega.Expect(res).To(gomega.BeNil())
+			gomega.Expect(err.Error()).To(gomega.ContainSubstring("can't create volume group"))
+			clientMock.AssertCalled(ginkgo.GinkgoT(), "DeleteProtectionPolicy", mock.Anything, validPolicyID)
+			clientMock.AssertCalled(ginkgo.GinkgoT(), "DeleteReplicationRule", mock.Anything, validRuleID)
+		})
+
 		ginkgo.It("should create volume and volumeGroup if policy exists - SYNC", func() {
 			clientMock.On("GetCustomHTTPHeaders").Return(api.NewSafeHeader().GetHeader())
 			clientMock.On("GetSoftwareMajorMinorVersion", context.Background()).Return(float32(3.0), nil)
@@ -346,7 +378,7 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 
 			EnsureProtectionPolicyExistsMockSync()
 
-			createGroupRequest := &gopowerstore.VolumeGroupCreate{Name: validGroupNameSync, ProtectionPolicyID: validPolicyID}
+			createGroupRequest := &gopowerstore.VolumeGroupCreate{Name: validGroupNameSync, Description: driverVolumeGroupDescription(""), ProtectionPolicyID: validPolicyID}
 			clientMock.On("CreateVolumeGroup", mock.Anything, createGroupRequest).Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
 			clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.VolumeGroup{ID: validGroupID, ProtectionPolicyID: validPolicyID}, nil)
 
@@ -398,9 +430,11 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 			}, nil)
 
 			clientMock.On("GetProtectionPolicyByName", mock.Anything, "pp-"+validNamespacedGroupName).
-				Return(gopowerstore.ProtectionPolicy{ID: validPolicyID}, nil)
+				Return(gopowerstore.ProtectionPolicy{ID: validPolicyID, ReplicationRules: []gopowerstore.ReplicationRule{
+					{ID: validRuleID, Name: "rr-" + validNamespacedGroupName},
+				}}, nil)
 
-			createGroupRequest := &gopowerstore.VolumeGroupCreate{Name: validNamespacedGroupName, ProtectionPolicyID: validPolicyID}
+			createGroupRequest := &gopowerstore.VolumeGroupCreate{Name: validNamespacedGroupName, Description: driverVolumeGroupDescription(""), ProtectionPolicyID: validPolicyID}
 			clientMock.On("CreateVolumeGroup", mock.Anything, createGroupRequest).Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
 			clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.VolumeGroup{ID: validGroupID, ProtectionPolicyID: validPolicyID}, nil)
 			clientMock.On("GetCustomHTTPHeaders").Return(api.NewSafeHeader().GetHeader())
@@ -456,9 +490,11 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 			}, nil)
 
 			clientMock.On("GetProtectionPolicyByName", mock.Anything, "pp-"+validNamespacedGroupNameSync).
-				Return(gopowerstore.ProtectionPolicy{ID: validPolicyID}, nil)
+				Return(gopowerstore.ProtectionPolicy{ID: validPolicyID, ReplicationRules: []gopowerstore.ReplicationRule{
+					{ID: validRuleID, Name: "rr-" + validNamespacedGroupNameSync},
+				}}, nil)
 
-			createGroupRequest := &gopowerstore.VolumeGroupCreate{Name: validNamespacedGroupNameSync, ProtectionPolicyID: validPolicyID}
+			createGroupRequest := &gopowerstore.VolumeGroupCreate{Name: validNamespacedGroupNameSync, Description: driverVolumeGroupDescription(""), ProtectionPolicyID: validPolicyID}
 			clientMock.On("CreateVolumeGroup", mock.Anything, createGroupRequest).Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
 			clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.VolumeGroup{ID: validGroupID, ProtectionPolicyID: validPolicyID}, nil)
 			clientMock.On("GetCustomHTTPHeaders").Return(api.NewSafeHeader().GetHeader())
@@ -705,6 +741,7 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 
 			clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
 				Return(gopowerstore.RemoteSystem{}, gopowerstore.NewHostIsNotExistError())
+			clientMock.On("GetRemoteSystems", mock.Anything, mock.Anything).Return([]gopowerstore.RemoteSystem{}, nil)
 
 			res, err := ctrlSvc.CreateVolume(context.Background(), req)
 			gomega.Expect(res).To(gomega.BeNil())
@@ -718,6 +755,7 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 
 			clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
 				Return(gopowerstore.RemoteSystem{}, gopowerstore.NewHostIsNotExistError())
+			clientMock.On("GetRemoteSystems", mock.Anything, mock.Anything).Return([]gopowerstore.RemoteSystem{}, nil)
 
 			// Setting Replciation mode and corresponding attributes for SYNC
 			req.Parameters[ctrlSvc.WithRP(KeyReplicationMode)] = replicationModeSync
@@ -819,6 +857,18 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 			gomega.Expect(err.Error()).To(gomega.ContainSubstring("replication mode ASYNC requires RPO value to be non Zero"))
 		})
 
+		ginkgo.It("should fail when the alert threshold isn't a multiple of the RPO", func() {
+			req.Parameters[ctrlSvc.WithRP(KeyReplicationMode)] = replicationModeAsync
+			req.Parameters[ctrlSvc.WithRP(KeyReplicationRPO)] = validRPO
+			req.Parameters[ctrlSvc.WithRP(KeyReplicationAlertThreshold)] = "7"
+
+			res, err := ctrlSvc.CreateVolume(context.Background(), req)
+			gomega.Expect(res).To(gomega.BeNil())
+			gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+			gomega.Expect(err.Error()).To(gomega.ContainSubstring("expected a multiple of the RPO"))
+			clientMock.AssertNotCalled(ginkgo.GinkgoT(), "CreateReplicationRule", mock.Anything, mock.Anything)
+		})
+
 		ginkgo.It("should fail when mode is SYNC and RPO is not Zero", func() {
 			clientMock.On("CreateVolume", mock.Anything, mock.Anything).Return(gopowerstore.CreateResponse{ID: validBaseVolID}, nil)
 
@@ -844,6 +894,7 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 			req.Parameters[ctrlSvc.WithRP(KeyReplicationRemoteSystem)] = "invalid"
 
 			clientMock.On("GetRemoteSystemByName", mock.Anything, "invalid").Return(gopowerstore.RemoteSystem{}, gopowerstore.NewNotFoundError())
+			clientMock.On("GetRemoteSystems", mock.Anything, mock.Anything).Return([]gopowerstore.RemoteSystem{}, nil)
 
 			res, err := ctrlSvc.CreateVolume(context.Background(), req)
 
@@ -972,6 +1023,7 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 
 				// return 404 Not Found error when querying for the remote system
 				clientMock.On("GetRemoteSystemByName", mock.Anything, "invalid").Return(gopowerstore.RemoteSystem{}, gopowerstore.NewNotFoundError())
+				clientMock.On("GetRemoteSystems", mock.Anything, mock.Anything).Return([]gopowerstore.RemoteSystem{}, nil)
 
 				res, err := ctrlSvc.CreateVolume(context.Background(), req)
 
@@ -3787,7 +3839,7 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				_, err := ctrlSvc.ControllerPublishVolume(context.Background(), req)
 
 				gomega.Expect(err).ToNot(gomega.BeNil())
-				gomega.Expect(err.Error()).To(gomega.ContainSubstring("failed to find array with ID"))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("legacy handle references unknown array IP " + ip))
 			})
 		})
 	})
@@ -5255,8 +5307,10 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 					"globalID":                localAddress,
 					"systemName":              localSystemName,
 					"managementAddress":       localAddress,
+					"managementPort":          "443",
 					"remoteSystemName":        remoteSystemName,
 					"remoteManagementAddress": remoteAddress,
+					"remoteManagementPort":    "443",
 					"remoteGlobalID":          remoteSerialNumber,
 					"VolumeGroupName":         volumeGroupName,
 				}
@@ -5265,8 +5319,10 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 					"globalID":                remoteSerialNumber,
 					"systemName":              remoteSystemName,
 					"managementAddress":       remoteAddress,
+					"managementPort":          "443",
 					"remoteSystemName":        localSystemName,
 					"remoteManagementAddress": localAddress,
+					"remoteManagementPort":    "443",
 					"VolumeGroupName":         volumeGroupName,
 				}
 
@@ -5316,6 +5372,70 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				}))
 			})
 
+			ginkgo.It("should fail if the group replicates to a different remote system", func() {
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, Name: validVolumeGroupName}}}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+					Return(gopowerstore.ReplicationSession{
+						RemoteSystemID:   validRemoteSystemID,
+						LocalResourceID:  validGroupID,
+						RemoteResourceID: validRemoteGroupID,
+					}, nil)
+				clientMock.On("GetCluster", mock.Anything).
+					Return(gopowerstore.Cluster{Name: validClusterName, ManagementAddress: firstValidID}, nil)
+				clientMock.On("GetRemoteSystem", mock.Anything, validRemoteSystemID).
+					Return(gopowerstore.RemoteSystem{
+						Name:              "remote-a",
+						ManagementAddress: secondValidID,
+						SerialNumber:      validRemoteSystemGlobalID,
+					}, nil)
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+					Parameters: map[string]string{
+						ctrlSvc.WithRP(KeyReplicationRemoteSystem): "remote-b",
+					},
+				}
+
+				res, err := ctrlSvc.CreateStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(err).NotTo(gomega.BeNil())
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+					"already replicates to remote system remote-a, but remote system remote-b was requested"))
+			})
+
+			ginkgo.It("should succeed if the requested remote system matches the existing session", func() {
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, Name: validVolumeGroupName}}}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+					Return(gopowerstore.ReplicationSession{
+						RemoteSystemID:   validRemoteSystemID,
+						LocalResourceID:  validGroupID,
+						RemoteResourceID: validRemoteGroupID,
+					}, nil)
+				clientMock.On("GetCluster", mock.Anything).
+					Return(gopowerstore.Cluster{Name: validClusterName, ManagementAddress: firstValidID}, nil)
+				clientMock.On("GetRemoteSystem", mock.Anything, validRemoteSystemID).
+					Return(gopowerstore.RemoteSystem{
+						Name:              validRemoteSystemName,
+						ManagementAddress: secondValidID,
+						SerialNumber:      validRemoteSystemGlobalID,
+					}, nil)
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+					Parameters: map[string]string{
+						ctrlSvc.WithRP(KeyReplicationRemoteSystem): validRemoteSystemName,
+					},
+				}
+
+				res, err := ctrlSvc.CreateStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.RemoteProtectionGroupId).To(gomega.Equal(validRemoteGroupID))
+			})
+
 			ginkgo.It("should successfully discover protection group of a host-based nfs volume", func() {
 				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
 					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, Name: validVolumeGroupName}}}, nil)
@@ -5362,6 +5482,103 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				}))
 			})
 
+			ginkgo.It("should successfully discover protection group of an nfs volume from its NAS server", func() {
+				clientMock.On("GetFS", mock.Anything, validBaseVolID).
+					Return(gopowerstore.FileSystem{ID: validBaseVolID, NasServerID: validNasID}, nil)
+				clientMock.On("GetNAS", mock.Anything, validNasID).
+					Return(gopowerstore.NAS{ID: validNasID, Name: validNasName}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validNasID).
+					Return(gopowerstore.ReplicationSession{
+						RemoteSystemID:   validRemoteSystemID,
+						ResourceType:     "nas_server",
+						LocalResourceID:  validNasID,
+						RemoteResourceID: "remote-" + validNasID,
+					}, nil)
+				clientMock.On("GetCluster", mock.Anything).
+					Return(gopowerstore.Cluster{Name: validClusterName, ManagementAddress: firstValidID}, nil)
+				clientMock.On("GetRemoteSystem", mock.Anything, validRemoteSystemID).
+					Return(gopowerstore.RemoteSystem{
+						Name:              validRemoteSystemName,
+						ManagementAddress: secondValidID,
+						SerialNumber:      validRemoteSystemGlobalID,
+					}, nil)
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "nfs",
+				}
+
+				res, err := ctrlSvc.CreateStorageProtectionGroup(context.Background(), req)
+
+				localParams, remoteParams := getLocalAndRemoteParams(validClusterName, firstValidID,
+					validRemoteSystemName, secondValidID, validRemoteSystemGlobalID, "")
+				for _, params := range []map[string]string{localParams, remoteParams} {
+					delete(params, "VolumeGroupName")
+					params["NASServerName"] = validNasName
+				}
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(&csiext.CreateStorageProtectionGroupResponse{
+					LocalProtectionGroupId:          validNasID,
+					RemoteProtectionGroupId:         "remote-" + validNasID,
+					LocalProtectionGroupAttributes:  localParams,
+					RemoteProtectionGroupAttributes: remoteParams,
+				}))
+			})
+
+			ginkgo.It("should use the NAS server of the file system rather than the one configured for the array", func() {
+				otherNasID, otherNasName := "other-"+validNasID, "other-"+validNasName
+				clientMock.On("GetFS", mock.Anything, validBaseVolID).
+					Return(gopowerstore.FileSystem{ID: validBaseVolID, NasServerID: otherNasID}, nil)
+				clientMock.On("GetNAS", mock.Anything, otherNasID).
+					Return(gopowerstore.NAS{ID: otherNasID, Name: otherNasName}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, otherNasID).
+					Return(gopowerstore.ReplicationSession{
+						RemoteSystemID:   validRemoteSystemID,
+						ResourceType:     "nas_server",
+						LocalResourceID:  otherNasID,
+						RemoteResourceID: "remote-" + otherNasID,
+					}, nil)
+				clientMock.On("GetCluster", mock.Anything).
+					Return(gopowerstore.Cluster{Name: validClusterName, ManagementAddress: firstValidID}, nil)
+				clientMock.On("GetRemoteSystem", mock.Anything, validRemoteSystemID).
+					Return(gopowerstore.RemoteSystem{
+						Name:              validRemoteSystemName,
+						ManagementAddress: secondValidID,
+						SerialNumber:      validRemoteSystemGlobalID,
+					}, nil)
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "nfs",
+				}
+
+				res, err := ctrlSvc.CreateStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.LocalProtectionGroupId).To(gomega.Equal(otherNasID))
+				gomega.Expect(res.LocalProtectionGroupAttributes).To(gomega.HaveKeyWithValue("NASServerName", otherNasName))
+				gomega.Expect(res.RemoteProtectionGroupAttributes).To(gomega.HaveKeyWithValue("NASServerName", otherNasName))
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "GetNASByName", mock.Anything, validNasName)
+			})
+
+			ginkgo.It("should fail with a clear message if the NAS server of an nfs volume has no replication session", func() {
+				clientMock.On("GetFS", mock.Anything, validBaseVolID).
+					Return(gopowerstore.FileSystem{ID: validBaseVolID, NasServerID: validNasID}, nil)
+				clientMock.On("GetNAS", mock.Anything, validNasID).
+					Return(gopowerstore.NAS{ID: validNasID, Name: validNasName}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validNasID).
+					Return(gopowerstore.ReplicationSession{}, gopowerstore.NewNotFoundError())
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "nfs",
+				}
+
+				res, err := ctrlSvc.CreateStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.FailedPrecondition))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("NAS server " + validNasName + " has no replication session"))
+			})
+
 			ginkgo.It("should fail if volume doesn't exists", func() {
 				req := &csiext.CreateStorageProtectionGroupRequest{
 					VolumeHandle: "",
@@ -5374,6 +5591,18 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				gomega.Expect(err.Error()).To(gomega.ContainSubstring("volume ID is required"))
 			})
 
+			ginkgo.It("should reject a metro volume handle", func() {
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validMetroBlockVolumeID,
+				}
+
+				res, err := ctrlSvc.CreateStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("is a metro volume replicated to array " + secondValidID))
+			})
+
 			ginkgo.It("should fail if volume is single", func() {
 				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
 					Return(gopowerstore.VolumeGroups{}, gopowerstore.APIError{})
@@ -5388,6 +5617,49 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				gomega.Expect(err).NotTo(gomega.BeNil())
 			})
 
+			ginkgo.It("should include the array message when the remote system can't be retrieved", func() {
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID}}}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+					Return(gopowerstore.ReplicationSession{RemoteSystemID: validRemoteSystemID}, nil)
+				clientMock.On("GetCluster", mock.Anything).
+					Return(gopowerstore.Cluster{Name: validClusterName}, nil)
+				clientMock.On("GetRemoteSystem", mock.Anything, validRemoteSystemID).
+					Return(gopowerstore.RemoteSystem{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{
+						StatusCode: http.StatusNotFound,
+						Message:    "The remote system was not found.",
+					}})
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+				}
+
+				res, err := ctrlSvc.CreateStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(err).NotTo(gomega.BeNil())
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+					"can't get remote system " + validRemoteSystemID + ": array returned HTTP 404: The remote system was not found."))
+			})
+
+			ginkgo.It("should fail with a clear message if the volume group has no replication session", func() {
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, Name: validVolumeGroupName}}}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+					Return(gopowerstore.ReplicationSession{}, gopowerstore.NewNotFoundError())
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+				}
+
+				res, err := ctrlSvc.CreateStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.FailedPrecondition))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("volume group " + validVolumeGroupName +
+					" has no replication session; ensure protection policy with a replication rule is applied"))
+			})
+
 			ginkgo.It("should fail when volume group not in replication session", func() {
 				// policy with replication rule not assigned
 				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
@@ -5408,6 +5680,82 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 		})
 	})
 
+	ginkgo.Describe("calling VerifyStorageProtectionGroup", func() {
+		ginkgo.When("verifying that a protection group is ready", func() {
+			ginkgo.It("should report the group as ready if everything is in place", func() {
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, Name: validVolumeGroupName}}}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+					Return(gopowerstore.ReplicationSession{
+						ID:               validSessionID,
+						RemoteSystemID:   validRemoteSystemID,
+						LocalResourceID:  validGroupID,
+						RemoteResourceID: validRemoteGroupID,
+					}, nil)
+				clientMock.On("GetCluster", mock.Anything).
+					Return(gopowerstore.Cluster{Name: validClusterName, ManagementAddress: firstValidID}, nil)
+				clientMock.On("GetRemoteSystem", mock.Anything, validRemoteSystemID).
+					Return(gopowerstore.RemoteSystem{
+						Name:              validRemoteSystemName,
+						ManagementAddress: secondValidID,
+						SerialNumber:      validRemoteSystemGlobalID,
+					}, nil)
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+				}
+
+				res, err := ctrlSvc.VerifyStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Ready).To(gomega.BeTrue())
+				gomega.Expect(res.Diagnostics).To(gomega.Equal([]string{
+					"volume group " + validVolumeGroupName + " found",
+					"replication session " + validSessionID + " found",
+					"remote system " + validRemoteSystemName + " found",
+				}))
+			})
+
+			ginkgo.It("should report the group as not ready when it has no replication session", func() {
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, Name: validVolumeGroupName}}}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+					Return(gopowerstore.ReplicationSession{}, gopowerstore.APIError{
+						ErrorMsg: &api.ErrorMsg{
+							StatusCode: http.StatusNotFound,
+							Message:    "The replication session was not found.",
+						},
+					})
+
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+				}
+
+				res, err := ctrlSvc.VerifyStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Ready).To(gomega.BeFalse())
+				gomega.Expect(res.Diagnostics).To(gomega.Equal([]string{
+					"volume group " + validVolumeGroupName + " found",
+					"volume group " + validVolumeGroupName +
+						" has no replication session; ensure protection policy with a replication rule is applied",
+				}))
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "GetRemoteSystem", mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should reject nfs volumes", func() {
+				req := &csiext.CreateStorageProtectionGroupRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "nfs",
+				}
+
+				res, err := ctrlSvc.VerifyStorageProtectionGroup(context.Background(), req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+			})
+		})
+	})
+
 	ginkgo.Describe("calling CreateRemoteVolume", func() {
 		ginkgo.When("creating remote volume", func() {
 			ginkgo.It("should return info if everything is ok", func() {
@@ -5454,6 +5802,104 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 						},
 					}}))
 			})
+			ginkgo.It("should add the provisioning attributes of the source volume to the volume context", func() {
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID}}}, nil)
+
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+					Return(gopowerstore.ReplicationSession{
+						LocalResourceID:  validGroupID,
+						RemoteResourceID: validRemoteGroupID,
+						RemoteSystemID:   validRemoteSystemID,
+						StorageElementPairs: []gopowerstore.StorageElementPair{
+							{
+								LocalStorageElementID:  validBaseVolID,
+								RemoteStorageElementID: validRemoteVolID,
+							},
+						},
+					}, nil)
+
+				clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+					Return(gopowerstore.Volume{
+						ID:                  validBaseVolID,
+						Size:                validVolSize,
+						LogicalUsed:         validVolSize / 2,
+						PerformancePolicyID: "default_high",
+						AppType:             "Other",
+						AppTypeOther:        "ledger",
+					}, nil)
+
+				clientMock.On("GetCluster", mock.Anything).
+					Return(gopowerstore.Cluster{Name: validClusterName}, nil)
+
+				clientMock.On("GetRemoteSystem", mock.Anything, validRemoteSystemID).
+					Return(gopowerstore.RemoteSystem{Name: validRemoteSystemName, ManagementAddress: secondValidID, ID: validRemoteSystemID, SerialNumber: validRemoteSystemGlobalID}, nil)
+
+				req := &csiext.CreateRemoteVolumeRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+				}
+
+				res, err := ctrlSvc.CreateRemoteVolume(context.Background(), req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.RemoteVolume.VolumeContext).To(gomega.Equal(map[string]string{
+					"remoteSystem":                     validClusterName,
+					"managementAddress":                secondValidID,
+					"arrayID":                          validRemoteSystemGlobalID,
+					identifiers.KeyPerformancePolicyID: "default_high",
+					identifiers.KeyAppType:             "Other",
+					identifiers.KeyAppTypeOther:        "ledger",
+					"logicalUsed":                      fmt.Sprint(validVolSize / 2),
+				}))
+			})
+			ginkgo.It("should carry the NAS server of an nfs volume into the remote volume context", func() {
+				otherNasID, otherNasName := "other-"+validNasID, "other-"+validNasName
+				clientMock.On("GetFS", mock.Anything, validBaseVolID).
+					Return(gopowerstore.FileSystem{
+						ID:          validBaseVolID,
+						NasServerID: otherNasID,
+						SizeTotal:   validVolSize + ReservedSize,
+					}, nil)
+				clientMock.On("GetNAS", mock.Anything, otherNasID).
+					Return(gopowerstore.NAS{ID: otherNasID, Name: otherNasName}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, otherNasID).
+					Return(gopowerstore.ReplicationSession{
+						RemoteSystemID:   validRemoteSystemID,
+						LocalResourceID:  otherNasID,
+						RemoteResourceID: "remote-" + otherNasID,
+						StorageElementPairs: []gopowerstore.StorageElementPair{{
+							LocalStorageElementID:  validBaseVolID,
+							RemoteStorageElementID: validRemoteVolID,
+						}},
+					}, nil)
+				clientMock.On("GetCluster", mock.Anything).
+					Return(gopowerstore.Cluster{Name: validClusterName, ManagementAddress: firstValidID}, nil)
+				clientMock.On("GetRemoteSystem", mock.Anything, validRemoteSystemID).
+					Return(gopowerstore.RemoteSystem{
+						Name:              validRemoteSystemName,
+						ManagementAddress: secondValidID,
+						SerialNumber:      validRemoteSystemGlobalID,
+					}, nil)
+
+				req := &csiext.CreateRemoteVolumeRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "nfs",
+				}
+
+				res, err := ctrlSvc.CreateRemoteVolume(context.Background(), req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.RemoteVolume).To(gomega.Equal(&csiext.Volume{
+					CapacityBytes: validVolSize,
+					VolumeId:      validRemoteVolID + "/" + validRemoteSystemGlobalID + "/nfs",
+					VolumeContext: map[string]string{
+						"remoteSystem":         validClusterName,
+						"arrayID":              validRemoteSystemGlobalID,
+						"managementAddress":    secondValidID,
+						identifiers.KeyNasName: otherNasName,
+					},
+				}))
+			})
+
 			ginkgo.It("should fail if volume id is empty", func() {
 				req := &csiext.CreateRemoteVolumeRequest{
 					VolumeHandle: "",
@@ -5466,6 +5912,18 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				gomega.Expect(err.Error()).To(gomega.ContainSubstring("volume ID is required"))
 			})
 
+			ginkgo.It("should reject a metro volume handle", func() {
+				req := &csiext.CreateRemoteVolumeRequest{
+					VolumeHandle: validMetroBlockVolumeID,
+				}
+
+				res, err := ctrlSvc.CreateRemoteVolume(context.Background(), req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("is a metro volume"))
+			})
+
 			ginkgo.It("should fail if volume not in volumeGroup", func() {
 				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
 					Return(gopowerstore.VolumeGroups{}, gopowerstore.APIError{})
@@ -5480,6 +5938,24 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				gomega.Expect(err).NotTo(gomega.BeNil())
 			})
 
+			ginkgo.It("should fail with a clear message if the volume group has no replication session", func() {
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, Name: validVolumeGroupName}}}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+					Return(gopowerstore.ReplicationSession{}, gopowerstore.NewNotFoundError())
+
+				req := &csiext.CreateRemoteVolumeRequest{
+					VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+				}
+
+				res, err := ctrlSvc.CreateRemoteVolume(context.Background(), req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.FailedPrecondition))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("volume group " + validVolumeGroupName +
+					" has no replication session; ensure protection policy with a replication rule is applied"))
+			})
+
 			ginkgo.It("should fail if parent volume group not replicated", func() {
 				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
 					Return(gopowerstore.VolumeGroups{}, gopowerstore.APIError{})
@@ -5556,23 +6032,125 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 			ginkgo.It("should failed if remote system not in list", func() {
 				clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
 					Return(gopowerstore.RemoteSystem{}, gopowerstore.NewHostIsNotExistError())
+				clientMock.On("GetRemoteSystems", mock.Anything, mock.Anything).Return([]gopowerstore.RemoteSystem{}, nil)
 
 				_, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
 					validGroupName, validRemoteSystemName, validRPO)
 				gomega.Expect(err).ToNot(gomega.BeNil())
 			})
 
+			ginkgo.It("should fail with the serial numbers when remote systems share the name", func() {
+				clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
+					Return(gopowerstore.RemoteSystem{}, gopowerstore.NewHostIsNotExistError())
+				clientMock.On("GetRemoteSystems", mock.Anything, map[string]string{"name": "eq." + validRemoteSystemName}).
+					Return([]gopowerstore.RemoteSystem{
+						{ID: "rs-2", Name: validRemoteSystemName, SerialNumber: "PS222222222222"},
+						{ID: validRemoteSystemID, Name: validRemoteSystemName, SerialNumber: validRemoteSystemGlobalID},
+					}, nil)
+
+				_, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
+					validGroupName, validRemoteSystemName, validRPO)
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+					"remote system name " + validRemoteSystemName + " is ambiguous, it is shared by the remote systems with serial numbers " +
+						validRemoteSystemGlobalID + ", PS222222222222; specify the remote system by its serial number instead"))
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "GetProtectionPolicyByName", mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should find the remote system by its serial number", func() {
+				clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemGlobalID).
+					Return(gopowerstore.RemoteSystem{}, gopowerstore.NewHostIsNotExistError())
+				clientMock.On("GetRemoteSystems", mock.Anything, map[string]string{"name": "eq." + validRemoteSystemGlobalID}).
+					Return([]gopowerstore.RemoteSystem{}, nil)
+				clientMock.On("GetRemoteSystems", mock.Anything, map[string]string{"serial_number": "eq." + validRemoteSystemGlobalID}).
+					Return([]gopowerstore.RemoteSystem{
+						{ID: validRemoteSystemID, Name: validRemoteSystemName, SerialNumber: validRemoteSystemGlobalID},
+					}, nil)
+				clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyName).
+					Return(gopowerstore.ProtectionPolicy{}, gopowerstore.NewNotFoundError())
+				clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
+					Return(gopowerstore.ReplicationRule{ID: validRuleID}, nil)
+				clientMock.On("CreateProtectionPolicy", mock.Anything, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validPolicyID}, nil)
+
+				res, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
+					validGroupName, validRemoteSystemGlobalID, validRPO)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(validPolicyID))
+			})
+
 			ginkgo.It("should return existing policy", func() {
 				clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
 					Return(gopowerstore.RemoteSystem{ID: validRemoteSystemID, Name: validRemoteSystemName}, nil)
 
+				clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyName).
+					Return(gopowerstore.ProtectionPolicy{ID: validPolicyID, Name: validPolicyName, ReplicationRules: []gopowerstore.ReplicationRule{
+						{ID: validRuleID, Name: validRuleName},
+					}}, nil)
+
+				res, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
+					validGroupName, validRemoteSystemName, validRPO)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(validPolicyID))
+			})
+
+			ginkgo.It("should add the missing rule to an existing policy", func() {
+				clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
+					Return(gopowerstore.RemoteSystem{ID: validRemoteSystemID, Name: validRemoteSystemName}, nil)
+				clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyName).
+					Return(gopowerstore.ProtectionPolicy{
+						ID: validPolicyID, Name: validPolicyName,
+						SnapshotRules: []gopowerstore.SnapshotRule{{ID: "snapshot-rule"}},
+					}, nil)
+				clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
+					Return(gopowerstore.ReplicationRule{ID: validRuleID, Name: validRuleName}, nil)
+				clientMock.On("ModifyProtectionPolicy", mock.Anything, &gopowerstore.ProtectionPolicyCreate{
+					Name:               validPolicyName,
+					ReplicationRuleIDs: []string{validRuleID},
+					SnapshotRuleIDs:    []string{"snapshot-rule"},
+				}, validPolicyID).Return(gopowerstore.EmptyResponse(""), nil)
+
+				res, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
+					validGroupName, validRemoteSystemName, validRPO)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(validPolicyID))
+				clientMock.AssertCalled(ginkgo.GinkgoT(), "ModifyProtectionPolicy", mock.Anything, mock.Anything, validPolicyID)
+			})
+
+			ginkgo.It("should create the missing rule of an existing policy", func() {
+				clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
+					Return(gopowerstore.RemoteSystem{ID: validRemoteSystemID, Name: validRemoteSystemName}, nil)
 				clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyName).
 					Return(gopowerstore.ProtectionPolicy{ID: validPolicyID, Name: validPolicyName}, nil)
+				clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
+					Return(gopowerstore.ReplicationRule{}, gopowerstore.NewNotFoundError())
+				clientMock.On("CreateReplicationRule", mock.Anything, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validRuleID}, nil)
+				clientMock.On("ModifyProtectionPolicy", mock.Anything, mock.MatchedBy(func(p *gopowerstore.ProtectionPolicyCreate) bool {
+					return len(p.ReplicationRuleIDs) == 1 && p.ReplicationRuleIDs[0] == validRuleID
+				}), validPolicyID).Return(gopowerstore.EmptyResponse(""), nil)
 
 				res, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
 					validGroupName, validRemoteSystemName, validRPO)
 				gomega.Expect(err).To(gomega.BeNil())
 				gomega.Expect(res).To(gomega.Equal(validPolicyID))
+				clientMock.AssertCalled(ginkgo.GinkgoT(), "CreateReplicationRule", mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should fail when the missing rule can't be added to an existing policy", func() {
+				clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
+					Return(gopowerstore.RemoteSystem{ID: validRemoteSystemID, Name: validRemoteSystemName}, nil)
+				clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyName).
+					Return(gopowerstore.ProtectionPolicy{ID: validPolicyID, Name: validPolicyName}, nil)
+				clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
+					Return(gopowerstore.ReplicationRule{ID: validRuleID, Name: validRuleName}, nil)
+				clientMock.On("ModifyProtectionPolicy", mock.Anything, mock.Anything, validPolicyID).
+					Return(gopowerstore.EmptyResponse(""), errors.New("modify failed"))
+
+				_, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
+					validGroupName, validRemoteSystemName, validRPO)
+				gomega.Expect(err).ToNot(gomega.BeNil())
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("can't add replication rule"))
 			})
 
 			ginkgo.It("should successfully create new policy with existing rule", func() {
@@ -5598,6 +6176,43 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 		})
 	})
 
+	ginkgo.Describe("calling EnsureProtectionPolicyExists with a failing policy creation", func() {
+		ginkgo.BeforeEach(func() {
+			clientMock.On("GetRemoteSystemByName", mock.Anything, validRemoteSystemName).
+				Return(gopowerstore.RemoteSystem{ID: validRemoteSystemID, Name: validRemoteSystemName}, nil)
+			clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyName).
+				Return(gopowerstore.ProtectionPolicy{}, gopowerstore.NewNotFoundError())
+			clientMock.On("CreateProtectionPolicy", mock.Anything, mock.Anything).
+				Return(gopowerstore.CreateResponse{}, errors.New("injected error"))
+		})
+
+		ginkgo.It("should roll back the rule created by the call", func() {
+			clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
+				Return(gopowerstore.ReplicationRule{}, gopowerstore.NewNotFoundError())
+			clientMock.On("CreateReplicationRule", mock.Anything, mock.Anything).
+				Return(gopowerstore.CreateResponse{ID: validRuleID}, nil)
+			clientMock.On("DeleteReplicationRule", mock.Anything, validRuleID).
+				Return(gopowerstore.EmptyResponse(""), errors.New("rollback failed"))
+
+			res, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
+				validGroupName, validRemoteSystemName, validRPO)
+			gomega.Expect(res).To(gomega.BeEmpty())
+			// rollback is best-effort, the original error is returned
+			gomega.Expect(err.Error()).To(gomega.ContainSubstring("can't create protection policy"))
+			clientMock.AssertCalled(ginkgo.GinkgoT(), "DeleteReplicationRule", mock.Anything, validRuleID)
+		})
+
+		ginkgo.It("should not delete a pre-existing rule", func() {
+			clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
+				Return(gopowerstore.ReplicationRule{ID: validRuleID}, nil)
+
+			_, err := EnsureProtectionPolicyExists(context.Background(), ctrlSvc.DefaultArray(),
+				validGroupName, validRemoteSystemName, validRPO)
+			gomega.Expect(err).ToNot(gomega.BeNil())
+			clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteReplicationRule", mock.Anything, mock.Anything)
+		})
+	})
+
 	ginkgo.Describe("calling EnsureReplicationRuleExists", func() {
 		ginkgo.When("ensure replication rule exists", func() {
 			ginkgo.It("should successfully create new rule if it doesn't exists", func() {
@@ -5619,6 +6234,26 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				gomega.Expect(res).To(gomega.Equal(validRuleID))
 			})
 
+			ginkgo.It("should create new rule with the alert threshold", func() {
+				clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
+					Return(gopowerstore.ReplicationRule{}, gopowerstore.NewNotFoundError())
+
+				clientMock.On("CreateReplicationRule", mock.Anything,
+					&gopowerstore.ReplicationRuleCreate{
+						Name:           validRuleName,
+						Rpo:            validRPO,
+						RemoteSystemID: validRemoteSystemID,
+						AlertThreshold: 15,
+					},
+				).Return(gopowerstore.CreateResponse{ID: validRuleID}, nil)
+
+				res, err := EnsureReplicationRuleExistsWithAlertThreshold(context.Background(), ctrlSvc.DefaultArray(),
+					validGroupName, validRemoteSystemID, validRPO, 15)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(validRuleID))
+			})
+
 			ginkgo.It("should return existing rule", func() {
 				clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).
 					Return(gopowerstore.ReplicationRule{ID: validRuleID}, nil)
@@ -5655,6 +6290,110 @@ var _ = ginkgo.Describe("CSIControllerService", func() {
 				gomega.Expect(err).ToNot(gomega.BeNil())
 				gomega.Expect(err.Error()).To(gomega.ContainSubstring("can't create replication rule"))
 			})
+
+			ginkgo.It("should report the RPOs to use when the remote system rejects the RPO", func() {
+				clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).Return(
+					gopowerstore.ReplicationRule{}, gopowerstore.NewNotFoundError(),
+				)
+
+				apiErr := gopowerstore.NewAPIError()
+				apiErr.StatusCode = http.StatusBadRequest
+				apiErr.Message = "The specified RPO is not supported by the remote system."
+
+				clientMock.On("CreateReplicationRule", mock.Anything,
+					&gopowerstore.ReplicationRuleCreate{
+						Name:           validRuleName,
+						Rpo:            gopowerstore.RpoSixHours,
+						RemoteSystemID: validRemoteSystemID,
+					},
+				).Return(gopowerstore.CreateResponse{}, gopowerstore.WrapErr(apiErr.ErrorMsg))
+
+				res, err := EnsureReplicationRuleExists(context.Background(), ctrlSvc.DefaultArray(),
+					validGroupName, validRemoteSystemID, gopowerstore.RpoSixHours)
+
+				gomega.Expect(res).To(gomega.BeEmpty())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+					"remote system " + validRemoteSystemID + " does not support RPO Six_Hours"))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+					"RPOs that may be supported instead: Twelve_Hours, One_Day"))
+			})
+
+			ginkgo.It("should not treat other bad requests as an unsupported RPO", func() {
+				clientMock.On("GetReplicationRuleByName", mock.Anything, validRuleName).Return(
+					gopowerstore.ReplicationRule{}, gopowerstore.NewNotFoundError(),
+				)
+
+				apiErr := gopowerstore.NewAPIError()
+				apiErr.StatusCode = http.StatusBadRequest
+				apiErr.Message = "The specified remote system does not exist."
+
+				clientMock.On("CreateReplicationRule", mock.Anything, mock.Anything).
+					Return(gopowerstore.CreateResponse{}, gopowerstore.WrapErr(apiErr.ErrorMsg))
+
+				_, err := EnsureReplicationRuleExists(context.Background(), ctrlSvc.DefaultArray(),
+					validGroupName, validRemoteSystemID, validRPO)
+
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.Internal))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("can't create replication rule"))
+			})
+		})
+	})
+
+	ginkgo.Describe("calling GetDriverCapabilities", func() {
+		ginkgo.AfterEach(func() {
+			csictx.Setenv(context.Background(), identifiers.EnvPodmonEnabled, "")
+			csictx.Setenv(context.Background(), nfs.EnvNFSServerPort, "")
+			csictx.Setenv(context.Background(), identifiers.EnvReplicationPrefix, "replication.storage.dell.com")
+		})
+
+		ginkgo.It("should report only the always-on extensions by default", func() {
+			csictx.Setenv(context.Background(), identifiers.EnvReplicationPrefix, "")
+			_ = ctrlSvc.Init()
+
+			gomega.Expect(ctrlSvc.GetDriverCapabilities()).To(gomega.Equal(DriverCapabilities{
+				VolumeGroupSnapshot: true,
+			}))
+		})
+
+		ginkgo.It("should report every extension enabled by configuration", func() {
+			csictx.Setenv(context.Background(), identifiers.EnvPodmonEnabled, "true")
+			csictx.Setenv(context.Background(), nfs.EnvNFSServerPort, "2050")
+			_ = ctrlSvc.Init()
+
+			gomega.Expect(ctrlSvc.GetDriverCapabilities()).To(gomega.Equal(DriverCapabilities{
+				Replication:         true,
+				VolumeGroupSnapshot: true,
+				ConnectivityChecks:  true,
+				HostBasedNFS:        true,
+			}))
+		})
+
+		ginkgo.It("should not report connectivity checks when podmon is disabled", func() {
+			csictx.Setenv(context.Background(), identifiers.EnvPodmonEnabled, "false")
+			_ = ctrlSvc.Init()
+
+			gomega.Expect(ctrlSvc.GetDriverCapabilities().ConnectivityChecks).To(gomega.BeFalse())
+			gomega.Expect(ctrlSvc.GetDriverCapabilities().Replication).To(gomega.BeTrue())
+		})
+	})
+
+	ginkgo.Describe("calling Init", func() {
+		ginkgo.AfterEach(func() {
+			csictx.Setenv(context.Background(), identifiers.EnvReplicationActionPollInterval, "")
+		})
+
+		ginkgo.It("should read the replication action poll interval", func() {
+			csictx.Setenv(context.Background(), identifiers.EnvReplicationActionPollInterval, "5s")
+			_ = ctrlSvc.Init()
+
+			gomega.Expect(ctrlSvc.replicationActionPollInterval).To(gomega.Equal(5 * time.Second))
+		})
+
+		ginkgo.It("should default the replication action poll interval", func() {
+			_ = ctrlSvc.Init()
+
+			gomega.Expect(ctrlSvc.replicationActionPollInterval).To(gomega.Equal(identifiers.DefaultReplicationActionPollInterval))
 		})
 	})
 
@@ -6017,7 +6756,9 @@ func EnsureProtectionPolicyExistsMock() {
 	}, nil)
 
 	clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyName).
-		Return(gopowerstore.ProtectionPolicy{ID: validPolicyID}, nil)
+		Return(gopowerstore.ProtectionPolicy{ID: validPolicyID, ReplicationRules: []gopowerstore.ReplicationRule{
+			{ID: validRuleID, Name: validRuleName},
+		}}, nil)
 }
 
 func EnsureProtectionPolicyExistsMockSync() {
@@ -6028,5 +6769,7 @@ func EnsureProtectionPolicyExistsMockSync() {
 	}, nil)
 
 	clientMock.On("GetProtectionPolicyByName", mock.Anything, validPolicyNameSync).
-		Return(gopowerstore.ProtectionPolicy{ID: validPolicyID}, nil)
+		Return(gopowerstore.ProtectionPolicy{ID: validPolicyID, ReplicationRules: []gopowerstore.ReplicationRule{
+			{ID: validRuleID, Name: "rr-" + validGroupNameSync},
+		}}, nil)
 }
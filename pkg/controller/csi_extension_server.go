@@ -18,10 +18,14 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
@@ -37,6 +41,17 @@ import (
 // StateReady resembles ready state
 const StateReady = "Ready"
 
+// snapshotNameTimestampLayout is the layout used to render the {timestamp} token of a snapshot name template
+const snapshotNameTimestampLayout = "20060102150405"
+
+var (
+	// ErrNoIOInProgress is returned by the IO in-progress check of a volume whose metrics show no recent IO
+	ErrNoIOInProgress = errors.New("no IOInProgress")
+	// ErrMetricsUnavailable is returned by the IO in-progress check of a volume whose metrics can't be queried,
+	// e.g. because the array is unreachable, so that the activity of the volume is unknown
+	ErrMetricsUnavailable = errors.New("performance metrics unavailable")
+)
+
 // CreateVolumeGroupSnapshot creates volume group snapshot
 func (s *Service) CreateVolumeGroupSnapshot(ctx context.Context, request *vgsext.CreateVolumeGroupSnapshotRequest) (*vgsext.CreateVolumeGroupSnapshotResponse, error) {
 	log.Infof("CreateVolumeGroupSnapshot called with req: %v", request)
@@ -49,11 +64,6 @@ func (s *Service) CreateVolumeGroupSnapshot(ctx context.Context, request *vgsext
 	var reqParams gopowerstore.VolumeGroupSnapshotCreate
 	reqParams.Name = request.GetName()
 	reqParams.Description = request.GetDescription()
-	parsedVolHandle := strings.Split(request.SourceVolumeIDs[0], "/")
-	var arr string
-	if len(parsedVolHandle) >= 2 {
-		arr = parsedVolHandle[1]
-	}
 
 	var sourceVols []string
 	var volGroup gopowerstore.VolumeGroup
@@ -61,17 +71,55 @@ func (s *Service) CreateVolumeGroupSnapshot(ctx context.Context, request *vgsext
 	var int64CreationTime int64
 	var existingVgID string
 
-	for _, v := range request.GetSourceVolumeIDs() {
-		sourceVols = append(sourceVols, strings.Split(v, "/")[0])
+	// the array and protocol of the first source volume, legacy handles name the array by its IP
+	var arr, protocol string
+	requestedVols := make(map[string]bool, len(request.GetSourceVolumeIDs()))
+	for i, v := range request.GetSourceVolumeIDs() {
+		volumeHandle, err := array.ParseVolumeID(ctx, v, s.DefaultArray(), nil)
+		if err != nil {
+			err = status.Errorf(codes.InvalidArgument, "unable to parse source volume %s: %s", v, err.Error())
+			log.Errorf("Error from CreateVolumeGroupSnapshot: %v ", err)
+			return nil, err
+		}
+		if i == 0 {
+			arr, protocol = volumeHandle.LocalArrayGlobalID, volumeHandle.Protocol
+		}
+		// a volume group can only hold volumes of a single array
+		if volumeHandle.LocalArrayGlobalID != arr {
+			err := status.Errorf(codes.InvalidArgument,
+				"source volume %s is on array %s, but source volume %s is on array %s: all source volumes of a volume group snapshot must be on the same array",
+				v, volumeHandle.LocalArrayGlobalID, request.SourceVolumeIDs[0], arr)
+			log.Errorf("Error from CreateVolumeGroupSnapshot: %v ", err)
+			return nil, err
+		}
+		sourceVols = append(sourceVols, volumeHandle.LocalUUID)
+		requestedVols[volumeHandle.LocalUUID] = true
+	}
+	arrConfig, ok := s.Arrays()[arr]
+	if !ok || arrConfig == nil {
+		err := status.Errorf(codes.InvalidArgument, "array %s not found", arr)
+		log.Errorf("Error from CreateVolumeGroupSnapshot: %v ", err)
+		return nil, err
 	}
+
+	// render member snapshot names up front so an invalid template fails before anything is created
+	var snapNames map[string]string
+	if template := request.GetParameters()[KeySnapshotNameTemplate]; template != "" {
+		snapNames, err = s.renderSnapshotNames(ctx, arrConfig, template, request.GetName(), sourceVols)
+		if err != nil {
+			log.Errorf("Error from CreateVolumeGroupSnapshot: %v ", err)
+			return nil, err
+		}
+	}
+
 	// To create volume group
 	vgParams := gopowerstore.VolumeGroupCreate{
 		Name:        request.GetName(),
-		Description: request.GetDescription(),
+		Description: driverVolumeGroupDescription(request.GetDescription()),
 		VolumeIDs:   sourceVols,
 	}
 
-	gotVg, err := s.Arrays()[arr].GetClient().GetVolumeGroupByName(ctx, request.GetName())
+	gotVg, err := arrConfig.GetClient().GetVolumeGroupByName(ctx, request.GetName())
 	if err != nil {
 		if apiError, ok := err.(gopowerstore.APIError); !(ok && apiError.NotFound()) {
 			return nil, status.Errorf(codes.Internal, "Error getting volume group by name: %s", err.Error())
@@ -83,25 +131,28 @@ func (s *Service) CreateVolumeGroupSnapshot(ctx context.Context, request *vgsext
 		// taking the existing volume group to re-create
 		existingVgID = gotVg.ID
 		// add members to existing volume group before taking snapshot
-		_, err := s.Arrays()[arr].GetClient().AddMembersToVolumeGroup(ctx, &gopowerstore.VolumeGroupMembers{VolumeIDs: sourceVols}, existingVgID)
-		if err != nil {
-			if apiError, ok := err.(gopowerstore.APIError); !(ok && apiError.VolumeNameIsAlreadyUse()) {
-				return nil, status.Errorf(codes.Internal, "Error adding volume group members: %s", err.Error())
-			}
+		if err := s.addVolumeGroupMembers(ctx, arrConfig, existingVgID, sourceVols); err != nil {
+			return nil, err
 		}
 	} else {
-		r, err := s.Arrays()[arr].GetClient().GetVolumeGroupsByVolumeID(ctx, vgParams.VolumeIDs[0])
+		r, err := arrConfig.GetClient().GetVolumeGroupsByVolumeID(ctx, vgParams.VolumeIDs[0])
 		if err != nil {
 			if apiError, ok := err.(gopowerstore.APIError); !(ok && apiError.NotFound()) {
 				return nil, status.Errorf(codes.Internal, "Error getting volume group by volume ID: %s", err.Error())
 			}
 		}
 		if len(r.VolumeGroup) == 0 {
-			resp, err := s.Arrays()[arr].GetClient().CreateVolumeGroup(ctx, &vgParams)
+			resp, err := arrConfig.GetClient().CreateVolumeGroup(ctx, &vgParams)
 			if err != nil {
 				if apiError, ok := err.(gopowerstore.APIError); !(ok && apiError.VolumeNameIsAlreadyUse()) {
 					return nil, status.Errorf(codes.Internal, "Error creating volume group: %s", err.Error())
 				}
+				// the group was created by a concurrent request in the meantime, continue with it
+				log.Infof("Volume group %s already exists, re-fetching it", vgParams.Name)
+				resp.ID, err = getVolumeGroupIDByName(ctx, arrConfig, vgParams.Name)
+				if err != nil {
+					return nil, err
+				}
 			}
 			if resp.ID != "" {
 				existingVgID = resp.ID
@@ -111,39 +162,67 @@ func (s *Service) CreateVolumeGroupSnapshot(ctx context.Context, request *vgsext
 		}
 	}
 	if existingVgID != "" {
-		resp, err := s.Arrays()[arr].GetClient().CreateVolumeGroupSnapshot(ctx, existingVgID, &reqParams)
+		resp, err := arrConfig.GetClient().CreateVolumeGroupSnapshot(ctx, existingVgID, &reqParams)
 		if err != nil {
 			if apiError, ok := err.(gopowerstore.APIError); !(ok && apiError.VolumeNameIsAlreadyUse()) {
 				return nil, status.Errorf(codes.Internal, "Error creating volume group snapshot: %s", err.Error())
 			}
 		}
 
-		volGroup, err = s.Arrays()[arr].GetClient().GetVolumeGroup(ctx, resp.ID)
+		volGroup, err = arrConfig.GetClient().GetVolumeGroup(ctx, resp.ID)
 		if err != nil {
 			if apiError, ok := err.(gopowerstore.APIError); !(ok && apiError.VolumeNameIsAlreadyUse()) {
 				return nil, status.Errorf(codes.Internal, "Error getting volume group snapshot: %s", err.Error())
 			}
 		}
+		volGroup, err = s.waitForSnapshotMembers(ctx, arrConfig, volGroup, requestedVols)
+		if err != nil {
+			return nil, err
+		}
 		etime, _ := time.Parse(time.RFC3339, volGroup.CreationTimeStamp)
 		int64CreationTime = etime.Unix() * 1000000000 // we need to convert to nano seconds
 
+		// renamed members along with their original names, to roll back the renames when one of them fails
+		var renamed []gopowerstore.Volume
 		for _, v := range volGroup.Volumes {
+			// an existing group may hold volumes beyond the requested sources, report only the requested ones
+			if !requestedVols[v.ProtectionData.SourceID] {
+				log.Debugf("Skipping snapshot %s of volume %s that was not requested", v.ID, v.ProtectionData.SourceID)
+				continue
+			}
 			var snapState bool
 			if v.State == StateReady {
 				snapState = true
 			}
-			volID := strings.Split(request.SourceVolumeIDs[0], "/")
-			if len(volID) >= 3 {
-				snapsList = append(snapsList, &vgsext.Snapshot{
-					Name:          v.Name,
-					SnapId:        v.ID + "/" + arr + "/" + volID[2],
-					ReadyToUse:    snapState,
-					CapacityBytes: v.Size,
-					SourceId:      v.ProtectionData.SourceID + "/" + arr + "/" + volID[2],
-					CreationTime:  int64CreationTime,
-				})
+			if name, ok := snapNames[v.ProtectionData.SourceID]; ok && name != v.Name {
+				_, err := arrConfig.GetClient().ModifyVolume(ctx, &gopowerstore.VolumeModify{
+					Name:               name,
+					Description:        v.Description,
+					ProtectionPolicyID: v.ProtectionPolicyID,
+				}, v.ID)
+				if err != nil {
+					rollbackSnapshotRenames(ctx, arrConfig, renamed)
+					return nil, status.Errorf(codes.Internal, "Error renaming volume group snapshot member %s: %s", v.ID, err.Error())
+				}
+				renamed = append(renamed, v)
+				v.Name = name
 			}
+			snapsList = append(snapsList, &vgsext.Snapshot{
+				Name:          v.Name,
+				SnapId:        v.ID + "/" + arr + "/" + protocol,
+				ReadyToUse:    snapState,
+				CapacityBytes: v.Size,
+				SourceId:      v.ProtectionData.SourceID + "/" + arr + "/" + protocol,
+				CreationTime:  int64CreationTime,
+			})
 		}
+		// the array doesn't guarantee the order of the group members, keep the response stable across retries
+		sort.Slice(snapsList, func(i, j int) bool {
+			if snapsList[i].SourceId != snapsList[j].SourceId {
+				return snapsList[i].SourceId < snapsList[j].SourceId
+			}
+			return snapsList[i].SnapId < snapsList[j].SnapId
+		})
 	}
 
 	return &vgsext.CreateVolumeGroupSnapshotResponse{
@@ -153,6 +232,280 @@ func (s *Service) CreateVolumeGroupSnapshot(ctx context.Context, request *vgsext
 	}, nil
 }
 
+// DeleteVolumeGroupSnapshot deletes the volume group snapshot with the given snapshotGroupID, in the
+// id/globalID/protocol format, un-assigning its protection policy first. A snapshot group that no longer
+// exists is treated as deleted. The volume group snapshot extension has no delete RPC, so this isn't served over gRPC.
+func (s *Service) DeleteVolumeGroupSnapshot(ctx context.Context, snapshotGroupID string) error {
+	log.Infof("DeleteVolumeGroupSnapshot called with snapshot group ID: %s", snapshotGroupID)
+
+	handle, err := array.ParseVolumeHandle(snapshotGroupID)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid snapshot group ID %q: %s", snapshotGroupID, status.Convert(err).Message())
+	}
+	arr, err := s.GetOneArray(handle.LocalArrayGlobalID)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "array %s not found", handle.LocalArrayGlobalID)
+	}
+	groupID := handle.LocalUUID
+
+	vg, err := arr.GetClient().GetVolumeGroup(ctx, groupID)
+	if err != nil {
+		if apiError, ok := err.(gopowerstore.APIError); ok && apiError.NotFound() {
+			log.Infof("volume group snapshot %s not found on array %s, assuming it is deleted", groupID, arr.GlobalID)
+			return nil
+		}
+		return status.Errorf(codes.Internal, "Error getting volume group snapshot %s: %s", groupID, err.Error())
+	}
+	// never delete the source volume group, or any other group, when given its ID by mistake
+	if vg.Type != "" && vg.Type != gopowerstore.VolumeTypeEnumSnapshot {
+		return status.Errorf(codes.FailedPrecondition, "volume group %s is not a volume group snapshot but a %s volume group",
+			groupID, vg.Type)
+	}
+
+	if vg.ProtectionPolicyID != "" {
+		_, err = arr.GetClient().UpdateVolumeGroupProtectionPolicy(ctx, groupID, &gopowerstore.VolumeGroupChangePolicy{})
+		if apiError, ok := err.(gopowerstore.APIError); err != nil && !(ok && apiError.NotFound()) {
+			return status.Errorf(codes.Internal, "Error un-assigning protection policy %s from volume group snapshot %s: %s",
+				vg.ProtectionPolicyID, groupID, err.Error())
+		}
+	}
+
+	_, err = arr.GetClient().DeleteVolumeGroup(ctx, groupID)
+	if err != nil {
+		if apiError, ok := err.(gopowerstore.APIError); ok && apiError.NotFound() {
+			return nil
+		}
+		return status.Errorf(codes.Internal, "Error deleting volume group snapshot %s: %s", groupID, err.Error())
+	}
+	log.Infof("volume group snapshot %s deleted from array %s", groupID, arr.GlobalID)
+	return nil
+}
+
+// waitForSnapshotMembers polls the volume group snapshot until none of the requested members is in a
+// transient state, or the snapshot ready timeout expires. On timeout the last fetched group is returned.
+func (s *Service) waitForSnapshotMembers(ctx context.Context, arr *array.PowerStoreArray, volGroup gopowerstore.VolumeGroup,
+	requestedVols map[string]bool,
+) (gopowerstore.VolumeGroup, error) {
+	timeout := s.vgsSnapshotReadyTimeout
+	if timeout <= 0 {
+		timeout = identifiers.DefaultVGSSnapshotReadyTimeout
+	}
+	interval := s.vgsSnapshotPollInterval
+	if interval <= 0 {
+		interval = identifiers.DefaultVGSSnapshotPollInterval
+	}
+	deadline := time.Now().Add(timeout)
+
+	for hasTransientSnapshotMembers(volGroup, requestedVols) {
+		if time.Now().After(deadline) {
+			log.Warnf("Volume group snapshot %s members didn't settle within %v", volGroup.ID, timeout)
+			return volGroup, nil
+		}
+		select {
+		case <-ctx.Done():
+			return volGroup, status.Errorf(codes.DeadlineExceeded, "Error waiting for volume group snapshot %s members: %s",
+				volGroup.ID, ctx.Err().Error())
+		case <-time.After(interval):
+		}
+
+		next, err := arr.GetClient().GetVolumeGroup(ctx, volGroup.ID)
+		if err != nil {
+			return volGroup, status.Errorf(codes.Internal, "Error getting volume group snapshot: %s", err.Error())
+		}
+		volGroup = next
+	}
+	return volGroup, nil
+}
+
+// hasTransientSnapshotMembers returns true when any of the requested snapshot members is still initializing
+func hasTransientSnapshotMembers(volGroup gopowerstore.VolumeGroup, requestedVols map[string]bool) bool {
+	for _, v := range volGroup.Volumes {
+		if requestedVols[v.ProtectionData.SourceID] && v.State == gopowerstore.VolumeStateEnumInitializing {
+			log.Debugf("Volume group snapshot member %s is in state %s", v.ID, v.State)
+			return true
+		}
+	}
+	return false
+}
+
+// getVolumeGroupIDByName returns the ID of the existing volume group with the given name
+func getVolumeGroupIDByName(ctx context.Context, arr *array.PowerStoreArray, name string) (string, error) {
+	vg, err := arr.GetClient().GetVolumeGroupByName(ctx, name)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Error getting existing volume group %s: %s", name, err.Error())
+	}
+	if vg.ID == "" {
+		return "", status.Errorf(codes.Internal, "Error getting existing volume group %s: volume group not found", name)
+	}
+	return vg.ID, nil
+}
+
+func (s *Service) getVGSMemberBatchSize() int {
+	if s.vgsMemberBatchSize > 0 {
+		return s.vgsMemberBatchSize
+	}
+	return identifiers.DefaultVGSMemberBatchSize
+}
+
+// addVolumeGroupMembers adds volIDs to the volume group in batches of at most the configured member batch size.
+// Every batch is attempted; the errors of the failed batches are aggregated into the returned error.
+func (s *Service) addVolumeGroupMembers(ctx context.Context, arr *array.PowerStoreArray, groupID string, volIDs []string) error {
+	batchSize := s.getVGSMemberBatchSize()
+	if len(volIDs) <= batchSize {
+		return addVolumeGroupMemberBatch(ctx, arr, groupID, volIDs)
+	}
+
+	var errs []string
+	batches := 0
+	for start := 0; start < len(volIDs); start += batchSize {
+		end := min(start+batchSize, len(volIDs))
+		batches++
+		if err := addVolumeGroupMemberBatch(ctx, arr, groupID, volIDs[start:end]); err != nil {
+			errs = append(errs, status.Convert(err).Message())
+		}
+	}
+	if len(errs) > 0 {
+		return status.Errorf(codes.Internal, "%d of %d volume group member batches failed: %s",
+			len(errs), batches, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// addVolumeGroupMemberBatch adds volIDs to the volume group. When the array rejects the batch because some of the
+// volumes are already members, the add is retried with only the volumes that are not members yet.
+func addVolumeGroupMemberBatch(ctx context.Context, arr *array.PowerStoreArray, groupID string, volIDs []string) error {
+	_, err := arr.GetClient().AddMembersToVolumeGroup(ctx, &gopowerstore.VolumeGroupMembers{VolumeIDs: volIDs}, groupID)
+	if err == nil {
+		return nil
+	}
+	if apiError, ok := err.(gopowerstore.APIError); !(ok && apiError.VolumeNameIsAlreadyUse()) {
+		return status.Errorf(codes.Internal, "Error adding volume group members: %s", err.Error())
+	}
+
+	vg, err := arr.GetClient().GetVolumeGroup(ctx, groupID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Error getting volume group members: %s", err.Error())
+	}
+	members := make(map[string]bool, len(vg.Volumes))
+	for _, v := range vg.Volumes {
+		members[v.ID] = true
+	}
+	var missing []string
+	for _, volID := range volIDs {
+		if !members[volID] {
+			missing = append(missing, volID)
+		}
+	}
+	if len(missing) == 0 {
+		log.Debugf("All volumes are already members of volume group %s", groupID)
+		return nil
+	}
+
+	log.Infof("Retrying adding volumes %v that are not yet members of volume group %s", missing, groupID)
+	_, err = arr.GetClient().AddMembersToVolumeGroup(ctx, &gopowerstore.VolumeGroupMembers{VolumeIDs: missing}, groupID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Error adding volume group members: %s", err.Error())
+	}
+	return nil
+}
+
+// renderSnapshotNames renders the member snapshot name for every source volume using the given template.
+// The returned map is keyed by source volume ID. Templates rendering the same name for several members are
+// rejected, and the {timestamp} token is taken from the group name, so that retries render the same names.
+func (s *Service) renderSnapshotNames(ctx context.Context, arr *array.PowerStoreArray, template, group string,
+	sourceVols []string,
+) (map[string]string, error) {
+	var timestamp time.Time
+	if strings.Contains(template, "{timestamp}") {
+		var ok bool
+		if timestamp, ok = snapshotNameTimestamp(group); !ok {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"snapshot name template %s uses {timestamp}, but volume group snapshot name %s doesn't end with a Unix timestamp",
+				template, group)
+		}
+	}
+
+	names := make(map[string]string, len(sourceVols))
+	volumesByName := make(map[string]string, len(sourceVols))
+	for i, volID := range sourceVols {
+		var volName string
+		if strings.Contains(template, "{volume}") {
+			vol, err := arr.GetClient().GetVolume(ctx, volID)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "Error getting source volume %s: %s", volID, err.Error())
+			}
+			volName = vol.Name
+		}
+		name, err := renderSnapshotName(template, group, volName, i+1, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := volumesByName[name]; ok {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"snapshot name template %s renders the same name %s for volumes %s and %s, use {volume} or {index} to make it unique",
+				template, name, other, volID)
+		}
+		volumesByName[name] = volID
+		names[volID] = name
+	}
+	return names, nil
+}
+
+// snapshotNameTimestamp returns the time of the Unix timestamp, in seconds, milliseconds or nanoseconds,
+// the volume group snapshot name ends with, e.g. vgs-1700000000. It returns false when there is none.
+func snapshotNameTimestamp(group string) (time.Time, bool) {
+	suffix := group[strings.LastIndexFunc(group, func(r rune) bool { return r < '0' || r > '9' })+1:]
+	value, err := strconv.ParseInt(suffix, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	switch len(suffix) {
+	case 10:
+		return time.Unix(value, 0), true
+	case 13:
+		return time.UnixMilli(value), true
+	case 19:
+		return time.Unix(0, value), true
+	}
+	return time.Time{}, false
+}
+
+// rollbackSnapshotRenames restores the original names of the given volume group snapshot members after renaming
+// another member failed, so that a failed request doesn't leave the group half renamed. Failures are only logged,
+// the renames are repeated when the request is retried.
+func rollbackSnapshotRenames(ctx context.Context, arr *array.PowerStoreArray, renamed []gopowerstore.Volume) {
+	for _, v := range renamed {
+		_, err := arr.GetClient().ModifyVolume(ctx, &gopowerstore.VolumeModify{
+			Name:               v.Name,
+			Description:        v.Description,
+			ProtectionPolicyID: v.ProtectionPolicyID,
+		}, v.ID)
+		if err != nil {
+			log.Warnf("unable to restore name %s of volume group snapshot member %s: %s", v.Name, v.ID, err.Error())
+		}
+	}
+}
+
+// renderSnapshotName substitutes the supported {group}, {volume}, {index} and {timestamp} tokens in template
+// and validates that the result is a usable PowerStore volume name
+func renderSnapshotName(template, group, volume string, index int, timestamp time.Time) (string, error) {
+	name := strings.NewReplacer(
+		"{group}", group,
+		"{volume}", volume,
+		"{index}", strconv.Itoa(index),
+		"{timestamp}", timestamp.UTC().Format(snapshotNameTimestampLayout),
+	).Replace(template)
+
+	if strings.ContainsAny(name, "{}") {
+		return "", status.Errorf(codes.InvalidArgument, "snapshot name template %s contains unsupported tokens", template)
+	}
+	if utf8.RuneCountInString(name) > MaxVolumeNameLength {
+		return "", status.Errorf(codes.InvalidArgument, "snapshot name %s rendered from template %s is longer than %d character max",
+			name, template, MaxVolumeNameLength)
+	}
+	return name, nil
+}
+
 // validate if request has VGS name, and VGS name must be less than 28 chars
 func validateCreateVGSreq(request *vgsext.CreateVolumeGroupSnapshotRequest) error {
 	if request.Name == "" {
@@ -181,6 +534,8 @@ func validateCreateVGSreq(request *vgsext.CreateVolumeGroupSnapshotRequest) erro
 func (s *Service) ValidateVolumeHostConnectivity(ctx context.Context, req *podmon.ValidateVolumeHostConnectivityRequest) (*podmon.ValidateVolumeHostConnectivityResponse, error) {
 	// ctx, log, _ := GetRunIDLog(ctx)
 	log.Infof("ValidateVolumeHostConnectivity called %+v", req)
+	start := time.Now()
+	defer func() { validateHostConnectivityDuration.Observe(time.Since(start).Seconds()) }()
 	rep := &podmon.ValidateVolumeHostConnectivityResponse{
 		Messages: make([]string, 0),
 	}
@@ -194,6 +549,17 @@ func (s *Service) ValidateVolumeHostConnectivity(ctx context.Context, req *podmo
 	if req.GetNodeId() == "" {
 		return nil, fmt.Errorf("the NodeID is a required field")
 	}
+
+	// the checks below stop when the validation budget runs out, and the reply holds what was determined by then
+	callCtx := ctx
+	ctx, cancel := s.withValidationBudget(ctx)
+	defer cancel()
+	// the node IP is resolved once, every array check below queries the same node
+	nodeIP, err := s.getNodeIPSource().nodeIP(ctx, req.GetNodeId())
+	if err != nil {
+		log.Errorf("failed to parse node ID '%s': %s", req.GetNodeId(), err.Error())
+		return nil, status.Errorf(codes.InvalidArgument, "failed to parse node ID: %s", err.Error())
+	}
 	// create the map of all the array with array's GloabalID as key
 	globalIDs := make(map[string]bool)
 	globalID := req.GetArrayId()
@@ -214,82 +580,193 @@ func (s *Service) ValidateVolumeHostConnectivity(ctx context.Context, req *podmo
 			}
 		}
 	} else {
+		// fail fast, before any connectivity check, when a volume doesn't belong to the requested array
+		if err := validateVolumesOnArray(ctx, req.GetVolumeIds(), globalID, s.DefaultArray()); err != nil {
+			return nil, err
+		}
 		globalIDs[globalID] = true
 	}
 
-	// Go through each of the globalIDs
+	// First - check if the arrays are visible from the node
+	arrayIDs := make([]string, 0, len(globalIDs))
 	for globalID := range globalIDs {
-		// First - check if the array is visible from the node
-		err := s.checkIfNodeIsConnected(ctx, globalID, req.GetNodeId(), rep)
-		if err != nil {
-			return rep, err
-		}
+		arrayIDs = append(arrayIDs, globalID)
+	}
+	sort.Strings(arrayIDs)
+	err = s.checkIfNodeIsConnectedToArrays(ctx, arrayIDs, req.GetNodeId(), nodeIP, rep)
+	if err == nil {
+		// metro volumes are reachable through either array, report the connectivity of both sides
+		err = s.checkMetroVolumesConnectivity(ctx, req.GetVolumeIds(), req.GetNodeId(), nodeIP, start, rep)
+	}
+	if err != nil {
+		s.noteValidationBudget(ctx, callCtx, rep)
+		rep.Messages = capConnectivityMessages(rep.Messages, s.getMaxConnectivityMessages())
+		return rep, err
 	}
 
 	// Check for IOinProgress only when volumes IDs are present in the request as the field is required only in the latter case also to reduce number of calls to the API making it efficient
 	if len(req.GetVolumeIds()) > 0 {
-		// Get array config
+		// resolve array config for every volume, and both sides of metro volumes, before issuing any query
+		checks := make([]ioCheck, 0, len(req.GetVolumeIds()))
 		for _, volID := range req.GetVolumeIds() {
-			volume, err := array.ParseVolumeID(ctx, volID, s.DefaultArray(), nil)
+			volumeChecks, err := s.volumeIOChecks(ctx, volID, rep)
 			if err != nil {
-				log.Errorf("failed to parse volumeID, %s, for querying IO metrics. err: %s", volID, err.Error())
 				return nil, err
 			}
+			checks = append(checks, volumeChecks...)
+		}
 
-			localArray, err := s.GetOneArray(volume.LocalArrayGlobalID)
-			if err != nil || localArray == nil {
-				log.Errorf("failed to get local array configuration for array %s for volume activity validation: %s",
-					volume.LocalArrayGlobalID, err.Error())
-				return nil, err
-			}
+		// This context is for the whole set of requests. Used to cancel any
+		// pending requests as soon as IO is detected on any volume.
+		ioCtx, ioCtxCancel := context.WithCancel(ctx)
 
-			// set to nil to avoid unnecessary API calls by subsequent iterations
-			var remoteArray *array.PowerStoreArray
-			if volume.RemoteArrayGlobalID != "" {
-				remoteArray, err = s.GetOneArray(volume.RemoteArrayGlobalID)
-				if err != nil {
-					log.Errorf("failed to get remote array configuration for array %s for volume activity validation: %s",
-						volume.RemoteArrayGlobalID, err.Error())
-					return nil, err
-				}
-			}
+		// pool shared by all requests, bounding the number of concurrent metric queries
+		// across all volumes, including both sides of metro volumes
+		pool := s.getIOCheckPool()
 
-			// This context is for the set of requests for the current volume.
-			// Used to cancel any pending requests before checking for IO on any
-			// subsequent volumes.
-			ioCtx, ioCtxCancel := context.WithCancel(ctx)
-
-			// channels for receiving responses from async requests
-			reqChs := make([]<-chan error, 0)
-			// check if any IO is inProgress for the current local globalID/array
-			reqLocalCh := asyncGetIOInProgress(ioCtx, volume.LocalUUID, *localArray, volume.Protocol)
-			reqChs = append(reqChs, reqLocalCh)
-
-			if remoteArray != nil {
-				// check if any IO is inProgress for the current remote globalID/array
-				reqRemoteCh := asyncGetIOInProgress(ioCtx, volume.RemoteUUID, *remoteArray, volume.Protocol)
-				reqChs = append(reqChs, reqRemoteCh)
-			}
+		// a slow volume may only take its share of the deadline, leaving time for the others to be checked
+		timeout := ioCheckTimeout(ioCtx, len(checks), s.getMaxConcurrentIOChecks())
 
-			if rep.IosInProgress = isIOInProgress(ioCtx, reqChs...); rep.IosInProgress {
-				// so long as at least one volume has IO in-progress
-				// we should report it.
-				// This status is effectively a logical OR of all the volumes
-				ioCtxCancel()
-				log.Infof("IO detected for volume %s", volID)
-				break
-			}
+		// channels for receiving responses from async requests
+		reqChs := make([]<-chan error, 0, len(checks))
+		for _, check := range checks {
+			reqChs = append(reqChs, asyncGetIOInProgress(ioCtx, pool, check.volID, check.array, check.protocol, check.maxAge, check.samples,
+				check.interval, timeout))
+		}
 
-			// make sure to cancel any pending requests from this iteration
-			// so no goroutines are left running.
-			ioCtxCancel()
+		// so long as at least one volume has IO in-progress we should report it.
+		// This status is effectively a logical OR of all the volumes
+		ioInProgress, timedOut, unavailable := checkIOInProgress(ioCtx, reqChs...)
+		if rep.IosInProgress = ioInProgress; rep.IosInProgress {
+			log.Infof("IO detected for volumes %v", req.GetVolumeIds())
+		} else if timedOut && s.reportIOOnCheckTimeout {
+			// the activity of the volumes is unknown, report IO in-progress so the node isn't fenced
+			message := fmt.Sprintf("all IO checks timed out for volumes %v, reporting IO in-progress", req.GetVolumeIds())
+			log.Warn(message)
+			rep.Messages = append(rep.Messages, message)
+			rep.IosInProgress = true
 		}
+		if !rep.IosInProgress && unavailable > 0 {
+			// tell an unreachable array apart from idle volumes
+			message := fmt.Sprintf("%s for %d of %d IO checks of volumes %v, the array may be unreachable",
+				ErrMetricsUnavailable, unavailable, len(checks), req.GetVolumeIds())
+			log.Warn(message)
+			rep.Messages = append(rep.Messages, message)
+		}
+
+		// make sure to cancel any pending requests so no goroutines are left running.
+		ioCtxCancel()
 	}
 
+	s.noteValidationBudget(ctx, callCtx, rep)
+	rep.Messages = capConnectivityMessages(rep.Messages, s.getMaxConnectivityMessages())
 	log.Infof("ValidateVolumeHostConnectivity reply %+v", rep)
 	return rep, nil
 }
 
+// withValidationBudget bounds ctx by the validation budget, when one is configured
+func (s *Service) withValidationBudget(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.validationBudget <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.validationBudget)
+}
+
+// noteValidationBudget adds a message to rep when budgetCtx ran out of the validation budget while the
+// deadline of the call, callCtx, didn't expire
+func (s *Service) noteValidationBudget(budgetCtx, callCtx context.Context, rep *podmon.ValidateVolumeHostConnectivityResponse) {
+	if budgetCtx.Err() != context.DeadlineExceeded || callCtx.Err() != nil {
+		return
+	}
+	message := fmt.Sprintf("validation budget of %v exhausted, reporting the determination made so far", s.validationBudget)
+	log.Warn(message)
+	rep.Messages = append(rep.Messages, message)
+}
+
+// isCriticalConnectivityMessage returns true for the messages of a connectivity response reporting
+// that a node isn't connected to an array, or that the activity of its volumes is unknown
+func isCriticalConnectivityMessage(message string) bool {
+	return strings.Contains(message, " is not connected to node ") || strings.Contains(message, "IO checks timed out") ||
+		strings.HasPrefix(message, "validation budget of ") ||
+		strings.HasPrefix(message, ErrMetricsUnavailable.Error()) ||
+		strings.Contains(message, " is degraded on node ") || strings.Contains(message, " is disconnected from node ")
+}
+
+// capConnectivityMessages limits messages to maxMessages, keeping their order. The critical messages are always
+// retained, even beyond the limit, and the other ones that don't fit are replaced by a count of the omitted messages.
+func capConnectivityMessages(messages []string, maxMessages int) []string {
+	if len(messages) <= maxMessages {
+		return messages
+	}
+	critical := 0
+	for _, message := range messages {
+		if isCriticalConnectivityMessage(message) {
+			critical++
+		}
+	}
+	// leave room for the summary of the omitted messages
+	room := maxMessages - critical - 1
+	capped := make([]string, 0, maxMessages)
+	omitted := 0
+	for _, message := range messages {
+		switch {
+		case isCriticalConnectivityMessage(message):
+			capped = append(capped, message)
+		case room > 0:
+			capped = append(capped, message)
+			room--
+		default:
+			omitted++
+		}
+	}
+	if omitted > 0 {
+		capped = append(capped, fmt.Sprintf("%d more messages omitted", omitted))
+	}
+	return capped
+}
+
+// getMaxConnectivityMessages returns the max number of messages retained in a connectivity response
+func (s *Service) getMaxConnectivityMessages() int {
+	if s.maxConnectivityMessages > 0 {
+		return s.maxConnectivityMessages
+	}
+	return identifiers.DefaultPodmonMaxConnectivityMessages
+}
+
+// IsVolumeGroupIOInProgress returns true if any member volume of the volume group with the given groupID, on the
+// array with the given globalID, has IO in progress. The members are checked in parallel, bounded by the shared
+// IO check pool, and the remaining checks are canceled as soon as IO is detected on any member.
+func (s *Service) IsVolumeGroupIOInProgress(ctx context.Context, globalID string, groupID string) (bool, error) {
+	arr, err := s.GetOneArray(globalID)
+	if err != nil {
+		return false, status.Errorf(codes.NotFound, "array %s not found", globalID)
+	}
+	vg, err := arr.GetClient().GetVolumeGroup(ctx, groupID)
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "failed to get volume group %s on array %s: %s", groupID, globalID, err.Error())
+	}
+	if len(vg.Volumes) == 0 {
+		log.Infof("volume group %s on array %s has no member volumes", groupID, globalID)
+		return false, nil
+	}
+
+	ioCtx, ioCtxCancel := context.WithCancel(ctx)
+	defer ioCtxCancel()
+
+	// volume groups only hold block volumes
+	protocol := "scsi"
+	pool := s.getIOCheckPool()
+	timeout := ioCheckTimeout(ioCtx, len(vg.Volumes), s.getMaxConcurrentIOChecks())
+	reqChs := make([]<-chan error, 0, len(vg.Volumes))
+	for _, volume := range vg.Volumes {
+		reqChs = append(reqChs, asyncGetIOInProgress(ioCtx, pool, volume.ID, *arr, protocol,
+			s.getMetricsMaxAge(protocol), s.getMetricsSampleCount(), s.getMetricsInterval(), timeout))
+	}
+	ioInProgress := isIOInProgress(ioCtx, reqChs...)
+	log.Infof("IO in progress for volume group %s on array %s: %t", groupID, globalID, ioInProgress)
+	return ioInProgress, nil
+}
+
 // waitAndClose waits for all goroutines to complete by waiting on the WaitGroup, wg,
 // then closes the provided channel, ch.
 func waitAndClose(wg *sync.WaitGroup, ch chan error) {
@@ -305,6 +782,14 @@ func waitAndClose(wg *sync.WaitGroup, ch chan error) {
 // fan-in concurrency pattern and returns true if at least one response is a nil error,
 // denoting IO is in-progress.
 func isIOInProgress(ctx context.Context, chs ...<-chan error) bool {
+	ioInProgress, _, _ := checkIOInProgress(ctx, chs...)
+	return ioInProgress
+}
+
+// checkIOInProgress works like isIOInProgress, and additionally reports whether the ctx deadline
+// expired before any of the queries returned a result, i.e. all of them timed out, and the number
+// of queries that failed with ErrMetricsUnavailable.
+func checkIOInProgress(ctx context.Context, chs ...<-chan error) (bool, bool, int) {
 	// single channel on which the channels in "chs" will write their results
 	errCh := make(chan error)
 	wg := &sync.WaitGroup{}
@@ -344,9 +829,19 @@ func isIOInProgress(ctx context.Context, chs ...<-chan error) bool {
 	// Read results as they're ready.
 	// If the errCh channel is closed before a nil error is
 	// received, assume there is no IO in-progress.
+	answered, timeouts, unavailable := 0, 0, 0
 	for err := range errCh {
 		if err != nil {
 			log.Debugf("error received while validating volume connectivity: %s", err.Error())
+			switch {
+			case errors.Is(err, context.DeadlineExceeded):
+				timeouts++
+			case ctx.Err() == nil:
+				answered++
+				if errors.Is(err, ErrMetricsUnavailable) {
+					unavailable++
+				}
+			}
 			continue
 		}
 
@@ -354,30 +849,243 @@ func isIOInProgress(ctx context.Context, chs ...<-chan error) bool {
 		// and we don't leave any goroutines blocking, trying to write to the channel.
 		cancel()
 		log.Info("IO in-progress detected while validating volume connectivity")
-		return true
+		return true, false, 0
 	}
 
+	timedOut := answered == 0 && len(chs) > 0 && (timeouts > 0 || ctx.Err() == context.DeadlineExceeded)
 	log.Info("no IO in-progress was detected while validating volume connectivity")
-	return false
+	return false, timedOut, unavailable
+}
+
+// ioCheck describes a single IO in-progress query for a volume on an array
+type ioCheck struct {
+	volID    string
+	array    array.PowerStoreArray
+	protocol string
+	// max age of a metric to count as IO in progress
+	maxAge time.Duration
+	// number of the most recent metric samples inspected
+	samples int
+	// interval of the queried metrics
+	interval gopowerstore.MetricsIntervalEnum
+}
+
+// volumeIOChecks returns the IO checks of the volume volID, one for each side of a metro volume. The sides of a
+// metro volume are resolved independently, so that the volume is still checked on one side when the half of its
+// handle or the array of the other side can't be used. Such fallbacks are reported in rep.
+func (s *Service) volumeIOChecks(ctx context.Context, volID string, rep *podmon.ValidateVolumeHostConnectivityResponse,
+) ([]ioCheck, error) {
+	volume, err := array.ParseVolumeID(ctx, volID, s.DefaultArray(), nil)
+	if err != nil && strings.Contains(volID, ":") {
+		volume, err = parseMetroRemoteHalf(volID, err)
+		if err == nil {
+			message := fmt.Sprintf("local half of metro volume %s can't be parsed, checking volume activity on remote array %s only",
+				volID, volume.RemoteArrayGlobalID)
+			log.Warn(message)
+			rep.Messages = append(rep.Messages, message)
+		}
+	}
+	if err != nil {
+		log.Errorf("failed to parse volumeID, %s, for querying IO metrics. err: %s", volID, err.Error())
+		return nil, err
+	}
+	if array.IsLegacyVolumeHandle(volID) {
+		// the protocol of a legacy volume handle is inferred by probing the default array
+		message := fmt.Sprintf("legacy volume %s resolved to protocol %s on array %s",
+			volID, volume.Protocol, volume.LocalArrayGlobalID)
+		log.Info(message)
+		rep.Messages = append(rep.Messages, message)
+	}
+
+	var localArray, remoteArray *array.PowerStoreArray
+	if volume.LocalUUID != "" {
+		localArray, err = s.GetOneArray(volume.LocalArrayGlobalID)
+		if err != nil || localArray == nil {
+			log.Errorf("failed to get local array configuration for array %s for volume activity validation: %v",
+				volume.LocalArrayGlobalID, err)
+			if volume.RemoteArrayGlobalID == "" {
+				return nil, err
+			}
+			localArray = nil
+		}
+	}
+	if volume.RemoteArrayGlobalID != "" {
+		remoteArray, err = s.GetOneArray(volume.RemoteArrayGlobalID)
+		if err != nil {
+			// the remote side of the metro volume is not managed by this driver so its metrics
+			// can't be queried; the result would be meaningless, so only check the local side
+			log.Warnf("remote array %s of metro volume %s is unmanaged, checking volume activity on local array %s only",
+				volume.RemoteArrayGlobalID, volID, volume.LocalArrayGlobalID)
+			remoteArray = nil
+		}
+	}
+
+	newCheck := func(id string, arr *array.PowerStoreArray) ioCheck {
+		return ioCheck{volID: id, array: *arr, protocol: volume.Protocol,
+			maxAge: s.getMetricsMaxAge(volume.Protocol), samples: s.getMetricsSampleCount(), interval: s.getMetricsInterval()}
+	}
+	switch {
+	case localArray != nil && remoteArray != nil:
+		return orderMetroIOChecks(ctx, localArray, newCheck(volume.LocalUUID, localArray), newCheck(volume.RemoteUUID, remoteArray)), nil
+	case localArray != nil:
+		return []ioCheck{newCheck(volume.LocalUUID, localArray)}, nil
+	case remoteArray != nil:
+		if volume.LocalUUID != "" {
+			log.Warnf("local array %s of metro volume %s is unknown, checking volume activity on remote array %s only",
+				volume.LocalArrayGlobalID, volID, volume.RemoteArrayGlobalID)
+		}
+		return []ioCheck{newCheck(volume.RemoteUUID, remoteArray)}, nil
+	}
+	return nil, status.Errorf(codes.NotFound, "neither array %s nor array %s of metro volume %s is configured",
+		volume.LocalArrayGlobalID, volume.RemoteArrayGlobalID, volID)
+}
+
+// parseMetroRemoteHalf returns the remote side of the metro volume handle volID, whose local half failed to parse
+// with parseErr, so that the activity of the volume can still be checked on the remote array. Metro volumes are block
+// volumes, so the protocol is scsi. parseErr is returned when the remote half can't be parsed either.
+func parseMetroRemoteHalf(volID string, parseErr error) (array.VolumeHandle, error) {
+	halves := strings.SplitN(volID, ":", 2)
+	remote := strings.Split(halves[1], "/")
+	if len(remote) < 2 || remote[0] == "" || remote[1] == "" {
+		return array.VolumeHandle{}, parseErr
+	}
+	return array.VolumeHandle{RemoteUUID: remote[0], RemoteArrayGlobalID: remote[1], Protocol: "scsi"}, nil
+}
+
+// orderMetroIOChecks returns the IO checks of both sides of a metro volume with the preferred side first.
+// The preferred side is the one reported by the metro replication session, independent of the volume handle order.
+// When the session can't be read the local side is assumed to be preferred.
+func orderMetroIOChecks(ctx context.Context, localArray *array.PowerStoreArray, local, remote ioCheck) []ioCheck {
+	rs, err := localArray.GetClient().GetReplicationSessionByLocalResourceID(ctx, local.volID)
+	if err != nil {
+		log.Warnf("unable to get metro session of volume %s on array %s, assuming the local side is preferred: %s",
+			local.volID, local.array.GlobalID, err.Error())
+		return []ioCheck{local, remote}
+	}
+
+	preferred, nonPreferred := local, remote
+	if rs.Role == string(gopowerstore.ReplicationRoleMetroNonPreferred) {
+		preferred, nonPreferred = remote, local
+	}
+	log.Infof("metro volume %s is preferred on array %s, non-preferred volume %s is on array %s",
+		preferred.volID, preferred.array.GlobalID, nonPreferred.volID, nonPreferred.array.GlobalID)
+	return []ioCheck{preferred, nonPreferred}
+}
+
+// getMaxConcurrentIOChecks returns the max number of concurrent IO metric queries
+func (s *Service) getMaxConcurrentIOChecks() int {
+	if s.maxConcurrentIOChecks > 0 {
+		return s.maxConcurrentIOChecks
+	}
+	return identifiers.DefaultPodmonMaxConcurrentIOChecks
+}
+
+// getMetricsMaxAge returns the max age of a performance metric of the given protocol to count as IO in progress
+func (s *Service) getMetricsMaxAge(protocol string) time.Duration {
+	maxAge := s.blockMetricsMaxAge
+	if !array.IsBlockProtocol(protocol) {
+		maxAge = s.nfsMetricsMaxAge
+	}
+	if maxAge > 0 {
+		return maxAge
+	}
+	return identifiers.DefaultPodmonMetricsMaxAge
+}
+
+// getMetricsSampleCount returns the number of the most recent performance metric samples inspected for IO in progress
+func (s *Service) getMetricsSampleCount() int {
+	if s.metricsSampleCount > 0 {
+		return s.metricsSampleCount
+	}
+	return identifiers.DefaultPodmonMetricsSampleCount
+}
+
+// metricsIntervalFiveSec is the five second performance metrics interval, which gopowerstore has no constant for
+const metricsIntervalFiveSec gopowerstore.MetricsIntervalEnum = "Five_Sec"
+
+// metricsIntervalNames maps the names of the metrics intervals, without separators and in lower case,
+// e.g. "fivesec" for "FiveSec" or "Five_Sec", to their duration
+var metricsIntervalNames = map[string]time.Duration{
+	"fivesec":   5 * time.Second,
+	"twentysec": 20 * time.Second,
+	"onemin":    time.Minute,
+	"fivemins":  5 * time.Minute,
+}
+
+// parseMetricsInterval maps a human readable metrics interval, e.g. "5s" or "20s", or its name, e.g. "FiveSec"
+// or "TwentySec", to the PowerStore interval. Only the intervals fine enough to detect IO in progress are accepted.
+func parseMetricsInterval(value string) (gopowerstore.MetricsIntervalEnum, error) {
+	name := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(value), "_", ""))
+	duration, ok := metricsIntervalNames[name]
+	if !ok {
+		var err error
+		if duration, err = time.ParseDuration(strings.TrimSpace(value)); err != nil {
+			return "", fmt.Errorf("invalid metrics interval %q: %s", value, err.Error())
+		}
+	}
+	switch duration {
+	case 5 * time.Second:
+		return metricsIntervalFiveSec, nil
+	case 20 * time.Second:
+		return gopowerstore.TwentySec, nil
+	}
+	if duration == time.Minute {
+		return "", fmt.Errorf("unsupported metrics interval %q, PowerStore provides no one minute metrics, "+
+			"supported intervals are 5s (FiveSec) and 20s (TwentySec)", value)
+	}
+	return "", fmt.Errorf("unsupported metrics interval %q, supported intervals are 5s (FiveSec) and 20s (TwentySec)", value)
+}
+
+// getMetricsInterval returns the interval of the performance metrics queried for IO in progress
+func (s *Service) getMetricsInterval() gopowerstore.MetricsIntervalEnum {
+	if s.metricsInterval != "" {
+		return s.metricsInterval
+	}
+	return identifiers.DefaultPodmonMetricsInterval
 }
 
 // asyncGetIOInProgress starts an async request to getIOInProgress and returns a channel
 // on which the result can be received.
 // It can be used to dispatch multiple requests in parallel for situations such as metro
 // volumes where multiple volumes need to be checked for IO to determine if the volume is active.
-func asyncGetIOInProgress(ctx context.Context, volID string, array array.PowerStoreArray, protocol string) <-chan error {
+// If pool is not nil, a slot in it is held for the duration of the query, bounding the number
+// of concurrent queries sharing the same pool.
+// If timeout is positive, the query is abandoned after timeout, starting once it holds its slot,
+// and the error on the channel wraps context.DeadlineExceeded.
+func asyncGetIOInProgress(ctx context.Context, pool *ioCheckPool, volID string, array array.PowerStoreArray, protocol string,
+	maxAge time.Duration, samples int, interval gopowerstore.MetricsIntervalEnum, timeout time.Duration,
+) <-chan error {
 	errCh := make(chan error)
 	go func() {
 		defer close(errCh)
+
+		if pool != nil {
+			if !pool.acquire(ctx) {
+				log.Errorf("unable to query for IOs in-progress for volume %s on array %s: context canceled or pool shut down", volID, array.GlobalID)
+				return
+			}
+		}
 		log.Infof("checking if IO is in-progress for volume %s on array %s", volID, array.GlobalID)
+		checkCtx := ctx
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			checkCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		err := getIOInProgress(checkCtx, volID, array, protocol, maxAge, samples, interval)
+		if err != nil && ctx.Err() == nil && checkCtx.Err() == context.DeadlineExceeded {
+			log.Warnf("IO check for volume %s on array %s didn't complete within %s", volID, array.GlobalID, timeout)
+			err = fmt.Errorf("IO check for volume %s on array %s timed out: %w", volID, array.GlobalID, context.DeadlineExceeded)
+		}
+		if pool != nil {
+			pool.release()
+		}
 
-		// This blocks until both functions have been evaluated, which can be slow.
-		// Only then can the select statement determine which case to execute. If context has
-		// been canceled when the function returns, don't try to write anything to the channel
-		// because there will likely be no listeners and the select will block forever
+		// If context has been canceled when the function returns, don't try to write anything
+		// to the channel because there will likely be no listeners and the send will block forever
 		// if the channel is not read.
 		select {
-		case errCh <- getIOInProgress(ctx, volID, array, protocol):
+		case errCh <- err:
 		case <-ctx.Done():
 			log.Errorf("context deadline exceeded while querying for IOs in-progress for volume %s on array %s", volID, array.GlobalID)
 		}
@@ -385,74 +1093,313 @@ func asyncGetIOInProgress(ctx context.Context, volID string, array array.PowerSt
 	return errCh
 }
 
-// checkIfNodeIsConnected looks at the 'nodeId' to determine if there is connectivity to the 'arrayId' array.
-// The 'rep' object will be filled with the results of the check.
-func (s *Service) checkIfNodeIsConnected(ctx context.Context, arrayID string, nodeID string, rep *podmon.ValidateVolumeHostConnectivityResponse) error {
+// ioCheckTimeout returns the share of the remaining deadline of ctx allotted to each of n IO checks, run up to
+// slots at a time, so that a slow check can't use up the time of the checks waiting for a slot.
+// It returns 0, for no timeout, when ctx has no deadline.
+func ioCheckTimeout(ctx context.Context, n, slots int) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok || n <= 0 {
+		return 0
+	}
+	if slots <= 0 || slots > n {
+		slots = n
+	}
+	rounds := (n + slots - 1) / slots
+	return time.Until(deadline) / time.Duration(rounds)
+}
+
+// ioCheckPool bounds the number of IO metric queries in flight across all ValidateVolumeHostConnectivity calls
+type ioCheckPool struct {
+	slots   chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+func newIOCheckPool(size int) *ioCheckPool {
+	return &ioCheckPool{slots: make(chan struct{}, size)}
+}
+
+// acquire waits for a free slot. It returns false if ctx is done first or the pool is shut down.
+func (p *ioCheckPool) acquire(ctx context.Context) bool {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return false
+	}
+	p.running.Add(1)
+	p.mu.RUnlock()
+
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		p.running.Done()
+		return false
+	}
+}
+
+// release frees a slot taken by acquire
+func (p *ioCheckPool) release() {
+	<-p.slots
+	p.running.Done()
+}
+
+// shutdown rejects new queries and waits for the queries in flight to complete
+func (p *ioCheckPool) shutdown() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.running.Wait()
+}
+
+// getIOCheckPool returns the IO check pool, creating it on first use
+func (s *Service) getIOCheckPool() *ioCheckPool {
+	s.ioCheckPoolOnce.Do(func() {
+		s.ioCheckPool = newIOCheckPool(s.getMaxConcurrentIOChecks())
+	})
+	return s.ioCheckPool
+}
+
+// Shutdown stops accepting IO checks and waits for the checks in flight to complete
+func (s *Service) Shutdown() {
+	s.getIOCheckPool().shutdown()
+}
+
+// getMaxConcurrentConnectivityChecks returns the max number of arrays checked concurrently for node connectivity
+func (s *Service) getMaxConcurrentConnectivityChecks() int {
+	if s.maxConcurrentConnectivityChecks > 0 {
+		return s.maxConcurrentConnectivityChecks
+	}
+	return identifiers.DefaultPodmonMaxConcurrentConnectivityChecks
+}
+
+// validateVolumesOnArray checks that every volume resides on the array with the given globalID.
+// Metro volumes are accepted when either of their sides resides on the array.
+func validateVolumesOnArray(ctx context.Context, volIDs []string, globalID string, defaultArray *array.PowerStoreArray) error {
+	for _, volID := range volIDs {
+		volumeHandle, err := array.ParseVolumeID(ctx, volID, defaultArray, nil)
+		if err != nil {
+			log.Errorf("failed to parse volumeID %s: %s", volID, err.Error())
+			return err
+		}
+		if volumeHandle.LocalArrayGlobalID != globalID && volumeHandle.RemoteArrayGlobalID != globalID {
+			return status.Errorf(codes.InvalidArgument, "volume %s resides on array %s, not on the requested array %s",
+				volID, volumeHandle.LocalArrayGlobalID, globalID)
+		}
+	}
+	return nil
+}
+
+// checkIfNodeIsConnectedToArrays checks the connectivity of the node, reachable at nodeIP, to every array in arrayIDs
+// in parallel, bounded by the max number of concurrent connectivity checks.
+// Every array is checked so that the status of each of them is reported, and the node is reported as connected
+// when any of the arrays is connected. The 'rep' object will be filled with the aggregated results, whose
+// messages are in the order of arrayIDs regardless of the order the checks complete in.
+func (s *Service) checkIfNodeIsConnectedToArrays(ctx context.Context, arrayIDs []string, nodeID, nodeIP string, rep *podmon.ValidateVolumeHostConnectivityResponse) error {
+	type result struct {
+		index int
+		rep   *podmon.ValidateVolumeHostConnectivityResponse
+		err   error
+	}
+
+	checkCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, s.getMaxConcurrentConnectivityChecks())
+	// buffered so that checks finishing after an error is returned never block
+	results := make(chan result, len(arrayIDs))
+	for i, arrayID := range arrayIDs {
+		go func(index int, arrayID string) {
+			select {
+			case sem <- struct{}{}:
+			case <-checkCtx.Done():
+				log.Debugf("skipping connectivity check for array %s to node %s: %s", arrayID, nodeID, checkCtx.Err())
+				return
+			}
+			defer func() { <-sem }()
+
+			arrayRep := &podmon.ValidateVolumeHostConnectivityResponse{}
+			err := s.checkIfNodeIsConnected(checkCtx, arrayID, nodeID, nodeIP, arrayRep)
+			results <- result{index: index, rep: arrayRep, err: err}
+		}(i, arrayID)
+	}
+
+	// completed checks by index in arrayIDs
+	arrayReps := make([]*podmon.ValidateVolumeHostConnectivityResponse, len(arrayIDs))
+	defer func() {
+		for _, arrayRep := range arrayReps {
+			if arrayRep != nil {
+				rep.Messages = append(rep.Messages, arrayRep.Messages...)
+			}
+		}
+	}()
+
+	rep.Connected = false
+	for range arrayIDs {
+		res := <-results
+		if res.err != nil {
+			return res.err
+		}
+		arrayReps[res.index] = res.rep
+		if res.rep.Connected {
+			rep.Connected = true
+		}
+	}
+	return nil
+}
+
+// checkMetroVolumesConnectivity reports, for every metro volume in volIDs whose both arrays are managed by the driver,
+// whether the node is connected to both arrays, to only one of them (degraded), or to none (disconnected).
+// Arrays successfully checked since 'since' aren't queried again, arrays whose check failed are checked again. The node is reported as connected when it is
+// connected to either side of a metro volume.
+func (s *Service) checkMetroVolumesConnectivity(ctx context.Context, volIDs []string, nodeID, nodeIP string, since time.Time,
+	rep *podmon.ValidateVolumeHostConnectivityResponse,
+) error {
+	isConnected := func(arrayID string) (bool, error) {
+		if cached, ok := s.GetCachedConnectivity(nodeID, arrayID); ok && cached.Error == "" && !cached.CheckedAt.Before(since) {
+			return cached.Connected, nil
+		}
+		arrayRep := &podmon.ValidateVolumeHostConnectivityResponse{}
+		if err := s.checkIfNodeIsConnected(ctx, arrayID, nodeID, nodeIP, arrayRep); err != nil {
+			return false, err
+		}
+		rep.Messages = append(rep.Messages, arrayRep.Messages...)
+		return arrayRep.Connected, nil
+	}
+
+	for _, volID := range volIDs {
+		volume, err := array.ParseVolumeID(ctx, volID, s.DefaultArray(), nil)
+		if err != nil || volume.RemoteArrayGlobalID == "" {
+			continue
+		}
+		if _, err := s.GetOneArray(volume.RemoteArrayGlobalID); err != nil {
+			log.Debugf("remote array %s of metro volume %s is unmanaged, skipping its connectivity check",
+				volume.RemoteArrayGlobalID, volID)
+			continue
+		}
+
+		localConnected, err := isConnected(volume.LocalArrayGlobalID)
+		if err != nil {
+			return err
+		}
+		remoteConnected, err := isConnected(volume.RemoteArrayGlobalID)
+		if err != nil {
+			return err
+		}
+
+		var message string
+		switch {
+		case localConnected && remoteConnected:
+			message = fmt.Sprintf("metro volume %s is connected to node %s through arrays %s and %s",
+				volID, nodeID, volume.LocalArrayGlobalID, volume.RemoteArrayGlobalID)
+			log.Info(message)
+		case localConnected || remoteConnected:
+			up, down := volume.LocalArrayGlobalID, volume.RemoteArrayGlobalID
+			if remoteConnected {
+				up, down = down, up
+			}
+			message = fmt.Sprintf("metro volume %s is degraded on node %s: connected to array %s, not connected to array %s",
+				volID, nodeID, up, down)
+			log.Warn(message)
+		default:
+			message = fmt.Sprintf("metro volume %s is disconnected from node %s: not connected to arrays %s and %s",
+				volID, nodeID, volume.LocalArrayGlobalID, volume.RemoteArrayGlobalID)
+			log.Error(message)
+		}
+		rep.Messages = append(rep.Messages, message)
+		if localConnected || remoteConnected {
+			rep.Connected = true
+		}
+	}
+	return nil
+}
+
+// checkIfNodeIsConnected queries the node 'nodeId', reachable at 'nodeIP', to determine if there is connectivity
+// to the 'arrayId' array. The 'rep' object will be filled with the results of the check.
+func (s *Service) checkIfNodeIsConnected(ctx context.Context, arrayID string, nodeID, nodeIP string, rep *podmon.ValidateVolumeHostConnectivityResponse) error {
 	log.Infof("Checking if array %s is connected to node %s", arrayID, nodeID)
 	var message string
 	rep.Connected = false
 
-	nodeIP := identifiers.GetIPListFromString(nodeID)
-	if len(nodeIP) == 0 {
-		log.Errorf("failed to parse node ID '%s'", nodeID)
-		return fmt.Errorf("failed to parse node ID")
-	}
-	ip := nodeIP[len(nodeIP)-1]
 	// form url to call array on node
-	url := "http://" + ip + identifiers.APIPort + identifiers.ArrayStatus + "/" + arrayID
-	connected, err := s.QueryArrayStatus(ctx, url)
-	if err != nil {
-		message = fmt.Sprintf("connectivity unknown for array %s to node %s due to %s", arrayID, nodeID, err)
-		log.Error(message)
-		rep.Messages = append(rep.Messages, message)
-		log.Errorf("%s", err.Error())
+	url := s.arrayStatusURL(nodeIP, arrayID)
+	start := time.Now()
+	connected, reason, err := s.queryArrayStatusWithReason(ctx, url)
+	s.storeConnectivity(nodeID, arrayID, connected, err)
+
+	arrayName := arrayID
+	if clusterName := s.getArrayClusterName(ctx, arrayID); clusterName != "" {
+		arrayName = fmt.Sprintf("%s (cluster %s)", arrayID, clusterName)
 	}
 
-	if connected {
+	switch {
+	case err != nil:
+		observeConnectivityCheck(arrayID, connectivityResultUnknown, start)
+		message = fmt.Sprintf("array %s is not connected to node %s: status endpoint unreachable: %s", arrayName, nodeID, err)
+		log.Error(message)
+	case connected:
+		observeConnectivityCheck(arrayID, connectivityResultConnected, start)
 		rep.Connected = true
-		message = fmt.Sprintf("array %s is connected to node %s", arrayID, nodeID)
-	} else {
-		message = fmt.Sprintf("array %s is not connected to node %s", arrayID, nodeID)
+		message = fmt.Sprintf("array %s is connected to node %s", arrayName, nodeID)
+		if reason != "" {
+			message += ": " + reason
+		}
+		log.Info(message)
+	default:
+		observeConnectivityCheck(arrayID, connectivityResultDisconnected, start)
+		message = fmt.Sprintf("array %s is not connected to node %s: %s", arrayName, nodeID, reason)
+		log.Info(message)
 	}
-	log.Info(message)
 	rep.Messages = append(rep.Messages, message)
 	return nil
 }
 
 // getIOInProgress attempts to determine if IO has recently occurred for a given volume, volID,
-// and returns a nil error if IO has occurred.
-func getIOInProgress(ctx context.Context, volID string, arrayConfig array.PowerStoreArray, protocol string) (err error) {
+// and returns a nil error if IO has occurred. Otherwise the error wraps ErrNoIOInProgress, or
+// ErrMetricsUnavailable when the metrics of the volume can't be queried. Only the last samples metrics are inspected, or the
+// default number of them when samples isn't positive, and metrics older than maxAge are ignored.
+// Metrics of the given interval are queried, or of the default interval when it is empty.
+func getIOInProgress(ctx context.Context, volID string, arrayConfig array.PowerStoreArray, protocol string,
+	maxAge time.Duration, samples int, interval gopowerstore.MetricsIntervalEnum,
+) (err error) {
+	if interval == "" {
+		interval = identifiers.DefaultPodmonMetricsInterval
+	}
+	if samples <= 0 {
+		samples = identifiers.DefaultPodmonMetricsSampleCount
+	}
 	// Call PerformanceMetricsByVolume  or  PerformanceMetricsByFileSystem in gopowerstore based on the volume type
-	if protocol == "scsi" {
-		resp, err := arrayConfig.Client.PerformanceMetricsByVolume(ctx, volID, gopowerstore.TwentySec)
+	if array.IsBlockProtocol(protocol) {
+		resp, err := arrayConfig.Client.PerformanceMetricsByVolume(ctx, volID, interval)
 		if err != nil {
 			log.Errorf("Error %v while checking IsIOInProgress for array having globalId %s for volumeId %s", err.Error(), arrayConfig.GlobalID, volID)
-			return fmt.Errorf("error %v while while checking IsIOInProgress", err.Error())
+			return fmt.Errorf("%w for volume %s on array %s: %s", ErrMetricsUnavailable, volID, arrayConfig.GlobalID, err.Error())
 		}
-		// check last four entries status recieved in the response
-		for i := len(resp) - 1; i >= (len(resp)-4) && i >= 0; i-- {
-			if resp[i].TotalIops > 0.0 && checkIfEntryIsLatest(resp[i].CommonMetricsFields.Timestamp) {
+		// check the last entries status recieved in the response
+		for i := len(resp) - 1; i >= (len(resp)-samples) && i >= 0; i-- {
+			if resp[i].TotalIops > 0.0 && checkIfEntryIsLatest(resp[i].CommonMetricsFields.Timestamp, maxAge) {
 				return nil
 			}
 		}
-		return fmt.Errorf("no IOInProgress for volume %s on array %s", volID, arrayConfig.GlobalID)
+		return fmt.Errorf("%w for volume %s on array %s", ErrNoIOInProgress, volID, arrayConfig.GlobalID)
 	}
 	// nfs volume type logic
-	resp, err := arrayConfig.Client.PerformanceMetricsByFileSystem(ctx, volID, gopowerstore.TwentySec)
+	resp, err := arrayConfig.Client.PerformanceMetricsByFileSystem(ctx, volID, interval)
 	if err != nil {
 		log.Errorf("Error %v while checking IsIOInProgress for array having globalId %s for volumeId %s", err.Error(), arrayConfig.GlobalID, volID)
-		return fmt.Errorf("error %v while while checking IsIOInProgress", err.Error())
+		return fmt.Errorf("%w for volume %s on array %s: %s", ErrMetricsUnavailable, volID, arrayConfig.GlobalID, err.Error())
 	}
-	// check last four entries status recieved in the response
-	for i := len(resp) - 1; i >= len(resp)-4 && i >= 0; i-- {
-		if resp[i].TotalIops > 0.0 && checkIfEntryIsLatest(resp[i].CommonMetricsFields.Timestamp) {
+	// check the last entries status recieved in the response
+	for i := len(resp) - 1; i >= len(resp)-samples && i >= 0; i-- {
+		if resp[i].TotalIops > 0.0 && checkIfEntryIsLatest(resp[i].CommonMetricsFields.Timestamp, maxAge) {
 			return nil
 		}
 	}
-	return fmt.Errorf("no IOInProgress for volume %s on array %s", volID, arrayConfig.GlobalID)
+	return fmt.Errorf("%w for volume %s on array %s", ErrNoIOInProgress, volID, arrayConfig.GlobalID)
 }
 
-func checkIfEntryIsLatest(timestamp strfmt.DateTime) bool {
+func checkIfEntryIsLatest(timestamp strfmt.DateTime, maxAge time.Duration) bool {
 	RFC3339MillisNoColon := "2006-01-02T15:04:05Z"
 	stringTime := timestamp.String()
 	timeFromResponse, err := time.Parse(RFC3339MillisNoColon, stringTime)
@@ -463,7 +1410,7 @@ func checkIfEntryIsLatest(timestamp strfmt.DateTime) bool {
 	log.Debugf("timestamp recieved from the response body is %v", timeFromResponse)
 	currentTime := time.Now().UTC()
 	log.Debugf("current time %v", currentTime)
-	if currentTime.Sub(timeFromResponse).Seconds() < 60 {
+	if currentTime.Sub(timeFromResponse) < maxAge {
 		log.Debug("found a fresh metric")
 		return true
 	}
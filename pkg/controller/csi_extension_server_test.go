@@ -21,16 +21,22 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
+	commonext "github.com/dell/dell-csi-extensions/common"
 	podmon "github.com/dell/dell-csi-extensions/podmon"
 	vgsext "github.com/dell/dell-csi-extensions/volumeGroupSnapshot"
 	"github.com/dell/gopowerstore"
@@ -40,8 +46,13 @@ import (
 	"github.com/google/uuid"
 	ginkgo "github.com/onsi/ginkgo"
 	gomega "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
 )
 
 const (
@@ -49,14 +60,12 @@ const (
 )
 
 var nodeConnectivityServer = struct {
-	port       string
-	statusPath string
+	port string
 }{
-	port:       "9028",
-	statusPath: "/array-status",
+	port: "9028",
 }
 
-var arrayOneStatusEndpoint = filepath.Join(nodeConnectivityServer.statusPath, firstValidID)
+var arrayOneStatusEndpoint = identifiers.ArrayStatusEndpoint(firstValidID)
 
 func getActiveIOVolumeMetrics() []gopowerstore.PerformanceMetricsByVolumeResponse {
 	volumeMetrics := make([]gopowerstore.PerformanceMetricsByVolumeResponse, 6)
@@ -126,6 +135,36 @@ func startNodeConnectivityCheckerServer(port string, endpoints ...string) {
 	}()
 }
 
+func connectivityCheckCount(globalID, result string) float64 {
+	m := &dto.Metric{}
+	_ = connectivityCheckResults.WithLabelValues(globalID, result).Write(m)
+	return m.GetCounter().GetValue()
+}
+
+func cacheLookupCount(cache, result string) float64 {
+	m := &dto.Metric{}
+	_ = cacheLookups.WithLabelValues(cache, result).Write(m)
+	return m.GetCounter().GetValue()
+}
+
+func cacheEntryCount(cache string) float64 {
+	m := &dto.Metric{}
+	_ = cacheEntries.WithLabelValues(cache).Write(m)
+	return m.GetGauge().GetValue()
+}
+
+func connectivityCheckLatencyCount(globalID string) uint64 {
+	m := &dto.Metric{}
+	_ = connectivityCheckDuration.WithLabelValues(globalID).(prometheus.Metric).Write(m)
+	return m.GetHistogram().GetSampleCount()
+}
+
+func validateHostConnectivityLatencyCount() uint64 {
+	m := &dto.Metric{}
+	_ = validateHostConnectivityDuration.Write(m)
+	return m.GetHistogram().GetSampleCount()
+}
+
 var _ = ginkgo.Describe("csi-extension-server", func() {
 	ginkgo.BeforeSuite(func() {
 		startNodeConnectivityCheckerServer(nodeConnectivityServer.port, arrayOneStatusEndpoint)
@@ -133,9 +172,16 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 
 	ginkgo.BeforeEach(func() {
 		setVariables()
+		// connectivity messages name the cluster of the array when it can be looked up
+		clientMock.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{}, gopowerstore.NewNotFoundError()).Maybe()
 	})
 
 	ginkgo.Describe("calling ValidateVolumeHostConnectivity()", func() {
+		ginkgo.BeforeEach(func() {
+			clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validBaseVolID).
+				Return(gopowerstore.ReplicationSession{Role: string(gopowerstore.ReplicationRoleMetroPreferred)}, nil).Maybe()
+		})
+
 		ginkgo.When("checking if ValidateVolumeHostConnectivity is implemented ", func() {
 			ginkgo.It("should return a message that ValidateVolumeHostConnectivity is implemented", func() {
 				req := &podmon.ValidateVolumeHostConnectivityRequest{}
@@ -175,17 +221,126 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 			})
 		})
 
-		ginkgo.When("the request contains a metro volumeID with an invalid remote arrayID", func() {
-			ginkgo.It("should return an error", func() {
+		ginkgo.When("the request contains a volumeID on another array than the requested one", func() {
+			ginkgo.It("should fail before checking the node connectivity", func() {
 				req := &podmon.ValidateVolumeHostConnectivityRequest{
-					ArrayId:   "default",
-					VolumeIds: []string{invalidMetroBlockVolumeID},
+					ArrayId:   secondValidID,
+					VolumeIds: []string{validBlockVolumeID},
 					NodeId:    validNodeID,
 				}
 
 				res, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
 				gomega.Expect(res).To(gomega.BeNil())
-				gomega.Expect(err).ToNot(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+					fmt.Sprintf("resides on array %s, not on the requested array %s", firstValidID, secondValidID)))
+				_, checked := ctrlSvc.GetCachedConnectivity(validNodeID, secondValidID)
+				gomega.Expect(checked).To(gomega.BeFalse())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should fail when only one of several volumes is on another array", func() {
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					ArrayId:   firstValidID,
+					VolumeIds: []string{validBlockVolumeID, invalidBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				res, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				_, checked := ctrlSvc.GetCachedConnectivity(validNodeID, firstValidID)
+				gomega.Expect(checked).To(gomega.BeFalse())
+			})
+
+			ginkgo.It("should accept a metro volume whose remote side is on the requested array", func() {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything).
+					Return(getInactiveIOVolumeMetrics(), nil)
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					ArrayId:   secondValidID,
+					VolumeIds: []string{validMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				_, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+			})
+		})
+
+		ginkgo.When("metrics freshness thresholds differ per protocol", func() {
+			metricAge := 90 * time.Second
+			getTimestamp := func() strfmt.DateTime {
+				ts, _ := strfmt.ParseDateTime(time.Now().UTC().Add(-metricAge).Format("2006-01-02T15:04:05Z"))
+				return ts
+			}
+
+			ginkgo.BeforeEach(func() {
+				ctrlSvc.blockMetricsMaxAge = time.Minute
+				ctrlSvc.nfsMetricsMaxAge = 2 * time.Minute
+			})
+
+			ginkgo.It("should count an nfs metric that is fresh under the nfs threshold", func() {
+				resp := make([]gopowerstore.PerformanceMetricsByFileSystemResponse, 1)
+				resp[0].TotalIops = 4.9
+				resp[0].CommonMetricsFields.Timestamp = getTimestamp()
+				clientMock.On("PerformanceMetricsByFileSystem", mock.Anything, validBaseVolID, mock.Anything).
+					Return(resp, nil)
+
+				res, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validNfsVolumeID},
+					NodeId:    validNodeID,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.IosInProgress).To(gomega.BeTrue())
+			})
+
+			ginkgo.It("should not count a block metric of the same age", func() {
+				resp := make([]gopowerstore.PerformanceMetricsByVolumeResponse, 1)
+				resp[0].TotalIops = 4.9
+				resp[0].CommonMetricsFields.Timestamp = getTimestamp()
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).
+					Return(resp, nil)
+
+				res, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validBlockVolumeID},
+					NodeId:    validNodeID,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.IosInProgress).To(gomega.BeFalse())
+			})
+		})
+
+		ginkgo.When("the request contains a metro volumeID with an unconfigured remote arrayID", func() {
+			ginkgo.It("should check only the local side", func() {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).Times(1).
+					Return(getActiveIOVolumeMetrics(), nil)
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					ArrayId:   firstValidID,
+					VolumeIds: []string{invalidMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				res, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.IosInProgress).To(gomega.BeTrue())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "PerformanceMetricsByVolume", mock.Anything, validRemoteVolID, mock.Anything)
+			})
+
+			ginkgo.It("should report IO is not in-progress when the local side is idle", func() {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).Times(1).
+					Return(getInactiveIOVolumeMetrics(), nil)
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{invalidMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				res, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.IosInProgress).To(gomega.BeFalse())
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "PerformanceMetricsByVolume", 1)
 			})
 		})
 
@@ -222,6 +377,31 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				_, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
 				gomega.Expect(err).ToNot(gomega.BeNil())
 			})
+
+			ginkgo.It("should use the address of the kubernetes node when node IPs are looked up in kubernetes", func() {
+				ctrlSvc.nodeIPs = newK8sNodeIPSource(func(_ context.Context) ([]corev1.Node, error) {
+					return []corev1.Node{newTestK8sNode("003c684ccb0c4ca0a9c99423563dfd2c",
+						corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "127.0.0.1"})}, nil
+				})
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					ArrayId: firstValidID,
+					NodeId:  "csi-node-003c684ccb0c4ca0a9c99423563dfd2c-@@@",
+				}
+				res, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Connected).To(gomega.BeTrue())
+			})
+
+			ginkgo.It("should reject a node ID without a prefix before querying the node", func() {
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					ArrayId: firstValidID,
+					NodeId:  "003c684ccb0c4ca0a9c99423563dfd2c-127.0.0.1",
+				}
+				res, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("failed to parse node ID"))
+			})
 		})
 
 		ginkgo.When("the request has a volume ID but no array ID and no IO is in progress", func() {
@@ -246,6 +426,26 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 			})
 		})
 
+		ginkgo.When("the request has a legacy volume ID", func() {
+			ginkgo.It("should report the resolved protocol and array", func() {
+				clientMock.On("GetVolume", context.Background(), validLegacyVolID).Return(gopowerstore.Volume{ApplianceID: validApplianceID}, nil)
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validLegacyVolID, mock.Anything).
+					Return(getInactiveIOVolumeMetrics(), nil)
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).
+					Return(getInactiveIOVolumeMetrics(), nil)
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validLegacyVolID, validBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(fmt.Sprintf(
+					"legacy volume %s resolved to protocol scsi on array %s", validLegacyVolID, ctrlSvc.DefaultArray().GlobalID)))
+				gomega.Expect(response.Messages).ToNot(gomega.ContainElement(gomega.ContainSubstring(validBlockVolumeID)))
+			})
+		})
+
 		ginkgo.When("not sending arrayId in request body and default array is connected well and IO operation is also there ", func() {
 			ginkgo.It("should return IO in-progress", func() {
 				clientMock.On("GetVolume", context.Background(), mock.Anything).Return(gopowerstore.Volume{ApplianceID: validApplianceID}, nil)
@@ -280,6 +480,70 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 			})
 		})
 
+		ginkgo.When("checking IO for metro and non-metro volumes", func() {
+			ginkgo.It("should not exceed the max number of concurrent IO checks", func() {
+				ctrlSvc.maxConcurrentIOChecks = 2
+
+				var inFlight, maxInFlight int32
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything).
+					Run(func(_ mock.Arguments) {
+						cur := atomic.AddInt32(&inFlight, 1)
+						for {
+							prev := atomic.LoadInt32(&maxInFlight)
+							if cur <= prev || atomic.CompareAndSwapInt32(&maxInFlight, prev, cur) {
+								break
+							}
+						}
+						time.Sleep(50 * time.Millisecond)
+						atomic.AddInt32(&inFlight, -1)
+					}).
+					Return(getInactiveIOVolumeMetrics(), nil)
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{
+						validMetroBlockVolumeID,
+						validBlockVolumeID,
+						filepath.Join("other-vol-1", firstValidID, "scsi"),
+						filepath.Join("other-vol-2", secondValidID, "scsi"),
+					},
+					NodeId: validNodeID,
+				}
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.IosInProgress).To(gomega.BeFalse())
+				// metro volume is checked on both sides
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "PerformanceMetricsByVolume", 5)
+				gomega.Expect(atomic.LoadInt32(&maxInFlight)).To(gomega.BeNumerically("<=", 2))
+				gomega.Expect(atomic.LoadInt32(&maxInFlight)).To(gomega.BeNumerically(">", 0))
+			})
+		})
+
+		ginkgo.When("checking IO in several requests", func() {
+			ginkgo.It("should reuse the same IO check pool", func() {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything).
+					Return(getInactiveIOVolumeMetrics(), nil)
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				_, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				pool := ctrlSvc.ioCheckPool
+				gomega.Expect(pool).ToNot(gomega.BeNil())
+
+				_, err = ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(ctrlSvc.ioCheckPool).To(gomega.BeIdenticalTo(pool))
+				gomega.Expect(cap(pool.slots)).To(gomega.Equal(ctrlSvc.getMaxConcurrentIOChecks()))
+
+				ctrlSvc.Shutdown()
+				gomega.Expect(pool.acquire(context.Background())).To(gomega.BeFalse())
+			})
+		})
+
 		ginkgo.When("the preferred array of a metro volume is disconnected, but the non-preferred is connected", func() {
 			ginkgo.It("should report IO is in-progress", func() {
 				// preferred side will have no IO in-progress
@@ -324,6 +588,24 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 			})
 		})
 
+		ginkgo.When("the metrics of a volume can't be queried", func() {
+			ginkgo.It("should report that the array may be unreachable", func() {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).Times(1).
+					Return(nil, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusServiceUnavailable}})
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.IosInProgress).To(gomega.BeFalse())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(
+					gomega.HavePrefix("performance metrics unavailable for 1 of 1 IO checks")))
+			})
+		})
+
 		ginkgo.When("context times out for both arrays of a metro volume", func() {
 			ginkgo.It("should report IO is not in-progress", func() {
 				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).After(time.Second*11).Times(1).
@@ -346,6 +628,62 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 			})
 		})
 
+		ginkgo.When("all IO checks of a metro volume time out", func() {
+			timeOutBothSides := func() *podmon.ValidateVolumeHostConnectivityRequest {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).After(time.Second*2).Times(1).
+					Return(getActiveIOVolumeMetrics(), nil)
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validRemoteVolID, mock.Anything).After(time.Second*2).Times(1).
+					Return(getActiveIOVolumeMetrics(), nil)
+				return &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+			}
+
+			ginkgo.It("should report IO is not in-progress by default", func() {
+				req := timeOutBothSides()
+
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				defer cancel()
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(ctx, req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.IosInProgress).To(gomega.BeFalse())
+			})
+
+			ginkgo.It("should report IO is in-progress when configured to fail safe", func() {
+				ctrlSvc.reportIOOnCheckTimeout = true
+				req := timeOutBothSides()
+
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				defer cancel()
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(ctx, req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.IosInProgress).To(gomega.BeTrue())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(gomega.HavePrefix("all IO checks timed out")))
+			})
+
+			ginkgo.It("should report IO is not in-progress when configured to fail safe but one side answered", func() {
+				ctrlSvc.reportIOOnCheckTimeout = true
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).After(time.Second*2).Times(1).
+					Return(getActiveIOVolumeMetrics(), nil)
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validRemoteVolID, mock.Anything).Times(1).
+					Return(getInactiveIOVolumeMetrics(), nil)
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				defer cancel()
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(ctx, req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.IosInProgress).To(gomega.BeFalse())
+			})
+		})
+
 		ginkgo.When("at least one volume has IO in-progress", func() {
 			ginkgo.It("should report IO is in-progress", func() {
 				activeVolumeMetrics := getActiveIOVolumeMetrics()
@@ -373,73 +711,629 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				gomega.Expect(response.IosInProgress).To(gomega.BeTrue())
 			})
 		})
-	})
 
-	ginkgo.Describe("calling IsIOInProgress and QueryArrayStatus", func() {
-		ginkgo.When("IOConnectivity for scsi type volume on array", func() {
-			ginkgo.It("should not fail", func() {
-				var resp []gopowerstore.PerformanceMetricsByVolumeResponse
-				clientMock.On("PerformanceMetricsByVolume", context.Background(), mock.Anything, mock.Anything).
-					Return(resp, gopowerstore.APIError{
-						ErrorMsg: &api.ErrorMsg{
-							StatusCode: http.StatusInternalServerError,
-						},
-					})
-				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "scsi")
-				gomega.Expect(err).ToNot(gomega.BeNil())
+		ginkgo.When("only the remote side of a metro volume has IO in progress", func() {
+			ginkgo.It("should report IO in progress when both halves of the handle parse", func() {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).
+					Return(getInactiveIOVolumeMetrics(), nil)
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validRemoteVolID, mock.Anything).
+					Return(getActiveIOVolumeMetrics(), nil)
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.IosInProgress).To(gomega.BeTrue())
 			})
-		})
 
-		ginkgo.When("IOConnectivity for nfs type volume on array", func() {
-			ginkgo.It("should not fail", func() {
-				var resp []gopowerstore.PerformanceMetricsByFileSystemResponse
-				clientMock.On("PerformanceMetricsByFileSystem", context.Background(), mock.Anything, mock.Anything).
-					Return(resp, gopowerstore.APIError{
-						ErrorMsg: &api.ErrorMsg{
-							StatusCode: http.StatusInternalServerError,
-						},
-					})
-				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "nfs")
-				gomega.Expect(err).ToNot(gomega.BeNil())
+			ginkgo.It("should report IO in progress when the local half of the handle doesn't parse", func() {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validRemoteVolID, mock.Anything).
+					Return(getActiveIOVolumeMetrics(), nil)
+
+				// the local half references an array IP that isn't configured
+				volID := validBaseVolID + "/10.9.9.9/scsi:" + validRemoteVolID + "/" + secondValidID
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{volID},
+					NodeId:    validNodeID,
+				}
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.IosInProgress).To(gomega.BeTrue())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(
+					"local half of metro volume " + volID + " can't be parsed, checking volume activity on remote array " +
+						secondValidID + " only"))
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything)
 			})
-		})
 
-		ginkgo.When("IOConnectivity for scsi type volume on array when IO operation is not there", func() {
-			ginkgo.It("should not fail", func() {
-				resp := make([]gopowerstore.PerformanceMetricsByVolumeResponse, 6)
-				resp[0].TotalIops = 0.0
-				resp[1].TotalIops = 0.0
-				resp[2].TotalIops = 0.0
-				resp[3].TotalIops = 0.0
-				resp[4].TotalIops = 0.0
-				resp[5].TotalIops = 0.0
-				clientMock.On("PerformanceMetricsByVolume", context.Background(), mock.Anything, mock.Anything).
-					Return(resp, nil)
-				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "scsi")
-				gomega.Expect(err).ToNot(gomega.BeNil())
+			ginkgo.It("should fail when neither half of the handle parses", func() {
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validBaseVolID + "/10.9.9.9/scsi:" + validRemoteVolID},
+					NodeId:    validNodeID,
+				}
+
+				_, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
 			})
 		})
 
-		ginkgo.When("IOConnectivity for scsi type volume on array when IO operation is there", func() {
-			ginkgo.It("should not fail", func() {
-				resp := make([]gopowerstore.PerformanceMetricsByVolumeResponse, 6)
-				freshTime, _ := strfmt.ParseDateTime(fmt.Sprint(time.Now().UTC().Format("2006-01-02T15:04:05Z")))
-				resp[0].TotalIops = 0.0
-				resp[1].TotalIops = 0.0
-				resp[2].TotalIops = 4.9
-				resp[2].CommonMetricsFields.Timestamp = freshTime
-				resp[3].TotalIops = 0.0
-				resp[4].CommonMetricsFields.Timestamp = freshTime
-				resp[4].TotalIops = 4.6
-				resp[5].TotalIops = 0.0
-				clientMock.On("PerformanceMetricsByVolume", context.Background(), mock.Anything, mock.Anything).
-					Return(resp, nil)
-				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "scsi")
+		ginkgo.When("the validation budget runs out", func() {
+			manyVolumes := func(n int) []string {
+				volIDs := make([]string, 0, n)
+				for i := 0; i < n; i++ {
+					volIDs = append(volIDs, filepath.Join(uuid.New().String(), firstValidID, "scsi"))
+				}
+				return volIDs
+			}
+
+			ginkgo.It("should reply with the determination made so far", func() {
+				ctrlSvc.validationBudget = 300 * time.Millisecond
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything).After(2*time.Second).
+					Return(getActiveIOVolumeMetrics(), nil)
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: manyVolumes(30),
+					NodeId:    validNodeID,
+				}
+
+				start := time.Now()
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
 				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(time.Since(start)).To(gomega.BeNumerically("<", time.Second))
+				gomega.Expect(response.IosInProgress).To(gomega.BeFalse())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(
+					"validation budget of 300ms exhausted, reporting the determination made so far"))
 			})
-		})
 
-		ginkgo.When("IOConnectivity for scsi type volume on array when IO operation is there but entry is not fresh", func() {
+			ginkgo.It("should still report IO in progress as soon as it is detected", func() {
+				ctrlSvc.validationBudget = 5 * time.Second
+				ctrlSvc.maxConcurrentIOChecks = 50
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).
+					Return(getActiveIOVolumeMetrics(), nil)
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything).After(5*time.Second).
+					Return(getInactiveIOVolumeMetrics(), nil).Maybe()
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: append(manyVolumes(30), validBaseVolID+"/"+firstValidID+"/scsi"),
+					NodeId:    validNodeID,
+				}
+
+				start := time.Now()
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(time.Since(start)).To(gomega.BeNumerically("<", 2*time.Second))
+				gomega.Expect(response.IosInProgress).To(gomega.BeTrue())
+				gomega.Expect(response.Messages).NotTo(gomega.ContainElement(gomega.HavePrefix("validation budget of")))
+			})
+		})
+	})
+
+	ginkgo.Describe("calling IsVolumeGroupIOInProgress", func() {
+		group := gopowerstore.VolumeGroup{ID: validGroupID, Volumes: []gopowerstore.Volume{{ID: "idle-member"}, {ID: "active-member"}}}
+
+		ginkgo.When("one member of the group has IO in progress", func() {
+			ginkgo.It("should report IO in progress", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(group, nil)
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, "idle-member", gopowerstore.TwentySec).
+					Return(getInactiveIOVolumeMetrics(), nil).Maybe()
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, "active-member", gopowerstore.TwentySec).
+					Return(getActiveIOVolumeMetrics(), nil)
+
+				active, err := ctrlSvc.IsVolumeGroupIOInProgress(context.Background(), firstValidID, validGroupID)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(active).To(gomega.BeTrue())
+			})
+		})
+
+		ginkgo.When("no member of the group has IO in progress", func() {
+			ginkgo.It("should not report IO in progress", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(group, nil)
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, mock.Anything, gopowerstore.TwentySec).
+					Return(getInactiveIOVolumeMetrics(), nil)
+
+				active, err := ctrlSvc.IsVolumeGroupIOInProgress(context.Background(), firstValidID, validGroupID)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(active).To(gomega.BeFalse())
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "PerformanceMetricsByVolume", 2)
+			})
+		})
+
+		ginkgo.When("the group has no members", func() {
+			ginkgo.It("should not report IO in progress", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.VolumeGroup{ID: validGroupID}, nil)
+
+				active, err := ctrlSvc.IsVolumeGroupIOInProgress(context.Background(), firstValidID, validGroupID)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(active).To(gomega.BeFalse())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		ginkgo.When("the group can't be fetched", func() {
+			ginkgo.It("should fail", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.VolumeGroup{}, errors.New("api error"))
+
+				_, err := ctrlSvc.IsVolumeGroupIOInProgress(context.Background(), firstValidID, validGroupID)
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.Internal))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("failed to get volume group"))
+			})
+		})
+
+		ginkgo.When("the array is unknown", func() {
+			ginkgo.It("should fail", func() {
+				_, err := ctrlSvc.IsVolumeGroupIOInProgress(context.Background(), "unknown-array", validGroupID)
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.NotFound))
+			})
+		})
+	})
+
+	ginkgo.Describe("checking node connectivity to several arrays", func() {
+		ginkgo.When("only some of the arrays are connected to the node", func() {
+			ginkgo.It("should report the node as connected", func() {
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).
+					Return(getInactiveIOVolumeMetrics(), nil)
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validBlockVolumeID, filepath.Join(validBaseVolID, secondValidID, "scsi")},
+					NodeId:    validNodeID,
+				}
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.Connected).To(gomega.BeTrue())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(
+					fmt.Sprintf("array %s is connected to node %s", firstValidID, validNodeID)))
+			})
+
+			ginkgo.It("should report the node as connected with a single connectivity check at a time", func() {
+				ctrlSvc.maxConcurrentConnectivityChecks = 1
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+
+				err := ctrlSvc.checkIfNodeIsConnectedToArrays(context.Background(),
+					[]string{secondValidID, "globalvolid3", firstValidID}, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(rep.Connected).To(gomega.BeTrue())
+				gomega.Expect(rep.Messages).To(gomega.ContainElement(
+					fmt.Sprintf("array %s is connected to node %s", firstValidID, validNodeID)))
+			})
+
+			ginkgo.It("should report the status of every array when only some of them are connected", func() {
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+
+				err := ctrlSvc.checkIfNodeIsConnectedToArrays(context.Background(),
+					[]string{firstValidID, secondValidID, "globalvolid3"}, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(rep.Connected).To(gomega.BeTrue())
+				gomega.Expect(rep.Messages).To(gomega.HaveLen(3))
+				gomega.Expect(rep.Messages[0]).To(gomega.Equal(
+					fmt.Sprintf("array %s is connected to node %s", firstValidID, validNodeID)))
+				gomega.Expect(rep.Messages[1]).To(gomega.HavePrefix(
+					fmt.Sprintf("array %s is not connected to node %s: status endpoint unreachable: ", secondValidID, validNodeID)))
+				gomega.Expect(rep.Messages[2]).To(gomega.HavePrefix(
+					fmt.Sprintf("array %s is not connected to node %s: status endpoint unreachable: ", "globalvolid3", validNodeID)))
+			})
+		})
+
+		ginkgo.When("connectivity has been checked", func() {
+			ginkgo.It("should cache the last status of every checked array", func() {
+				_, ok := ctrlSvc.GetCachedConnectivity(validNodeID, firstValidID)
+				gomega.Expect(ok).To(gomega.BeFalse())
+
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+				err := ctrlSvc.checkIfNodeIsConnected(context.Background(), firstValidID, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				err = ctrlSvc.checkIfNodeIsConnected(context.Background(), secondValidID, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+
+				cached, ok := ctrlSvc.GetCachedConnectivity(validNodeID, firstValidID)
+				gomega.Expect(ok).To(gomega.BeTrue())
+				gomega.Expect(cached.Connected).To(gomega.BeTrue())
+
+				cached, ok = ctrlSvc.GetCachedConnectivity(validNodeID, secondValidID)
+				gomega.Expect(ok).To(gomega.BeTrue())
+				gomega.Expect(cached.Connected).To(gomega.BeFalse())
+				gomega.Expect(cached.Error).ToNot(gomega.BeEmpty())
+			})
+		})
+
+		ginkgo.When("none of the arrays are connected to the node", func() {
+			ginkgo.It("should report the node as not connected with a message per array", func() {
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+
+				err := ctrlSvc.checkIfNodeIsConnectedToArrays(context.Background(),
+					[]string{secondValidID, "globalvolid3"}, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(rep.Connected).To(gomega.BeFalse())
+				gomega.Expect(rep.Messages).To(gomega.HaveLen(2))
+				gomega.Expect(rep.Messages).To(gomega.ContainElements(
+					gomega.HavePrefix(fmt.Sprintf("array %s is not connected to node %s: status endpoint unreachable: ", secondValidID, validNodeID)),
+					gomega.HavePrefix(fmt.Sprintf("array %s is not connected to node %s: status endpoint unreachable: ", "globalvolid3", validNodeID))))
+			})
+		})
+
+		ginkgo.When("the node reports a stale last success for the array", func() {
+			ginkgo.It("should report the age of the last success as the reason", func() {
+				staleArrayID := "globalvolid-stale"
+				var status identifiers.ArrayConnectivityStatus
+				status.LastAttempt = time.Now().Unix()
+				status.LastSuccess = time.Now().Unix() - 100
+				input, _ := json.Marshal(status)
+				http.HandleFunc(identifiers.ArrayStatusEndpoint(staleArrayID), func(w http.ResponseWriter, _ *http.Request) {
+					w.Write(input)
+				})
+
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+				err := ctrlSvc.checkIfNodeIsConnected(context.Background(), staleArrayID, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(rep.Connected).To(gomega.BeFalse())
+				gomega.Expect(rep.Messages).To(gomega.HaveLen(1))
+				gomega.Expect(rep.Messages[0]).To(gomega.HavePrefix(
+					fmt.Sprintf("array %s is not connected to node %s: last successful connectivity test was ", staleArrayID, validNodeID)))
+				gomega.Expect(rep.Messages[0]).ToNot(gomega.ContainSubstring("unreachable"))
+			})
+		})
+
+		ginkgo.When("the node reports its active data paths to the array", func() {
+			serveDataPaths := func(arrayID string, paths int) {
+				status := identifiers.ArrayConnectivityStatus{
+					LastAttempt:     time.Now().Unix(),
+					LastSuccess:     time.Now().Unix(),
+					ActiveDataPaths: &paths,
+				}
+				input, _ := json.Marshal(status)
+				http.HandleFunc(identifiers.ArrayStatusEndpoint(arrayID), func(w http.ResponseWriter, _ *http.Request) {
+					w.Write(input)
+				})
+			}
+
+			ginkgo.It("should include the number of active paths in the message", func() {
+				arrayID := "globalvolid-datapaths"
+				serveDataPaths(arrayID, 4)
+
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+				err := ctrlSvc.checkIfNodeIsConnected(context.Background(), arrayID, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(rep.Connected).To(gomega.BeTrue())
+				gomega.Expect(rep.Messages).To(gomega.ConsistOf(
+					fmt.Sprintf("array %s is connected to node %s: 4 active data paths", arrayID, validNodeID)))
+			})
+
+			ginkgo.It("should report the node as not connected when there are no active paths", func() {
+				arrayID := "globalvolid-no-datapaths"
+				serveDataPaths(arrayID, 0)
+
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+				err := ctrlSvc.checkIfNodeIsConnected(context.Background(), arrayID, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(rep.Connected).To(gomega.BeFalse())
+				gomega.Expect(rep.Messages).To(gomega.ConsistOf(
+					fmt.Sprintf("array %s is not connected to node %s: no active data paths to the array", arrayID, validNodeID)))
+			})
+		})
+
+		ginkgo.When("the node is connected to none of many arrays", func() {
+			ginkgo.It("should retain every not connected message beyond the message cap", func() {
+				arrays := ctrlSvc.Arrays()
+				arrayIDs := make([]string, 0, 6)
+				for i := 0; i < 6; i++ {
+					arrayID := fmt.Sprintf("globalvolid-unreachable-%d", i)
+					arrays[arrayID] = &array.PowerStoreArray{GlobalID: arrayID, Client: clientMock}
+					arrayIDs = append(arrayIDs, arrayID)
+				}
+				ctrlSvc.SetArrays(arrays)
+				ctrlSvc.maxConnectivityMessages = 2
+
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+				err := ctrlSvc.checkIfNodeIsConnectedToArrays(context.Background(), arrayIDs, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				messages := capConnectivityMessages(rep.Messages, ctrlSvc.getMaxConnectivityMessages())
+				gomega.Expect(messages).To(gomega.HaveLen(len(arrayIDs)))
+				for _, arrayID := range arrayIDs {
+					gomega.Expect(messages).To(gomega.ContainElement(gomega.HavePrefix(
+						fmt.Sprintf("array %s is not connected to node %s", arrayID, validNodeID))))
+				}
+			})
+		})
+
+		ginkgo.When("several arrays are checked in parallel", func() {
+			ginkgo.It("should report the messages of every array in the order of the arrays", func() {
+				arrayIDs := []string{"globalvolid-parallel-a", "globalvolid-parallel-b", "globalvolid-parallel-c"}
+				for i, arrayID := range arrayIDs {
+					status := identifiers.ArrayConnectivityStatus{LastAttempt: time.Now().Unix(), LastSuccess: time.Now().Unix() - 100}
+					input, _ := json.Marshal(status)
+					// the first array answers last
+					delay := time.Duration(len(arrayIDs)-i) * 20 * time.Millisecond
+					http.HandleFunc(identifiers.ArrayStatusEndpoint(arrayID), func(w http.ResponseWriter, _ *http.Request) {
+						time.Sleep(delay)
+						w.Write(input)
+					})
+				}
+				ctrlSvc.maxConcurrentConnectivityChecks = len(arrayIDs)
+
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+				err := ctrlSvc.checkIfNodeIsConnectedToArrays(context.Background(), arrayIDs, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(rep.Connected).To(gomega.BeFalse())
+				gomega.Expect(rep.Messages).To(gomega.HaveLen(len(arrayIDs)))
+				for i, arrayID := range arrayIDs {
+					gomega.Expect(rep.Messages[i]).To(gomega.HavePrefix(
+						fmt.Sprintf("array %s is not connected to node %s: last successful connectivity test was ", arrayID, validNodeID)))
+				}
+			})
+		})
+
+		ginkgo.When("the cluster of the array can be looked up", func() {
+			ginkgo.It("should name the cluster in the message and look it up only once", func() {
+				arrayID := "globalvolid-cluster"
+				status := identifiers.ArrayConnectivityStatus{LastAttempt: time.Now().Unix(), LastSuccess: time.Now().Unix()}
+				input, _ := json.Marshal(status)
+				http.HandleFunc(identifiers.ArrayStatusEndpoint(arrayID), func(w http.ResponseWriter, _ *http.Request) {
+					w.Write(input)
+				})
+				client := new(gopowerstoremock.Client)
+				client.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{Name: validClusterName}, nil).Once()
+				arrays := ctrlSvc.Arrays()
+				arrays[arrayID] = &array.PowerStoreArray{GlobalID: arrayID, Client: client}
+				ctrlSvc.SetArrays(arrays)
+
+				for i := 0; i < 2; i++ {
+					rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+					err := ctrlSvc.checkIfNodeIsConnected(context.Background(), arrayID, validNodeID, validNodeIP, rep)
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(rep.Messages).To(gomega.ConsistOf(
+						fmt.Sprintf("array %s (cluster %s) is connected to node %s", arrayID, validClusterName, validNodeID)))
+				}
+				client.AssertNumberOfCalls(ginkgo.GinkgoT(), "GetCluster", 1)
+			})
+		})
+
+		ginkgo.When("the cluster of the array can't be looked up", func() {
+			ginkgo.It("should only name the array in the message", func() {
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+				err := ctrlSvc.checkIfNodeIsConnected(context.Background(), firstValidID, validNodeID, validNodeIP, rep)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(rep.Messages).To(gomega.ConsistOf(
+					fmt.Sprintf("array %s is connected to node %s", firstValidID, validNodeID)))
+				clientMock.AssertCalled(ginkgo.GinkgoT(), "GetCluster", mock.Anything)
+			})
+		})
+
+		ginkgo.When("the request contains metro volumes", func() {
+			addMetroArray := func(arrayID string, reachable bool) {
+				if reachable {
+					status := identifiers.ArrayConnectivityStatus{LastAttempt: time.Now().Unix(), LastSuccess: time.Now().Unix()}
+					input, _ := json.Marshal(status)
+					http.HandleFunc(identifiers.ArrayStatusEndpoint(arrayID), func(w http.ResponseWriter, _ *http.Request) {
+						w.Write(input)
+					})
+				}
+				arrays := ctrlSvc.Arrays()
+				arrays[arrayID] = &array.PowerStoreArray{GlobalID: arrayID, Client: clientMock}
+				ctrlSvc.SetArrays(arrays)
+			}
+
+			ginkgo.BeforeEach(func() {
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.ReplicationSession{Role: string(gopowerstore.ReplicationRoleMetroPreferred)}, nil).Maybe()
+				clientMock.On("PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything).
+					Return(getInactiveIOVolumeMetrics(), nil)
+			})
+
+			ginkgo.It("should report the volume as degraded when only one side is connected", func() {
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.Connected).To(gomega.BeTrue())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(
+					fmt.Sprintf("metro volume %s is degraded on node %s: connected to array %s, not connected to array %s",
+						validMetroBlockVolumeID, validNodeID, firstValidID, secondValidID)))
+				gomega.Expect(response.Messages).To(gomega.ContainElement(gomega.HavePrefix(
+					fmt.Sprintf("array %s is not connected to node %s", secondValidID, validNodeID))))
+			})
+
+			ginkgo.It("should list the kubernetes nodes only once for every array checked", func() {
+				listed := 0
+				ctrlSvc.nodeIPs = newK8sNodeIPSource(func(_ context.Context) ([]corev1.Node, error) {
+					listed++
+					return []corev1.Node{newTestK8sNode("1a47a1b91c444a8a90193d8066669603",
+						corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: validNodeIP})}, nil
+				})
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{validMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				}
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.Connected).To(gomega.BeTrue())
+				gomega.Expect(listed).To(gomega.Equal(1))
+			})
+
+			ginkgo.It("should report the volume as degraded when only the remote side is connected", func() {
+				localID, remoteID := "globalvolid-metro-down", "globalvolid-metro-up"
+				addMetroArray(localID, false)
+				addMetroArray(remoteID, true)
+				volID := filepath.Join(validBaseVolID, localID, "scsi:"+validRemoteVolID, remoteID)
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{volID},
+					NodeId:    validNodeID,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.Connected).To(gomega.BeTrue())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(
+					fmt.Sprintf("metro volume %s is degraded on node %s: connected to array %s, not connected to array %s",
+						volID, validNodeID, remoteID, localID)))
+			})
+
+			ginkgo.It("should report the volume as connected when both sides are connected", func() {
+				remoteID := "globalvolid-metro-connected"
+				addMetroArray(remoteID, true)
+				volID := filepath.Join(validBaseVolID, firstValidID, "scsi:"+validRemoteVolID, remoteID)
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{volID},
+					NodeId:    validNodeID,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.Connected).To(gomega.BeTrue())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(
+					fmt.Sprintf("metro volume %s is connected to node %s through arrays %s and %s",
+						volID, validNodeID, firstValidID, remoteID)))
+			})
+
+			ginkgo.It("should report the volume as disconnected and keep the message beyond the message cap", func() {
+				localID, remoteID := "globalvolid-metro-down-1", "globalvolid-metro-down-2"
+				addMetroArray(localID, false)
+				addMetroArray(remoteID, false)
+				volID := filepath.Join(validBaseVolID, localID, "scsi:"+validRemoteVolID, remoteID)
+				ctrlSvc.maxConnectivityMessages = 1
+
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{volID},
+					NodeId:    validNodeID,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.Connected).To(gomega.BeFalse())
+				gomega.Expect(response.Messages).To(gomega.ContainElement(
+					fmt.Sprintf("metro volume %s is disconnected from node %s: not connected to arrays %s and %s",
+						volID, validNodeID, localID, remoteID)))
+			})
+
+			ginkgo.It("should not check the remote side when it is unmanaged", func() {
+				response, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), &podmon.ValidateVolumeHostConnectivityRequest{
+					VolumeIds: []string{invalidMetroBlockVolumeID},
+					NodeId:    validNodeID,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(response.Connected).To(gomega.BeTrue())
+				gomega.Expect(response.Messages).ToNot(gomega.ContainElement(gomega.HavePrefix("metro volume ")))
+			})
+		})
+
+		ginkgo.When("connectivity checks are recorded as metrics", func() {
+			ginkgo.It("should count the result and observe the latency of every check", func() {
+				staleArrayID := "globalvolid-metrics-stale"
+				var status identifiers.ArrayConnectivityStatus
+				status.LastAttempt = time.Now().Unix()
+				status.LastSuccess = time.Now().Unix() - 100
+				input, _ := json.Marshal(status)
+				http.HandleFunc(identifiers.ArrayStatusEndpoint(staleArrayID), func(w http.ResponseWriter, _ *http.Request) {
+					w.Write(input)
+				})
+
+				connected := connectivityCheckCount(firstValidID, connectivityResultConnected)
+				disconnected := connectivityCheckCount(staleArrayID, connectivityResultDisconnected)
+				unknown := connectivityCheckCount(secondValidID, connectivityResultUnknown)
+				latencies := connectivityCheckLatencyCount(firstValidID)
+
+				rep := &podmon.ValidateVolumeHostConnectivityResponse{}
+				for _, arrayID := range []string{firstValidID, staleArrayID, secondValidID} {
+					err := ctrlSvc.checkIfNodeIsConnected(context.Background(), arrayID, validNodeID, validNodeIP, rep)
+					gomega.Expect(err).To(gomega.BeNil())
+				}
+
+				gomega.Expect(connectivityCheckCount(firstValidID, connectivityResultConnected)).To(gomega.Equal(connected + 1))
+				gomega.Expect(connectivityCheckCount(staleArrayID, connectivityResultDisconnected)).To(gomega.Equal(disconnected + 1))
+				gomega.Expect(connectivityCheckCount(secondValidID, connectivityResultUnknown)).To(gomega.Equal(unknown + 1))
+				gomega.Expect(connectivityCheckLatencyCount(firstValidID)).To(gomega.Equal(latencies + 1))
+			})
+
+			ginkgo.It("should observe the latency of ValidateVolumeHostConnectivity", func() {
+				observed := validateHostConnectivityLatencyCount()
+
+				req := &podmon.ValidateVolumeHostConnectivityRequest{
+					ArrayId: firstValidID,
+					NodeId:  validNodeID,
+				}
+				_, err := ctrlSvc.ValidateVolumeHostConnectivity(context.Background(), req)
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(validateHostConnectivityLatencyCount()).To(gomega.Equal(observed + 1))
+			})
+
+			ginkgo.It("should register the metrics only once", func() {
+				gomega.Expect(func() {
+					registerMetrics()
+					registerMetrics()
+				}).ToNot(gomega.Panic())
+				gomega.Expect(prometheus.Register(connectivityCheckResults)).To(
+					gomega.BeAssignableToTypeOf(prometheus.AlreadyRegisteredError{}))
+			})
+		})
+	})
+
+	ginkgo.Describe("calling IsIOInProgress and QueryArrayStatus", func() {
+		ginkgo.When("IOConnectivity for scsi type volume on array", func() {
+			ginkgo.It("should not fail", func() {
+				var resp []gopowerstore.PerformanceMetricsByVolumeResponse
+				clientMock.On("PerformanceMetricsByVolume", context.Background(), mock.Anything, mock.Anything).
+					Return(resp, gopowerstore.APIError{
+						ErrorMsg: &api.ErrorMsg{
+							StatusCode: http.StatusInternalServerError,
+						},
+					})
+				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "scsi", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
+				gomega.Expect(err).ToNot(gomega.BeNil())
+			})
+		})
+
+		ginkgo.When("IOConnectivity for nfs type volume on array", func() {
+			ginkgo.It("should not fail", func() {
+				var resp []gopowerstore.PerformanceMetricsByFileSystemResponse
+				clientMock.On("PerformanceMetricsByFileSystem", context.Background(), mock.Anything, mock.Anything).
+					Return(resp, gopowerstore.APIError{
+						ErrorMsg: &api.ErrorMsg{
+							StatusCode: http.StatusInternalServerError,
+						},
+					})
+				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "nfs", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
+				gomega.Expect(err).ToNot(gomega.BeNil())
+			})
+		})
+
+		ginkgo.When("IOConnectivity for scsi type volume on array when IO operation is not there", func() {
+			ginkgo.It("should not fail", func() {
+				resp := make([]gopowerstore.PerformanceMetricsByVolumeResponse, 6)
+				resp[0].TotalIops = 0.0
+				resp[1].TotalIops = 0.0
+				resp[2].TotalIops = 0.0
+				resp[3].TotalIops = 0.0
+				resp[4].TotalIops = 0.0
+				resp[5].TotalIops = 0.0
+				clientMock.On("PerformanceMetricsByVolume", context.Background(), mock.Anything, mock.Anything).
+					Return(resp, nil)
+				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "scsi", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
+				gomega.Expect(err).ToNot(gomega.BeNil())
+			})
+		})
+
+		ginkgo.When("IOConnectivity for scsi type volume on array when IO operation is there", func() {
+			ginkgo.It("should not fail", func() {
+				resp := make([]gopowerstore.PerformanceMetricsByVolumeResponse, 6)
+				freshTime, _ := strfmt.ParseDateTime(fmt.Sprint(time.Now().UTC().Format("2006-01-02T15:04:05Z")))
+				resp[0].TotalIops = 0.0
+				resp[1].TotalIops = 0.0
+				resp[2].TotalIops = 4.9
+				resp[2].CommonMetricsFields.Timestamp = freshTime
+				resp[3].TotalIops = 0.0
+				resp[4].CommonMetricsFields.Timestamp = freshTime
+				resp[4].TotalIops = 4.6
+				resp[5].TotalIops = 0.0
+				clientMock.On("PerformanceMetricsByVolume", context.Background(), mock.Anything, mock.Anything).
+					Return(resp, nil)
+				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "scsi", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
+				gomega.Expect(err).To(gomega.BeNil())
+			})
+		})
+
+		ginkgo.When("IOConnectivity for scsi type volume on array when IO operation is there but entry is not fresh", func() {
 			ginkgo.It("should fail", func() {
 				resp := make([]gopowerstore.PerformanceMetricsByVolumeResponse, 6)
 				// stale time
@@ -454,7 +1348,7 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				resp[5].TotalIops = 0.0
 				clientMock.On("PerformanceMetricsByVolume", context.Background(), mock.Anything, mock.Anything).
 					Return(resp, nil)
-				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "scsi")
+				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "scsi", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
 				gomega.Expect(err).ToNot(gomega.BeNil())
 			})
 		})
@@ -470,7 +1364,7 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				resp[5].TotalIops = 0.0
 				clientMock.On("PerformanceMetricsByFileSystem", context.Background(), mock.Anything, mock.Anything).
 					Return(resp, nil)
-				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "nfs")
+				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "nfs", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
 				gomega.Expect(err).ToNot(gomega.BeNil())
 			})
 		})
@@ -489,7 +1383,7 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				resp[5].TotalIops = 0.0
 				clientMock.On("PerformanceMetricsByFileSystem", context.Background(), mock.Anything, mock.Anything).
 					Return(resp, nil)
-				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "nfs")
+				err := getIOInProgress(context.Background(), validBlockVolumeID, *ctrlSvc.DefaultArray(), "nfs", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
 				gomega.Expect(err).To(gomega.BeNil())
 			})
 		})
@@ -618,14 +1512,86 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				gomega.Expect(res.SnapshotGroupID).To(gomega.Equal(validGroupID))
 			})
 
+			ginkgo.It("all source volumes are on the same array", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{}, nil)
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, "vol-1").
+					Return(gopowerstore.VolumeGroups{}, nil)
+				clientMock.On("CreateVolumeGroup", mock.Anything, &gopowerstore.VolumeGroupCreate{
+					Name:        validGroupName,
+					Description: driverVolumeGroupDescription(""),
+					VolumeIDs:   []string{"vol-1", "vol-2"},
+				}).Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID: validGroupID,
+						Volumes: []gopowerstore.Volume{
+							{ID: "vol-1", State: stateReady},
+							{ID: "vol-2", State: stateReady},
+						},
+					}, nil)
+
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name: validGroupName,
+					SourceVolumeIDs: []string{
+						"vol-1/" + firstValidID + "/scsi",
+						"vol-2/" + firstValidID + "/scsi",
+					},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.SnapshotGroupID).To(gomega.Equal(validGroupID))
+			})
+
+			ginkgo.It("source volumes have legacy handles naming the array by its IP", func() {
+				array.IPToArray = make(map[string]string)
+				array.IPToArray["192.168.0.1"] = firstValidID
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{}, nil)
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, "vol-1").
+					Return(gopowerstore.VolumeGroups{}, nil)
+				clientMock.On("CreateVolumeGroup", mock.Anything, &gopowerstore.VolumeGroupCreate{
+					Name:        validGroupName,
+					Description: driverVolumeGroupDescription(""),
+					VolumeIDs:   []string{"vol-1", "vol-2"},
+				}).Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID: validGroupID,
+						Volumes: []gopowerstore.Volume{
+							{ID: "snap-1", State: stateReady, ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-1"}},
+							{ID: "snap-2", State: stateReady, ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-2"}},
+						},
+					}, nil)
+
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: []string{"vol-1/192.168.0.1/scsi", "vol-2/" + firstValidID + "/scsi"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.SnapshotGroupID).To(gomega.Equal(validGroupID))
+				gomega.Expect(res.Snapshots).To(gomega.HaveLen(2))
+				gomega.Expect(res.Snapshots[0].SnapId).To(gomega.Equal("snap-1/" + firstValidID + "/scsi"))
+				gomega.Expect(res.Snapshots[0].SourceId).To(gomega.Equal("vol-1/" + firstValidID + "/scsi"))
+				gomega.Expect(res.Snapshots[1].SourceId).To(gomega.Equal("vol-2/" + firstValidID + "/scsi"))
+			})
+
 			ginkgo.It("there is no existing volume group", func() {
 				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
 					Return(gopowerstore.VolumeGroup{}, nil)
 				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
 					Return(gopowerstore.VolumeGroups{}, nil)
 				createGroupRequest := &gopowerstore.VolumeGroupCreate{
-					Name:      validGroupName,
-					VolumeIDs: []string{validBaseVolID},
+					Name:        validGroupName,
+					Description: driverVolumeGroupDescription(""),
+					VolumeIDs:   []string{validBaseVolID},
 				}
 				clientMock.On("CreateVolumeGroup", mock.Anything, createGroupRequest).
 					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
@@ -649,6 +1615,114 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				gomega.Expect(err).To(gomega.BeNil())
 				gomega.Expect(res.SnapshotGroupID).To(gomega.Equal(validGroupID))
 			})
+
+			ginkgo.It("should tag the created volume group as driver-created", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{}, nil)
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{}, nil)
+				var created *gopowerstore.VolumeGroupCreate
+				clientMock.On("CreateVolumeGroup", mock.Anything, mock.Anything).
+					Run(func(args mock.Arguments) {
+						created = args.Get(1).(*gopowerstore.VolumeGroupCreate)
+					}).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:      validGroupID,
+						Volumes: []gopowerstore.Volume{{ID: validBaseVolID, State: stateReady}},
+					}, nil)
+
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					Description:     "nightly backup",
+					SourceVolumeIDs: []string{validBaseVolID + "/" + firstValidID + "/scsi"},
+				}
+				_, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(created).ToNot(gomega.BeNil())
+				gomega.Expect(created.Description).To(gomega.Equal(driverVolumeGroupTag + " nightly backup"))
+				gomega.Expect(isDriverCreatedVolumeGroup(gopowerstore.VolumeGroup{Description: created.Description})).To(gomega.BeTrue())
+			})
+		})
+
+		ginkgo.When("the volume group is created concurrently", func() {
+			var nameInUseErr = gopowerstore.APIError{
+				ErrorMsg: &api.ErrorMsg{
+					StatusCode: http.StatusUnprocessableEntity,
+					Message:    "the name is already in use",
+				},
+			}
+
+			ginkgo.It("should let both requests succeed with the same volume group", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{}, nil).Times(2)
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{}, nil)
+				clientMock.On("CreateVolumeGroup", mock.Anything, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil).Once()
+				clientMock.On("CreateVolumeGroup", mock.Anything, mock.Anything).
+					Return(gopowerstore.CreateResponse{}, nameInUseErr).Once()
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: "vgs-id"}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(gopowerstore.VolumeGroup{ID: "vgs-id"}, nil)
+
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: []string{validBaseVolID + "/" + firstValidID + "/scsi"},
+				}
+
+				const requests = 2
+				var (
+					wg    sync.WaitGroup
+					start = make(chan struct{})
+					ress  = make([]*vgsext.CreateVolumeGroupSnapshotResponse, requests)
+					errs  = make([]error, requests)
+				)
+				for i := 0; i < requests; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						<-start
+						ress[i], errs[i] = ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+					}(i)
+				}
+				close(start)
+				wg.Wait()
+
+				for i := 0; i < requests; i++ {
+					gomega.Expect(errs[i]).To(gomega.BeNil())
+					gomega.Expect(ress[i].SnapshotGroupID).To(gomega.Equal("vgs-id"))
+				}
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "CreateVolumeGroup", 2)
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "CreateVolumeGroupSnapshot", 2)
+			})
+
+			ginkgo.It("should fail when the existing volume group can't be re-fetched", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{}, nil)
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{}, nil)
+				clientMock.On("CreateVolumeGroup", mock.Anything, mock.Anything).
+					Return(gopowerstore.CreateResponse{}, nameInUseErr)
+
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: []string{validBaseVolID + "/" + firstValidID + "/scsi"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error getting existing volume group"))
+				gomega.Expect(res).To(gomega.BeNil())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "CreateVolumeGroupSnapshot", mock.Anything, mock.Anything, mock.Anything)
+			})
 		})
 
 		ginkgo.When("should not create volume group snapshot with invalid request", func() {
@@ -746,8 +1820,9 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
 					Return(gopowerstore.VolumeGroups{}, nil)
 				createGroupRequest := &gopowerstore.VolumeGroupCreate{
-					Name:      validGroupName,
-					VolumeIDs: []string{validBaseVolID},
+					Name:        validGroupName,
+					Description: driverVolumeGroupDescription(""),
+					VolumeIDs:   []string{validBaseVolID},
 				}
 				clientMock.On("CreateVolumeGroup", mock.Anything, createGroupRequest).
 					Return(gopowerstore.CreateResponse{ID: validGroupID}, gopowerstore.NewNotFoundError())
@@ -791,36 +1866,1247 @@ var _ = ginkgo.Describe("csi-extension-server", func() {
 				gomega.Expect(res).To(gomega.BeNil())
 			})
 
-			ginkgo.It("get volume group fails", func() {
-				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
-					Return(gopowerstore.VolumeGroup{}, nil)
-				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
-					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, ProtectionPolicyID: validPolicyID}}}, nil)
-				clientMock.On("AddMembersToVolumeGroup",
-					mock.Anything,
-					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"),
-					validGroupID).
-					Return(gopowerstore.EmptyResponse(""), nil)
-				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
-					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
-				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
-					Return(gopowerstore.VolumeGroup{}, gopowerstore.NewNotFoundError())
+			ginkgo.It("get volume group fails", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{}, nil)
+				clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+					Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID, ProtectionPolicyID: validPolicyID}}}, nil)
+				clientMock.On("AddMembersToVolumeGroup",
+					mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"),
+					validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{}, gopowerstore.NewNotFoundError())
+
+				var sourceVols []string
+				sourceVols = append(sourceVols, validBaseVolID+"/"+firstValidID+"/scsi")
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: sourceVols,
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error getting volume group snapshot"))
+				gomega.Expect(res).To(gomega.BeNil())
+			})
+
+			ginkgo.It("array of the source volume is not configured", func() {
+				var sourceVols []string
+				sourceVols = append(sourceVols, validBaseVolID+"/unknown-array/scsi")
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: sourceVols,
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("array unknown-array not found"))
+				gomega.Expect(res).To(gomega.BeNil())
+			})
+
+			ginkgo.It("source volume handle references an unknown array IP", func() {
+				array.IPToArray = make(map[string]string)
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: []string{validBaseVolID + "/10.0.0.99/scsi"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(res).To(gomega.BeNil())
+			})
+
+			ginkgo.It("source volumes are on different arrays", func() {
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name: validGroupName,
+					SourceVolumeIDs: []string{
+						"vol-1/" + firstValidID + "/scsi",
+						"vol-2/" + secondValidID + "/scsi",
+					},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+					"source volume vol-2/" + secondValidID + "/scsi is on array " + secondValidID))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("must be on the same array"))
+				gomega.Expect(res).To(gomega.BeNil())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "GetVolumeGroupByName", mock.Anything, mock.Anything)
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "CreateVolumeGroupSnapshot", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("rendered snapshot name exceeds the max length", func() {
+				var sourceVols []string
+				sourceVols = append(sourceVols, validBaseVolID+"/"+firstValidID+"/scsi")
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: sourceVols,
+					Parameters:      map[string]string{KeySnapshotNameTemplate: strings.Repeat("a", MaxVolumeNameLength) + "-{index}"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("character max"))
+				gomega.Expect(res).To(gomega.BeNil())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "CreateVolumeGroupSnapshot", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("snapshot name template renders the same name for several members", func() {
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name: validGroupName,
+					SourceVolumeIDs: []string{
+						"vol-1/" + firstValidID + "/scsi",
+						"vol-2/" + firstValidID + "/scsi",
+					},
+					Parameters: map[string]string{KeySnapshotNameTemplate: "{group}-snap"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+					"renders the same name " + validGroupName + "-snap for volumes vol-1 and vol-2"))
+				gomega.Expect(res).To(gomega.BeNil())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "GetVolumeGroupByName", mock.Anything, mock.Anything)
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "CreateVolumeGroupSnapshot", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("snapshot name template uses a timestamp the group name doesn't end with", func() {
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: []string{validBaseVolID + "/" + firstValidID + "/scsi"},
+					Parameters:      map[string]string{KeySnapshotNameTemplate: "snap-{timestamp}"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("doesn't end with a Unix timestamp"))
+				gomega.Expect(res).To(gomega.BeNil())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "CreateVolumeGroupSnapshot", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		ginkgo.When("some source volumes are already volume group members", func() {
+			var (
+				memberErr = gopowerstore.APIError{
+					ErrorMsg: &api.ErrorMsg{
+						StatusCode: http.StatusUnprocessableEntity,
+						Message:    "volume is already a member of the volume group",
+					},
+				}
+				req = vgsext.CreateVolumeGroupSnapshotRequest{
+					Name: validGroupName,
+					SourceVolumeIDs: []string{
+						"vol-1/" + firstValidID + "/scsi",
+						"vol-2/" + firstValidID + "/scsi",
+					},
+				}
+			)
+
+			ginkgo.BeforeEach(func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID}, nil)
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{"vol-1", "vol-2"}}, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), memberErr).Once()
+			})
+
+			ginkgo.It("should retry adding only the volumes that are not members yet", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:      validGroupID,
+						Volumes: []gopowerstore.Volume{{ID: "vol-1"}},
+					}, nil)
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{"vol-2"}}, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil).Once()
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: "vgs-id"}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(gopowerstore.VolumeGroup{ID: "vgs-id"}, nil)
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.SnapshotGroupID).To(gomega.Equal("vgs-id"))
+				clientMock.AssertCalled(ginkgo.GinkgoT(), "AddMembersToVolumeGroup", mock.Anything,
+					&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{"vol-2"}}, validGroupID)
+			})
+
+			ginkgo.It("should not retry when every volume is already a member", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:      validGroupID,
+						Volumes: []gopowerstore.Volume{{ID: "vol-1"}, {ID: "vol-2"}},
+					}, nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: "vgs-id"}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(gopowerstore.VolumeGroup{ID: "vgs-id"}, nil)
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.SnapshotGroupID).To(gomega.Equal("vgs-id"))
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "AddMembersToVolumeGroup", 1)
+			})
+
+			ginkgo.It("should fail when retrying the remaining volumes fails", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:      validGroupID,
+						Volumes: []gopowerstore.Volume{{ID: "vol-1"}},
+					}, nil)
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{"vol-2"}}, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), gopowerstore.NewNotFoundError()).Once()
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error adding volume group members"))
+				gomega.Expect(res).To(gomega.BeNil())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "CreateVolumeGroupSnapshot", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should fail when volume group members can't be listed", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{}, gopowerstore.NewNotFoundError())
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error getting volume group members"))
+				gomega.Expect(res).To(gomega.BeNil())
+			})
+		})
+
+		ginkgo.When("the source volume list exceeds the member batch size", func() {
+			var req vgsext.CreateVolumeGroupSnapshotRequest
+
+			ginkgo.BeforeEach(func() {
+				ctrlSvc.vgsMemberBatchSize = 2
+				req = vgsext.CreateVolumeGroupSnapshotRequest{Name: validGroupName}
+				for i := 1; i <= 5; i++ {
+					req.SourceVolumeIDs = append(req.SourceVolumeIDs, fmt.Sprintf("vol-%d/%s/scsi", i, firstValidID))
+				}
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID}, nil)
+			})
+
+			ginkgo.It("should add the members in batches", func() {
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"), validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: "vgs-id"}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(gopowerstore.VolumeGroup{ID: "vgs-id"}, nil)
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.SnapshotGroupID).To(gomega.Equal("vgs-id"))
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "AddMembersToVolumeGroup", 3)
+				for _, batch := range [][]string{{"vol-1", "vol-2"}, {"vol-3", "vol-4"}, {"vol-5"}} {
+					clientMock.AssertCalled(ginkgo.GinkgoT(), "AddMembersToVolumeGroup", mock.Anything,
+						&gopowerstore.VolumeGroupMembers{VolumeIDs: batch}, validGroupID)
+				}
+			})
+
+			ginkgo.It("should attempt every batch and aggregate the errors", func() {
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{"vol-1", "vol-2"}}, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), errors.New("first batch failed"))
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{"vol-3", "vol-4"}}, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{"vol-5"}}, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), errors.New("last batch failed"))
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(err).NotTo(gomega.BeNil())
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("2 of 3 volume group member batches failed"))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("first batch failed"))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("last batch failed"))
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "AddMembersToVolumeGroup", 3)
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "CreateVolumeGroupSnapshot", mock.Anything, mock.Anything, mock.Anything)
+			})
+		})
+
+		ginkgo.When("the snapshot members are still initializing", func() {
+			var req vgsext.CreateVolumeGroupSnapshotRequest
+			getGroup := func(state gopowerstore.VolumeStateEnum) gopowerstore.VolumeGroup {
+				return gopowerstore.VolumeGroup{
+					ID: "vgs-id",
+					Volumes: []gopowerstore.Volume{{
+						ID:             "snap-id",
+						State:          state,
+						ProtectionData: gopowerstore.ProtectionData{SourceID: validBaseVolID},
+					}},
+				}
+			}
+
+			ginkgo.BeforeEach(func() {
+				ctrlSvc.vgsSnapshotPollInterval = time.Millisecond
+				req = vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: []string{validBlockVolumeID},
+				}
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID}, nil)
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"), validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: "vgs-id"}, nil)
+			})
+
+			ginkgo.It("should wait until the members are ready", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(getGroup(gopowerstore.VolumeStateEnumInitializing), nil).Twice()
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(getGroup(gopowerstore.VolumeStateEnumReady), nil).Once()
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Snapshots).To(gomega.HaveLen(1))
+				gomega.Expect(res.Snapshots[0].ReadyToUse).To(gomega.BeTrue())
+				clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "GetVolumeGroup", 3)
+			})
+
+			ginkgo.It("should report the members as not ready when they don't settle in time", func() {
+				ctrlSvc.vgsSnapshotReadyTimeout = 20 * time.Millisecond
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(getGroup(gopowerstore.VolumeStateEnumInitializing), nil)
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Snapshots).To(gomega.HaveLen(1))
+				gomega.Expect(res.Snapshots[0].ReadyToUse).To(gomega.BeFalse())
+			})
+
+			ginkgo.It("should fail when re-fetching the snapshot fails", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(getGroup(gopowerstore.VolumeStateEnumInitializing), nil).Once()
+				clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+					Return(gopowerstore.VolumeGroup{}, errors.New("connection reset")).Once()
+
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(err).NotTo(gomega.BeNil())
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error getting volume group snapshot: connection reset"))
+			})
+		})
+
+		ginkgo.When("the array returns the snapshot members in varying order", func() {
+			ginkgo.It("should list the snapshots sorted by source volume", func() {
+				members := []gopowerstore.Volume{
+					{ID: "snap-c", State: stateReady, ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-c"}},
+					{ID: "snap-a", State: stateReady, ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-a"}},
+					{ID: "snap-b", State: stateReady, ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-b"}},
+				}
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name: validGroupName,
+					SourceVolumeIDs: []string{
+						"vol-b/" + firstValidID + "/scsi",
+						"vol-c/" + firstValidID + "/scsi",
+						"vol-a/" + firstValidID + "/scsi",
+					},
+				}
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID}, nil)
+				clientMock.On("AddMembersToVolumeGroup", mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"), validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: "vgs-id"}, nil)
+
+				orders := [][]gopowerstore.Volume{
+					{members[0], members[1], members[2]},
+					{members[2], members[0], members[1]},
+					{members[1], members[2], members[0]},
+				}
+				for _, order := range orders {
+					clientMock.On("GetVolumeGroup", mock.Anything, "vgs-id").
+						Return(gopowerstore.VolumeGroup{ID: "vgs-id", Volumes: order}, nil).Once()
+
+					res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					snapIDs := make([]string, 0, len(res.Snapshots))
+					for _, snap := range res.Snapshots {
+						snapIDs = append(snapIDs, snap.SnapId)
+					}
+					gomega.Expect(snapIDs).To(gomega.Equal([]string{
+						"snap-a/" + firstValidID + "/scsi",
+						"snap-b/" + firstValidID + "/scsi",
+						"snap-c/" + firstValidID + "/scsi",
+					}))
+				}
+			})
+		})
+
+		ginkgo.When("existing volume group has more members than requested", func() {
+			ginkgo.It("should only list snapshots of requested source volumes", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID, ProtectionPolicyID: validPolicyID}, nil)
+				clientMock.On("AddMembersToVolumeGroup",
+					mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"),
+					validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:                 validGroupID,
+						ProtectionPolicyID: validPolicyID,
+						Volumes: []gopowerstore.Volume{
+							{
+								ID:             "snap-id",
+								State:          stateReady,
+								ProtectionData: gopowerstore.ProtectionData{SourceID: validBaseVolID},
+							},
+							{
+								ID:             "extra-snap-id",
+								State:          stateReady,
+								ProtectionData: gopowerstore.ProtectionData{SourceID: "extra-vol-id"},
+							},
+						},
+					}, nil)
+
+				var sourceVols []string
+				sourceVols = append(sourceVols, validBaseVolID+"/"+firstValidID+"/scsi")
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: sourceVols,
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Snapshots).To(gomega.HaveLen(1))
+				gomega.Expect(res.Snapshots[0].SnapId).To(gomega.Equal("snap-id/" + firstValidID + "/scsi"))
+				gomega.Expect(res.Snapshots[0].SourceId).To(gomega.Equal(validBaseVolID + "/" + firstValidID + "/scsi"))
+			})
+
+			ginkgo.It("should list snapshots of every requested source volume", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID, ProtectionPolicyID: validPolicyID}, nil)
+				clientMock.On("AddMembersToVolumeGroup",
+					mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"),
+					validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:                 validGroupID,
+						ProtectionPolicyID: validPolicyID,
+						Volumes: []gopowerstore.Volume{
+							{ID: "snap-1", ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-1"}},
+							{ID: "snap-extra", ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-extra"}},
+							{ID: "snap-2", ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-2"}},
+						},
+					}, nil)
+
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name: validGroupName,
+					SourceVolumeIDs: []string{
+						"vol-1/" + firstValidID + "/scsi",
+						"vol-2/" + firstValidID + "/scsi",
+					},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Snapshots).To(gomega.HaveLen(2))
+				gomega.Expect(res.Snapshots[0].SourceId).To(gomega.Equal("vol-1/" + firstValidID + "/scsi"))
+				gomega.Expect(res.Snapshots[1].SourceId).To(gomega.Equal("vol-2/" + firstValidID + "/scsi"))
+			})
+		})
+
+		ginkgo.When("snapshot name template is specified", func() {
+			ginkgo.It("should rename member snapshots", func() {
+				clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+					Return(gopowerstore.Volume{ID: validBaseVolID, Name: "pvc-vol"}, nil)
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID, ProtectionPolicyID: validPolicyID}, nil)
+				clientMock.On("AddMembersToVolumeGroup",
+					mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"),
+					validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:                 validGroupID,
+						ProtectionPolicyID: validPolicyID,
+						Volumes: []gopowerstore.Volume{{
+							ID:             "snap-id",
+							Name:           validGroupName + "-1",
+							State:          stateReady,
+							ProtectionData: gopowerstore.ProtectionData{SourceID: validBaseVolID},
+						}},
+					}, nil)
+				clientMock.On("ModifyVolume", mock.Anything,
+					&gopowerstore.VolumeModify{Name: validGroupName + "-pvc-vol-1"}, "snap-id").
+					Return(gopowerstore.EmptyResponse(""), nil)
+
+				var sourceVols []string
+				sourceVols = append(sourceVols, validBaseVolID+"/"+firstValidID+"/scsi")
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: sourceVols,
+					Parameters:      map[string]string{KeySnapshotNameTemplate: "{group}-{volume}-{index}"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Snapshots).To(gomega.HaveLen(1))
+				gomega.Expect(res.Snapshots[0].Name).To(gomega.Equal(validGroupName + "-pvc-vol-1"))
+			})
+
+			ginkgo.It("should fail when renaming member snapshot fails", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID, ProtectionPolicyID: validPolicyID}, nil)
+				clientMock.On("AddMembersToVolumeGroup",
+					mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"),
+					validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:                 validGroupID,
+						ProtectionPolicyID: validPolicyID,
+						Volumes: []gopowerstore.Volume{{
+							ID:             "snap-id",
+							State:          stateReady,
+							ProtectionData: gopowerstore.ProtectionData{SourceID: validBaseVolID},
+						}},
+					}, nil)
+				clientMock.On("ModifyVolume", mock.Anything, mock.Anything, "snap-id").
+					Return(gopowerstore.EmptyResponse(""), errors.New("rename failed"))
+
+				var sourceVols []string
+				sourceVols = append(sourceVols, validBaseVolID+"/"+firstValidID+"/scsi")
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name:            validGroupName,
+					SourceVolumeIDs: sourceVols,
+					Parameters:      map[string]string{KeySnapshotNameTemplate: "{group}-snap-{index}"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).Error()
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error renaming volume group snapshot member"))
+				gomega.Expect(res).To(gomega.BeNil())
+			})
+
+			ginkgo.It("should restore the names of renamed members when renaming another one fails", func() {
+				clientMock.On("GetVolumeGroupByName", mock.Anything, validGroupName).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID}, nil)
+				clientMock.On("AddMembersToVolumeGroup",
+					mock.Anything,
+					mock.AnythingOfType("*gopowerstore.VolumeGroupMembers"),
+					validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil)
+				clientMock.On("CreateVolumeGroupSnapshot", mock.Anything, validGroupID, mock.Anything).
+					Return(gopowerstore.CreateResponse{ID: validGroupID}, nil)
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID: validGroupID,
+						Volumes: []gopowerstore.Volume{
+							{ID: "snap-1", Name: "orig-1", State: stateReady, ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-1"}},
+							{ID: "snap-2", Name: "orig-2", State: stateReady, ProtectionData: gopowerstore.ProtectionData{SourceID: "vol-2"}},
+						},
+					}, nil)
+				clientMock.On("ModifyVolume", mock.Anything,
+					&gopowerstore.VolumeModify{Name: validGroupName + "-1"}, "snap-1").
+					Return(gopowerstore.EmptyResponse(""), nil).Once()
+				clientMock.On("ModifyVolume", mock.Anything,
+					&gopowerstore.VolumeModify{Name: validGroupName + "-2"}, "snap-2").
+					Return(gopowerstore.EmptyResponse(""), errors.New("rename failed")).Once()
+				clientMock.On("ModifyVolume", mock.Anything,
+					&gopowerstore.VolumeModify{Name: "orig-1"}, "snap-1").
+					Return(gopowerstore.EmptyResponse(""), nil).Once()
+
+				req := vgsext.CreateVolumeGroupSnapshotRequest{
+					Name: validGroupName,
+					SourceVolumeIDs: []string{
+						"vol-1/" + firstValidID + "/scsi",
+						"vol-2/" + firstValidID + "/scsi",
+					},
+					Parameters: map[string]string{KeySnapshotNameTemplate: "{group}-{index}"},
+				}
+				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+
+				gomega.Expect(err).ToNot(gomega.BeNil())
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error renaming volume group snapshot member snap-2"))
+				gomega.Expect(res).To(gomega.BeNil())
+				clientMock.AssertCalled(ginkgo.GinkgoT(), "ModifyVolume", mock.Anything,
+					&gopowerstore.VolumeModify{Name: "orig-1"}, "snap-1")
+			})
+		})
+	})
+
+	ginkgo.Describe("calling DeleteVolumeGroupSnapshot()", func() {
+		snapshotGroupID := validGroupID + "/" + firstValidID + "/scsi"
+
+		ginkgo.When("the volume group snapshot exists", func() {
+			ginkgo.It("should un-assign its protection policy and delete it", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{
+						ID:                 validGroupID,
+						Type:               gopowerstore.VolumeTypeEnumSnapshot,
+						ProtectionPolicyID: validPolicyID,
+					}, nil)
+				clientMock.On("UpdateVolumeGroupProtectionPolicy", mock.Anything, validGroupID,
+					&gopowerstore.VolumeGroupChangePolicy{ProtectionPolicyID: ""}).
+					Return(gopowerstore.EmptyResponse(""), nil).Once()
+				clientMock.On("DeleteVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil).Once()
+
+				err := ctrlSvc.DeleteVolumeGroupSnapshot(context.Background(), snapshotGroupID)
+				gomega.Expect(err).To(gomega.BeNil())
+				clientMock.AssertExpectations(ginkgo.GinkgoT())
+			})
+
+			ginkgo.It("should delete it without a protection policy", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID, Type: gopowerstore.VolumeTypeEnumSnapshot}, nil)
+				clientMock.On("DeleteVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), nil).Once()
+
+				err := ctrlSvc.DeleteVolumeGroupSnapshot(context.Background(), snapshotGroupID)
+				gomega.Expect(err).To(gomega.BeNil())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "UpdateVolumeGroupProtectionPolicy", mock.Anything, mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should fail when the deletion fails", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID, Type: gopowerstore.VolumeTypeEnumSnapshot}, nil)
+				clientMock.On("DeleteVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), errors.New("internal error"))
+
+				err := ctrlSvc.DeleteVolumeGroupSnapshot(context.Background(), snapshotGroupID)
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.Internal))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error deleting volume group snapshot"))
+			})
+
+			ginkgo.It("should refuse to delete a volume group that isn't a snapshot", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID, Type: gopowerstore.VolumeTypeEnumPrimary}, nil)
+
+				err := ctrlSvc.DeleteVolumeGroupSnapshot(context.Background(), snapshotGroupID)
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.FailedPrecondition))
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteVolumeGroup", mock.Anything, mock.Anything)
+			})
+		})
+
+		ginkgo.When("the volume group snapshot doesn't exist", func() {
+			ginkgo.It("should succeed without deleting anything", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{}, gopowerstore.NewNotFoundError())
+
+				err := ctrlSvc.DeleteVolumeGroupSnapshot(context.Background(), snapshotGroupID)
+				gomega.Expect(err).To(gomega.BeNil())
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteVolumeGroup", mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should succeed when it is deleted concurrently", func() {
+				clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.VolumeGroup{ID: validGroupID, Type: gopowerstore.VolumeTypeEnumSnapshot}, nil)
+				clientMock.On("DeleteVolumeGroup", mock.Anything, validGroupID).
+					Return(gopowerstore.EmptyResponse(""), gopowerstore.NewNotFoundError())
+
+				err := ctrlSvc.DeleteVolumeGroupSnapshot(context.Background(), snapshotGroupID)
+				gomega.Expect(err).To(gomega.BeNil())
+			})
+		})
+
+		ginkgo.When("the snapshot group ID is malformed", func() {
+			ginkgo.It("should fail without querying the array", func() {
+				for _, id := range []string{"", validGroupID, validGroupID + "/" + firstValidID} {
+					err := ctrlSvc.DeleteVolumeGroupSnapshot(context.Background(), id)
+					gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				}
+				clientMock.AssertNotCalled(ginkgo.GinkgoT(), "GetVolumeGroup", mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should fail when the array isn't configured", func() {
+				err := ctrlSvc.DeleteVolumeGroupSnapshot(context.Background(), validGroupID+"/unknown-array/scsi")
+				gomega.Expect(status.Code(err)).To(gomega.Equal(codes.InvalidArgument))
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("array unknown-array not found"))
+			})
+		})
+	})
+})
+
+func TestService_PingArray(t *testing.T) {
+	tests := []struct {
+		name          string
+		globalID      string
+		clusterErr    error
+		wantReachable bool
+		wantCode      codes.Code
+	}{
+		{name: "reachable array", globalID: firstValidID, wantReachable: true},
+		{name: "unreachable array", globalID: firstValidID, clusterErr: errors.New("connection refused")},
+		{name: "unknown array", globalID: "unknown", wantCode: codes.NotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := new(gopowerstoremock.Client)
+			client.On("GetCluster", mock.Anything).After(10*time.Millisecond).Return(gopowerstore.Cluster{}, tt.clusterErr)
+
+			s := &Service{}
+			s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+
+			got, err := s.PingArray(context.Background(), tt.globalID)
+			if tt.wantCode != codes.OK {
+				assert.Equal(t, tt.wantCode, status.Code(err))
+				assert.Nil(t, got)
+				client.AssertNotCalled(t, "GetCluster", mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.globalID, got.GlobalID)
+			assert.Equal(t, tt.wantReachable, got.Reachable)
+			assert.GreaterOrEqual(t, got.Latency, 10*time.Millisecond)
+			if tt.clusterErr != nil {
+				assert.Contains(t, got.Error, tt.clusterErr.Error())
+			} else {
+				assert.Empty(t, got.Error)
+			}
+		})
+	}
+}
+
+func TestService_ArrayReachability(t *testing.T) {
+	newClient := func(err error) *gopowerstoremock.Client {
+		client := new(gopowerstoremock.Client)
+		client.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{}, err)
+		return client
+	}
+	tests := []struct {
+		name            string
+		clusterErrs     map[string]error
+		wantReachable   int
+		wantUnreachable []string
+	}{
+		{
+			name:          "all arrays reachable",
+			clusterErrs:   map[string]error{firstValidID: nil, secondValidID: nil},
+			wantReachable: 2, wantUnreachable: []string{},
+		},
+		{
+			name:          "mixed reachability",
+			clusterErrs:   map[string]error{firstValidID: nil, secondValidID: errors.New("connection refused"), "globalvolid3": errors.New("timeout")},
+			wantReachable: 1, wantUnreachable: []string{secondValidID, "globalvolid3"},
+		},
+		{
+			name:          "no array reachable",
+			clusterErrs:   map[string]error{firstValidID: errors.New("connection refused")},
+			wantReachable: 0, wantUnreachable: []string{firstValidID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arrays := make(map[string]*array.PowerStoreArray)
+			for globalID, err := range tt.clusterErrs {
+				arrays[globalID] = &array.PowerStoreArray{GlobalID: globalID, Client: newClient(err)}
+			}
+			s := &Service{maxConcurrentConnectivityChecks: 1}
+			s.SetArrays(arrays)
+
+			got := s.ArrayReachability(context.Background())
+			assert.Equal(t, len(tt.clusterErrs), got.Total)
+			assert.Equal(t, tt.wantReachable, got.Reachable)
+			assert.Equal(t, tt.wantUnreachable, got.UnreachableIDs)
+			assert.Equal(t, len(tt.wantUnreachable) == 0, got.Ready())
+		})
+	}
+}
+
+func TestService_CheckArrayConnectivity(t *testing.T) {
+	healthy := new(gopowerstoremock.Client)
+	healthy.On("GetCluster", mock.Anything).After(100*time.Millisecond).Return(gopowerstore.Cluster{}, nil)
+	failing := new(gopowerstoremock.Client)
+	failing.On("GetCluster", mock.Anything).After(100*time.Millisecond).Return(gopowerstore.Cluster{}, errors.New("connection refused"))
+
+	s := &Service{maxConcurrentConnectivityChecks: 2}
+	s.SetArrays(map[string]*array.PowerStoreArray{
+		firstValidID:  {GlobalID: firstValidID, Client: healthy},
+		secondValidID: {GlobalID: secondValidID, Client: failing},
+	})
+
+	start := time.Now()
+	got := s.CheckArrayConnectivity(context.Background())
+
+	assert.Less(t, time.Since(start), 190*time.Millisecond, "arrays should be checked concurrently")
+	assert.Len(t, got, 2)
+	assert.NoError(t, got[firstValidID])
+	assert.EqualError(t, got[secondValidID], "connection refused")
+	// the result is not cached
+	s.CheckArrayConnectivity(context.Background())
+	healthy.AssertNumberOfCalls(t, "GetCluster", 2)
+}
+
+func TestService_ProbeControllerArrayConnectivity(t *testing.T) {
+	tests := []struct {
+		name                   string
+		probeArrayConnectivity bool
+		clusterErr             error
+		wantReady              bool
+	}{
+		{name: "connectivity not probed", clusterErr: errors.New("connection refused"), wantReady: true},
+		{name: "array reachable", probeArrayConnectivity: true, wantReady: true},
+		{name: "array unreachable", probeArrayConnectivity: true, clusterErr: errors.New("connection refused")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := new(gopowerstoremock.Client)
+			client.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{}, tt.clusterErr)
+			s := &Service{probeArrayConnectivity: tt.probeArrayConnectivity}
+			s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+
+			got, err := s.ProbeController(context.Background(), &commonext.ProbeControllerRequest{})
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantReady, got.GetReady().GetValue())
+			if !tt.probeArrayConnectivity {
+				client.AssertNotCalled(t, "GetCluster", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestService_ArrayReachabilityCache(t *testing.T) {
+	client := new(gopowerstoremock.Client)
+	client.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{}, nil)
+	s := &Service{}
+	s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+
+	first := s.ArrayReachability(context.Background())
+	second := s.ArrayReachability(context.Background())
+	assert.Equal(t, first, second)
+	client.AssertNumberOfCalls(t, "GetCluster", 1)
+
+	// an expired summary is computed again
+	s.reachability.CheckedAt = time.Now().Add(-identifiers.ArrayReachabilityCacheTTL)
+	s.ArrayReachability(context.Background())
+	client.AssertNumberOfCalls(t, "GetCluster", 2)
+}
+
+func TestService_GetCachedConnectivity(t *testing.T) {
+	s := &Service{}
+
+	_, ok := s.GetCachedConnectivity(validNodeID, firstValidID)
+	assert.False(t, ok, "no status should be cached before any check")
+
+	s.storeConnectivity(validNodeID, firstValidID, true, nil)
+	s.storeConnectivity(validNodeID, secondValidID, false, errors.New("connection refused"))
+	time.Sleep(10 * time.Millisecond)
+
+	got, ok := s.GetCachedConnectivity(validNodeID, firstValidID)
+	assert.True(t, ok)
+	assert.True(t, got.Connected)
+	assert.Empty(t, got.Error)
+	assert.GreaterOrEqual(t, got.Age, 10*time.Millisecond)
+
+	got, ok = s.GetCachedConnectivity(validNodeID, secondValidID)
+	assert.True(t, ok)
+	assert.False(t, got.Connected)
+	assert.Equal(t, "connection refused", got.Error)
+
+	_, ok = s.GetCachedConnectivity("other-node", firstValidID)
+	assert.False(t, ok, "status is cached per node")
+
+	// a newer check replaces the cached status
+	s.storeConnectivity(validNodeID, firstValidID, false, nil)
+	got, ok = s.GetCachedConnectivity(validNodeID, firstValidID)
+	assert.True(t, ok)
+	assert.False(t, got.Connected)
+	assert.Less(t, got.Age, 10*time.Millisecond)
+}
+
+func TestService_getMetricsMaxAge(t *testing.T) {
+	tests := []struct {
+		name      string
+		blockEnv  string
+		nfsEnv    string
+		wantBlock time.Duration
+		wantNfs   time.Duration
+	}{
+		{name: "defaults", wantBlock: 60 * time.Second, wantNfs: 60 * time.Second},
+		{name: "separate thresholds", blockEnv: "30s", nfsEnv: "2m", wantBlock: 30 * time.Second, wantNfs: 2 * time.Minute},
+		{name: "invalid values fall back to default", blockEnv: "abc", nfsEnv: "-5s", wantBlock: 60 * time.Second, wantNfs: 60 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.blockEnv != "" {
+				t.Setenv(identifiers.EnvPodmonBlockMetricsMaxAge, tt.blockEnv)
+			}
+			if tt.nfsEnv != "" {
+				t.Setenv(identifiers.EnvPodmonNfsMetricsMaxAge, tt.nfsEnv)
+			}
+			s := &Service{}
+			assert.NoError(t, s.Init())
+			assert.Equal(t, tt.wantBlock, s.getMetricsMaxAge("scsi"))
+			assert.Equal(t, tt.wantNfs, s.getMetricsMaxAge("nfs"))
+		})
+	}
+}
+
+func Test_getIOInProgressProtocolRouting(t *testing.T) {
+	tests := []struct {
+		protocol  string
+		wantBlock bool
+	}{
+		{protocol: "scsi", wantBlock: true},
+		{protocol: "SCSI", wantBlock: true},
+		{protocol: "Scsi", wantBlock: true},
+		{protocol: "nfs", wantBlock: false},
+		{protocol: "NFS", wantBlock: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.protocol, func(t *testing.T) {
+			client := new(gopowerstoremock.Client)
+			client.On("PerformanceMetricsByVolume", mock.Anything, validBlockVolumeID, mock.Anything).
+				Return([]gopowerstore.PerformanceMetricsByVolumeResponse{}, nil)
+			client.On("PerformanceMetricsByFileSystem", mock.Anything, validBlockVolumeID, mock.Anything).
+				Return([]gopowerstore.PerformanceMetricsByFileSystemResponse{}, nil)
+			arr := array.PowerStoreArray{Client: client, GlobalID: firstValidID}
+
+			err := getIOInProgress(context.Background(), validBlockVolumeID, arr, tt.protocol, identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
+			assert.Error(t, err)
+			if tt.wantBlock {
+				client.AssertCalled(t, "PerformanceMetricsByVolume", mock.Anything, validBlockVolumeID, mock.Anything)
+				client.AssertNotCalled(t, "PerformanceMetricsByFileSystem", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				client.AssertCalled(t, "PerformanceMetricsByFileSystem", mock.Anything, validBlockVolumeID, mock.Anything)
+				client.AssertNotCalled(t, "PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func Test_getIOInProgressErrors(t *testing.T) {
+	apiErr := gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusServiceUnavailable}}
+	tests := []struct {
+		name     string
+		protocol string
+		err      error
+		want     error
+	}{
+		{name: "idle block volume", protocol: "scsi", want: ErrNoIOInProgress},
+		{name: "idle nfs volume", protocol: "nfs", want: ErrNoIOInProgress},
+		{name: "block metrics failure", protocol: "scsi", err: apiErr, want: ErrMetricsUnavailable},
+		{name: "nfs metrics failure", protocol: "nfs", err: apiErr, want: ErrMetricsUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := new(gopowerstoremock.Client)
+			client.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).
+				Return(getInactiveIOVolumeMetrics(), tt.err)
+			client.On("PerformanceMetricsByFileSystem", mock.Anything, validBaseVolID, mock.Anything).
+				Return([]gopowerstore.PerformanceMetricsByFileSystemResponse{{}}, tt.err)
+			arr := array.PowerStoreArray{Client: client, GlobalID: firstValidID}
+
+			err := getIOInProgress(context.Background(), validBaseVolID, arr, tt.protocol, identifiers.DefaultPodmonMetricsMaxAge,
+				identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec)
+			assert.ErrorIs(t, err, tt.want)
+			for _, other := range []error{ErrNoIOInProgress, ErrMetricsUnavailable} {
+				if other != tt.want {
+					assert.NotErrorIs(t, err, other)
+				}
+			}
+		})
+	}
+}
+
+func TestService_getMetricsSampleCount(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want int
+	}{
+		{name: "default", want: 4},
+		{name: "configured", env: "8", want: 8},
+		{name: "zero falls back to default", env: "0", want: 4},
+		{name: "negative falls back to default", env: "-2", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv(identifiers.EnvPodmonMetricsSampleCount, tt.env)
+			}
+			s := &Service{}
+			assert.NoError(t, s.Init())
+			assert.Equal(t, tt.want, s.getMetricsSampleCount())
+		})
+	}
+}
+
+func Test_getIOInProgressWindow(t *testing.T) {
+	timestamp := func(age time.Duration) strfmt.DateTime {
+		ts, _ := strfmt.ParseDateTime(time.Now().UTC().Add(-age).Format("2006-01-02T15:04:05Z"))
+		return ts
+	}
+	// metrics of an array aggregating slowly: the only active sample is 90s old, followed by five idle samples
+	metrics := func() []gopowerstore.PerformanceMetricsByVolumeResponse {
+		resp := make([]gopowerstore.PerformanceMetricsByVolumeResponse, 6)
+		resp[0].TotalIops = 4.9
+		resp[0].CommonMetricsFields.Timestamp = timestamp(90 * time.Second)
+		for i := 1; i < len(resp); i++ {
+			resp[i].CommonMetricsFields.Timestamp = timestamp(0)
+		}
+		return resp
+	}
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		samples int
+		wantIO  bool
+	}{
+		{name: "defaults", maxAge: identifiers.DefaultPodmonMetricsMaxAge, samples: identifiers.DefaultPodmonMetricsSampleCount},
+		{name: "stale at 60s", maxAge: 60 * time.Second, samples: 6},
+		{name: "fresh at 120s, beyond the sample count", maxAge: 120 * time.Second, samples: 4},
+		{name: "fresh at 120s", maxAge: 120 * time.Second, samples: 6, wantIO: true},
+		{name: "non positive sample count falls back to default", maxAge: 120 * time.Second, samples: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := new(gopowerstoremock.Client)
+			client.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, mock.Anything).Return(metrics(), nil)
+			arr := array.PowerStoreArray{Client: client, GlobalID: firstValidID}
+
+			err := getIOInProgress(context.Background(), validBaseVolID, arr, "scsi", tt.maxAge, tt.samples, gopowerstore.TwentySec)
+			assert.Equal(t, tt.wantIO, err == nil)
+		})
+	}
+}
+
+func Test_parseMetricsInterval(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    gopowerstore.MetricsIntervalEnum
+		wantErr bool
+	}{
+		{value: "5s", want: metricsIntervalFiveSec},
+		{value: "20s", want: gopowerstore.TwentySec},
+		{value: " 20s ", want: gopowerstore.TwentySec},
+		{value: "0m5s", want: metricsIntervalFiveSec},
+		{value: "FiveSec", want: metricsIntervalFiveSec},
+		{value: "Five_Sec", want: metricsIntervalFiveSec},
+		{value: "TwentySec", want: gopowerstore.TwentySec},
+		{value: "twenty_sec", want: gopowerstore.TwentySec},
+		{value: "OneMin", wantErr: true},
+		{value: "FiveMins", wantErr: true},
+		{value: "5m", wantErr: true},
+		{value: "10s", wantErr: true},
+		{value: "twenty", wantErr: true},
+		{value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseMetricsInterval(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_getMetricsInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want gopowerstore.MetricsIntervalEnum
+	}{
+		{name: "default", want: gopowerstore.TwentySec},
+		{name: "five seconds", env: "5s", want: metricsIntervalFiveSec},
+		{name: "interval name", env: "FiveSec", want: metricsIntervalFiveSec},
+		{name: "unknown interval name falls back to default", env: "OneMin", want: gopowerstore.TwentySec},
+		{name: "invalid value falls back to default", env: "1h", want: gopowerstore.TwentySec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv(identifiers.EnvPodmonMetricsInterval, tt.env)
+			}
+			s := &Service{}
+			assert.NoError(t, s.Init())
+			assert.Equal(t, tt.want, s.getMetricsInterval())
+		})
+	}
+}
+
+func Test_getIOInProgressInterval(t *testing.T) {
+	client := new(gopowerstoremock.Client)
+	client.On("PerformanceMetricsByVolume", mock.Anything, validBlockVolumeID, metricsIntervalFiveSec).
+		Return([]gopowerstore.PerformanceMetricsByVolumeResponse{}, nil).Once()
+	client.On("PerformanceMetricsByVolume", mock.Anything, validBlockVolumeID, gopowerstore.TwentySec).
+		Return([]gopowerstore.PerformanceMetricsByVolumeResponse{}, nil).Once()
+	arr := array.PowerStoreArray{Client: client, GlobalID: firstValidID}
+
+	_ = getIOInProgress(context.Background(), validBlockVolumeID, arr, "scsi", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, metricsIntervalFiveSec)
+	// callers that don't specify an interval get the default one
+	_ = getIOInProgress(context.Background(), validBlockVolumeID, arr, "scsi", identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, "")
+
+	client.AssertExpectations(t)
+}
+
+func TestService_IsVolumeGroupIOInProgressInterval(t *testing.T) {
+	t.Setenv(identifiers.EnvPodmonMetricsInterval, "FiveSec")
+	client := new(gopowerstoremock.Client)
+	client.On("GetVolumeGroup", mock.Anything, validGroupID).
+		Return(gopowerstore.VolumeGroup{Volumes: []gopowerstore.Volume{{ID: validBaseVolID}}}, nil)
+	client.On("PerformanceMetricsByVolume", mock.Anything, validBaseVolID, metricsIntervalFiveSec).
+		Return([]gopowerstore.PerformanceMetricsByVolumeResponse{}, nil).Once()
+	s := &Service{}
+	assert.NoError(t, s.Init())
+	s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+
+	_, err := s.IsVolumeGroupIOInProgress(context.Background(), firstValidID, validGroupID)
+	assert.NoError(t, err)
+	client.AssertExpectations(t)
+}
+
+func TestOrderMetroIOChecks(t *testing.T) {
+	localArray := array.PowerStoreArray{GlobalID: "PS000000000001"}
+	remoteArray := array.PowerStoreArray{GlobalID: "PS000000000002"}
+	local := ioCheck{volID: "local-vol", array: localArray, protocol: "scsi"}
+	remote := ioCheck{volID: "remote-vol", array: remoteArray, protocol: "scsi"}
+
+	tests := []struct {
+		name    string
+		session gopowerstore.ReplicationSession
+		err     error
+		want    []ioCheck
+	}{
+		{
+			name:    "local side is preferred",
+			session: gopowerstore.ReplicationSession{Role: string(gopowerstore.ReplicationRoleMetroPreferred)},
+			want:    []ioCheck{local, remote},
+		},
+		{
+			name:    "remote side is preferred although it is second in the handle",
+			session: gopowerstore.ReplicationSession{Role: string(gopowerstore.ReplicationRoleMetroNonPreferred)},
+			want:    []ioCheck{remote, local},
+		},
+		{
+			name: "session can't be read",
+			err:  errors.New("session not found"),
+			want: []ioCheck{local, remote},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := gopowerstoremock.NewClient(t)
+			client.On("GetReplicationSessionByLocalResourceID", mock.Anything, "local-vol").Return(tt.session, tt.err)
+			arr := localArray
+			arr.Client = client
+
+			got := orderMetroIOChecks(context.Background(), &arr, local, remote)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
 
-				var sourceVols []string
-				sourceVols = append(sourceVols, validBaseVolID+"/"+firstValidID+"/scsi")
-				req := vgsext.CreateVolumeGroupSnapshotRequest{
-					Name:            validGroupName,
-					SourceVolumeIDs: sourceVols,
-				}
-				res, err := ctrlSvc.CreateVolumeGroupSnapshot(context.Background(), &req)
+func Test_renderSnapshotName(t *testing.T) {
+	timestamp := time.Date(2024, time.March, 5, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "all tokens",
+			template: "{group}-{volume}-{index}-{timestamp}",
+			want:     "vgs-pvc-1-3-20240305102030",
+		},
+		{
+			name:     "static text only",
+			template: "snapshot",
+			want:     "snapshot",
+		},
+		{
+			name:     "repeated tokens",
+			template: "{index}{index}",
+			want:     "33",
+		},
+		{
+			name:     "unsupported token",
+			template: "{group}-{namespace}",
+			wantErr:  true,
+		},
+		{
+			name:     "exactly max length",
+			template: strings.Repeat("a", MaxVolumeNameLength-1) + "{index}",
+			want:     strings.Repeat("a", MaxVolumeNameLength-1) + "3",
+		},
+		{
+			name:     "exceeds max length",
+			template: strings.Repeat("a", MaxVolumeNameLength) + "{index}",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderSnapshotName(tt.template, "vgs", "pvc-1", 3, timestamp)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
 
-				gomega.Expect(err).Error()
-				gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error getting volume group snapshot"))
-				gomega.Expect(res).To(gomega.BeNil())
-			})
+func Test_snapshotNameTimestamp(t *testing.T) {
+	tests := []struct {
+		name   string
+		group  string
+		want   time.Time
+		wantOk bool
+	}{
+		{name: "seconds", group: "vgs-1709634030", want: time.Unix(1709634030, 0), wantOk: true},
+		{name: "milliseconds", group: "vgs-1709634030123", want: time.UnixMilli(1709634030123), wantOk: true},
+		{name: "nanoseconds", group: "1709634030123456789", want: time.Unix(0, 1709634030123456789), wantOk: true},
+		{name: "no timestamp", group: "vgs-snapshot"},
+		{name: "too short a number", group: "vgs-12345"},
+		{name: "empty name", group: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := snapshotNameTimestamp(tt.group)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.True(t, tt.want.Equal(got))
 		})
-	})
-})
+	}
+}
 
 func Test_waitAndClose(t *testing.T) {
 	type args struct {
@@ -921,12 +3207,43 @@ func Test_isIOInProgress(t *testing.T) {
 	}
 }
 
+func TestIOCheckPoolShutdown(t *testing.T) {
+	pool := newIOCheckPool(1)
+	if !pool.acquire(context.Background()) {
+		t.Fatal("acquire() = false, want a free slot")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pool.shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("shutdown() returned while a check was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pool.release()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("shutdown() didn't return after the check in flight completed")
+	}
+
+	if pool.acquire(context.Background()) {
+		t.Error("acquire() = true after shutdown, want false")
+	}
+}
+
 func Test_asyncGetIOInProgress(t *testing.T) {
 	ctxTimeout := time.Millisecond * 100
 	responseDelay := ctxTimeout * 2
 
 	type args struct {
 		ctx      func() context.Context
+		pool     *ioCheckPool
 		volID    string
 		array    array.PowerStoreArray
 		protocol string
@@ -979,13 +3296,59 @@ func Test_asyncGetIOInProgress(t *testing.T) {
 			wantResp: true,
 			wantErr:  true,
 		},
+		{
+			name: "returns the error when a slot is free",
+			args: args{
+				ctx: func() context.Context {
+					ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+					t.Cleanup(func() { cancel() })
+					return ctx
+				},
+				pool:  newIOCheckPool(1),
+				volID: validBlockVolumeID,
+				array: func() array.PowerStoreArray {
+					clientMock = new(gopowerstoremock.Client)
+					clientMock.On("PerformanceMetricsByVolume", mock.Anything, validBlockVolumeID, mock.Anything).
+						Return(nil, errors.New("an error occurred")).Times(1)
+
+					return array.PowerStoreArray{Client: clientMock, IP: "192.168.0.1", GlobalID: firstValidID}
+				}(),
+				protocol: "scsi",
+			},
+			wantResp: true,
+			wantErr:  true,
+		},
+		{
+			name: "context times out while waiting for a free slot",
+			args: args{
+				ctx: func() context.Context {
+					ctx, cancel := context.WithTimeout(context.Background(), ctxTimeout)
+					t.Cleanup(func() { cancel() })
+					return ctx
+				},
+				pool: func() *ioCheckPool {
+					pool := newIOCheckPool(1)
+					pool.acquire(context.Background())
+					return pool
+				}(),
+				volID: validBlockVolumeID,
+				array: func() array.PowerStoreArray {
+					// no expectations, the query must never be issued
+					clientMock = new(gopowerstoremock.Client)
+					return array.PowerStoreArray{Client: clientMock, IP: "192.168.0.1", GlobalID: firstValidID}
+				}(),
+				protocol: "scsi",
+			},
+			wantResp: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			now := time.Now()
 
 			ctx := tt.args.ctx()
-			errCh := asyncGetIOInProgress(ctx, tt.args.volID, tt.args.array, tt.args.protocol)
+			errCh := asyncGetIOInProgress(ctx, tt.args.pool, tt.args.volID, tt.args.array, tt.args.protocol,
+				identifiers.DefaultPodmonMetricsMaxAge, identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec, 0)
 
 			gotResp := false
 			select {
@@ -1006,3 +3369,489 @@ func Test_asyncGetIOInProgress(t *testing.T) {
 		})
 	}
 }
+
+func newTestK8sNode(machineID string, addresses ...corev1.NodeAddress) corev1.Node {
+	return corev1.Node{Status: corev1.NodeStatus{
+		NodeInfo:  corev1.NodeSystemInfo{MachineID: machineID},
+		Addresses: addresses,
+	}}
+}
+
+func Test_k8sNodeIPSource(t *testing.T) {
+	const (
+		nodeID    = "csi-node-003c684ccb0c4ca0a9c99423563dfd2c-10.0.0.1"
+		machineID = "003c684ccb0c4ca0a9c99423563dfd2c"
+	)
+	internalIP := corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "192.168.0.10"}
+	hostname := corev1.NodeAddress{Type: corev1.NodeHostName, Address: "worker-1"}
+
+	tests := []struct {
+		name    string
+		nodeID  string
+		nodes   []corev1.Node
+		listErr error
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "internal IP of the matching node",
+			nodeID: nodeID,
+			nodes:  []corev1.Node{newTestK8sNode("other"), newTestK8sNode(machineID, hostname, internalIP)},
+			want:   "192.168.0.10",
+		},
+		{
+			name:   "hostname when the node has no internal IP",
+			nodeID: nodeID,
+			nodes:  []corev1.Node{newTestK8sNode(machineID, hostname)},
+			want:   "worker-1",
+		},
+		{
+			name:   "dashed host ID",
+			nodeID: "csi-node-003c684c-cb0c-4ca0-a9c9-9423563dfd2c-10.0.0.1",
+			nodes:  []corev1.Node{newTestK8sNode(machineID, internalIP)},
+			want:   "192.168.0.10",
+		},
+		{
+			name:   "node ID with an invalid IP",
+			nodeID: "csi-node-003c684ccb0c4ca0a9c99423563dfd2c-@@@",
+			nodes:  []corev1.Node{newTestK8sNode(machineID, internalIP)},
+			want:   "192.168.0.10",
+		},
+		{
+			name:   "falls back to the node ID when no node matches",
+			nodeID: nodeID,
+			nodes:  []corev1.Node{newTestK8sNode("other", internalIP)},
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "falls back to the node ID when the node has no address",
+			nodeID: nodeID,
+			nodes:  []corev1.Node{newTestK8sNode(machineID)},
+			want:   "10.0.0.1",
+		},
+		{
+			name:    "falls back to the node ID when kubernetes isn't available",
+			nodeID:  nodeID,
+			listErr: errors.New("no kubeconfig"),
+			want:    "10.0.0.1",
+		},
+		{
+			name:    "fails when neither kubernetes nor the node ID has the IP",
+			nodeID:  "csi-node-003c684ccb0c4ca0a9c99423563dfd2c-@@@",
+			listErr: errors.New("no kubeconfig"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newK8sNodeIPSource(func(_ context.Context) ([]corev1.Node, error) {
+				return tt.nodes, tt.listErr
+			})
+			got, err := source.nodeIP(context.Background(), tt.nodeID)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_capConnectivityMessages(t *testing.T) {
+	connected := func(i int) string { return fmt.Sprintf("array array-%d is connected to node node-1", i) }
+	notConnected := func(i int) string {
+		return fmt.Sprintf("array array-%d is not connected to node node-1: no active data paths to the array", i)
+	}
+	timedOut := "all IO checks timed out for volumes [vol-1], reporting IO in-progress"
+	unavailable := "performance metrics unavailable for 1 of 1 IO checks of volumes [vol-1], the array may be unreachable"
+
+	tests := []struct {
+		name        string
+		messages    []string
+		maxMessages int
+		want        []string
+	}{
+		{
+			name:        "within the cap",
+			messages:    []string{connected(1), notConnected(2)},
+			maxMessages: 2,
+			want:        []string{connected(1), notConnected(2)},
+		},
+		{
+			name:        "overflow is summarized",
+			messages:    []string{connected(1), connected(2), connected(3), connected(4), connected(5)},
+			maxMessages: 3,
+			want:        []string{connected(1), connected(2), "3 more messages omitted"},
+		},
+		{
+			name:        "critical messages are retained in order",
+			messages:    []string{connected(1), notConnected(2), connected(3), timedOut, connected(4)},
+			maxMessages: 4,
+			want:        []string{connected(1), notConnected(2), timedOut, "2 more messages omitted"},
+		},
+		{
+			name:        "critical messages are retained beyond the cap",
+			messages:    []string{notConnected(1), connected(2), notConnected(3), notConnected(4)},
+			maxMessages: 2,
+			want:        []string{notConnected(1), notConnected(3), notConnected(4), "1 more messages omitted"},
+		},
+		{
+			name:        "unavailable metrics are critical",
+			messages:    []string{connected(1), connected(2), unavailable},
+			maxMessages: 2,
+			want:        []string{unavailable, "2 more messages omitted"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, capConnectivityMessages(tt.messages, tt.maxMessages))
+		})
+	}
+}
+
+func Test_parseArrayStatusPathPrefix(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: ""},
+		{value: " /node-status ", want: "/node-status"},
+		{value: "/ingress/node-status", want: "/ingress/node-status"},
+		{value: "node-status", wantErr: true},
+		{value: "/node-status/", wantErr: true},
+		{value: "/", wantErr: true},
+		{value: "//node-status", wantErr: true},
+		{value: "/ingress/../node-status", wantErr: true},
+		{value: "/node-status?x=1", wantErr: true},
+		{value: "/node%2fstatus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseArrayStatusPathPrefix(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_arrayStatusURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		scheme string
+		prefix string
+		host   string
+		want   string
+	}{
+		{
+			name: "no prefix",
+			host: "10.0.0.1",
+			want: "http://10.0.0.1" + identifiers.APIPort + "/array-status/" + firstValidID,
+		},
+		{
+			name:   "prefix",
+			prefix: "/ingress/node-status",
+			host:   "10.0.0.1",
+			want:   "http://10.0.0.1" + identifiers.APIPort + "/ingress/node-status/array-status/" + firstValidID,
+		},
+		{
+			name:   "prefix with an IPv6 host",
+			prefix: "/node-status",
+			host:   "fd00::1",
+			want:   "http://[fd00::1]" + identifiers.APIPort + "/node-status/array-status/" + firstValidID,
+		},
+		{
+			name:   "https",
+			scheme: "https",
+			host:   "10.0.0.1",
+			want:   "https://10.0.0.1" + identifiers.APIPort + "/array-status/" + firstValidID,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{arrayStatusScheme: tt.scheme, arrayStatusPathPrefix: tt.prefix}
+			assert.Equal(t, tt.want, s.arrayStatusURL(tt.host, firstValidID))
+		})
+	}
+}
+
+func TestService_queryArrayStatusRetry(t *testing.T) {
+	connected := func(w http.ResponseWriter) {
+		now := time.Now().Unix()
+		_ = json.NewEncoder(w).Encode(identifiers.ArrayConnectivityStatus{LastSuccess: now, LastAttempt: now})
+	}
+	notConnected := func(w http.ResponseWriter) {
+		now := time.Now().Unix()
+		_ = json.NewEncoder(w).Encode(identifiers.ArrayConnectivityStatus{LastSuccess: now - 3600, LastAttempt: now})
+	}
+	dropConnection := func(w http.ResponseWriter) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}
+	withStatus := func(code int) func(http.ResponseWriter) {
+		return func(w http.ResponseWriter) { w.WriteHeader(code) }
+	}
+
+	tests := []struct {
+		name          string
+		responses     []func(http.ResponseWriter)
+		wantConnected bool
+		wantErr       bool
+		wantCalls     int32
+	}{
+		{
+			name:          "transport failures are retried",
+			responses:     []func(http.ResponseWriter){dropConnection, dropConnection, connected},
+			wantConnected: true,
+			wantCalls:     3,
+		},
+		{
+			name:          "server errors are retried",
+			responses:     []func(http.ResponseWriter){withStatus(http.StatusServiceUnavailable), connected},
+			wantConnected: true,
+			wantCalls:     2,
+		},
+		{
+			name:      "attempts are bounded",
+			responses: []func(http.ResponseWriter){dropConnection, dropConnection, dropConnection, connected},
+			wantErr:   true,
+			wantCalls: identifiers.PodmonArrayStatusAttempts,
+		},
+		{
+			name:      "not connected response is definitive",
+			responses: []func(http.ResponseWriter){notConnected, connected},
+			wantCalls: 1,
+		},
+		{
+			name:      "client errors are definitive",
+			responses: []func(http.ResponseWriter){withStatus(http.StatusNotFound), connected},
+			wantErr:   true,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				tt.responses[calls.Add(1)-1](w)
+			}))
+			defer server.Close()
+			s := &Service{arrayStatusRetryBackoff: time.Millisecond}
+
+			got, err := s.QueryArrayStatus(context.Background(), server.URL+identifiers.ArrayStatusEndpoint(firstValidID))
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantConnected, got)
+			assert.Equal(t, tt.wantCalls, calls.Load())
+		})
+	}
+}
+
+func TestService_queryArrayStatusRetryDeadline(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	s := &Service{arrayStatusRetryBackoff: time.Minute}
+
+	// the deadline expires before the first retry would be made
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	got, err := s.QueryArrayStatus(ctx, server.URL+identifiers.ArrayStatusEndpoint(firstValidID))
+	assert.Error(t, err)
+	assert.False(t, got)
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func Test_ioCheckTimeout(t *testing.T) {
+	withDeadline := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		t.Cleanup(cancel)
+		return ctx
+	}
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		n     int
+		slots int
+		want  time.Duration
+	}{
+		{name: "no deadline", ctx: context.Background(), n: 4, slots: 1, want: 0},
+		{name: "one check at a time", ctx: withDeadline(), n: 4, slots: 1, want: time.Second / 4},
+		{name: "two checks at a time", ctx: withDeadline(), n: 4, slots: 2, want: time.Second / 2},
+		{name: "more slots than checks", ctx: withDeadline(), n: 4, slots: 10, want: time.Second},
+		{name: "no checks", ctx: withDeadline(), n: 0, slots: 1, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, ioCheckTimeout(tt.ctx, tt.n, tt.slots), float64(50*time.Millisecond))
+		})
+	}
+}
+
+func TestService_IsVolumeGroupIOInProgressSlowVolume(t *testing.T) {
+	fresh, _ := strfmt.ParseDateTime(time.Now().UTC().Format("2006-01-02T15:04:05Z"))
+	volumes := []gopowerstore.Volume{{ID: "slow"}, {ID: "fast-1"}, {ID: "fast-2"}, {ID: "active"}}
+
+	client := new(gopowerstoremock.Client)
+	client.On("GetVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.VolumeGroup{ID: validGroupID, Volumes: volumes}, nil)
+	// the metrics of the slow volume never arrive before the deadline
+	client.On("PerformanceMetricsByVolume", mock.Anything, "slow", mock.Anything).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(nil, context.DeadlineExceeded)
+	client.On("PerformanceMetricsByVolume", mock.Anything, mock.MatchedBy(func(id string) bool {
+		return strings.HasPrefix(id, "fast-")
+	}), mock.Anything).Return([]gopowerstore.PerformanceMetricsByVolumeResponse{}, nil)
+	var active gopowerstore.PerformanceMetricsByVolumeResponse
+	active.TotalIops = 10
+	active.CommonMetricsFields.Timestamp = fresh
+	client.On("PerformanceMetricsByVolume", mock.Anything, "active", mock.Anything).
+		Return([]gopowerstore.PerformanceMetricsByVolumeResponse{active}, nil)
+
+	// a single check at a time, so the slow volume would hold the only slot for the whole deadline
+	s := &Service{maxConcurrentIOChecks: 1}
+	s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	got, err := s.IsVolumeGroupIOInProgress(ctx, firstValidID, validGroupID)
+	assert.NoError(t, err)
+	assert.True(t, got)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func Test_checkIOInProgressCheckTimeouts(t *testing.T) {
+	client := new(gopowerstoremock.Client)
+	client.On("PerformanceMetricsByVolume", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { <-args.Get(0).(context.Context).Done() }).
+		Return(nil, context.DeadlineExceeded)
+	arr := array.PowerStoreArray{Client: client, GlobalID: firstValidID}
+
+	// every check uses up its share without the deadline of the request expiring
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reqChs := []<-chan error{
+		asyncGetIOInProgress(ctx, nil, "vol-1", arr, "scsi", identifiers.DefaultPodmonMetricsMaxAge,
+			identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec, 50*time.Millisecond),
+		asyncGetIOInProgress(ctx, nil, "vol-2", arr, "scsi", identifiers.DefaultPodmonMetricsMaxAge,
+			identifiers.DefaultPodmonMetricsSampleCount, gopowerstore.TwentySec, 50*time.Millisecond),
+	}
+	ioInProgress, timedOut, _ := checkIOInProgress(ctx, reqChs...)
+	assert.False(t, ioInProgress)
+	assert.True(t, timedOut)
+	assert.NoError(t, ctx.Err())
+}
+
+func TestService_queryArrayStatusHTTPS(t *testing.T) {
+	const token = "node-status-token"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		now := time.Now().Unix()
+		_ = json.NewEncoder(w).Encode(identifiers.ArrayConnectivityStatus{LastSuccess: now, LastAttempt: now})
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte(token+"\n"), 0o600))
+	wrongTokenFile := filepath.Join(dir, "wrong-token")
+	assert.NoError(t, os.WriteFile(wrongTokenFile, []byte("wrong"), 0o600))
+
+	tests := []struct {
+		name          string
+		caFile        string
+		tokenFile     string
+		wantConnected bool
+		wantErr       string
+	}{
+		{name: "trusted CA and valid token", caFile: caFile, tokenFile: tokenFile, wantConnected: true},
+		{name: "no CA configured", tokenFile: tokenFile, wantErr: "certificate"},
+		{name: "invalid token", caFile: caFile, tokenFile: wrongTokenFile, wantErr: "unexpected response"},
+		{name: "missing token file", caFile: caFile, tokenFile: filepath.Join(dir, "missing"), wantErr: "token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(identifiers.EnvPodmonArrayStatusScheme, "https")
+			t.Setenv(identifiers.EnvPodmonArrayStatusCAFile, tt.caFile)
+			t.Setenv(identifiers.EnvPodmonArrayStatusTokenFile, tt.tokenFile)
+			s := &Service{arrayStatusRetryBackoff: time.Millisecond}
+			s.initArrayStatusClient(context.Background())
+			assert.Equal(t, "https", s.arrayStatusScheme)
+
+			got, err := s.QueryArrayStatus(context.Background(), server.URL+identifiers.ArrayStatusEndpoint(firstValidID))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantConnected, got)
+		})
+	}
+}
+
+func TestService_initArrayStatusClientInvalid(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	for _, caFile := range []string{notPEM, filepath.Join(dir, "missing.pem")} {
+		t.Run(filepath.Base(caFile), func(t *testing.T) {
+			t.Setenv(identifiers.EnvPodmonArrayStatusScheme, "ftp")
+			t.Setenv(identifiers.EnvPodmonArrayStatusCAFile, caFile)
+			s := &Service{}
+			s.initArrayStatusClient(context.Background())
+			assert.Equal(t, "http", s.arrayStatusScheme)
+			assert.Nil(t, s.arrayStatusTransport)
+		})
+	}
+}
+
+func TestService_CacheMetrics(t *testing.T) {
+	registerMetrics()
+	registerMetrics()
+	assert.IsType(t, prometheus.AlreadyRegisteredError{}, prometheus.Register(cacheLookups))
+	assert.IsType(t, prometheus.AlreadyRegisteredError{}, prometheus.Register(cacheEntries))
+
+	client := new(gopowerstoremock.Client)
+	client.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{Name: validClusterName}, nil)
+	s := &Service{}
+	s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+
+	for _, cache := range []string{cacheArrayReachability, cacheClusterName, cacheConnectivity} {
+		hits, misses := cacheLookupCount(cache, "hit"), cacheLookupCount(cache, "miss")
+		switch cache {
+		case cacheArrayReachability:
+			s.ArrayReachability(context.Background())
+			s.ArrayReachability(context.Background())
+			s.ArrayReachability(context.Background())
+		case cacheClusterName:
+			s.getArrayClusterName(context.Background(), firstValidID)
+			s.getArrayClusterName(context.Background(), firstValidID)
+			s.getArrayClusterName(context.Background(), firstValidID)
+		case cacheConnectivity:
+			s.GetCachedConnectivity(validNodeID, firstValidID)
+			s.storeConnectivity(validNodeID, firstValidID, true, nil)
+			s.GetCachedConnectivity(validNodeID, firstValidID)
+			s.GetCachedConnectivity(validNodeID, firstValidID)
+		}
+		assert.Equal(t, float64(2), cacheLookupCount(cache, "hit")-hits, cache)
+		assert.Equal(t, float64(1), cacheLookupCount(cache, "miss")-misses, cache)
+		assert.Equal(t, float64(1), cacheEntryCount(cache), cache)
+	}
+}
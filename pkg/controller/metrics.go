@@ -0,0 +1,152 @@
+/*
+ *
+ * Copyright © 2025 Dell Inc. or its subsidiaries. All Rights Reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ */
+
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// controller caches reported by the cache metrics
+const (
+	cacheArrayReachability = "array_reachability"
+	cacheClusterName       = "cluster_name"
+	cacheConnectivity      = "connectivity"
+)
+
+// results of a node to array connectivity check
+const (
+	connectivityResultConnected    = "connected"
+	connectivityResultDisconnected = "disconnected"
+	connectivityResultUnknown      = "unknown"
+)
+
+var (
+	// connectivityCheckDuration is the latency of querying a node for its connectivity to an array
+	connectivityCheckDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "powerstore",
+		Subsystem: "podmon",
+		Name:      "connectivity_check_duration_seconds",
+		Help:      "Latency of node to array connectivity checks.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"global_id"})
+
+	// connectivityCheckResults counts node to array connectivity checks by their result
+	connectivityCheckResults = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "powerstore",
+		Subsystem: "podmon",
+		Name:      "connectivity_checks_total",
+		Help:      "Number of node to array connectivity checks by result.",
+	}, []string{"global_id", "result"})
+
+	// validateHostConnectivityDuration is the latency of ValidateVolumeHostConnectivity calls
+	validateHostConnectivityDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "powerstore",
+		Subsystem: "podmon",
+		Name:      "validate_volume_host_connectivity_duration_seconds",
+		Help:      "Latency of ValidateVolumeHostConnectivity calls.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// cacheLookups counts the lookups of the controller caches by their result
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "powerstore",
+		Subsystem: "controller",
+		Name:      "cache_lookups_total",
+		Help:      "Number of lookups of the controller caches by result.",
+	}, []string{"cache", "result"})
+
+	// cacheEntries is the number of entries of the controller caches
+	cacheEntries = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "powerstore",
+		Subsystem: "controller",
+		Name:      "cache_entries",
+		Help:      "Number of entries in the controller caches.",
+	}, []string{"cache"})
+
+	registerMetricsOnce sync.Once
+)
+
+// registerMetrics registers the controller metrics with the default prometheus registry.
+// It is safe to call multiple times, e.g. when several services are initialized in tests.
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		for _, collector := range []prometheus.Collector{
+			connectivityCheckDuration,
+			connectivityCheckResults,
+			validateHostConnectivityDuration,
+			cacheLookups,
+			cacheEntries,
+		} {
+			if err := prometheus.Register(collector); err != nil {
+				var alreadyRegistered prometheus.AlreadyRegisteredError
+				if !errors.As(err, &alreadyRegistered) {
+					log.Errorf("failed to register metrics collector: %s", err.Error())
+				}
+			}
+		}
+	})
+}
+
+// observeConnectivityCheck records the latency and the result of a connectivity check to the array globalID
+func observeConnectivityCheck(globalID, result string, start time.Time) {
+	connectivityCheckDuration.WithLabelValues(globalID).Observe(time.Since(start).Seconds())
+	connectivityCheckResults.WithLabelValues(globalID, result).Inc()
+}
+
+// observeCacheLookup counts a lookup of the given controller cache as a hit or a miss
+func observeCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(cache, result).Inc()
+}
+
+// setCacheEntries sets the number of entries of the given controller cache to the number of keys in m
+func setCacheEntries(cache string, m *sync.Map) {
+	entries := 0
+	m.Range(func(_, _ interface{}) bool {
+		entries++
+		return true
+	})
+	cacheEntries.WithLabelValues(cache).Set(float64(entries))
+}
+
+// ServeMetrics exposes the registered metrics on the given address until the server fails
+func ServeMetrics(address string) {
+	log.Infof("starting metrics server on %s", address)
+	mux := http.NewServeMux()
+	mux.Handle(identifiers.MetricsEndpoint, promhttp.Handler())
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: identifiers.PodmonArrayConnectivityTimeout,
+	}
+	if err := server.ListenAndServe(); err != nil {
+		log.Errorf("unable to start metrics server due to %s", err)
+	}
+}
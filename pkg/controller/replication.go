@@ -18,15 +18,25 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dell/csi-powerstore/v2/pkg/array"
+	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 	"github.com/dell/csm-sharednfs/nfs"
 	csiext "github.com/dell/dell-csi-extensions/replication"
 	"github.com/dell/gopowerstore"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -42,13 +52,19 @@ func (s *Service) CreateRemoteVolume(ctx context.Context,
 
 	volumeHandle, err := array.ParseVolumeID(ctx, volID, s.DefaultArray(), nil)
 	if err != nil {
-		log.Error(err)
+		log.WithFields(identifiers.GetLogFields(ctx)).Error(err)
+		return nil, err
+	}
+	if err := rejectMetroVolume(volumeHandle, volID); err != nil {
 		return nil, err
 	}
 	id := volumeHandle.LocalUUID
 	arrayID := volumeHandle.LocalArrayGlobalID
 	protocol := volumeHandle.Protocol
 
+	ctx, logger := withReplicationLogFields(ctx, arrayID)
+	logger.WithField("VolumeID", volID).Info("Creating remote volume")
+
 	volPrefix := ""
 	if accessMode, ok := params[nfs.CsiNfsParameter]; ok && accessMode != "" {
 		// host-based nfs volumes should have the "shared-nfs" parameter
@@ -62,22 +78,41 @@ func (s *Service) CreateRemoteVolume(ctx context.Context,
 
 	arr, ok := s.Arrays()[arrayID]
 	if !ok {
-		log.Info("ip is nil")
+		logger.Info("ip is nil")
 		return nil, status.Error(codes.InvalidArgument, "failed to find array with given IP")
 	}
 
-	vgs, err := arr.GetClient().GetVolumeGroupsByVolumeID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-	if len(vgs.VolumeGroup) == 0 {
-		return nil, status.Error(codes.Unimplemented, "replication of volumes that aren't assigned to group is not implemented yet")
-	}
-	vg := vgs.VolumeGroup[0]
+	// file systems are replicated by the NAS server they actually live on, which isn't necessarily the NAS server
+	// configured for the array
+	var rs gopowerstore.ReplicationSession
+	var fs gopowerstore.FileSystem
+	var nas gopowerstore.NAS
+	if protocol == "nfs" {
+		fs, err = arr.GetClient().GetFS(ctx, id)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "can't get file system %s: %s", id, apiErrorDetails(err))
+		}
+		rs, nas, err = getNASReplicationSession(ctx, arr, fs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		vgs, err := arr.GetClient().GetVolumeGroupsByVolumeID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(vgs.VolumeGroup) == 0 {
+			return nil, status.Error(codes.Unimplemented, "replication of volumes that aren't assigned to group is not implemented yet")
+		}
+		vg := vgs.VolumeGroup[0]
 
-	rs, err := arr.Client.GetReplicationSessionByLocalResourceID(ctx, vg.ID)
-	if err != nil {
-		return nil, err
+		rs, err = arr.Client.GetReplicationSessionByLocalResourceID(ctx, vg.ID)
+		if err != nil {
+			if isNoReplicationSessionError(err) {
+				return nil, status.Error(codes.FailedPrecondition, noReplicationSessionMessage(vg))
+			}
+			return nil, err
+		}
 	}
 
 	var remoteVolumeID string
@@ -91,9 +126,12 @@ func (s *Service) CreateRemoteVolume(ctx context.Context,
 		return nil, status.Errorf(codes.Internal, "couldn't find volume id %s in storage element pairs of replication session", id)
 	}
 
-	vol, err := arr.Client.GetVolume(ctx, id)
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "can't query volume: %s", err.Error())
+	var vol gopowerstore.Volume
+	if protocol != "nfs" {
+		vol, err = arr.Client.GetVolume(ctx, id)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "can't query volume: %s", err.Error())
+		}
 	}
 	localSystem, err := arr.Client.GetCluster(ctx)
 	if err != nil {
@@ -104,14 +142,30 @@ func (s *Service) CreateRemoteVolume(ctx context.Context,
 		return nil, err
 	}
 
+	// the remote size check compares block volumes only
+	if sizeCheck := params[s.WithRP(KeyReplicationRemoteSizeCheck)]; sizeCheck != "" && protocol != "nfs" {
+		err = s.checkRemoteVolumeSize(ctx, remoteSystem.SerialNumber, remoteVolumeID, vol.Size, sizeCheck)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	remoteParams := map[string]string{
-		"remoteSystem":                                   localSystem.Name,
-		s.replicationContextPrefix + "arrayID":           remoteSystem.SerialNumber,
-		s.replicationContextPrefix + "managementAddress": remoteSystem.ManagementAddress,
+		"remoteSystem":                           localSystem.Name,
+		s.withContextPrefix("arrayID"):           remoteSystem.SerialNumber,
+		s.withContextPrefix("managementAddress"): remoteSystem.ManagementAddress,
+	}
+	size := vol.Size
+	if protocol == "nfs" {
+		// the NAS server is replicated under its own name, so the remote file system is served by the same name
+		remoteParams[identifiers.KeyNasName] = nas.Name
+		size = fs.SizeTotal - ReservedSize
+	} else {
+		s.addProvisioningAttributes(remoteParams, vol)
 	}
 	remoteVolume := getRemoteCSIVolume(
-		volPrefix+remoteVolumeID+"/"+remoteParams[s.replicationContextPrefix+"arrayID"]+"/"+protocol,
-		vol.Size,
+		volPrefix+remoteVolumeID+"/"+remoteParams[s.withContextPrefix("arrayID")]+"/"+protocol,
+		size,
 	)
 	remoteVolume.VolumeContext = remoteParams
 	return &csiext.CreateRemoteVolumeResponse{
@@ -119,101 +173,303 @@ func (s *Service) CreateRemoteVolume(ctx context.Context,
 	}, nil
 }
 
+// checkRemoteVolumeSize compares the actual size of the remote volume with the size of the source volume.
+// Depending on mode, a mismatch is either logged as a warning or returned as an error.
+func (s *Service) checkRemoteVolumeSize(ctx context.Context, remoteArrayID, remoteVolumeID string, size int64, mode string) error {
+	if mode != RemoteSizeCheckWarn && mode != RemoteSizeCheckError {
+		return status.Errorf(codes.InvalidArgument, "invalid remote volume size check mode %s, must be one of %s or %s",
+			mode, RemoteSizeCheckWarn, RemoteSizeCheckError)
+	}
+
+	remoteArray, ok := s.Arrays()[remoteArrayID]
+	if !ok {
+		log.WithFields(identifiers.GetLogFields(ctx)).Warnf("remote array %s is not configured, skipping size check of remote volume %s", remoteArrayID, remoteVolumeID)
+		return nil
+	}
+
+	remoteVol, err := remoteArray.GetClient().GetVolume(ctx, remoteVolumeID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "can't query remote volume: %s", err.Error())
+	}
+	if remoteVol.Size == size {
+		return nil
+	}
+
+	msg := fmt.Sprintf("size of remote volume %s (%d) does not match size of source volume (%d)", remoteVolumeID, remoteVol.Size, size)
+	if mode == RemoteSizeCheckError {
+		return status.Error(codes.FailedPrecondition, msg)
+	}
+	log.WithFields(identifiers.GetLogFields(ctx)).Warn(msg)
+	return nil
+}
+
 // CreateStorageProtectionGroup creates storage protection group
 func (s *Service) CreateStorageProtectionGroup(ctx context.Context,
 	req *csiext.CreateStorageProtectionGroupRequest,
 ) (*csiext.CreateStorageProtectionGroupResponse, error) {
-	volID := req.GetVolumeHandle()
-	if volID == "" {
-		return nil, status.Error(codes.InvalidArgument, "volume ID is required")
+	ctx, arr, id, protocol, err := s.getProtectionGroupVolume(ctx, req, "Creating storage protection group")
+	if err != nil {
+		return nil, err
 	}
+	arrayID := arr.GlobalID
 	params := req.GetParameters()
 
-	volumeHandle, err := array.ParseVolumeID(ctx, volID, s.DefaultArray(), nil)
+	// block volumes are replicated by their volume group, file systems by their NAS server
+	var rs gopowerstore.ReplicationSession
+	var groupKind, groupName, groupNameKey string
+	if protocol == "nfs" {
+		fs, err := arr.GetClient().GetFS(ctx, id)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "can't get file system %s: %s", id, apiErrorDetails(err))
+		}
+		var nas gopowerstore.NAS
+		rs, nas, err = getNASReplicationSession(ctx, arr, fs)
+		if err != nil {
+			return nil, err
+		}
+		groupKind, groupName, groupNameKey = "NAS server", nas.Name, s.withContextPrefix("NASServerName")
+	} else {
+		vgs, err := arr.GetClient().GetVolumeGroupsByVolumeID(ctx, id)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "can't get volume groups of volume %s: %s", id, apiErrorDetails(err))
+		}
+		if len(vgs.VolumeGroup) == 0 {
+			return nil, status.Error(codes.Unimplemented, "replication of volumes that aren't assigned to group is not implemented yet")
+		}
+		vg := vgs.VolumeGroup[0]
+
+		rs, err = arr.Client.GetReplicationSessionByLocalResourceID(ctx, vg.ID)
+		if err != nil {
+			if isNoReplicationSessionError(err) {
+				return nil, status.Error(codes.FailedPrecondition, noReplicationSessionMessage(vg))
+			}
+			return nil, status.Errorf(codes.Internal, "can't get replication session of volume group %s: %s", vg.ID, apiErrorDetails(err))
+		}
+		groupKind, groupName, groupNameKey = "volume group", vg.Name, s.withContextPrefix("VolumeGroupName")
+	}
+
+	localSystem, err := arr.Client.GetCluster(ctx)
 	if err != nil {
-		log.Error(err)
-		return nil, err
+		return nil, status.Errorf(codes.Internal, "can't get local system: %s", apiErrorDetails(err))
 	}
 
-	id := volumeHandle.LocalUUID
-	arrayID := volumeHandle.LocalArrayGlobalID
-	protocol := volumeHandle.Protocol
+	remoteSystem, err := arr.Client.GetRemoteSystem(ctx, rs.RemoteSystemID)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "can't get remote system %s: %s", rs.RemoteSystemID, apiErrorDetails(err))
+	}
 
-	if accessMode, ok := params[nfs.CsiNfsParameter]; ok && accessMode != "" {
-		// host-based nfs volumes should have the "shared-nfs" parameter
-		// and a "nfs-" prefix in the volume ID that we need to remove
-		// for gopowerstore queries to succeed
-		volPrefix := array.GetVolumeUUIDPrefix(id)
-		id = strings.TrimPrefix(id, volPrefix)
+	// the group may already be protected by a policy targeting another remote system
+	if requested, ok := params[s.WithRP(KeyReplicationRemoteSystem)]; ok && requested != "" && requested != remoteSystem.Name {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"%s %s already replicates to remote system %s, but remote system %s was requested",
+			groupKind, groupName, remoteSystem.Name, requested)
 	}
 
-	arr, ok := s.Arrays()[arrayID]
-	if !ok {
-		log.Info("id is nil")
-		return nil, status.Error(codes.InvalidArgument, "failed to find array with given ID")
+	localPort := managementPort(arr.Endpoint)
+	remotePort := defaultManagementPort
+	if remoteArr, ok := s.Arrays()[remoteSystem.SerialNumber]; ok {
+		remotePort = managementPort(remoteArr.Endpoint)
+	}
+
+	localParams := map[string]string{
+		s.withContextPrefix("systemName"):              localSystem.Name,
+		s.withContextPrefix("managementAddress"):       localSystem.ManagementAddress,
+		s.withContextPrefix("managementPort"):          localPort,
+		s.withContextPrefix("remoteSystemName"):        remoteSystem.Name,
+		s.withContextPrefix("remoteManagementAddress"): remoteSystem.ManagementAddress,
+		s.withContextPrefix("remoteManagementPort"):    remotePort,
+		s.withContextPrefix("globalID"):                arrayID,
+		s.withContextPrefix("remoteGlobalID"):          remoteSystem.SerialNumber,
+		groupNameKey:                                   groupName,
+	}
+	remoteParams := map[string]string{
+		s.withContextPrefix("systemName"):              remoteSystem.Name,
+		s.withContextPrefix("managementAddress"):       remoteSystem.ManagementAddress,
+		s.withContextPrefix("managementPort"):          remotePort,
+		s.withContextPrefix("remoteSystemName"):        localSystem.Name,
+		s.withContextPrefix("remoteManagementAddress"): localSystem.ManagementAddress,
+		s.withContextPrefix("remoteManagementPort"):    localPort,
+		s.withContextPrefix("globalID"):                remoteSystem.SerialNumber,
+		groupNameKey:                                   groupName,
 	}
 
+	return &csiext.CreateStorageProtectionGroupResponse{
+		LocalProtectionGroupId:          rs.LocalResourceID,
+		RemoteProtectionGroupId:         rs.RemoteResourceID,
+		LocalProtectionGroupAttributes:  localParams,
+		RemoteProtectionGroupAttributes: remoteParams,
+	}, nil
+}
+
+// ProtectionGroupReadiness is the result of verifying that the protection group of a volume can be created
+type ProtectionGroupReadiness struct {
+	Ready       bool
+	Diagnostics []string
+}
+
+// VerifyStorageProtectionGroup runs the lookups of CreateStorageProtectionGroup without assembling
+// the protection group attributes, and reports whether the protection group of the volume is ready.
+// Failed lookups are reported as diagnostics, only invalid requests are returned as errors.
+func (s *Service) VerifyStorageProtectionGroup(ctx context.Context,
+	req *csiext.CreateStorageProtectionGroupRequest,
+) (*ProtectionGroupReadiness, error) {
+	ctx, arr, id, protocol, err := s.getProtectionGroupVolume(ctx, req, "Verifying storage protection group")
+	if err != nil {
+		return nil, err
+	}
 	if protocol == "nfs" {
-		return nil, status.Error(codes.InvalidArgument, "replication is not supported for NFS volumes")
+		return nil, status.Error(codes.InvalidArgument, "verifying the replication of NFS volumes is not supported")
+	}
+	result := &ProtectionGroupReadiness{}
+	notReady := func(format string, args ...interface{}) (*ProtectionGroupReadiness, error) {
+		result.Diagnostics = append(result.Diagnostics, fmt.Sprintf(format, args...))
+		return result, nil
 	}
 
 	vgs, err := arr.GetClient().GetVolumeGroupsByVolumeID(ctx, id)
 	if err != nil {
-		return nil, err
+		return notReady("can't get volume groups of volume %s: %s", id, apiErrorDetails(err))
 	}
 	if len(vgs.VolumeGroup) == 0 {
-		return nil, status.Error(codes.Unimplemented, "replication of volumes that aren't assigned to group is not implemented yet")
+		return notReady("volume %s isn't assigned to a volume group", id)
 	}
 	vg := vgs.VolumeGroup[0]
+	result.Diagnostics = append(result.Diagnostics, fmt.Sprintf("volume group %s found", vg.Name))
 
 	rs, err := arr.Client.GetReplicationSessionByLocalResourceID(ctx, vg.ID)
+	if isNoReplicationSessionError(err) {
+		return notReady("%s", noReplicationSessionMessage(vg))
+	}
 	if err != nil {
-		return nil, err
+		return notReady("can't get replication session of volume group %s: %s", vg.ID, apiErrorDetails(err))
 	}
+	result.Diagnostics = append(result.Diagnostics, fmt.Sprintf("replication session %s found", rs.ID))
 
-	localSystem, err := arr.Client.GetCluster(ctx)
-	if err != nil {
-		return nil, err
+	if _, err = arr.Client.GetCluster(ctx); err != nil {
+		return notReady("can't get local system: %s", apiErrorDetails(err))
 	}
 
 	remoteSystem, err := arr.Client.GetRemoteSystem(ctx, rs.RemoteSystemID)
 	if err != nil {
-		return nil, err
+		return notReady("can't get remote system %s: %s", rs.RemoteSystemID, apiErrorDetails(err))
 	}
-	localParams := map[string]string{
-		s.replicationContextPrefix + "systemName":              localSystem.Name,
-		s.replicationContextPrefix + "managementAddress":       localSystem.ManagementAddress,
-		s.replicationContextPrefix + "remoteSystemName":        remoteSystem.Name,
-		s.replicationContextPrefix + "remoteManagementAddress": remoteSystem.ManagementAddress,
-		s.replicationContextPrefix + "globalID":                arrayID,
-		s.replicationContextPrefix + "remoteGlobalID":          remoteSystem.SerialNumber,
-		s.replicationContextPrefix + "VolumeGroupName":         vg.Name,
+	if requested, ok := req.GetParameters()[s.WithRP(KeyReplicationRemoteSystem)]; ok && requested != "" && requested != remoteSystem.Name {
+		return notReady("volume group %s already replicates to remote system %s, but remote system %s was requested",
+			vg.Name, remoteSystem.Name, requested)
 	}
-	remoteParams := map[string]string{
-		s.replicationContextPrefix + "systemName":              remoteSystem.Name,
-		s.replicationContextPrefix + "managementAddress":       remoteSystem.ManagementAddress,
-		s.replicationContextPrefix + "remoteSystemName":        localSystem.Name,
-		s.replicationContextPrefix + "remoteManagementAddress": localSystem.ManagementAddress,
-		s.replicationContextPrefix + "globalID":                remoteSystem.SerialNumber,
-		s.replicationContextPrefix + "VolumeGroupName":         vg.Name,
+	result.Diagnostics = append(result.Diagnostics, fmt.Sprintf("remote system %s found", remoteSystem.Name))
+
+	result.Ready = true
+	return result, nil
+}
+
+// getProtectionGroupVolume validates the volume of a protection group request and returns
+// the ctx with replication log fields, the array of the volume, the volume ID on that array and its protocol
+func (s *Service) getProtectionGroupVolume(ctx context.Context,
+	req *csiext.CreateStorageProtectionGroupRequest, message string,
+) (context.Context, *array.PowerStoreArray, string, string, error) {
+	volID := req.GetVolumeHandle()
+	if volID == "" {
+		return ctx, nil, "", "", status.Error(codes.InvalidArgument, "volume ID is required")
 	}
+	params := req.GetParameters()
 
-	return &csiext.CreateStorageProtectionGroupResponse{
-		LocalProtectionGroupId:          rs.LocalResourceID,
-		RemoteProtectionGroupId:         rs.RemoteResourceID,
-		LocalProtectionGroupAttributes:  localParams,
-		RemoteProtectionGroupAttributes: remoteParams,
-	}, nil
+	volumeHandle, err := array.ParseVolumeID(ctx, volID, s.DefaultArray(), nil)
+	if err != nil {
+		log.WithFields(identifiers.GetLogFields(ctx)).Error(err)
+		return ctx, nil, "", "", err
+	}
+	if err := rejectMetroVolume(volumeHandle, volID); err != nil {
+		return ctx, nil, "", "", err
+	}
+
+	id := volumeHandle.LocalUUID
+	arrayID := volumeHandle.LocalArrayGlobalID
+	protocol := volumeHandle.Protocol
+
+	ctx, logger := withReplicationLogFields(ctx, arrayID)
+	logger.WithField("VolumeID", volID).Info(message)
+
+	if accessMode, ok := params[nfs.CsiNfsParameter]; ok && accessMode != "" {
+		// host-based nfs volumes should have the "shared-nfs" parameter
+		// and a "nfs-" prefix in the volume ID that we need to remove
+		// for gopowerstore queries to succeed
+		volPrefix := array.GetVolumeUUIDPrefix(id)
+		id = strings.TrimPrefix(id, volPrefix)
+	}
+
+	arr, ok := s.Arrays()[arrayID]
+	if !ok {
+		logger.Info("id is nil")
+		return ctx, nil, "", "", status.Error(codes.InvalidArgument, "failed to find array with given ID")
+	}
+
+	return ctx, arr, id, protocol, nil
+}
+
+// rejectMetroVolume returns an InvalidArgument error for metro volume handles. Metro volumes are already replicated by
+// their metro session, so an async or sync replication request for one is a misconfiguration rather than a request
+// that can be served from the local half of the handle.
+func rejectMetroVolume(volumeHandle array.VolumeHandle, volID string) error {
+	if volumeHandle.RemoteUUID == "" {
+		return nil
+	}
+	return status.Errorf(codes.InvalidArgument,
+		"volume %s is a metro volume replicated to array %s, it can't be replicated again", volID, volumeHandle.RemoteArrayGlobalID)
+}
+
+// getNASReplicationSession returns the replication session of the NAS server of the file system along with the NAS
+// server. The NAS server is looked up from the file system, as it may differ from the NAS server configured for the array.
+func getNASReplicationSession(ctx context.Context, arr *array.PowerStoreArray, fs gopowerstore.FileSystem,
+) (gopowerstore.ReplicationSession, gopowerstore.NAS, error) {
+	nas, err := arr.GetClient().GetNAS(ctx, fs.NasServerID)
+	if err != nil {
+		return gopowerstore.ReplicationSession{}, gopowerstore.NAS{},
+			status.Errorf(codes.Internal, "can't get NAS server %s of file system %s: %s", fs.NasServerID, fs.ID, apiErrorDetails(err))
+	}
+	rs, err := arr.GetClient().GetReplicationSessionByLocalResourceID(ctx, nas.ID)
+	if err != nil {
+		if isNoReplicationSessionError(err) {
+			return gopowerstore.ReplicationSession{}, nas, status.Errorf(codes.FailedPrecondition,
+				"NAS server %s has no replication session; ensure protection policy with a replication rule is applied", nas.Name)
+		}
+		return gopowerstore.ReplicationSession{}, nas, status.Errorf(codes.Internal,
+			"can't get replication session of NAS server %s: %s", nas.ID, apiErrorDetails(err))
+	}
+	return rs, nas, nil
 }
 
 // EnsureProtectionPolicyExists  ensures protection policy exists
 func EnsureProtectionPolicyExists(ctx context.Context, arr *array.PowerStoreArray,
 	vgName string, remoteSystemName string, rpoEnum gopowerstore.RPOEnum,
 ) (string, error) {
+	return EnsureProtectionPolicyExistsWithAlertThreshold(ctx, arr, vgName, remoteSystemName, rpoEnum, 0)
+}
+
+// EnsureProtectionPolicyExistsWithAlertThreshold works like EnsureProtectionPolicyExists, creating a missing
+// replication rule with the given alert threshold, see EnsureReplicationRuleExistsWithAlertThreshold.
+func EnsureProtectionPolicyExistsWithAlertThreshold(ctx context.Context, arr *array.PowerStoreArray,
+	vgName string, remoteSystemName string, rpoEnum gopowerstore.RPOEnum, alertThreshold int,
+) (string, error) {
+	ppID, _, err := ensureProtectionPolicy(ctx, arr, vgName, remoteSystemName, rpoEnum, alertThreshold)
+	return ppID, err
+}
+
+// ensureProtectionPolicy ensures protection policy exists and returns a rollback func that
+// removes, best-effort, the protection policy and replication rule created by this call, if any.
+// Callers should invoke the rollback if a later stage fails so retries don't accumulate orphaned objects.
+func ensureProtectionPolicy(ctx context.Context, arr *array.PowerStoreArray,
+	vgName string, remoteSystemName string, rpoEnum gopowerstore.RPOEnum, alertThreshold int,
+) (string, func(context.Context), error) {
+	rollback := func(context.Context) {}
+
 	// Get id of specified remote system
-	rs, err := arr.Client.GetRemoteSystemByName(ctx, remoteSystemName)
+	rs, err := getRemoteSystem(ctx, arr, remoteSystemName)
 	if err != nil {
-		return "", status.Errorf(codes.Internal, "can't query remote system by name: %s", err.Error())
+		if _, ok := status.FromError(err); ok {
+			return "", rollback, err
+		}
+		return "", rollback, status.Errorf(codes.Internal, "can't query remote system by name: %s", err.Error())
 	}
 
 	ppName := "pp-" + vgName
@@ -221,13 +477,21 @@ func EnsureProtectionPolicyExists(ctx context.Context, arr *array.PowerStoreArra
 	// Check that protection policy already exists
 	pp, err := arr.Client.GetProtectionPolicyByName(ctx, ppName)
 	if err == nil {
-		return pp.ID, nil
+		if err := ensurePolicyReplicationRule(ctx, arr, pp, vgName, rs.ID, rpoEnum, alertThreshold); err != nil {
+			return "", rollback, err
+		}
+		return pp.ID, rollback, nil
 	}
 
 	// ensure that replicationRule exists
-	rrID, err := EnsureReplicationRuleExists(ctx, arr, vgName, rs.ID, rpoEnum)
+	rrID, rrCreated, err := ensureReplicationRule(ctx, arr, vgName, rs.ID, rpoEnum, alertThreshold)
 	if err != nil {
-		return "", status.Errorf(codes.Internal, "can't ensure that replication rule exists")
+		return "", rollback, status.Errorf(codes.Internal, "can't ensure that replication rule exists")
+	}
+	if rrCreated {
+		rollback = func(ctx context.Context) {
+			deleteReplicationRule(ctx, arr, rrID)
+		}
 	}
 
 	newPp, err := arr.Client.CreateProtectionPolicy(ctx, &gopowerstore.ProtectionPolicyCreate{
@@ -235,16 +499,110 @@ func EnsureProtectionPolicyExists(ctx context.Context, arr *array.PowerStoreArra
 		ReplicationRuleIDs: []string{rrID},
 	})
 	if err != nil {
-		return "", status.Errorf(codes.Internal, "can't create protection policy: %s", err.Error())
+		rollback(ctx)
+		return "", func(context.Context) {}, status.Errorf(codes.Internal, "can't create protection policy: %s", err.Error())
+	}
+
+	return newPp.ID, func(ctx context.Context) {
+		log.Infof("rolling back protection policy %s", newPp.ID)
+		if _, err := arr.Client.DeleteProtectionPolicy(ctx, newPp.ID); err != nil {
+			log.Warnf("failed to roll back protection policy %s: %s", newPp.ID, err.Error())
+			// the rule can't be removed while the policy still references it
+			return
+		}
+		rollback(ctx)
+	}, nil
+}
+
+// getRemoteSystem returns the remote system of the array named remoteSystem. As names aren't unique, a name shared
+// by several remote systems is rejected with InvalidArgument, listing their serial numbers, and a remote system can
+// also be given by its serial number. The error of the lookup by name is returned when neither finds a single system.
+func getRemoteSystem(ctx context.Context, arr *array.PowerStoreArray, remoteSystem string) (gopowerstore.RemoteSystem, error) {
+	rs, err := arr.Client.GetRemoteSystemByName(ctx, remoteSystem)
+	if err == nil {
+		return rs, nil
+	}
+
+	// the lookup by name fails for both missing and ambiguous names, tell them apart
+	named, listErr := arr.Client.GetRemoteSystems(ctx, map[string]string{"name": "eq." + remoteSystem})
+	if listErr != nil {
+		log.Debugf("unable to list remote systems named %s: %s", remoteSystem, listErr.Error())
+		return rs, err
+	}
+	if len(named) > 1 {
+		serials := make([]string, 0, len(named))
+		for _, system := range named {
+			serials = append(serials, system.SerialNumber)
+		}
+		sort.Strings(serials)
+		return rs, status.Errorf(codes.InvalidArgument,
+			"remote system name %s is ambiguous, it is shared by the remote systems with serial numbers %s; "+
+				"specify the remote system by its serial number instead", remoteSystem, strings.Join(serials, ", "))
+	}
+
+	bySerial, listErr := arr.Client.GetRemoteSystems(ctx, map[string]string{"serial_number": "eq." + remoteSystem})
+	if listErr == nil && len(bySerial) == 1 {
+		log.Infof("remote system %s found by serial number, named %s", remoteSystem, bySerial[0].Name)
+		return bySerial[0], nil
 	}
+	return rs, err
+}
 
-	return newPp.ID, nil
+// ensurePolicyReplicationRule makes sure the existing protection policy pp references the replication rule of
+// the volume group, which may be missing after a partial setup. As a policy holds a single replication rule,
+// the rule of the volume group replaces any other replication rule of the policy.
+func ensurePolicyReplicationRule(ctx context.Context, arr *array.PowerStoreArray, pp gopowerstore.ProtectionPolicy,
+	vgName string, remoteSystemID string, rpoEnum gopowerstore.RPOEnum, alertThreshold int,
+) error {
+	rrName := "rr-" + vgName
+	for _, rr := range pp.ReplicationRules {
+		if rr.Name == rrName {
+			return nil
+		}
+	}
+
+	log.Warnf("protection policy %s does not reference replication rule %s, adding it", pp.Name, rrName)
+	rrID, _, err := ensureReplicationRule(ctx, arr, vgName, remoteSystemID, rpoEnum, alertThreshold)
+	if err != nil {
+		return status.Errorf(codes.Internal, "can't ensure that replication rule exists")
+	}
+	snapshotRuleIDs := make([]string, 0, len(pp.SnapshotRules))
+	for _, sr := range pp.SnapshotRules {
+		snapshotRuleIDs = append(snapshotRuleIDs, sr.ID)
+	}
+	_, err = arr.Client.ModifyProtectionPolicy(ctx, &gopowerstore.ProtectionPolicyCreate{
+		Name:               pp.Name,
+		Description:        pp.Description,
+		ReplicationRuleIDs: []string{rrID},
+		SnapshotRuleIDs:    snapshotRuleIDs,
+	}, pp.ID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "can't add replication rule %s to protection policy %s: %s",
+			rrName, pp.Name, err.Error())
+	}
+	return nil
 }
 
 // EnsureReplicationRuleExists ensures replication rule exists
 func EnsureReplicationRuleExists(ctx context.Context, arr *array.PowerStoreArray,
 	vgName string, remoteSystemID string, rpoEnum gopowerstore.RPOEnum,
 ) (string, error) {
+	return EnsureReplicationRuleExistsWithAlertThreshold(ctx, arr, vgName, remoteSystemID, rpoEnum, 0)
+}
+
+// EnsureReplicationRuleExistsWithAlertThreshold ensures replication rule exists. A new rule alerts when its RPO
+// is exceeded for alertThreshold minutes, or after the default threshold of the array when alertThreshold is 0.
+func EnsureReplicationRuleExistsWithAlertThreshold(ctx context.Context, arr *array.PowerStoreArray,
+	vgName string, remoteSystemID string, rpoEnum gopowerstore.RPOEnum, alertThreshold int,
+) (string, error) {
+	rrID, _, err := ensureReplicationRule(ctx, arr, vgName, remoteSystemID, rpoEnum, alertThreshold)
+	return rrID, err
+}
+
+// ensureReplicationRule ensures replication rule exists and reports whether it was created by this call
+func ensureReplicationRule(ctx context.Context, arr *array.PowerStoreArray,
+	vgName string, remoteSystemID string, rpoEnum gopowerstore.RPOEnum, alertThreshold int,
+) (string, bool, error) {
 	rrName := "rr-" + vgName
 	rr, err := arr.Client.GetReplicationRuleByName(ctx, rrName)
 	if err != nil {
@@ -253,13 +611,107 @@ func EnsureReplicationRuleExists(ctx context.Context, arr *array.PowerStoreArray
 			Name:           rrName,
 			Rpo:            rpoEnum,
 			RemoteSystemID: remoteSystemID,
+			AlertThreshold: alertThreshold,
 		})
 		if err != nil {
-			return "", status.Errorf(codes.Internal, "can't create replication rule: %s", err.Error())
+			if isUnsupportedRPOError(err) {
+				return "", false, status.Errorf(codes.InvalidArgument,
+					"remote system %s does not support RPO %s: %s; RPOs that may be supported instead: %s",
+					remoteSystemID, rpoEnum, err.Error(), alternativeRPOs(rpoEnum))
+			}
+			return "", false, status.Errorf(codes.Internal, "can't create replication rule: %s", err.Error())
+		}
+		return newRr.ID, true, nil
+	}
+	return rr.ID, false, nil
+}
+
+// knownRPOs lists the RPOs that can be requested for a replication rule, shortest first
+var knownRPOs = []gopowerstore.RPOEnum{
+	gopowerstore.RpoZero,
+	gopowerstore.RpoFiveMinutes,
+	gopowerstore.RpoFifteenMinutes,
+	gopowerstore.RpoThirtyMinutes,
+	gopowerstore.RpoOneHour,
+	gopowerstore.RpoSixHours,
+	gopowerstore.RpoTwelveHours,
+	gopowerstore.RpoOneDay,
+}
+
+// rpoMinutes maps the asynchronous RPOs to their length in minutes
+var rpoMinutes = map[gopowerstore.RPOEnum]int{
+	gopowerstore.RpoFiveMinutes:    5,
+	gopowerstore.RpoFifteenMinutes: 15,
+	gopowerstore.RpoThirtyMinutes:  30,
+	gopowerstore.RpoOneHour:        60,
+	gopowerstore.RpoSixHours:       6 * 60,
+	gopowerstore.RpoTwelveHours:    12 * 60,
+	gopowerstore.RpoOneDay:         24 * 60,
+}
+
+// maxReplicationAlertThreshold is the longest alert threshold, in minutes, PowerStore accepts for a replication rule
+const maxReplicationAlertThreshold = 24 * 60
+
+// parseReplicationAlertThreshold parses the alert threshold of a replication rule, in minutes. It must be a multiple
+// of the RPO, so the alert isn't raised before the RPO elapses, and is only supported with an asynchronous RPO.
+func parseReplicationAlertThreshold(value string, rpoEnum gopowerstore.RPOEnum) (int, error) {
+	threshold, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || threshold <= 0 {
+		return 0, status.Errorf(codes.InvalidArgument,
+			"invalid replication alert threshold %q, expected a positive number of minutes", value)
+	}
+	rpo, ok := rpoMinutes[rpoEnum]
+	if !ok {
+		return 0, status.Errorf(codes.InvalidArgument,
+			"replication alert threshold is only supported with an asynchronous RPO, not %s", rpoEnum)
+	}
+	if threshold%rpo != 0 || threshold > maxReplicationAlertThreshold {
+		return 0, status.Errorf(codes.InvalidArgument,
+			"invalid replication alert threshold %d, expected a multiple of the RPO %s (%d minutes) up to %d minutes",
+			threshold, rpoEnum, rpo, maxReplicationAlertThreshold)
+	}
+	return threshold, nil
+}
+
+// isUnsupportedRPOError reports whether the array rejected a replication rule because of its RPO
+func isUnsupportedRPOError(err error) bool {
+	apiErr, ok := err.(gopowerstore.APIError)
+	if !ok || apiErr.ErrorMsg == nil {
+		return false
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	return strings.Contains(strings.ToLower(apiErr.Message), "rpo")
+}
+
+// alternativeRPOs returns a comma separated list of the known RPOs longer than the rejected one,
+// as remote systems reject RPOs that are too short for them. All other RPOs are listed when there are none.
+func alternativeRPOs(rejected gopowerstore.RPOEnum) string {
+	var longer, others []string
+	found := false
+	for _, rpo := range knownRPOs {
+		if rpo == rejected {
+			found = true
+			continue
 		}
-		return newRr.ID, nil
+		others = append(others, string(rpo))
+		if found {
+			longer = append(longer, string(rpo))
+		}
+	}
+	if len(longer) == 0 {
+		return strings.Join(others, ", ")
+	}
+	return strings.Join(longer, ", ")
+}
+
+// deleteReplicationRule removes a replication rule as part of a best-effort rollback
+func deleteReplicationRule(ctx context.Context, arr *array.PowerStoreArray, rrID string) {
+	log.Infof("rolling back replication rule %s", rrID)
+	if _, err := arr.Client.DeleteReplicationRule(ctx, rrID); err != nil {
+		log.Warnf("failed to roll back replication rule %s: %s", rrID, err.Error())
 	}
-	return rr.ID, nil
 }
 
 // GetReplicationCapabilities is a getter for replication capabilities
@@ -341,11 +793,10 @@ func (s *Service) GetReplicationCapabilities(_ context.Context, _ *csiext.GetRep
 func (s *Service) ExecuteAction(ctx context.Context,
 	req *csiext.ExecuteActionRequest,
 ) (*csiext.ExecuteActionResponse, error) {
-	var reqID string
 	localParams := req.GetProtectionGroupAttributes()
 	protectionGroupID := req.GetProtectionGroupId()
 	action := req.GetAction().GetActionTypes().String()
-	globalID, ok := localParams[s.replicationContextPrefix+"globalID"]
+	globalID, ok := localParams[s.withContextPrefix("globalID")]
 	if !ok {
 		return nil, status.Error(codes.InvalidArgument, "missing globalID in protection group attributes")
 	}
@@ -356,16 +807,15 @@ func (s *Service) ExecuteAction(ctx context.Context,
 	pstoreClient := arr.GetClient()
 
 	// log all parameters used in ExecuteAction call
-	fields := map[string]interface{}{
-		"RequestID":             reqID,
-		"GlobalID":              localParams[s.replicationContextPrefix+"globalID"],
+	ctx, logger := withReplicationLogFields(ctx, globalID)
+	logger.WithFields(log.Fields{
 		"ProtectedStorageGroup": protectionGroupID,
 		"Action":                action,
-	}
-	log.WithFields(fields).Info("Executing ExecuteAction with following fields")
+	}).Info("Executing ExecuteAction with following fields")
 	rs, err := pstoreClient.GetReplicationSessionByLocalResourceID(ctx, protectionGroupID)
 	if err != nil {
-		return nil, err
+		return nil, status.Errorf(codes.Internal, "can't get replication session of protection group %s: %s",
+			protectionGroupID, apiErrorDetails(err))
 	}
 	client := pstoreClient
 	var execAction gopowerstore.ActionType
@@ -393,12 +843,16 @@ func (s *Service) ExecuteAction(ctx context.Context,
 		return nil, resErr
 	}
 
-	statusResp, err := s.GetStorageProtectionGroupStatus(ctx, &csiext.GetStorageProtectionGroupStatusRequest{
-		ProtectionGroupId:         protectionGroupID,
-		ProtectionGroupAttributes: localParams,
-	})
+	rs, err = s.waitForReplicationAction(ctx, client, protectionGroupID, execAction)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "can't get storage protection group status: %s", err.Error())
+		return nil, status.Errorf(codes.Internal, "can't get storage protection group status: %s", apiErrorDetails(err))
+	}
+	logger.Infof("The state for replication session (%s) for group (%s) after action %s is (%s): %s.",
+		rs.ID, protectionGroupID, action, rs.State, StateDescription(rs.State))
+	if execAction == gopowerstore.RsActionReprotect && s.verifyReprotect {
+		if err := verifyReprotect(rs, protectionGroupID); err != nil {
+			return nil, err
+		}
 	}
 
 	resp := &csiext.ExecuteActionResponse{
@@ -406,11 +860,80 @@ func (s *Service) ExecuteAction(ctx context.Context,
 		ActionTypes: &csiext.ExecuteActionResponse_Action{
 			Action: req.GetAction(),
 		},
-		Status: statusResp.Status,
+		Status: &csiext.StorageProtectionGroupStatus{
+			State:    protectionGroupState(rs.State),
+			IsSource: rs.Role != "Destination",
+		},
 	}
 	return resp, nil
 }
 
+// waitForReplicationAction polls the replication session of the protection group until it reaches the final state of
+// the executed action, or the replication action timeout or the context expires. On timeout the last fetched session
+// is returned, so that the caller reports the last observed status rather than failing an action that is in progress.
+func (s *Service) waitForReplicationAction(ctx context.Context, client gopowerstore.Client, groupID string,
+	action gopowerstore.ActionType,
+) (gopowerstore.ReplicationSession, error) {
+	timeout := s.replicationActionTimeout
+	if timeout <= 0 {
+		timeout = identifiers.DefaultReplicationActionTimeout
+	}
+	interval := s.replicationActionPollInterval
+	if interval <= 0 {
+		interval = identifiers.DefaultReplicationActionPollInterval
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		rs, err := client.GetReplicationSessionByLocalResourceID(ctx, groupID)
+		if err != nil {
+			return rs, err
+		}
+		if replicationActionDone(rs.State, action) {
+			return rs, nil
+		}
+		if time.Now().After(deadline) {
+			log.Warnf("Replication session %s didn't complete action %s within %v, last state is %s", rs.ID, action, timeout, rs.State)
+			return rs, nil
+		}
+		select {
+		case <-ctx.Done():
+			log.Warnf("Stopped waiting for replication session %s to complete action %s: %s, last state is %s",
+				rs.ID, action, ctx.Err().Error(), rs.State)
+			return rs, nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// verifyReprotect checks that a reprotect took effect, i.e. that the local protection group is the source of the
+// replication session re-read after the action, and not its destination anymore.
+func verifyReprotect(rs gopowerstore.ReplicationSession, groupID string) error {
+	if rs.Role == "Destination" {
+		return status.Errorf(codes.Internal, "reprotect of replication session %s didn't take effect, "+
+			"protection group %s is still the destination, state is %s", rs.ID, groupID, rs.State)
+	}
+	return nil
+}
+
+// replicationActionDone returns true when the replication session state is the final state of the given action.
+// The error state is final too, as the session won't leave it on its own.
+func replicationActionDone(state gopowerstore.RSStateEnum, action gopowerstore.ActionType) bool {
+	if state == gopowerstore.RsStateError {
+		return true
+	}
+	switch action {
+	case gopowerstore.RsActionFailover:
+		return state == gopowerstore.RsStateFailedOver
+	case gopowerstore.RsActionResume, gopowerstore.RsActionReprotect, gopowerstore.RsActionSync:
+		return state == gopowerstore.RsStateOk
+	case gopowerstore.RsActionPause:
+		return state == gopowerstore.RsStatePaused || state == gopowerstore.RsStatePausedForMigration ||
+			state == gopowerstore.RsStatePausedForNdu
+	}
+	return true
+}
+
 // ExecuteAction validates current state of replication & executes provided action on RS
 func ExecuteAction(session *gopowerstore.ReplicationSession, pstoreClient gopowerstore.Client, action gopowerstore.ActionType, failoverParams *gopowerstore.FailoverParams) error {
 	inDesiredState, actionRequired, err := validateRSState(session, action)
@@ -427,8 +950,8 @@ func ExecuteAction(session *gopowerstore.ReplicationSession, pstoreClient gopowe
 			failoverParams)
 		if err != nil {
 			if apiError, ok := err.(gopowerstore.APIError); ok && apiError.UnableToFailoverFromDestination() {
-				log.Error(fmt.Sprintf("Fail over: Failed to modify RS (%s) - Error (%s)", session.ID, err.Error()))
-				return status.Errorf(codes.Internal, "Execute action: Failed to modify RS (%s) - Error (%s)", session.ID, err.Error())
+				log.Error(fmt.Sprintf("Fail over: Failed to modify RS (%s) - Error (%s)", session.ID, apiErrorDetails(err)))
+				return status.Errorf(codes.Internal, "Execute action: Failed to modify RS (%s) - Error (%s)", session.ID, apiErrorDetails(err))
 			}
 		}
 		log.Debugf("Action (%s) successful on RS(%s)", string(action), session.ID)
@@ -474,7 +997,7 @@ func (s *Service) DeleteStorageProtectionGroup(ctx context.Context,
 ) (*csiext.DeleteStorageProtectionGroupResponse, error) {
 	localParams := req.GetProtectionGroupAttributes()
 	groupID := req.GetProtectionGroupId()
-	globalID, ok := localParams[s.replicationContextPrefix+"globalID"]
+	globalID, ok := localParams[s.withContextPrefix("globalID")]
 
 	if !ok {
 		return nil, status.Error(codes.InvalidArgument, "missing globalID in protection group attributes")
@@ -484,59 +1007,76 @@ func (s *Service) DeleteStorageProtectionGroup(ctx context.Context,
 	if !ok {
 		return nil, status.Errorf(codes.InvalidArgument, "can't find array with global id %s", globalID)
 	}
-	fields := map[string]interface{}{
-		"GlobalID":              globalID,
-		"ProtectedStorageGroup": groupID,
+	ctx, logger := withReplicationLogFields(ctx, globalID)
+	logger = logger.WithField("ProtectedStorageGroup", groupID)
+
+	if nasName, ok := localParams[s.withContextPrefix("NASServerName")]; ok {
+		// the NAS server and its protection policy are shared by other file systems and weren't created by the driver
+		logger.Infof("Protection group is NAS server %s, leaving it and its protection policy in place", nasName)
+		return &csiext.DeleteStorageProtectionGroupResponse{}, nil
 	}
 
-	log.WithFields(fields).Info("Deleting storage protection group")
+	logger.Info("Deleting storage protection group")
 
 	vg, err := arr.GetClient().GetVolumeGroup(ctx, groupID)
 	if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
-		return nil, status.Errorf(codes.Internal, "Error: Unable to get Volume Group")
+		return nil, status.Errorf(codes.Internal, "Error: Unable to get Volume Group: %s", apiErrorDetails(apiErr))
 	}
-	if vg.ID != "" {
+	if vg.ID != "" && !isVolumeGroupDeletable(vg, localParams[s.withContextPrefix("remoteSystemName")]) {
+		logger.Warnf("Volume group %s was not created by the driver, leaving it in place", groupID)
+	} else if vg.ID != "" {
 		if vg.ProtectionPolicyID != "" {
-			_, err := arr.GetClient().ModifyVolumeGroup(ctx, &gopowerstore.VolumeGroupModify{
+			// un-assigning the PP removes the replication session, which would leave the remote side
+			// dangling if the session is in the middle of transferring data or changing direction
+			rs, err := arr.GetClient().GetReplicationSessionByLocalResourceID(ctx, groupID)
+			if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
+				return nil, status.Errorf(codes.Internal, "Error: Unable to get replication session: %s", apiErrorDetails(apiErr))
+			}
+			if err == nil && isReplicationSessionBusy(rs.State) {
+				return nil, status.Errorf(codes.FailedPrecondition,
+					"replication session %s of volume group %s is still active: %s", rs.ID, groupID, StateDescription(rs.State))
+			}
+
+			_, err = arr.GetClient().ModifyVolumeGroup(ctx, &gopowerstore.VolumeGroupModify{
 				ProtectionPolicyID: "",
 			}, groupID)
 			if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
-				return nil, status.Errorf(codes.Internal, "Error: Unable to un-assign PP from Volume Group")
+				return nil, status.Errorf(codes.Internal, "Error: Unable to un-assign PP from Volume Group: %s", apiErrorDetails(apiErr))
 			}
 		}
 		_, err = arr.Client.DeleteVolumeGroup(ctx, groupID)
 		if apiError, ok := err.(gopowerstore.APIError); ok && !apiError.NotFound() {
-			return nil, status.Errorf(codes.Internal, "Error: %s: Unable to delete Volume Group", apiError.Error())
+			return nil, status.Errorf(codes.Internal, "Error: Unable to delete Volume Group: %s", apiErrorDetails(apiError))
 		}
 	}
 
-	log.WithFields(fields).Info("Deleting protection policy")
+	logger.Info("Deleting protection policy")
 
-	vgName, ok := localParams[s.replicationContextPrefix+"VolumeGroupName"]
+	vgName, ok := localParams[s.withContextPrefix("VolumeGroupName")]
 	if !ok {
 		return nil, status.Errorf(codes.Internal, "Error: Unable to get volume group name")
 	}
 	pp, err := arr.GetClient().GetProtectionPolicyByName(ctx, "pp-"+vgName)
 	if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
-		return nil, status.Errorf(codes.Internal, "Error: Unable to get the PP")
+		return nil, status.Errorf(codes.Internal, "Error: Unable to get the PP: %s", apiErrorDetails(apiErr))
 	}
 	if pp.ID != "" && len(pp.Volumes) == 0 && len(pp.VolumeGroups) == 0 {
 		_, err := arr.Client.DeleteProtectionPolicy(ctx, pp.ID)
 		if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
-			return nil, status.Errorf(codes.Internal, "Error: Unable to delete PP")
+			return nil, status.Errorf(codes.Internal, "Error: Unable to delete PP: %s", apiErrorDetails(apiErr))
 		}
 	}
 
-	log.WithFields(fields).Info("Deleting replication rule")
+	logger.Info("Deleting replication rule")
 
 	rr, err := arr.GetClient().GetReplicationRuleByName(ctx, "rr-"+vgName)
 	if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
-		return nil, status.Errorf(codes.Internal, "Error: RR not found")
+		return nil, status.Errorf(codes.Internal, "Error: RR not found: %s", apiErrorDetails(apiErr))
 	}
 	if rr.ID != "" && len(rr.ProtectionPolicies) == 0 {
 		_, err = arr.GetClient().DeleteReplicationRule(ctx, rr.ID)
 		if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
-			return nil, status.Errorf(codes.Internal, "Error: Unable to delete replication rule")
+			return nil, status.Errorf(codes.Internal, "Error: Unable to delete replication rule: %s", apiErrorDetails(apiErr))
 		}
 	}
 
@@ -549,17 +1089,124 @@ func (s *Service) DeleteLocalVolume(ctx context.Context,
 ) (*csiext.DeleteLocalVolumeResponse, error) {
 	log.Info("Deleting local volume " + req.VolumeHandle + " per request from remote replication controller")
 
-	// req.VolumeHandle is of format <volumeid>/<array ID>/<protocol>. We only need the IDs.
-	splitHandle := strings.Split(req.VolumeHandle, `/`)
+	opts, err := s.localVolumeDeleteOptions(req.GetVolumeAttributes())
+	if err != nil {
+		return nil, err
+	}
+	_, steps, err := s.deleteLocalVolume(ctx, req.VolumeHandle, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.dryRun {
+		// the response has no field for the steps, they are returned in a trailer of the call
+		if err := grpc.SetTrailer(ctx, metadata.MD{DryRunStepsTrailer: steps}); err != nil {
+			log.Warnf("can't return the dry run steps of deleting local volume %s: %s", req.VolumeHandle, err.Error())
+		}
+	}
+	return &csiext.DeleteLocalVolumeResponse{}, nil
+}
+
+// localVolumeDeleteOptions controls how deleteLocalVolume deletes a local volume
+type localVolumeDeleteOptions struct {
+	// force removes the volume from its volume groups and un-assigns its protection policy before deleting it,
+	// e.g. for orphans whose group or policy is already gone on the surviving side
+	force bool
+	// dryRun only reports the steps of the deletion
+	dryRun bool
+}
+
+// localVolumeDeleteOptions reads the force and dry run flags of DeleteLocalVolume from the volume attributes
+func (s *Service) localVolumeDeleteOptions(attributes map[string]string) (localVolumeDeleteOptions, error) {
+	var opts localVolumeDeleteOptions
+	for key, flag := range map[string]*bool{KeyReplicationForceDelete: &opts.force, KeyReplicationDryRun: &opts.dryRun} {
+		value, ok := attributes[s.WithRP(key)]
+		if !ok {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, status.Errorf(codes.InvalidArgument, "invalid value %s of %s, must be true or false", value, s.WithRP(key))
+		}
+		*flag = b
+	}
+	return opts, nil
+}
+
+// LocalVolumeDeleteStatus is the outcome of deleting a single local volume in DeleteLocalVolumes
+type LocalVolumeDeleteStatus string
+
+const (
+	// LocalVolumeDeleted means the volume was deleted
+	LocalVolumeDeleted LocalVolumeDeleteStatus = "deleted"
+	// LocalVolumeAlreadyGone means the volume did not exist anymore
+	LocalVolumeAlreadyGone LocalVolumeDeleteStatus = "already-gone"
+	// LocalVolumeDeleteFailed means the volume could not be deleted, see LocalVolumeDeleteResult.Err
+	LocalVolumeDeleteFailed LocalVolumeDeleteStatus = "error"
+)
+
+// LocalVolumeDeleteResult is the result of deleting the local volume with the given handle
+type LocalVolumeDeleteResult struct {
+	VolumeHandle string
+	Status       LocalVolumeDeleteStatus
+	Err          error
+}
+
+// getMaxConcurrentLocalVolumeDeletes returns the max number of local volumes deleted concurrently
+func (s *Service) getMaxConcurrentLocalVolumeDeletes() int {
+	if s.maxConcurrentLocalVolumeDeletes > 0 {
+		return s.maxConcurrentLocalVolumeDeletes
+	}
+	return identifiers.DefaultMaxConcurrentLocalVolumeDeletes
+}
+
+// DeleteLocalVolumes deletes the local volumes with the given handles, e.g. after a DR teardown, applying the
+// same checks as DeleteLocalVolume to every volume. The volumes are deleted in parallel, bounded by the max
+// number of concurrent local volume deletes. The results are returned in the order of volumeHandles.
+func (s *Service) DeleteLocalVolumes(ctx context.Context, volumeHandles []string) []LocalVolumeDeleteResult {
+	log.Infof("Deleting %d local volumes per request from remote replication controller", len(volumeHandles))
+
+	results := make([]LocalVolumeDeleteResult, len(volumeHandles))
+	sem := make(chan struct{}, s.getMaxConcurrentLocalVolumeDeletes())
+	var wg sync.WaitGroup
+	for i, handle := range volumeHandles {
+		wg.Add(1)
+		go func(i int, handle string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			result := LocalVolumeDeleteResult{VolumeHandle: handle, Status: LocalVolumeDeleted}
+			alreadyGone, _, err := s.deleteLocalVolume(ctx, handle, localVolumeDeleteOptions{})
+			if err != nil {
+				result.Status = LocalVolumeDeleteFailed
+				result.Err = err
+			} else if alreadyGone {
+				result.Status = LocalVolumeAlreadyGone
+			}
+			results[i] = result
+		}(i, handle)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// deleteLocalVolume deletes the local volume with the given handle unless it is part of a volume group
+// or under a protection policy, in which case it is first removed from them when opts.force is set.
+// It returns true when the volume did not exist anymore, along with the steps of the deletion, which
+// are only reported, not executed, when opts.dryRun is set.
+func (s *Service) deleteLocalVolume(ctx context.Context, volumeHandle string, opts localVolumeDeleteOptions) (bool, []string, error) {
+	// volumeHandle is of format <volumeid>/<array ID>/<protocol>. We only need the IDs.
+	splitHandle := strings.Split(volumeHandle, `/`)
 	if len(splitHandle) != 3 {
-		return nil, status.Errorf(codes.InvalidArgument, "can't delete volume of improper handle format")
+		return false, nil, status.Errorf(codes.InvalidArgument, "can't delete volume of improper handle format")
 	}
 	volumeID := splitHandle[0]
 	globalID := splitHandle[1]
 
 	arr, ok := s.Arrays()[globalID]
 	if !ok {
-		return nil, status.Errorf(codes.InvalidArgument, "can't find array with global ID %s", globalID)
+		return false, nil, status.Errorf(codes.InvalidArgument, "can't find array with global ID %s", globalID)
 	}
 
 	vol, err := arr.GetClient().GetVolume(ctx, volumeID)
@@ -568,40 +1215,357 @@ func (s *Service) DeleteLocalVolume(ctx context.Context,
 			if apiError.NotFound() {
 				// volume doesn't exist, return success
 				log.Info("Volume does not exist. It may have already been deleted.")
-				return &csiext.DeleteLocalVolumeResponse{}, nil
+				return true, nil, nil
 			}
 		}
 		// any other error means the volume to be deleted couldn't be retrieved, return error
-		return nil, status.Errorf(codes.Internal, "Error: Unable to get volume for deletion")
+		return false, nil, status.Errorf(codes.Internal, "Error: Unable to get volume for deletion")
 	}
 
 	vgs, err := arr.GetClient().GetVolumeGroupsByVolumeID(ctx, volumeID)
 	if err != nil {
 		if apiError, ok := err.(gopowerstore.APIError); !ok || !apiError.NotFound() {
-			return nil, err
+			return false, nil, err
 		}
 	}
 
-	// Do not proceed to DeleteVolume if there is a volume group or protection policy.
+	// Do not proceed to DeleteVolume if there is a volume group or protection policy, unless forced.
 	// DeleteVolume would remove those, and source-side deletion is the responsible party for that operation.
-	if len(vgs.VolumeGroup) != 0 {
-		log.Info("Cannot delete local volume " + volumeID + ", volume is part of a Volume Group and needs to be removed first.")
-		return nil, status.Errorf(codes.Internal, "Error: Unable to delete volume")
-	} else if vol.ProtectionPolicyID != "" {
-		log.Info("Cannot delete local volume " + volumeID + ", volume is under a protection policy that must be removed first.")
-		return nil, status.Errorf(codes.Internal, "Error: Unable to delete volume")
+	if !opts.force {
+		if len(vgs.VolumeGroup) != 0 {
+			log.Info("Cannot delete local volume " + volumeID + ", volume is part of a Volume Group and needs to be removed first.")
+			return false, nil, status.Errorf(codes.Internal, "Error: Unable to delete volume")
+		} else if vol.ProtectionPolicyID != "" {
+			log.Info("Cannot delete local volume " + volumeID + ", volume is under a protection policy that must be removed first.")
+			return false, nil, status.Errorf(codes.Internal, "Error: Unable to delete volume")
+		}
+	}
+
+	var steps []string
+	for _, vg := range vgs.VolumeGroup {
+		steps = append(steps, fmt.Sprintf("remove volume %s from volume group %s", volumeID, vg.ID))
+	}
+	if vol.ProtectionPolicyID != "" {
+		steps = append(steps, fmt.Sprintf("un-assign protection policy %s from volume %s", vol.ProtectionPolicyID, volumeID))
+	}
+	steps = append(steps, fmt.Sprintf("delete volume %s", volumeID))
+	if opts.dryRun {
+		log.Infof("Dry run of deleting local volume %s would %s", volumeID, strings.Join(steps, ", "))
+		return false, steps, nil
+	}
+
+	for _, vg := range vgs.VolumeGroup {
+		_, err = arr.GetClient().RemoveMembersFromVolumeGroup(ctx, &gopowerstore.VolumeGroupMembers{VolumeIDs: []string{volumeID}}, vg.ID)
+		if err != nil {
+			if apiError, ok := err.(gopowerstore.APIError); !ok || !apiError.VolumeAlreadyRemovedFromVolumeGroup() {
+				return false, steps, status.Errorf(codes.Internal, "Error: Unable to remove volume %s from volume group %s: %s",
+					volumeID, vg.ID, apiErrorDetails(err))
+			}
+		}
+		log.Info("Forcibly removed local volume " + volumeID + " from volume group " + vg.ID + ".")
+	}
+	if vol.ProtectionPolicyID != "" {
+		_, err = arr.GetClient().ModifyVolume(ctx, &gopowerstore.VolumeModify{ProtectionPolicyID: ""}, volumeID)
+		if err != nil {
+			return false, steps, status.Errorf(codes.Internal, "Error: Unable to un-assign protection policy %s from volume %s: %s",
+				vol.ProtectionPolicyID, volumeID, apiErrorDetails(err))
+		}
+		log.Info("Forcibly un-assigned protection policy " + vol.ProtectionPolicyID + " from local volume " + volumeID + ".")
 	}
 
 	_, err = arr.GetClient().DeleteVolume(ctx, nil, volumeID)
 	if err != nil {
 		if apiErr, ok := err.(gopowerstore.APIError); !ok || !apiErr.NotFound() {
 			log.Info("Cannot delete local volume " + volumeID + ", deletion returned a non-404 error code.")
-			return nil, status.Errorf(codes.Internal, "Error: Unable to delete volume")
+			return false, steps, status.Errorf(codes.Internal, "Error: Unable to delete volume")
 		}
+		log.Info("Local volume " + volumeID + " was already deleted.")
+		return true, steps, nil
 	}
 
 	log.Info("Local volume deleted successfully.")
-	return &csiext.DeleteLocalVolumeResponse{}, nil
+	return false, steps, nil
+}
+
+// ReplicationSessionActionStatus is the outcome of an action on a single replication session in
+// SuspendReplicationToRemote and ResumeReplicationToRemote
+type ReplicationSessionActionStatus string
+
+const (
+	// ReplicationSessionActionDone means the action was executed on the session
+	ReplicationSessionActionDone ReplicationSessionActionStatus = "done"
+	// ReplicationSessionAlreadyInState means the session was already in the desired state
+	ReplicationSessionAlreadyInState ReplicationSessionActionStatus = "already-in-state"
+	// ReplicationSessionActionFailed means the action could not be executed, see ReplicationSessionActionResult.Err
+	ReplicationSessionActionFailed ReplicationSessionActionStatus = "error"
+)
+
+// ReplicationSessionActionResult is the result of an action on the replication session of a local volume group
+type ReplicationSessionActionResult struct {
+	SessionID       string
+	LocalResourceID string
+	Status          ReplicationSessionActionStatus
+	Err             error
+}
+
+// SuspendReplicationToRemote pauses every replication session from the array with the given globalID
+// to the remote system with the given ID, e.g. ahead of planned maintenance of the remote array.
+func (s *Service) SuspendReplicationToRemote(ctx context.Context, globalID, remoteSystemID string) ([]ReplicationSessionActionResult, error) {
+	return s.executeActionToRemote(ctx, globalID, remoteSystemID, gopowerstore.RsActionPause)
+}
+
+// ResumeReplicationToRemote resumes every replication session from the array with the given globalID
+// to the remote system with the given ID, e.g. after SuspendReplicationToRemote.
+func (s *Service) ResumeReplicationToRemote(ctx context.Context, globalID, remoteSystemID string) ([]ReplicationSessionActionResult, error) {
+	return s.executeActionToRemote(ctx, globalID, remoteSystemID, gopowerstore.RsActionResume)
+}
+
+// executeActionToRemote executes action on the replication sessions of all protected volume groups of the array
+// that replicate to remoteSystemID. Sessions to other remote systems are left untouched.
+func (s *Service) executeActionToRemote(ctx context.Context, globalID, remoteSystemID string,
+	action gopowerstore.ActionType,
+) ([]ReplicationSessionActionResult, error) {
+	arr, ok := s.Arrays()[globalID]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "can't find array with global id %s", globalID)
+	}
+	if remoteSystemID == "" {
+		return nil, status.Error(codes.InvalidArgument, "remote system ID is required")
+	}
+	ctx, logger := withReplicationLogFields(ctx, globalID)
+	logger = logger.WithFields(log.Fields{
+		"RemoteSystem": remoteSystemID,
+		"Action":       action,
+	})
+
+	vgs, err := arr.GetClient().GetVolumeGroups(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "can't list volume groups: %s", apiErrorDetails(err))
+	}
+
+	var results []ReplicationSessionActionResult
+	for _, vg := range vgs {
+		if vg.ProtectionPolicyID == "" {
+			continue
+		}
+		rs, err := arr.GetClient().GetReplicationSessionByLocalResourceID(ctx, vg.ID)
+		if err != nil {
+			if isNoReplicationSessionError(err) {
+				continue
+			}
+			results = append(results, ReplicationSessionActionResult{
+				LocalResourceID: vg.ID,
+				Status:          ReplicationSessionActionFailed,
+				Err: status.Errorf(codes.Internal, "can't get replication session of volume group %s: %s",
+					vg.ID, apiErrorDetails(err)),
+			})
+			continue
+		}
+		if rs.RemoteSystemID != remoteSystemID {
+			continue
+		}
+
+		result := ReplicationSessionActionResult{SessionID: rs.ID, LocalResourceID: vg.ID, Status: ReplicationSessionActionDone}
+		inDesiredState, actionRequired, _ := validateRSState(&rs, action)
+		switch {
+		case inDesiredState:
+			result.Status = ReplicationSessionAlreadyInState
+		case !actionRequired:
+			result.Status = ReplicationSessionActionFailed
+			result.Err = status.Errorf(codes.Aborted, "RS (%s) is still executing previous action", rs.ID)
+		default:
+			if _, err := arr.GetClient().ExecuteActionOnReplicationSession(ctx, rs.ID, action, nil); err != nil {
+				result.Status = ReplicationSessionActionFailed
+				result.Err = status.Errorf(codes.Internal, "failed to modify RS (%s): %s", rs.ID, apiErrorDetails(err))
+			}
+		}
+		logger.Infof("Replication session %s of volume group %s: %s", rs.ID, vg.ID, result.Status)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// ReplicationSessionDump describes a replication session of a volume group in a support bundle
+type ReplicationSessionDump struct {
+	ID               string                   `json:"id"`
+	State            gopowerstore.RSStateEnum `json:"state"`
+	Role             string                   `json:"role"`
+	Type             string                   `json:"type"`
+	RemoteSystemID   string                   `json:"remoteSystemID"`
+	RemoteResourceID string                   `json:"remoteResourceID"`
+	VolumeGroupID    string                   `json:"volumeGroupID"`
+	VolumeGroupName  string                   `json:"volumeGroupName"`
+}
+
+// ArrayReplicationSessionsDump lists the replication sessions of one array in a support bundle.
+// Only the endpoint and global ID of the array are included, never its credentials.
+type ArrayReplicationSessionsDump struct {
+	GlobalID string                   `json:"globalID"`
+	Endpoint string                   `json:"endpoint"`
+	Sessions []ReplicationSessionDump `json:"sessions"`
+	Errors   []string                 `json:"errors,omitempty"`
+}
+
+// ReplicationSessionsDump is the support bundle snapshot of the replication sessions of all configured arrays
+type ReplicationSessionsDump struct {
+	Arrays []ArrayReplicationSessionsDump `json:"arrays"`
+}
+
+// DumpReplicationSessions returns a JSON snapshot of the replication sessions of the protected volume groups
+// of every configured array, for support cases. Arrays are ordered by global ID and sessions by volume group name,
+// so that dumps taken at different times can be compared. Failures to query an array are recorded in the dump
+// instead of failing the call.
+func (s *Service) DumpReplicationSessions(ctx context.Context) ([]byte, error) {
+	arrays := s.Arrays()
+	globalIDs := make([]string, 0, len(arrays))
+	for globalID := range arrays {
+		globalIDs = append(globalIDs, globalID)
+	}
+	sort.Strings(globalIDs)
+
+	dump := ReplicationSessionsDump{Arrays: make([]ArrayReplicationSessionsDump, 0, len(globalIDs))}
+	for _, globalID := range globalIDs {
+		dump.Arrays = append(dump.Arrays, dumpArrayReplicationSessions(ctx, arrays[globalID]))
+	}
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "can't serialize replication sessions: %s", err.Error())
+	}
+	return data, nil
+}
+
+// dumpArrayReplicationSessions collects the replication sessions of the protected volume groups of arr
+func dumpArrayReplicationSessions(ctx context.Context, arr *array.PowerStoreArray) ArrayReplicationSessionsDump {
+	result := ArrayReplicationSessionsDump{
+		GlobalID: arr.GlobalID,
+		Endpoint: arr.Endpoint,
+		Sessions: []ReplicationSessionDump{},
+	}
+	ctx, logger := withReplicationLogFields(ctx, arr.GlobalID)
+
+	vgs, err := arr.GetClient().GetVolumeGroups(ctx)
+	if err != nil {
+		logger.Errorf("Can't list volume groups: %s", apiErrorDetails(err))
+		result.Errors = append(result.Errors, fmt.Sprintf("can't list volume groups: %s", apiErrorDetails(err)))
+		return result
+	}
+
+	for _, vg := range vgs {
+		if vg.ProtectionPolicyID == "" {
+			continue
+		}
+		rs, err := arr.GetClient().GetReplicationSessionByLocalResourceID(ctx, vg.ID)
+		if err != nil {
+			if isNoReplicationSessionError(err) {
+				continue
+			}
+			result.Errors = append(result.Errors, fmt.Sprintf("can't get replication session of volume group %s: %s",
+				vg.ID, apiErrorDetails(err)))
+			continue
+		}
+		result.Sessions = append(result.Sessions, ReplicationSessionDump{
+			ID:               rs.ID,
+			State:            rs.State,
+			Role:             rs.Role,
+			Type:             rs.Type,
+			RemoteSystemID:   rs.RemoteSystemID,
+			RemoteResourceID: rs.RemoteResourceID,
+			VolumeGroupID:    vg.ID,
+			VolumeGroupName:  vg.Name,
+		})
+	}
+	sort.Slice(result.Sessions, func(i, j int) bool {
+		if result.Sessions[i].VolumeGroupName != result.Sessions[j].VolumeGroupName {
+			return result.Sessions[i].VolumeGroupName < result.Sessions[j].VolumeGroupName
+		}
+		return result.Sessions[i].ID < result.Sessions[j].ID
+	})
+	return result
+}
+
+// OrphanedReplicationObjects lists the names of the driver created protection policies and replication rules
+// that are no longer referenced on an array.
+type OrphanedReplicationObjects struct {
+	ProtectionPolicies []string
+	ReplicationRules   []string
+}
+
+// CleanupOrphanedReplicationObjects finds the "pp-" protection policies and "rr-" replication rules on the array
+// that are left behind by failed DeleteStorageProtectionGroup calls and, unless dryRun is set, deletes them.
+// A protection policy is orphaned when no volume, volume group, file system or virtual machine uses it,
+// a replication rule when every protection policy using it is orphaned. Replicas of remote objects are skipped.
+func (s *Service) CleanupOrphanedReplicationObjects(ctx context.Context, arrayID string, dryRun bool) (*OrphanedReplicationObjects, error) {
+	ctx, logger := withReplicationLogFields(ctx, arrayID)
+
+	arr, ok := s.Arrays()[arrayID]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "can't find array with provided id %s", arrayID)
+	}
+
+	pps, err := arr.GetClient().GetProtectionPolicies(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "can't list protection policies: %s", apiErrorDetails(err))
+	}
+	rrs, err := arr.GetClient().GetReplicationRules(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "can't list replication rules: %s", apiErrorDetails(err))
+	}
+
+	orphans := &OrphanedReplicationObjects{}
+	orphanedPPs := make(map[string]bool)
+	var deletePPs, deleteRRs []string
+	for _, pp := range pps {
+		if !strings.HasPrefix(pp.Name, "pp-") || pp.IsReplica || pp.IsReadOnly {
+			continue
+		}
+		if len(pp.Volumes) > 0 || len(pp.VolumeGroups) > 0 || len(pp.FileSystems) > 0 || len(pp.VirtualMachines) > 0 {
+			continue
+		}
+		orphanedPPs[pp.ID] = true
+		orphans.ProtectionPolicies = append(orphans.ProtectionPolicies, pp.Name)
+		deletePPs = append(deletePPs, pp.ID)
+	}
+	for _, rr := range rrs {
+		if !strings.HasPrefix(rr.Name, "rr-") || rr.IsReplica || rr.IsReadOnly {
+			continue
+		}
+		referenced := false
+		for _, pp := range rr.ProtectionPolicies {
+			if !orphanedPPs[pp.ID] {
+				referenced = true
+				break
+			}
+		}
+		if referenced {
+			continue
+		}
+		orphans.ReplicationRules = append(orphans.ReplicationRules, rr.Name)
+		deleteRRs = append(deleteRRs, rr.ID)
+	}
+
+	if dryRun {
+		logger.Infof("Found %d orphaned protection policies and %d orphaned replication rules (dry run)",
+			len(orphans.ProtectionPolicies), len(orphans.ReplicationRules))
+		return orphans, nil
+	}
+
+	// policies go first, a replication rule can't be deleted while a policy still uses it
+	for i, id := range deletePPs {
+		logger.Infof("Deleting orphaned protection policy %s", orphans.ProtectionPolicies[i])
+		_, err := arr.GetClient().DeleteProtectionPolicy(ctx, id)
+		if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
+			return orphans, status.Errorf(codes.Internal, "Error: Unable to delete PP %s: %s", orphans.ProtectionPolicies[i], apiErrorDetails(apiErr))
+		}
+	}
+	for i, id := range deleteRRs {
+		logger.Infof("Deleting orphaned replication rule %s", orphans.ReplicationRules[i])
+		_, err := arr.GetClient().DeleteReplicationRule(ctx, id)
+		if apiErr, ok := err.(gopowerstore.APIError); ok && !apiErr.NotFound() {
+			return orphans, status.Errorf(codes.Internal, "Error: Unable to delete replication rule %s: %s", orphans.ReplicationRules[i], apiErrorDetails(apiErr))
+		}
+	}
+	return orphans, nil
 }
 
 // GetStorageProtectionGroupStatus gets storage protection group status
@@ -611,7 +1575,7 @@ func (s *Service) GetStorageProtectionGroupStatus(ctx context.Context,
 	localParams := req.GetProtectionGroupAttributes()
 	groupID := req.GetProtectionGroupId()
 
-	globalID, ok := localParams[s.replicationContextPrefix+"globalID"]
+	globalID, ok := localParams[s.withContextPrefix("globalID")]
 	if !ok {
 		return nil, status.Error(codes.InvalidArgument, "missing globalID in protection group attributes")
 	}
@@ -631,38 +1595,123 @@ func (s *Service) GetStorageProtectionGroupStatus(ctx context.Context,
 		return nil, err
 	}
 
-	var state csiext.StorageProtectionGroupStatus_State
-	switch rs.State {
+	state := protectionGroupState(rs.State)
+	log.Infof("The current state for replication session (%s) for group (%s) is (%s): %s.", rs.ID, groupID, state.String(), StateDescription(rs.State))
+	resp := &csiext.GetStorageProtectionGroupStatusResponse{
+		Status: &csiext.StorageProtectionGroupStatus{
+			State:    state,
+			IsSource: rs.Role != "Destination",
+		},
+	}
+	return resp, err
+}
+
+// protectionGroupState maps the state of a replication session to the state of its storage protection group
+func protectionGroupState(state gopowerstore.RSStateEnum) csiext.StorageProtectionGroupStatus_State {
+	switch state {
 	case gopowerstore.RsStateOk:
-		state = csiext.StorageProtectionGroupStatus_SYNCHRONIZED
-		break
+		return csiext.StorageProtectionGroupStatus_SYNCHRONIZED
 	case gopowerstore.RsStateFailedOver:
-		state = csiext.StorageProtectionGroupStatus_FAILEDOVER
-		break
+		return csiext.StorageProtectionGroupStatus_FAILEDOVER
 	case gopowerstore.RsStatePaused, gopowerstore.RsStatePausedForMigration, gopowerstore.RsStatePausedForNdu, gopowerstore.RsStateSystemPaused:
-		state = csiext.StorageProtectionGroupStatus_SUSPENDED
-		break
+		return csiext.StorageProtectionGroupStatus_SUSPENDED
 	case gopowerstore.RsStateFailingOver, gopowerstore.RsStateFailingOverForDR, gopowerstore.RsStateResuming,
 		gopowerstore.RsStateReprotecting, gopowerstore.RsStatePartialCutoverForMigration, gopowerstore.RsStateSynchronizing,
 		gopowerstore.RsStateInitializing:
-		state = csiext.StorageProtectionGroupStatus_SYNC_IN_PROGRESS
-		break
+		return csiext.StorageProtectionGroupStatus_SYNC_IN_PROGRESS
 	case gopowerstore.RsStateError:
-		state = csiext.StorageProtectionGroupStatus_INVALID
-		break
+		return csiext.StorageProtectionGroupStatus_INVALID
 	default:
-		log.Infof("The status (%s) does not match with known protection group states", rs.State)
-		state = csiext.StorageProtectionGroupStatus_UNKNOWN
-		break
+		log.Infof("The status (%s) does not match with known protection group states", state)
+		return csiext.StorageProtectionGroupStatus_UNKNOWN
 	}
-	log.Infof("The current state for replication session (%s) for group (%s) is (%s).", rs.ID, groupID, state.String())
-	resp := &csiext.GetStorageProtectionGroupStatusResponse{
-		Status: &csiext.StorageProtectionGroupStatus{
-			State:    state,
-			IsSource: rs.Role != "Destination",
-		},
+}
+
+// StateDescription returns a human-readable explanation of the given replication session state
+func StateDescription(state gopowerstore.RSStateEnum) string {
+	switch state {
+	case gopowerstore.RsStateInitializing:
+		return "Initializing — replication session is being set up"
+	case gopowerstore.RsStateOk:
+		return "OK — replication session is operating normally"
+	case gopowerstore.RsStateSynchronizing:
+		return "Synchronizing — data is being copied to the destination"
+	case gopowerstore.RsStateSystemPaused:
+		return "System paused — replication suspended by the system"
+	case gopowerstore.RsStatePaused:
+		return "Paused — replication suspended by the user"
+	case gopowerstore.RsStatePausedForMigration:
+		return "Paused for migration — replication suspended while a migration is in progress"
+	case gopowerstore.RsStatePausedForNdu:
+		return "Paused for NDU — replication suspended during non-disruptive upgrade"
+	case gopowerstore.RsStateFractured:
+		return "Fractured — replication link is broken and the sides are out of sync"
+	case gopowerstore.RsStateResuming:
+		return "Resuming — replication session is resuming after a pause"
+	case gopowerstore.RsStateFailingOver:
+		return "Failing over — planned failover to the destination is in progress"
+	case gopowerstore.RsStateFailingOverForDR:
+		return "Failing over for DR — unplanned failover to the destination is in progress"
+	case gopowerstore.RsStateFailedOver:
+		return "Failed over — destination has taken over as the source"
+	case gopowerstore.RsStateReprotecting:
+		return "Reprotecting — replication direction is being re-established"
+	case gopowerstore.RsStatePartialCutoverForMigration:
+		return "Partial cutover for migration — migration cutover has only partially completed"
+	case gopowerstore.RsStateSwitchingToMetroSync:
+		return "Switching to metro sync — session is transitioning to metro synchronous replication"
+	case gopowerstore.RsStateError:
+		return "Error — replication session is in an error state and requires attention"
+	default:
+		return fmt.Sprintf("Unknown — replication session reported unrecognized state %q", string(state))
+	}
+}
+
+// withReplicationLogFields attaches globalID to the log fields carried by ctx, which already include the request ID.
+// It returns the derived context along with a logger scoped to those fields.
+func withReplicationLogFields(ctx context.Context, globalID string) (context.Context, *log.Entry) {
+	logFields := log.Fields{}
+	for k, v := range identifiers.GetLogFields(ctx) {
+		logFields[k] = v
+	}
+	if globalID != "" {
+		logFields["GlobalID"] = globalID
+	}
+	return identifiers.SetLogFields(ctx, logFields), log.WithFields(logFields)
+}
+
+// isNoReplicationSessionError returns true if err reports that no replication session exists for the resource,
+// e.g. because no protection policy is applied to the volume group yet or its session is still initializing
+func isNoReplicationSessionError(err error) bool {
+	apiErr, ok := err.(gopowerstore.APIError)
+	return ok && apiErr.ErrorMsg != nil && apiErr.NotFound()
+}
+
+// noReplicationSessionMessage describes a volume group that isn't replicating yet
+func noReplicationSessionMessage(vg gopowerstore.VolumeGroup) string {
+	name := vg.Name
+	if name == "" {
+		name = vg.ID
+	}
+	return fmt.Sprintf("volume group %s has no replication session; ensure protection policy with a replication rule is applied", name)
+}
+
+// isReplicationSessionBusy returns true if the replication session is in a transitional state,
+// where data is being transferred or the replication direction is changing
+func isReplicationSessionBusy(state gopowerstore.RSStateEnum) bool {
+	switch state {
+	case gopowerstore.RsStateInitializing,
+		gopowerstore.RsStateSynchronizing,
+		gopowerstore.RsStateResuming,
+		gopowerstore.RsStateFailingOver,
+		gopowerstore.RsStateFailingOverForDR,
+		gopowerstore.RsStateReprotecting,
+		gopowerstore.RsStatePartialCutoverForMigration,
+		gopowerstore.RsStateSwitchingToMetroSync:
+		return true
+	default:
+		return false
 	}
-	return resp, err
 }
 
 // WithRP appends Replication Prefix to provided string
@@ -675,11 +1724,54 @@ func (s *Service) WithRP(key string) string {
 	return replicationPrefix + "/" + key
 }
 
+// withContextPrefix appends Replication Context Prefix to provided string
+func (s *Service) withContextPrefix(key string) string {
+	return normalizeContextPrefix(s.replicationContextPrefix) + key
+}
+
+// normalizeContextPrefix makes sure a non-empty context prefix ends with exactly one "/" separator
+func normalizeContextPrefix(prefix string) string {
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// defaultManagementPort is the PowerStore REST API port used when an endpoint doesn't specify one.
+const defaultManagementPort = "443"
+
+// managementPort returns the port of the given array endpoint, falling back to defaultManagementPort.
+func managementPort(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Port() == "" {
+		return defaultManagementPort
+	}
+	return u.Port()
+}
+
 func getRemoteCSIVolume(volumeID string, size int64) *csiext.Volume {
 	volume := &csiext.Volume{
 		CapacityBytes: size,
 		VolumeId:      volumeID,
-		VolumeContext: nil, // TODO: add values to volume context if needed
 	}
 	return volume
 }
+
+// addProvisioningAttributes copies the provisioning attributes of the source volume that are set into the
+// remote volume context, so the remote PVC can be created with matching characteristics.
+// Performance policy and application type use the same keys as the storage class parameters.
+func (s *Service) addProvisioningAttributes(volumeContext map[string]string, vol gopowerstore.Volume) {
+	if vol.PerformancePolicyID != "" {
+		volumeContext[identifiers.KeyPerformancePolicyID] = vol.PerformancePolicyID
+	}
+	if vol.AppType != "" {
+		volumeContext[identifiers.KeyAppType] = string(vol.AppType)
+		if vol.AppTypeOther != "" {
+			volumeContext[identifiers.KeyAppTypeOther] = vol.AppTypeOther
+		}
+	}
+	if vol.LogicalUsed > 0 {
+		volumeContext[s.withContextPrefix("logicalUsed")] = strconv.FormatInt(vol.LogicalUsed, 10)
+	}
+}
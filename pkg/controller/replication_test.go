@@ -18,22 +18,31 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers/fs"
 	"github.com/dell/csm-sharednfs/nfs"
 	csiext "github.com/dell/dell-csi-extensions/replication"
+	csictx "github.com/dell/gocsi/context"
 	"github.com/dell/gopowerstore"
 	"github.com/dell/gopowerstore/api"
 	gopowerstoreMock "github.com/dell/gopowerstore/mocks"
 	ginkgo "github.com/onsi/ginkgo"
 	gomega "github.com/onsi/gomega"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -61,6 +70,36 @@ var _ = ginkgo.Describe("Replication", func() {
 			})
 		})
 
+		ginkgo.When("getting storage protection group status of a NAS server", func() {
+			ginkgo.It("should return synchronized status if the session is ok", func() {
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validNasID).Return(
+					gopowerstore.ReplicationSession{State: gopowerstore.RsStateOk, ResourceType: "nas_server", LocalResourceID: validNasID}, nil)
+
+				req := &csiext.GetStorageProtectionGroupStatusRequest{
+					ProtectionGroupId:         validNasID,
+					ProtectionGroupAttributes: map[string]string{"globalID": firstValidID, "NASServerName": validNasName},
+				}
+				res, err := ctrlSvc.GetStorageProtectionGroupStatus(context.Background(), req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Status.State).To(gomega.Equal(csiext.StorageProtectionGroupStatus_SYNCHRONIZED))
+				gomega.Expect(res.Status.IsSource).To(gomega.BeTrue())
+			})
+			ginkgo.It("should return suspended status if the session is paused", func() {
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validNasID).Return(
+					gopowerstore.ReplicationSession{State: gopowerstore.RsStatePaused, ResourceType: "nas_server", LocalResourceID: validNasID}, nil)
+
+				req := &csiext.GetStorageProtectionGroupStatusRequest{
+					ProtectionGroupId:         validNasID,
+					ProtectionGroupAttributes: map[string]string{"globalID": firstValidID, "NASServerName": validNasName},
+				}
+				res, err := ctrlSvc.GetStorageProtectionGroupStatus(context.Background(), req)
+
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res.Status.State).To(gomega.Equal(csiext.StorageProtectionGroupStatus_SUSPENDED))
+			})
+		})
+
 		ginkgo.When("getting storage protection group status and state is failed over", func() {
 			ginkgo.It("should return failed over status", func() {
 				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, mock.Anything).Return(
@@ -556,6 +595,309 @@ var _ = ginkgo.Describe("Replication", func() {
 					gomega.Expect(res).ToNot(gomega.BeNil())
 				})
 			})
+			ginkgo.When("deletion of a volume in a volume group is forced", func() {
+				ginkgo.It("should remove the volume from the group before deleting it", func() {
+					clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+						Return(gopowerstore.Volume{ID: validBaseVolID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+						Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID}}}, nil)
+					clientMock.On("RemoveMembersFromVolumeGroup", mock.Anything,
+						&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{validBaseVolID}}, validGroupID).
+						Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("DeleteVolume",
+						mock.Anything,
+						mock.AnythingOfType("*gopowerstore.VolumeDelete"),
+						validBaseVolID).
+						Return(gopowerstore.EmptyResponse(""), nil)
+
+					req := &csiext.DeleteLocalVolumeRequest{
+						VolumeHandle:     validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+						VolumeAttributes: map[string]string{ctrlSvc.WithRP(KeyReplicationForceDelete): "true"},
+					}
+					res, err := ctrlSvc.DeleteLocalVolume(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res).ToNot(gomega.BeNil())
+					clientMock.AssertCalled(ginkgo.GinkgoT(), "RemoveMembersFromVolumeGroup", mock.Anything,
+						&gopowerstore.VolumeGroupMembers{VolumeIDs: []string{validBaseVolID}}, validGroupID)
+					clientMock.AssertCalled(ginkgo.GinkgoT(), "DeleteVolume", mock.Anything,
+						mock.AnythingOfType("*gopowerstore.VolumeDelete"), validBaseVolID)
+				})
+			})
+			ginkgo.When("deletion of a protected volume is forced", func() {
+				ginkgo.It("should un-assign the protection policy before deleting the volume", func() {
+					clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+						Return(gopowerstore.Volume{ID: validBaseVolID, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+						Return(gopowerstore.VolumeGroups{}, nil)
+					clientMock.On("ModifyVolume", mock.Anything,
+						&gopowerstore.VolumeModify{ProtectionPolicyID: ""}, validBaseVolID).
+						Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("DeleteVolume",
+						mock.Anything,
+						mock.AnythingOfType("*gopowerstore.VolumeDelete"),
+						validBaseVolID).
+						Return(gopowerstore.EmptyResponse(""), nil)
+
+					req := &csiext.DeleteLocalVolumeRequest{
+						VolumeHandle:     validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+						VolumeAttributes: map[string]string{ctrlSvc.WithRP(KeyReplicationForceDelete): "true"},
+					}
+					res, err := ctrlSvc.DeleteLocalVolume(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res).ToNot(gomega.BeNil())
+					clientMock.AssertCalled(ginkgo.GinkgoT(), "ModifyVolume", mock.Anything,
+						&gopowerstore.VolumeModify{ProtectionPolicyID: ""}, validBaseVolID)
+				})
+				ginkgo.It("should fail when the protection policy can't be un-assigned", func() {
+					clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+						Return(gopowerstore.Volume{ID: validBaseVolID, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+						Return(gopowerstore.VolumeGroups{}, nil)
+					clientMock.On("ModifyVolume", mock.Anything,
+						&gopowerstore.VolumeModify{ProtectionPolicyID: ""}, validBaseVolID).
+						Return(gopowerstore.EmptyResponse(""), gopowerstore.WrapErr(gopowerstore.NewAPIError()))
+
+					req := &csiext.DeleteLocalVolumeRequest{
+						VolumeHandle:     validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+						VolumeAttributes: map[string]string{ctrlSvc.WithRP(KeyReplicationForceDelete): "true"},
+					}
+					res, err := ctrlSvc.DeleteLocalVolume(context.Background(), req)
+
+					gomega.Expect(res).To(gomega.BeNil())
+					gomega.Expect(err).ToNot(gomega.BeNil())
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+						"Unable to un-assign protection policy " + validPolicyID,
+					))
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteVolume", mock.Anything, mock.Anything, mock.Anything)
+				})
+			})
+			ginkgo.When("a forced deletion is a dry run", func() {
+				ginkgo.It("should report the steps without executing them", func() {
+					clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+						Return(gopowerstore.Volume{ID: validBaseVolID, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+						Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID}}}, nil)
+
+					alreadyGone, steps, err := ctrlSvc.deleteLocalVolume(context.Background(),
+						validBaseVolID+"/"+firstValidID+"/"+"iscsi", localVolumeDeleteOptions{force: true, dryRun: true})
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(alreadyGone).To(gomega.BeFalse())
+					gomega.Expect(steps).To(gomega.Equal([]string{
+						"remove volume " + validBaseVolID + " from volume group " + validGroupID,
+						"un-assign protection policy " + validPolicyID + " from volume " + validBaseVolID,
+						"delete volume " + validBaseVolID,
+					}))
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "RemoveMembersFromVolumeGroup", mock.Anything, mock.Anything, mock.Anything)
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "ModifyVolume", mock.Anything, mock.Anything, mock.Anything)
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteVolume", mock.Anything, mock.Anything, mock.Anything)
+				})
+				ginkgo.It("should return the steps in a trailer of DeleteLocalVolume", func() {
+					clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+						Return(gopowerstore.Volume{ID: validBaseVolID, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+						Return(gopowerstore.VolumeGroups{}, nil)
+
+					stream := &trailerStream{}
+					req := &csiext.DeleteLocalVolumeRequest{
+						VolumeHandle: validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+						VolumeAttributes: map[string]string{
+							ctrlSvc.WithRP(KeyReplicationForceDelete): "true",
+							ctrlSvc.WithRP(KeyReplicationDryRun):      "true",
+						},
+					}
+					res, err := ctrlSvc.DeleteLocalVolume(grpc.NewContextWithServerTransportStream(context.Background(), stream), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res).To(gomega.Equal(&csiext.DeleteLocalVolumeResponse{}))
+					gomega.Expect(stream.trailer.Get(DryRunStepsTrailer)).To(gomega.Equal([]string{
+						"un-assign protection policy " + validPolicyID + " from volume " + validBaseVolID,
+						"delete volume " + validBaseVolID,
+					}))
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteVolume", mock.Anything, mock.Anything, mock.Anything)
+				})
+				ginkgo.It("should still refuse a protected volume when not forced", func() {
+					clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+						Return(gopowerstore.Volume{ID: validBaseVolID, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+						Return(gopowerstore.VolumeGroups{}, nil)
+
+					req := &csiext.DeleteLocalVolumeRequest{
+						VolumeHandle:     validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+						VolumeAttributes: map[string]string{ctrlSvc.WithRP(KeyReplicationDryRun): "true"},
+					}
+					res, err := ctrlSvc.DeleteLocalVolume(context.Background(), req)
+
+					gomega.Expect(res).To(gomega.BeNil())
+					gomega.Expect(err).ToNot(gomega.BeNil())
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring("Unable to delete volume"))
+				})
+			})
+			ginkgo.When("the force flag is not a boolean", func() {
+				ginkgo.It("should fail", func() {
+					req := &csiext.DeleteLocalVolumeRequest{
+						VolumeHandle:     validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+						VolumeAttributes: map[string]string{ctrlSvc.WithRP(KeyReplicationForceDelete): "yes please"},
+					}
+					res, err := ctrlSvc.DeleteLocalVolume(context.Background(), req)
+
+					gomega.Expect(res).To(gomega.BeNil())
+					gomega.Expect(err).ToNot(gomega.BeNil())
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring("must be true or false"))
+				})
+			})
+		})
+
+		ginkgo.Describe("calling DeleteLocalVolumes()", func() {
+			ginkgo.When("deleting a mix of deletable, deleted and protected volumes", func() {
+				ginkgo.It("should report a result per volume handle", func() {
+					deletedVolID := "deleted-vol-id"
+					protectedVolID := "protected-vol-id"
+					groupedVolID := "grouped-vol-id"
+					ctrlSvc.maxConcurrentLocalVolumeDeletes = 2
+
+					clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+						Return(gopowerstore.Volume{ID: validBaseVolID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+						Return(gopowerstore.VolumeGroups{}, nil)
+					clientMock.On("DeleteVolume",
+						mock.Anything,
+						mock.AnythingOfType("*gopowerstore.VolumeDelete"),
+						validBaseVolID).
+						Return(gopowerstore.EmptyResponse(""), nil).Once()
+
+					clientMock.On("GetVolume", mock.Anything, deletedVolID).
+						Return(gopowerstore.Volume{}, gopowerstore.WrapErr(gopowerstore.NewNotFoundError()))
+
+					clientMock.On("GetVolume", mock.Anything, protectedVolID).
+						Return(gopowerstore.Volume{ID: protectedVolID, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, protectedVolID).
+						Return(gopowerstore.VolumeGroups{}, nil)
+
+					clientMock.On("GetVolume", mock.Anything, groupedVolID).
+						Return(gopowerstore.Volume{ID: groupedVolID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, groupedVolID).
+						Return(gopowerstore.VolumeGroups{VolumeGroup: []gopowerstore.VolumeGroup{{ID: validGroupID}}}, nil)
+
+					handles := []string{
+						validBaseVolID + "/" + firstValidID + "/" + "iscsi",
+						deletedVolID + "/" + firstValidID + "/" + "iscsi",
+						protectedVolID + "/" + firstValidID + "/" + "iscsi",
+						groupedVolID + "/" + firstValidID + "/" + "iscsi",
+						"improper-handle",
+					}
+					results := ctrlSvc.DeleteLocalVolumes(context.Background(), handles)
+
+					gomega.Expect(results).To(gomega.HaveLen(len(handles)))
+					for i, result := range results {
+						gomega.Expect(result.VolumeHandle).To(gomega.Equal(handles[i]))
+					}
+					gomega.Expect(results[0].Status).To(gomega.Equal(LocalVolumeDeleted))
+					gomega.Expect(results[0].Err).To(gomega.BeNil())
+					gomega.Expect(results[1].Status).To(gomega.Equal(LocalVolumeAlreadyGone))
+					gomega.Expect(results[1].Err).To(gomega.BeNil())
+					gomega.Expect(results[2].Status).To(gomega.Equal(LocalVolumeDeleteFailed))
+					gomega.Expect(results[2].Err.Error()).To(gomega.ContainSubstring("Unable to delete volume"))
+					gomega.Expect(results[3].Status).To(gomega.Equal(LocalVolumeDeleteFailed))
+					gomega.Expect(results[3].Err.Error()).To(gomega.ContainSubstring("Unable to delete volume"))
+					gomega.Expect(results[4].Status).To(gomega.Equal(LocalVolumeDeleteFailed))
+					gomega.Expect(results[4].Err.Error()).To(gomega.ContainSubstring("improper handle format"))
+
+					clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "DeleteVolume", 1)
+				})
+			})
+
+			ginkgo.When("the volume is removed while it is being deleted", func() {
+				ginkgo.It("should report the volume as already gone", func() {
+					clientMock.On("GetVolume", mock.Anything, validBaseVolID).
+						Return(gopowerstore.Volume{ID: validBaseVolID}, nil)
+					clientMock.On("GetVolumeGroupsByVolumeID", mock.Anything, validBaseVolID).
+						Return(gopowerstore.VolumeGroups{}, nil)
+					clientMock.On("DeleteVolume",
+						mock.Anything,
+						mock.AnythingOfType("*gopowerstore.VolumeDelete"),
+						validBaseVolID).
+						Return(gopowerstore.EmptyResponse(""), gopowerstore.WrapErr(gopowerstore.NewNotFoundError()))
+
+					results := ctrlSvc.DeleteLocalVolumes(context.Background(),
+						[]string{validBaseVolID + "/" + firstValidID + "/" + "iscsi"})
+
+					gomega.Expect(results).To(gomega.HaveLen(1))
+					gomega.Expect(results[0].Status).To(gomega.Equal(LocalVolumeAlreadyGone))
+					gomega.Expect(results[0].Err).To(gomega.BeNil())
+				})
+			})
+		})
+
+		ginkgo.Describe("calling SuspendReplicationToRemote() and ResumeReplicationToRemote()", func() {
+			otherRemoteSystemID := "other-remote-system-id"
+
+			mockSessions := func(firstState, secondState gopowerstore.RSStateEnum) {
+				clientMock.On("GetVolumeGroups", mock.Anything).Return([]gopowerstore.VolumeGroup{
+					{ID: "vg-1", ProtectionPolicyID: validPolicyID},
+					{ID: "vg-2", ProtectionPolicyID: validPolicyID},
+					{ID: "vg-other", ProtectionPolicyID: validPolicyID},
+					{ID: "vg-unprotected"},
+				}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, "vg-1").Return(
+					gopowerstore.ReplicationSession{ID: "rs-1", State: firstState, RemoteSystemID: validRemoteSystemID}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, "vg-2").Return(
+					gopowerstore.ReplicationSession{ID: "rs-2", State: secondState, RemoteSystemID: validRemoteSystemID}, nil)
+				clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, "vg-other").Return(
+					gopowerstore.ReplicationSession{ID: "rs-other", State: firstState, RemoteSystemID: otherRemoteSystemID}, nil)
+			}
+
+			ginkgo.When("suspending sessions to a remote system", func() {
+				ginkgo.It("should pause only the sessions to that remote", func() {
+					mockSessions("OK", "Paused")
+					clientMock.On("ExecuteActionOnReplicationSession", mock.Anything, "rs-1", gopowerstore.RsActionPause,
+						(*gopowerstore.FailoverParams)(nil)).Return(gopowerstore.EmptyResponse(""), nil)
+
+					results, err := ctrlSvc.SuspendReplicationToRemote(context.Background(), firstValidID, validRemoteSystemID)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(results).To(gomega.ConsistOf(
+						ReplicationSessionActionResult{SessionID: "rs-1", LocalResourceID: "vg-1", Status: ReplicationSessionActionDone},
+						ReplicationSessionActionResult{SessionID: "rs-2", LocalResourceID: "vg-2", Status: ReplicationSessionAlreadyInState},
+					))
+					clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "ExecuteActionOnReplicationSession", 1)
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "ExecuteActionOnReplicationSession",
+						mock.Anything, "rs-other", mock.Anything, mock.Anything)
+				})
+			})
+
+			ginkgo.When("resuming sessions to a remote system", func() {
+				ginkgo.It("should resume the paused sessions and report failures per session", func() {
+					mockSessions("Paused", "Failing_Over")
+					clientMock.On("ExecuteActionOnReplicationSession", mock.Anything, "rs-1", gopowerstore.RsActionResume,
+						(*gopowerstore.FailoverParams)(nil)).Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("ExecuteActionOnReplicationSession", mock.Anything, "rs-2", gopowerstore.RsActionResume,
+						(*gopowerstore.FailoverParams)(nil)).Return(gopowerstore.EmptyResponse(""),
+						gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusUnprocessableEntity}})
+
+					results, err := ctrlSvc.ResumeReplicationToRemote(context.Background(), firstValidID, validRemoteSystemID)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(results).To(gomega.HaveLen(2))
+					gomega.Expect(results[0].Status).To(gomega.Equal(ReplicationSessionActionDone))
+					gomega.Expect(results[1].Status).To(gomega.Equal(ReplicationSessionActionFailed))
+					gomega.Expect(results[1].Err.Error()).To(gomega.ContainSubstring("failed to modify RS (rs-2)"))
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "ExecuteActionOnReplicationSession",
+						mock.Anything, "rs-other", mock.Anything, mock.Anything)
+				})
+			})
+
+			ginkgo.When("the array is unknown", func() {
+				ginkgo.It("should fail", func() {
+					results, err := ctrlSvc.SuspendReplicationToRemote(context.Background(), "unknown", validRemoteSystemID)
+
+					gomega.Expect(results).To(gomega.BeNil())
+					gomega.Expect(err).ToNot(gomega.BeNil())
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring("can't find array with global id unknown"))
+				})
+			})
 		})
 
 		ginkgo.Describe("calling DeleteStorageProtectionGroup()", func() {
@@ -584,6 +926,19 @@ var _ = ginkgo.Describe("Replication", func() {
 						gomega.ContainSubstring("can't find array with global id"))
 				})
 			})
+			ginkgo.When("the protection group is a NAS server", func() {
+				ginkgo.It("should leave the NAS server and its protection policy in place", func() {
+					req := &csiext.DeleteStorageProtectionGroupRequest{
+						ProtectionGroupId:         validNasID,
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID, "NASServerName": validNasName},
+					}
+					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res).To(gomega.Equal(&csiext.DeleteStorageProtectionGroupResponse{}))
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "GetVolumeGroup", mock.Anything, mock.Anything)
+				})
+			})
 			ginkgo.When("can't get volume group", func() {
 				ginkgo.It("should fail", func() {
 					clientMock.On("GetVolumeGroup", mock.Anything, mock.Anything).Return(
@@ -593,6 +948,7 @@ var _ = ginkgo.Describe("Replication", func() {
 					params := make(map[string]string)
 
 					params["globalID"] = firstValidID
+					params["remoteSystemName"] = validRemoteSystemName
 
 					req.ProtectionGroupAttributes = params
 
@@ -612,6 +968,7 @@ var _ = ginkgo.Describe("Replication", func() {
 					params := make(map[string]string)
 
 					params["globalID"] = firstValidID
+					params["remoteSystemName"] = validRemoteSystemName
 
 					req.ProtectionGroupAttributes = params
 
@@ -628,15 +985,20 @@ var _ = ginkgo.Describe("Replication", func() {
 					vg := gopowerstore.VolumeGroup{}
 					vg.ProtectionPolicyID = validPolicyID
 					vg.ID = validGroupID
+					vg.Name = validGroupName
 					clientMock.On("GetVolumeGroup", mock.Anything, mock.Anything).Return(
 						vg, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).Return(
+						gopowerstore.ReplicationSession{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
 					clientMock.On("ModifyVolumeGroup", mock.Anything, mock.Anything, mock.Anything).Return(
 						gopowerstore.EmptyResponse(""), gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusBadRequest}})
 
 					req := new(csiext.DeleteStorageProtectionGroupRequest)
+					req.ProtectionGroupId = validGroupID
 					params := make(map[string]string)
 
 					params["globalID"] = firstValidID
+					params["remoteSystemName"] = validRemoteSystemName
 
 					req.ProtectionGroupAttributes = params
 
@@ -653,6 +1015,7 @@ var _ = ginkgo.Describe("Replication", func() {
 					vg := gopowerstore.VolumeGroup{}
 					vg.ProtectionPolicyID = ""
 					vg.ID = validGroupID
+					vg.Name = validGroupName
 					clientMock.On("GetVolumeGroup", mock.Anything, mock.Anything).Return(
 						vg, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
 					clientMock.On("DeleteVolumeGroup", mock.Anything, mock.Anything).Return(
@@ -662,6 +1025,7 @@ var _ = ginkgo.Describe("Replication", func() {
 					params := make(map[string]string)
 
 					params["globalID"] = firstValidID
+					params["remoteSystemName"] = validRemoteSystemName
 
 					req.ProtectionGroupAttributes = params
 					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
@@ -669,7 +1033,93 @@ var _ = ginkgo.Describe("Replication", func() {
 					gomega.Expect(res).To(gomega.BeNil())
 					gomega.Expect(err).ToNot(gomega.BeNil())
 					gomega.Expect(err.Error()).To(
-						gomega.ContainSubstring("Error: : Unable to delete Volume Group"))
+						gomega.ContainSubstring("Error: Unable to delete Volume Group: array returned HTTP 400"))
+				})
+			})
+			ginkgo.When("the volume group was not created by the driver", func() {
+				ginkgo.It("should leave the volume group in place", func() {
+					vg := gopowerstore.VolumeGroup{ID: validGroupID, Description: "owned by the backup team", ProtectionPolicyID: validPolicyID}
+					clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(vg, nil)
+					clientMock.On("GetProtectionPolicyByName", mock.Anything, "pp-"+validGroupName).Return(
+						gopowerstore.ProtectionPolicy{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+					clientMock.On("GetReplicationRuleByName", mock.Anything, "rr-"+validGroupName).Return(
+						gopowerstore.ReplicationRule{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+
+					req := &csiext.DeleteStorageProtectionGroupRequest{
+						ProtectionGroupId:         validGroupID,
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID, "remoteSystemName": validRemoteSystemName, "VolumeGroupName": validGroupName},
+					}
+					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res).ToNot(gomega.BeNil())
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "ModifyVolumeGroup", mock.Anything, mock.Anything, mock.Anything)
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteVolumeGroup", mock.Anything, mock.Anything)
+				})
+			})
+			ginkgo.When("the volume group has no description and isn't named by the driver", func() {
+				ginkgo.It("should leave the volume group in place", func() {
+					vg := gopowerstore.VolumeGroup{ID: validGroupID, Name: "database", ProtectionPolicyID: validPolicyID}
+					clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(vg, nil)
+					clientMock.On("GetProtectionPolicyByName", mock.Anything, "pp-"+validGroupName).Return(
+						gopowerstore.ProtectionPolicy{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+					clientMock.On("GetReplicationRuleByName", mock.Anything, "rr-"+validGroupName).Return(
+						gopowerstore.ReplicationRule{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+
+					req := &csiext.DeleteStorageProtectionGroupRequest{
+						ProtectionGroupId:         validGroupID,
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID, "remoteSystemName": validRemoteSystemName, "VolumeGroupName": validGroupName},
+					}
+					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res).ToNot(gomega.BeNil())
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "ModifyVolumeGroup", mock.Anything, mock.Anything, mock.Anything)
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteVolumeGroup", mock.Anything, mock.Anything)
+				})
+			})
+			ginkgo.When("the volume group is tagged as driver-created", func() {
+				ginkgo.It("should delete the volume group", func() {
+					vg := gopowerstore.VolumeGroup{ID: validGroupID, Description: driverVolumeGroupDescription("")}
+					clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(vg, nil)
+					clientMock.On("DeleteVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("GetProtectionPolicyByName", mock.Anything, "pp-"+validGroupName).Return(
+						gopowerstore.ProtectionPolicy{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+					clientMock.On("GetReplicationRuleByName", mock.Anything, "rr-"+validGroupName).Return(
+						gopowerstore.ReplicationRule{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+
+					req := &csiext.DeleteStorageProtectionGroupRequest{
+						ProtectionGroupId:         validGroupID,
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID, "remoteSystemName": validRemoteSystemName, "VolumeGroupName": validGroupName},
+					}
+					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res).ToNot(gomega.BeNil())
+					clientMock.AssertCalled(ginkgo.GinkgoT(), "DeleteVolumeGroup", mock.Anything, validGroupID)
+				})
+			})
+			ginkgo.When("the array rejects the volume group deletion", func() {
+				ginkgo.It("should include the array message in the error", func() {
+					vg := gopowerstore.VolumeGroup{ID: validGroupID, Name: validGroupName}
+					clientMock.On("GetVolumeGroup", mock.Anything, mock.Anything).Return(vg, nil)
+					clientMock.On("DeleteVolumeGroup", mock.Anything, mock.Anything).Return(
+						gopowerstore.EmptyResponse(""), gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{
+							StatusCode: http.StatusUnprocessableEntity,
+							Message:    "The volume group has snapshots.",
+							Arguments:  []string{validGroupID},
+						}})
+
+					req := &csiext.DeleteStorageProtectionGroupRequest{
+						ProtectionGroupId:         validGroupID,
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID, "remoteSystemName": validRemoteSystemName},
+					}
+					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
+
+					gomega.Expect(res).To(gomega.BeNil())
+					gomega.Expect(err).ToNot(gomega.BeNil())
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+						"Unable to delete Volume Group: array returned HTTP 422: The volume group has snapshots. (arguments: " + validGroupID + ")"))
 				})
 			})
 			ginkgo.When("Can't get the protection policy", func() {
@@ -683,6 +1133,8 @@ var _ = ginkgo.Describe("Replication", func() {
 
 					clientMock.On("GetVolumeGroup", mock.Anything, mock.Anything).Return(
 						vg, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).Return(
+						gopowerstore.ReplicationSession{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
 					clientMock.On("GetProtectionPolicyByName", mock.Anything, mock.Anything).Return(
 						gopowerstore.ProtectionPolicy{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusBadRequest}})
 					clientMock.On("ModifyVolumeGroup", mock.Anything, mock.Anything, mock.Anything).Return(
@@ -693,9 +1145,11 @@ var _ = ginkgo.Describe("Replication", func() {
 						gopowerstore.ReplicationRule{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
 
 					req := new(csiext.DeleteStorageProtectionGroupRequest)
+					req.ProtectionGroupId = validGroupID
 					params := make(map[string]string)
 
 					params["globalID"] = firstValidID
+					params["remoteSystemName"] = validRemoteSystemName
 					params["VolumeGroupName"] = validGroupName
 
 					req.ProtectionGroupAttributes = params
@@ -721,6 +1175,8 @@ var _ = ginkgo.Describe("Replication", func() {
 
 					clientMock.On("GetVolumeGroup", mock.Anything, mock.Anything).Return(
 						vg, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).Return(
+						gopowerstore.ReplicationSession{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
 					clientMock.On("GetProtectionPolicyByName", mock.Anything, mock.Anything).Return(
 						gopowerstore.ProtectionPolicy{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
 					clientMock.On("ModifyVolumeGroup", mock.Anything, mock.Anything, mock.Anything).Return(
@@ -731,9 +1187,11 @@ var _ = ginkgo.Describe("Replication", func() {
 						rr, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusBadRequest}})
 
 					req := new(csiext.DeleteStorageProtectionGroupRequest)
+					req.ProtectionGroupId = validGroupID
 					params := make(map[string]string)
 
 					params["globalID"] = firstValidID
+					params["remoteSystemName"] = validRemoteSystemName
 					params["VolumeGroupName"] = validGroupName
 
 					req.ProtectionGroupAttributes = params
@@ -759,6 +1217,8 @@ var _ = ginkgo.Describe("Replication", func() {
 
 					clientMock.On("GetVolumeGroup", mock.Anything, mock.Anything).Return(
 						vg, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).Return(
+						gopowerstore.ReplicationSession{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
 					clientMock.On("GetProtectionPolicyByName", mock.Anything, mock.Anything).Return(
 						gopowerstore.ProtectionPolicy{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusNotFound}})
 					clientMock.On("ModifyVolumeGroup", mock.Anything, mock.Anything, mock.Anything).Return(
@@ -771,9 +1231,11 @@ var _ = ginkgo.Describe("Replication", func() {
 						gopowerstore.EmptyResponse(""), gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusBadRequest}})
 
 					req := new(csiext.DeleteStorageProtectionGroupRequest)
+					req.ProtectionGroupId = validGroupID
 					params := make(map[string]string)
 
 					params["globalID"] = firstValidID
+					params["remoteSystemName"] = validRemoteSystemName
 					params["VolumeGroupName"] = validGroupName
 
 					req.ProtectionGroupAttributes = params
@@ -786,6 +1248,84 @@ var _ = ginkgo.Describe("Replication", func() {
 						gomega.ContainSubstring("Error: Unable to delete replication rule"))
 				})
 			})
+			ginkgo.When("the replication session is still synchronizing", func() {
+				ginkgo.It("should refuse to delete the group", func() {
+					clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(
+						gopowerstore.VolumeGroup{ID: validGroupID, Name: validGroupName, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).Return(
+						gopowerstore.ReplicationSession{ID: "rs-id", State: gopowerstore.RsStateSynchronizing}, nil)
+
+					req := &csiext.DeleteStorageProtectionGroupRequest{
+						ProtectionGroupId: validGroupID,
+						ProtectionGroupAttributes: map[string]string{
+							"globalID":         firstValidID,
+							"remoteSystemName": validRemoteSystemName,
+							"VolumeGroupName":  validGroupName,
+						},
+					}
+					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
+
+					gomega.Expect(res).To(gomega.BeNil())
+					gomega.Expect(err).ToNot(gomega.BeNil())
+					gomega.Expect(status.Code(err)).To(gomega.Equal(codes.FailedPrecondition))
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring("replication session rs-id of volume group"))
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "ModifyVolumeGroup", mock.Anything, mock.Anything, mock.Anything)
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteVolumeGroup", mock.Anything, mock.Anything)
+					clientMock.AssertNotCalled(ginkgo.GinkgoT(), "DeleteProtectionPolicy", mock.Anything, mock.Anything)
+				})
+			})
+			ginkgo.When("the replication session can't be retrieved", func() {
+				ginkgo.It("should fail", func() {
+					clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(
+						gopowerstore.VolumeGroup{ID: validGroupID, Name: validGroupName, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).Return(
+						gopowerstore.ReplicationSession{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusBadRequest}})
+
+					req := &csiext.DeleteStorageProtectionGroupRequest{
+						ProtectionGroupId:         validGroupID,
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID, "remoteSystemName": validRemoteSystemName},
+					}
+					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
+
+					gomega.Expect(res).To(gomega.BeNil())
+					gomega.Expect(err).ToNot(gomega.BeNil())
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring("Error: Unable to get replication session"))
+				})
+			})
+			ginkgo.When("the replication session is idle", func() {
+				ginkgo.It("should delete the group", func() {
+					clientMock.On("GetVolumeGroup", mock.Anything, validGroupID).Return(
+						gopowerstore.VolumeGroup{ID: validGroupID, Name: validGroupName, ProtectionPolicyID: validPolicyID}, nil)
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).Return(
+						gopowerstore.ReplicationSession{ID: "rs-id", State: gopowerstore.RsStatePaused}, nil)
+					clientMock.On("ModifyVolumeGroup", mock.Anything, &gopowerstore.VolumeGroupModify{}, validGroupID).Return(
+						gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("DeleteVolumeGroup", mock.Anything, validGroupID).Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("GetProtectionPolicyByName", mock.Anything, "pp-"+validGroupName).Return(
+						gopowerstore.ProtectionPolicy{ID: validPolicyID, ReplicationRules: []gopowerstore.ReplicationRule{
+							{ID: validRuleID, Name: "rr-" + validGroupName},
+						}}, nil)
+					clientMock.On("DeleteProtectionPolicy", mock.Anything, validPolicyID).Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("GetReplicationRuleByName", mock.Anything, "rr-"+validGroupName).Return(
+						gopowerstore.ReplicationRule{ID: validRuleID}, nil)
+					clientMock.On("DeleteReplicationRule", mock.Anything, validRuleID).Return(gopowerstore.EmptyResponse(""), nil)
+
+					req := &csiext.DeleteStorageProtectionGroupRequest{
+						ProtectionGroupId: validGroupID,
+						ProtectionGroupAttributes: map[string]string{
+							"globalID":         firstValidID,
+							"remoteSystemName": validRemoteSystemName,
+							"VolumeGroupName":  validGroupName,
+						},
+					}
+					res, err := ctrlSvc.DeleteStorageProtectionGroup(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res).ToNot(gomega.BeNil())
+					clientMock.AssertCalled(ginkgo.GinkgoT(), "DeleteVolumeGroup", mock.Anything, validGroupID)
+					clientMock.AssertCalled(ginkgo.GinkgoT(), "DeleteProtectionPolicy", mock.Anything, validPolicyID)
+				})
+			})
 		})
 		ginkgo.Describe("calling GetReplicationCapabilities()", func() {
 			ginkgo.When("basic parameters are declared", func() {
@@ -870,6 +1410,129 @@ var _ = ginkgo.Describe("Replication", func() {
 		})
 
 		ginkgo.Describe("calling ExecuteAction()", func() {
+			ginkgo.BeforeEach(func() {
+				ctrlSvc.replicationActionTimeout = 50 * time.Millisecond
+				ctrlSvc.replicationActionPollInterval = 10 * time.Millisecond
+			})
+
+			ginkgo.When("reprotect verification is enabled", func() {
+				reprotectRequest := func() *csiext.ExecuteActionRequest {
+					return &csiext.ExecuteActionRequest{
+						ProtectionGroupId: validGroupID,
+						ActionTypes: &csiext.ExecuteActionRequest_Action{
+							Action: &csiext.Action{ActionTypes: csiext.ActionTypes_REPROTECT_LOCAL},
+						},
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID},
+					}
+				}
+
+				ginkgo.BeforeEach(func() {
+					ctrlSvc.verifyReprotect = true
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{
+							ID: "test", State: gopowerstore.RsStateFailedOver, Role: "Destination",
+						}, nil).Once()
+					clientMock.On("ExecuteActionOnReplicationSession", mock.Anything, "test", gopowerstore.RsActionReprotect,
+						mock.Anything).Return(gopowerstore.EmptyResponse(""), nil)
+				})
+
+				ginkgo.It("should succeed when the protection group became the source", func() {
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStateOk, Role: "Source"}, nil)
+
+					res, err := ctrlSvc.ExecuteAction(context.Background(), reprotectRequest())
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res.Status.IsSource).To(gomega.BeTrue())
+					gomega.Expect(res.Status.State).To(gomega.Equal(csiext.StorageProtectionGroupStatus_SYNCHRONIZED))
+				})
+
+				ginkgo.It("should fail when the protection group is still the destination", func() {
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStateOk, Role: "Destination"}, nil)
+
+					res, err := ctrlSvc.ExecuteAction(context.Background(), reprotectRequest())
+
+					gomega.Expect(res).To(gomega.BeNil())
+					gomega.Expect(err).ToNot(gomega.BeNil())
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+						"reprotect of replication session test didn't take effect, protection group " + validGroupID +
+							" is still the destination"))
+				})
+			})
+
+			ginkgo.When("the replication session passes through an intermediate state", func() {
+				ginkgo.It("should wait for the final state of the action", func() {
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStateOk}, nil).Once()
+					clientMock.On("ExecuteActionOnReplicationSession", mock.Anything, "test", gopowerstore.RsActionFailover,
+						mock.Anything).Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStateFailingOver}, nil).Twice()
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStateFailedOver}, nil).Once()
+
+					req := &csiext.ExecuteActionRequest{
+						ProtectionGroupId: validGroupID,
+						ActionTypes: &csiext.ExecuteActionRequest_Action{
+							Action: &csiext.Action{ActionTypes: csiext.ActionTypes_FAILOVER_REMOTE},
+						},
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID},
+					}
+					res, err := ctrlSvc.ExecuteAction(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res.Status.State).To(gomega.Equal(csiext.StorageProtectionGroupStatus_FAILEDOVER))
+					clientMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "GetReplicationSessionByLocalResourceID", 4)
+				})
+
+				ginkgo.It("should report the last observed state when the action doesn't complete in time", func() {
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStatePaused}, nil).Once()
+					clientMock.On("ExecuteActionOnReplicationSession", mock.Anything, "test", gopowerstore.RsActionResume,
+						mock.Anything).Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStateResuming}, nil)
+
+					req := &csiext.ExecuteActionRequest{
+						ProtectionGroupId: validGroupID,
+						ActionTypes: &csiext.ExecuteActionRequest_Action{
+							Action: &csiext.Action{ActionTypes: csiext.ActionTypes_RESUME},
+						},
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID},
+					}
+					res, err := ctrlSvc.ExecuteAction(context.Background(), req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res.Success).To(gomega.BeTrue())
+					gomega.Expect(res.Status.State).To(gomega.Equal(csiext.StorageProtectionGroupStatus_SYNC_IN_PROGRESS))
+				})
+
+				ginkgo.It("should stop waiting when the context expires", func() {
+					ctrlSvc.replicationActionTimeout = time.Minute
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStateOk}, nil).Once()
+					clientMock.On("ExecuteActionOnReplicationSession", mock.Anything, "test", gopowerstore.RsActionPause,
+						mock.Anything).Return(gopowerstore.EmptyResponse(""), nil)
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).
+						Return(gopowerstore.ReplicationSession{ID: "test", State: gopowerstore.RsStateOk}, nil)
+
+					req := &csiext.ExecuteActionRequest{
+						ProtectionGroupId: validGroupID,
+						ActionTypes: &csiext.ExecuteActionRequest_Action{
+							Action: &csiext.Action{ActionTypes: csiext.ActionTypes_SUSPEND},
+						},
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID},
+					}
+					ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+					defer cancel()
+					res, err := ctrlSvc.ExecuteAction(ctx, req)
+
+					gomega.Expect(err).To(gomega.BeNil())
+					gomega.Expect(res.Status.State).To(gomega.Equal(csiext.StorageProtectionGroupStatus_SYNCHRONIZED))
+				})
+			})
+
 			ginkgo.When("action is unknown", func() {
 				ginkgo.It("should fail", func() {
 					action := &csiext.Action{
@@ -889,6 +1552,28 @@ var _ = ginkgo.Describe("Replication", func() {
 					gomega.Expect(err).NotTo(gomega.BeNil())
 				})
 			})
+			ginkgo.When("the replication session can't be retrieved", func() {
+				ginkgo.It("should include the array message in the error", func() {
+					clientMock.On("GetReplicationSessionByLocalResourceID", mock.Anything, validGroupID).Return(
+						gopowerstore.ReplicationSession{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{
+							StatusCode: http.StatusServiceUnavailable,
+							Message:    "The system is busy.",
+						}})
+
+					req := &csiext.ExecuteActionRequest{
+						ProtectionGroupId: validGroupID,
+						ActionTypes: &csiext.ExecuteActionRequest_Action{
+							Action: &csiext.Action{ActionTypes: csiext.ActionTypes_SUSPEND},
+						},
+						ProtectionGroupAttributes: map[string]string{"globalID": firstValidID},
+					}
+					_, err := ctrlSvc.ExecuteAction(context.Background(), req)
+
+					gomega.Expect(err).NotTo(gomega.BeNil())
+					gomega.Expect(err.Error()).To(gomega.ContainSubstring(
+						"can't get replication session of protection group " + validGroupID + ": array returned HTTP 503: The system is busy."))
+				})
+			})
 			ginkgo.When("Array can't be found", func() {
 				ginkgo.It("should fail", func() {
 					action := &csiext.Action{
@@ -1048,7 +1733,7 @@ var _ = ginkgo.Describe("Replication", func() {
 
 					gomega.Expect(err).NotTo(gomega.BeNil())
 					gomega.Expect(err.Error()).To(
-						gomega.ContainSubstring("Execute action: Failed to modify RS (test) - Error ()"))
+						gomega.ContainSubstring("Execute action: Failed to modify RS (test) - Error (array returned HTTP 400)"))
 				})
 			})
 			ginkgo.When("the action type is resume", func() {
@@ -1293,3 +1978,600 @@ func TestService_CreateRemoteVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestService_CreateRemoteVolume_RemoteSizeCheck(t *testing.T) {
+	const GiB int64 = 1073741824
+
+	localVolUUID := "aaaaaaaa-0000-bbbb-1111-cccccccccccc"
+	remoteVolUUID := "00000000-aaaa-1111-bbbb-222222222222"
+	powerstoreLocalSystemID := "PS000000000001"
+	powerstoreRemoteSystemID := "PS000000000002"
+	sizeCheckKey := ReplicationPrefix + "/" + KeyReplicationRemoteSizeCheck
+
+	tests := []struct {
+		name             string
+		mode             string
+		remoteConfigured bool
+		remoteSize       int64
+		wantErr          bool
+	}{
+		{name: "sizes match", mode: RemoteSizeCheckError, remoteConfigured: true, remoteSize: 5 * GiB},
+		{name: "sizes differ with warn mode", mode: RemoteSizeCheckWarn, remoteConfigured: true, remoteSize: 3 * GiB},
+		{name: "sizes differ with error mode", mode: RemoteSizeCheckError, remoteConfigured: true, remoteSize: 3 * GiB, wantErr: true},
+		{name: "remote array not configured", mode: RemoteSizeCheckError},
+		{name: "invalid mode", mode: "ignore", remoteConfigured: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			localClient := new(gopowerstoreMock.Client)
+			localClient.On("GetVolumeGroupsByVolumeID", mock.Anything, localVolUUID).Return(gopowerstore.VolumeGroups{
+				VolumeGroup: []gopowerstore.VolumeGroup{{ID: "vg-uuid"}},
+			}, nil)
+			localClient.On("GetReplicationSessionByLocalResourceID", mock.Anything, "vg-uuid").Return(
+				gopowerstore.ReplicationSession{
+					StorageElementPairs: []gopowerstore.StorageElementPair{
+						{LocalStorageElementID: localVolUUID, RemoteStorageElementID: remoteVolUUID},
+					},
+					RemoteSystemID: powerstoreRemoteSystemID,
+				}, nil)
+			localClient.On("GetVolume", mock.Anything, localVolUUID).Return(gopowerstore.Volume{Size: 5 * GiB}, nil)
+			localClient.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{Name: "local-system"}, nil)
+			localClient.On("GetRemoteSystem", mock.Anything, powerstoreRemoteSystemID).Return(
+				gopowerstore.RemoteSystem{SerialNumber: powerstoreRemoteSystemID, ManagementAddress: "127.0.0.2"}, nil)
+
+			localArray := &array.PowerStoreArray{GlobalID: powerstoreLocalSystemID, Client: localClient, IsDefault: true}
+			arrays := map[string]*array.PowerStoreArray{powerstoreLocalSystemID: localArray}
+
+			remoteClient := new(gopowerstoreMock.Client)
+			remoteClient.On("GetVolume", mock.Anything, remoteVolUUID).Return(gopowerstore.Volume{Size: tt.remoteSize}, nil)
+			if tt.remoteConfigured {
+				arrays[powerstoreRemoteSystemID] = &array.PowerStoreArray{GlobalID: powerstoreRemoteSystemID, Client: remoteClient}
+			}
+
+			s := &Service{}
+			s.SetArrays(arrays)
+			s.SetDefaultArray(localArray)
+
+			got, err := s.CreateRemoteVolume(context.Background(), &csiext.CreateRemoteVolumeRequest{
+				VolumeHandle: localVolUUID + "/" + powerstoreLocalSystemID + "/scsi",
+				Parameters:   map[string]string{sizeCheckKey: tt.mode},
+			})
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+			// the remote volume always reports the source volume size
+			assert.Equal(t, 5*GiB, got.RemoteVolume.CapacityBytes)
+			if !tt.remoteConfigured {
+				remoteClient.AssertNotCalled(t, "GetVolume", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestService_StorageProtectionGroupAttributesRoundTrip(t *testing.T) {
+	localVolUUID := "aaaaaaaa-0000-bbbb-1111-cccccccccccc"
+	powerstoreLocalSystemID := "PS000000000001"
+	powerstoreRemoteSystemID := "PS000000000002"
+	volumeGroupID := "vg-uuid"
+	volumeGroupName := "csi-vg"
+
+	tests := []struct {
+		name   string
+		prefix string
+	}{
+		{name: "prefix without separator", prefix: "powerstore"},
+		{name: "prefix with separator", prefix: "powerstore/"},
+		{name: "prefix with repeated separators", prefix: "powerstore//"},
+		{name: "empty prefix", prefix: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGopowerstoreClient := gopowerstoreMock.NewClient(t)
+			mockGopowerstoreClient.On("GetVolumeGroupsByVolumeID", mock.Anything, localVolUUID).Return(gopowerstore.VolumeGroups{
+				VolumeGroup: []gopowerstore.VolumeGroup{{ID: volumeGroupID, Name: volumeGroupName}},
+			}, nil)
+			mockGopowerstoreClient.On("GetReplicationSessionByLocalResourceID", mock.Anything, volumeGroupID).Return(
+				gopowerstore.ReplicationSession{
+					ID:               "rs-uuid",
+					State:            gopowerstore.RsStateOk,
+					LocalResourceID:  volumeGroupID,
+					RemoteSystemID:   powerstoreRemoteSystemID,
+					RemoteResourceID: "remote-vg-uuid",
+				}, nil)
+			mockGopowerstoreClient.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{Name: "local-system"}, nil)
+			mockGopowerstoreClient.On("GetRemoteSystem", mock.Anything, powerstoreRemoteSystemID).Return(
+				gopowerstore.RemoteSystem{Name: "remote-system", SerialNumber: powerstoreRemoteSystemID}, nil)
+			mockGopowerstoreClient.On("GetVolumeGroup", mock.Anything, volumeGroupID).Return(gopowerstore.VolumeGroup{}, nil)
+			mockGopowerstoreClient.On("GetProtectionPolicyByName", mock.Anything, "pp-"+volumeGroupName).
+				Return(gopowerstore.ProtectionPolicy{}, nil)
+			mockGopowerstoreClient.On("GetReplicationRuleByName", mock.Anything, "rr-"+volumeGroupName).
+				Return(gopowerstore.ReplicationRule{}, nil)
+
+			localArray := &array.PowerStoreArray{
+				GlobalID:      powerstoreLocalSystemID,
+				BlockProtocol: identifiers.ISCSITransport,
+				IsDefault:     true,
+				Client:        mockGopowerstoreClient,
+			}
+			s := &Service{
+				Locker:                   *new(array.Locker),
+				replicationContextPrefix: tt.prefix,
+			}
+			s.Locker.SetArrays(map[string]*array.PowerStoreArray{powerstoreLocalSystemID: localArray})
+			s.Locker.SetDefaultArray(localArray)
+
+			created, err := s.CreateStorageProtectionGroup(context.Background(), &csiext.CreateStorageProtectionGroupRequest{
+				VolumeHandle: localVolUUID + "/" + powerstoreLocalSystemID + "/scsi",
+			})
+			assert.NoError(t, err)
+
+			// every key must carry the same, single separator
+			for key := range created.LocalProtectionGroupAttributes {
+				assert.NotContains(t, key, "//")
+				assert.True(t, strings.HasPrefix(key, normalizeContextPrefix(tt.prefix)))
+			}
+			assert.Equal(t, powerstoreLocalSystemID, created.LocalProtectionGroupAttributes[s.withContextPrefix("globalID")])
+			assert.Equal(t, powerstoreRemoteSystemID, created.RemoteProtectionGroupAttributes[s.withContextPrefix("globalID")])
+
+			_, err = s.ExecuteAction(context.Background(), &csiext.ExecuteActionRequest{
+				ProtectionGroupId:         created.LocalProtectionGroupId,
+				ProtectionGroupAttributes: created.LocalProtectionGroupAttributes,
+				ActionTypes: &csiext.ExecuteActionRequest_Action{
+					Action: &csiext.Action{ActionTypes: csiext.ActionTypes_RESUME},
+				},
+			})
+			assert.NoError(t, err)
+
+			_, err = s.DeleteStorageProtectionGroup(context.Background(), &csiext.DeleteStorageProtectionGroupRequest{
+				ProtectionGroupId:         created.LocalProtectionGroupId,
+				ProtectionGroupAttributes: created.LocalProtectionGroupAttributes,
+			})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestService_ReplicationLogFields(t *testing.T) {
+	localVolUUID := "aaaaaaaa-0000-bbbb-1111-cccccccccccc"
+	remoteVolUUID := "00000000-aaaa-1111-bbbb-222222222222"
+	powerstoreLocalSystemID := "PS000000000001"
+	powerstoreRemoteSystemID := "PS000000000002"
+	volumeGroupID := "vg-uuid"
+	requestID := "csi.requestid-42"
+
+	mockGopowerstoreClient := gopowerstoreMock.NewClient(t)
+	mockGopowerstoreClient.On("GetVolumeGroupsByVolumeID", mock.Anything, localVolUUID).Return(gopowerstore.VolumeGroups{
+		VolumeGroup: []gopowerstore.VolumeGroup{{ID: volumeGroupID}},
+	}, nil).Maybe()
+	mockGopowerstoreClient.On("GetReplicationSessionByLocalResourceID", mock.Anything, volumeGroupID).Return(
+		gopowerstore.ReplicationSession{
+			State:               gopowerstore.RsStateOk,
+			LocalResourceID:     volumeGroupID,
+			RemoteSystemID:      powerstoreRemoteSystemID,
+			StorageElementPairs: []gopowerstore.StorageElementPair{{LocalStorageElementID: localVolUUID, RemoteStorageElementID: remoteVolUUID}},
+		}, nil).Maybe()
+	mockGopowerstoreClient.On("GetVolume", mock.Anything, localVolUUID).Return(gopowerstore.Volume{}, nil).Maybe()
+	mockGopowerstoreClient.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{}, nil).Maybe()
+	mockGopowerstoreClient.On("GetRemoteSystem", mock.Anything, powerstoreRemoteSystemID).Return(
+		gopowerstore.RemoteSystem{SerialNumber: powerstoreRemoteSystemID}, nil).Maybe()
+	mockGopowerstoreClient.On("GetVolumeGroup", mock.Anything, volumeGroupID).Return(gopowerstore.VolumeGroup{}, nil).Maybe()
+	mockGopowerstoreClient.On("GetProtectionPolicyByName", mock.Anything, mock.Anything).Return(gopowerstore.ProtectionPolicy{}, nil).Maybe()
+	mockGopowerstoreClient.On("GetReplicationRuleByName", mock.Anything, mock.Anything).Return(gopowerstore.ReplicationRule{}, nil).Maybe()
+
+	localArray := &array.PowerStoreArray{
+		GlobalID:      powerstoreLocalSystemID,
+		BlockProtocol: identifiers.ISCSITransport,
+		IsDefault:     true,
+		Client:        mockGopowerstoreClient,
+	}
+	s := &Service{Locker: *new(array.Locker)}
+	s.Locker.SetArrays(map[string]*array.PowerStoreArray{powerstoreLocalSystemID: localArray})
+	s.Locker.SetDefaultArray(localArray)
+
+	volumeHandle := localVolUUID + "/" + powerstoreLocalSystemID + "/scsi"
+	attributes := map[string]string{"globalID": powerstoreLocalSystemID, "VolumeGroupName": "csi-vg"}
+
+	tests := []struct {
+		name    string
+		message string
+		call    func(ctx context.Context) error
+	}{
+		{
+			name:    "CreateRemoteVolume",
+			message: "Creating remote volume",
+			call: func(ctx context.Context) error {
+				_, err := s.CreateRemoteVolume(ctx, &csiext.CreateRemoteVolumeRequest{VolumeHandle: volumeHandle})
+				return err
+			},
+		},
+		{
+			name:    "CreateStorageProtectionGroup",
+			message: "Creating storage protection group",
+			call: func(ctx context.Context) error {
+				_, err := s.CreateStorageProtectionGroup(ctx, &csiext.CreateStorageProtectionGroupRequest{VolumeHandle: volumeHandle})
+				return err
+			},
+		},
+		{
+			name:    "ExecuteAction",
+			message: "Executing ExecuteAction with following fields",
+			call: func(ctx context.Context) error {
+				_, err := s.ExecuteAction(ctx, &csiext.ExecuteActionRequest{
+					ProtectionGroupId:         volumeGroupID,
+					ProtectionGroupAttributes: attributes,
+					ActionTypes: &csiext.ExecuteActionRequest_Action{
+						Action: &csiext.Action{ActionTypes: csiext.ActionTypes_RESUME},
+					},
+				})
+				return err
+			},
+		},
+		{
+			name:    "DeleteStorageProtectionGroup",
+			message: "Deleting storage protection group",
+			call: func(ctx context.Context) error {
+				_, err := s.DeleteStorageProtectionGroup(ctx, &csiext.DeleteStorageProtectionGroupRequest{
+					ProtectionGroupId:         volumeGroupID,
+					ProtectionGroupAttributes: attributes,
+				})
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := logtest.NewGlobal()
+			defer hook.Reset()
+
+			ctx := context.WithValue(context.Background(), interface{}(csictx.RequestIDKey), requestID)
+			assert.NoError(t, tt.call(ctx))
+
+			var found bool
+			for _, entry := range hook.AllEntries() {
+				if entry.Message != tt.message {
+					continue
+				}
+				found = true
+				assert.Equal(t, requestID, entry.Data["RequestID"])
+				assert.Equal(t, powerstoreLocalSystemID, entry.Data["GlobalID"])
+			}
+			assert.True(t, found, "no log entry with message %q", tt.message)
+		})
+	}
+}
+
+func TestNormalizeContextPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{prefix: "", want: ""},
+		{prefix: "/", want: ""},
+		{prefix: "powerstore", want: "powerstore/"},
+		{prefix: "powerstore/", want: "powerstore/"},
+		{prefix: "powerstore///", want: "powerstore/"},
+		{prefix: "replication.storage.dell.com/powerstore", want: "replication.storage.dell.com/powerstore/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeContextPrefix(tt.prefix))
+		})
+	}
+}
+
+func TestStateDescription(t *testing.T) {
+	tests := []struct {
+		state gopowerstore.RSStateEnum
+		want  string
+	}{
+		{gopowerstore.RsStateInitializing, "Initializing — replication session is being set up"},
+		{gopowerstore.RsStateOk, "OK — replication session is operating normally"},
+		{gopowerstore.RsStateSynchronizing, "Synchronizing — data is being copied to the destination"},
+		{gopowerstore.RsStateSystemPaused, "System paused — replication suspended by the system"},
+		{gopowerstore.RsStatePaused, "Paused — replication suspended by the user"},
+		{gopowerstore.RsStatePausedForMigration, "Paused for migration — replication suspended while a migration is in progress"},
+		{gopowerstore.RsStatePausedForNdu, "Paused for NDU — replication suspended during non-disruptive upgrade"},
+		{gopowerstore.RsStateFractured, "Fractured — replication link is broken and the sides are out of sync"},
+		{gopowerstore.RsStateResuming, "Resuming — replication session is resuming after a pause"},
+		{gopowerstore.RsStateFailingOver, "Failing over — planned failover to the destination is in progress"},
+		{gopowerstore.RsStateFailingOverForDR, "Failing over for DR — unplanned failover to the destination is in progress"},
+		{gopowerstore.RsStateFailedOver, "Failed over — destination has taken over as the source"},
+		{gopowerstore.RsStateReprotecting, "Reprotecting — replication direction is being re-established"},
+		{gopowerstore.RsStatePartialCutoverForMigration, "Partial cutover for migration — migration cutover has only partially completed"},
+		{gopowerstore.RsStateSwitchingToMetroSync, "Switching to metro sync — session is transitioning to metro synchronous replication"},
+		{gopowerstore.RsStateError, "Error — replication session is in an error state and requires attention"},
+		{"Some_New_State", "Unknown — replication session reported unrecognized state \"Some_New_State\""},
+		{"", "Unknown — replication session reported unrecognized state \"\""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.state), func(t *testing.T) {
+			if got := StateDescription(tt.state); got != tt.want {
+				t.Errorf("StateDescription(%q) = %q, want %q", tt.state, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_CreateStorageProtectionGroupManagementPort(t *testing.T) {
+	localVolUUID := "aaaaaaaa-0000-bbbb-1111-cccccccccccc"
+	powerstoreLocalSystemID := "PS000000000001"
+	powerstoreRemoteSystemID := "PS000000000002"
+	volumeGroupID := "vg-uuid"
+
+	tests := []struct {
+		name           string
+		localEndpoint  string
+		remoteEndpoint string
+		wantLocalPort  string
+		wantRemotePort string
+	}{
+		{
+			name:           "non-default ports",
+			localEndpoint:  "https://10.0.0.1:8443/api/rest",
+			remoteEndpoint: "https://10.0.0.2:9443/api/rest",
+			wantLocalPort:  "8443",
+			wantRemotePort: "9443",
+		},
+		{
+			name:           "default port",
+			localEndpoint:  "https://10.0.0.1/api/rest",
+			remoteEndpoint: "https://10.0.0.2/api/rest",
+			wantLocalPort:  "443",
+			wantRemotePort: "443",
+		},
+		{
+			name:           "remote array not configured",
+			localEndpoint:  "https://10.0.0.1:8443/api/rest",
+			wantLocalPort:  "8443",
+			wantRemotePort: "443",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockGopowerstoreClient := gopowerstoreMock.NewClient(t)
+			mockGopowerstoreClient.On("GetVolumeGroupsByVolumeID", mock.Anything, localVolUUID).Return(gopowerstore.VolumeGroups{
+				VolumeGroup: []gopowerstore.VolumeGroup{{ID: volumeGroupID, Name: "csi-vg"}},
+			}, nil)
+			mockGopowerstoreClient.On("GetReplicationSessionByLocalResourceID", mock.Anything, volumeGroupID).Return(
+				gopowerstore.ReplicationSession{
+					LocalResourceID:  volumeGroupID,
+					RemoteSystemID:   powerstoreRemoteSystemID,
+					RemoteResourceID: "remote-vg-uuid",
+				}, nil)
+			mockGopowerstoreClient.On("GetCluster", mock.Anything).Return(gopowerstore.Cluster{Name: "local-system"}, nil)
+			mockGopowerstoreClient.On("GetRemoteSystem", mock.Anything, powerstoreRemoteSystemID).Return(
+				gopowerstore.RemoteSystem{Name: "remote-system", SerialNumber: powerstoreRemoteSystemID}, nil)
+
+			localArray := &array.PowerStoreArray{
+				GlobalID:      powerstoreLocalSystemID,
+				Endpoint:      tt.localEndpoint,
+				BlockProtocol: identifiers.ISCSITransport,
+				IsDefault:     true,
+				Client:        mockGopowerstoreClient,
+			}
+			arrays := map[string]*array.PowerStoreArray{powerstoreLocalSystemID: localArray}
+			if tt.remoteEndpoint != "" {
+				arrays[powerstoreRemoteSystemID] = &array.PowerStoreArray{
+					GlobalID: powerstoreRemoteSystemID,
+					Endpoint: tt.remoteEndpoint,
+				}
+			}
+			s := &Service{
+				Locker:                   *new(array.Locker),
+				replicationContextPrefix: "powerstore/",
+			}
+			s.Locker.SetArrays(arrays)
+			s.Locker.SetDefaultArray(localArray)
+
+			got, err := s.CreateStorageProtectionGroup(context.Background(), &csiext.CreateStorageProtectionGroupRequest{
+				VolumeHandle: localVolUUID + "/" + powerstoreLocalSystemID + "/scsi",
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantLocalPort, got.LocalProtectionGroupAttributes["powerstore/managementPort"])
+			assert.Equal(t, tt.wantRemotePort, got.LocalProtectionGroupAttributes["powerstore/remoteManagementPort"])
+			assert.Equal(t, tt.wantRemotePort, got.RemoteProtectionGroupAttributes["powerstore/managementPort"])
+			assert.Equal(t, tt.wantLocalPort, got.RemoteProtectionGroupAttributes["powerstore/remoteManagementPort"])
+		})
+	}
+}
+
+func TestParseReplicationAlertThreshold(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		rpo     gopowerstore.RPOEnum
+		want    int
+		wantErr string
+	}{
+		{name: "same as the RPO", value: "5", rpo: gopowerstore.RpoFiveMinutes, want: 5},
+		{name: "multiple of the RPO", value: " 120 ", rpo: gopowerstore.RpoThirtyMinutes, want: 120},
+		{name: "longest threshold", value: "1440", rpo: gopowerstore.RpoOneDay, want: 1440},
+		{name: "not a multiple of the RPO", value: "20", rpo: gopowerstore.RpoFifteenMinutes, wantErr: "expected a multiple of the RPO"},
+		{name: "shorter than the RPO", value: "30", rpo: gopowerstore.RpoOneHour, wantErr: "expected a multiple of the RPO"},
+		{name: "too long", value: "1445", rpo: gopowerstore.RpoFiveMinutes, wantErr: "up to 1440 minutes"},
+		{name: "synchronous RPO", value: "5", rpo: gopowerstore.RpoZero, wantErr: "only supported with an asynchronous RPO"},
+		{name: "not a number", value: "1h", rpo: gopowerstore.RpoOneHour, wantErr: "expected a positive number of minutes"},
+		{name: "zero", value: "0", rpo: gopowerstore.RpoOneHour, wantErr: "expected a positive number of minutes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseReplicationAlertThreshold(tt.value, tt.rpo)
+			if tt.wantErr != "" {
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_DumpReplicationSessions(t *testing.T) {
+	notFound := gopowerstore.WrapErr(gopowerstore.NewNotFoundError())
+
+	first := gopowerstoreMock.NewClient(t)
+	first.On("GetVolumeGroups", mock.Anything).Return([]gopowerstore.VolumeGroup{
+		{ID: "vg-2", Name: "csi-b", ProtectionPolicyID: "pp-1"},
+		{ID: "vg-1", Name: "csi-a", ProtectionPolicyID: "pp-1"},
+		{ID: "vg-3", Name: "csi-unprotected"},
+		{ID: "vg-4", Name: "csi-no-session", ProtectionPolicyID: "pp-1"},
+	}, nil)
+	first.On("GetReplicationSessionByLocalResourceID", mock.Anything, "vg-1").Return(gopowerstore.ReplicationSession{
+		ID: "rs-1", State: gopowerstore.RsStateOk, Role: "Source", Type: "Asynchronous",
+		RemoteSystemID: "rs-id-2", RemoteResourceID: "remote-vg-1",
+	}, nil)
+	first.On("GetReplicationSessionByLocalResourceID", mock.Anything, "vg-2").Return(gopowerstore.ReplicationSession{
+		ID: "rs-2", State: gopowerstore.RsStatePaused, Role: "Metro_Preferred", Type: "Metro_Active_Active",
+		RemoteSystemID: "rs-id-2", RemoteResourceID: "remote-vg-2",
+	}, nil)
+	first.On("GetReplicationSessionByLocalResourceID", mock.Anything, "vg-4").Return(gopowerstore.ReplicationSession{}, notFound)
+
+	second := gopowerstoreMock.NewClient(t)
+	second.On("GetVolumeGroups", mock.Anything).Return([]gopowerstore.VolumeGroup{
+		{ID: "remote-vg-1", Name: "csi-a", ProtectionPolicyID: "pp-2"},
+		{ID: "vg-5", Name: "csi-broken", ProtectionPolicyID: "pp-2"},
+	}, nil)
+	second.On("GetReplicationSessionByLocalResourceID", mock.Anything, "remote-vg-1").Return(gopowerstore.ReplicationSession{
+		ID: "rs-1", State: gopowerstore.RsStateOk, Role: "Destination", Type: "Asynchronous",
+		RemoteSystemID: "rs-id-1", RemoteResourceID: "vg-1",
+	}, nil)
+	second.On("GetReplicationSessionByLocalResourceID", mock.Anything, "vg-5").Return(
+		gopowerstore.ReplicationSession{}, gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusInternalServerError, Message: "internal error"}})
+
+	s := &Service{}
+	s.SetArrays(map[string]*array.PowerStoreArray{
+		"globalvolid2": {GlobalID: "globalvolid2", Endpoint: "https://192.168.0.2/api/rest", Username: "admin", Password: "secret", Client: second},
+		"globalvolid1": {GlobalID: "globalvolid1", Endpoint: "https://192.168.0.1/api/rest", Username: "admin", Password: "secret", Client: first},
+	})
+
+	data, err := s.DumpReplicationSessions(context.Background())
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.NotContains(t, string(data), "admin")
+	assert.JSONEq(t, `{
+		"arrays": [
+			{
+				"globalID": "globalvolid1",
+				"endpoint": "https://192.168.0.1/api/rest",
+				"sessions": [
+					{"id": "rs-1", "state": "OK", "role": "Source", "type": "Asynchronous", "remoteSystemID": "rs-id-2",
+						"remoteResourceID": "remote-vg-1", "volumeGroupID": "vg-1", "volumeGroupName": "csi-a"},
+					{"id": "rs-2", "state": "Paused", "role": "Metro_Preferred", "type": "Metro_Active_Active", "remoteSystemID": "rs-id-2",
+						"remoteResourceID": "remote-vg-2", "volumeGroupID": "vg-2", "volumeGroupName": "csi-b"}
+				]
+			},
+			{
+				"globalID": "globalvolid2",
+				"endpoint": "https://192.168.0.2/api/rest",
+				"sessions": [
+					{"id": "rs-1", "state": "OK", "role": "Destination", "type": "Asynchronous", "remoteSystemID": "rs-id-1",
+						"remoteResourceID": "vg-1", "volumeGroupID": "remote-vg-1", "volumeGroupName": "csi-a"}
+				],
+				"errors": ["can't get replication session of volume group vg-5: array returned HTTP 500: internal error"]
+			}
+		]
+	}`, string(data))
+}
+
+func TestService_DumpReplicationSessionsUnreachableArray(t *testing.T) {
+	client := gopowerstoreMock.NewClient(t)
+	client.On("GetVolumeGroups", mock.Anything).Return(nil, errors.New("connection refused"))
+	s := &Service{}
+	s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+
+	data, err := s.DumpReplicationSessions(context.Background())
+	assert.NoError(t, err)
+	var dump ReplicationSessionsDump
+	assert.NoError(t, json.Unmarshal(data, &dump))
+	assert.Len(t, dump.Arrays, 1)
+	assert.Equal(t, firstValidID, dump.Arrays[0].GlobalID)
+	assert.Empty(t, dump.Arrays[0].Sessions)
+	assert.Len(t, dump.Arrays[0].Errors, 1)
+	assert.Contains(t, dump.Arrays[0].Errors[0], "connection refused")
+}
+
+func TestService_CleanupOrphanedReplicationObjects(t *testing.T) {
+	newClient := func(t *testing.T) *gopowerstoreMock.Client {
+		client := gopowerstoreMock.NewClient(t)
+		client.On("GetProtectionPolicies", mock.Anything).Return([]gopowerstore.ProtectionPolicy{
+			{ID: "pp-id-1", Name: "pp-csi-used-vg", VolumeGroups: []gopowerstore.VolumeGroup{{ID: "vg-1"}}},
+			{ID: "pp-id-2", Name: "pp-csi-used-volume", Volumes: []gopowerstore.Volume{{ID: "vol-1"}}},
+			{ID: "pp-id-3", Name: "pp-csi-orphaned"},
+			{ID: "pp-id-4", Name: "pp-csi-replica", IsReplica: true},
+			{ID: "pp-id-5", Name: "customer-policy"},
+		}, nil)
+		client.On("GetReplicationRules", mock.Anything).Return([]gopowerstore.ReplicationRule{
+			{ID: "rr-id-1", Name: "rr-csi-used-vg", ProtectionPolicies: []gopowerstore.ProtectionPolicy{{ID: "pp-id-1"}}},
+			{ID: "rr-id-2", Name: "rr-csi-orphaned", ProtectionPolicies: []gopowerstore.ProtectionPolicy{{ID: "pp-id-3"}}},
+			{ID: "rr-id-3", Name: "rr-csi-unused"},
+			{ID: "rr-id-4", Name: "customer-rule"},
+		}, nil)
+		return client
+	}
+	want := &OrphanedReplicationObjects{
+		ProtectionPolicies: []string{"pp-csi-orphaned"},
+		ReplicationRules:   []string{"rr-csi-orphaned", "rr-csi-unused"},
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		s := &Service{}
+		s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: newClient(t)}})
+
+		got, err := s.CleanupOrphanedReplicationObjects(context.Background(), firstValidID, true)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		client := newClient(t)
+		deletePP := client.On("DeleteProtectionPolicy", mock.Anything, "pp-id-3").Return(gopowerstore.EmptyResponse(""), nil).Once()
+		client.On("DeleteReplicationRule", mock.Anything, "rr-id-2").Return(gopowerstore.EmptyResponse(""), nil).Once().NotBefore(deletePP)
+		client.On("DeleteReplicationRule", mock.Anything, "rr-id-3").Return(gopowerstore.EmptyResponse(""), gopowerstore.WrapErr(gopowerstore.NewNotFoundError())).Once()
+		s := &Service{}
+		s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+
+		got, err := s.CleanupOrphanedReplicationObjects(context.Background(), firstValidID, false)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("delete failure", func(t *testing.T) {
+		client := newClient(t)
+		client.On("DeleteProtectionPolicy", mock.Anything, "pp-id-3").Return(gopowerstore.EmptyResponse(""),
+			gopowerstore.APIError{ErrorMsg: &api.ErrorMsg{StatusCode: http.StatusUnprocessableEntity, Message: "policy is in use"}}).Once()
+		s := &Service{}
+		s.SetArrays(map[string]*array.PowerStoreArray{firstValidID: {GlobalID: firstValidID, Client: client}})
+
+		_, err := s.CleanupOrphanedReplicationObjects(context.Background(), firstValidID, false)
+		assert.Equal(t, codes.Internal, status.Code(err))
+		assert.ErrorContains(t, err, "policy is in use")
+	})
+
+	t.Run("unknown array", func(t *testing.T) {
+		s := &Service{}
+		s.SetArrays(map[string]*array.PowerStoreArray{})
+
+		_, err := s.CleanupOrphanedReplicationObjects(context.Background(), firstValidID, true)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+// trailerStream is the server transport stream of a unary call, capturing the trailer set by the handler
+type trailerStream struct {
+	trailer metadata.MD
+}
+
+func (s *trailerStream) Method() string { return "" }
+
+func (s *trailerStream) SetHeader(metadata.MD) error { return nil }
+
+func (s *trailerStream) SendHeader(metadata.MD) error { return nil }
+
+func (s *trailerStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
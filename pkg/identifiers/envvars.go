@@ -56,7 +56,7 @@ const (
 	// If file not exist or empty or in invalid format, then the driver will use all available FC ports
 	EnvFCPortsFilterFilePath = "X_CSI_FC_PORTS_FILTER_FILE_PATH"
 
-	// EnvThrottlingRateLimit sets a number of concurrent requests to APi
+	// EnvThrottlingRateLimit sets a number of concurrent requests to APi, the rateLimit of an array in config.yaml takes precedence
 	EnvThrottlingRateLimit = "X_CSI_POWERSTORE_THROTTLING_RATE_LIMIT"
 
 	// EnvEnableCHAP is the flag which determines if the driver is going
@@ -124,4 +124,116 @@ const (
 
 	// EnvPodmonArrayConnectivityTimeout specifies the timeout for array connectivity for podmon
 	EnvPodmonArrayConnectivityTimeout = "X_CSI_PODMON_ARRAY_CONNECTIVITY_TIMEOUT"
+
+	// EnvPodmonMaxConcurrentIOChecks specifies the max number of concurrent IO metric queries issued by podmon volume activity checks
+	EnvPodmonMaxConcurrentIOChecks = "X_CSI_PODMON_MAX_CONCURRENT_IO_CHECKS"
+
+	// EnvPodmonMaxConcurrentConnectivityChecks specifies the max number of arrays checked concurrently by podmon node connectivity checks
+	EnvPodmonMaxConcurrentConnectivityChecks = "X_CSI_PODMON_MAX_CONCURRENT_CONNECTIVITY_CHECKS"
+
+	// EnvVGSMemberBatchSize specifies the max number of volumes added to a volume group in a single request
+	EnvVGSMemberBatchSize = "X_CSI_VGS_MEMBER_BATCH_SIZE"
+
+	// EnvEndpointResolveInterval specifies how often FQDN array endpoints are re-resolved, e.g. "5m". Disabled when not set.
+	EnvEndpointResolveInterval = "X_CSI_POWERSTORE_ENDPOINT_RESOLVE_INTERVAL"
+
+	// EnvStrictVolumeHandles when set to "true" rejects legacy single-segment volume handles instead of probing the array
+	EnvStrictVolumeHandles = "X_CSI_POWERSTORE_STRICT_VOLUME_HANDLES"
+
+	// EnvPodmonBlockMetricsMaxAge specifies how old a block volume metric may be to still count as IO in progress, e.g. "60s"
+	EnvPodmonBlockMetricsMaxAge = "X_CSI_PODMON_BLOCK_METRICS_MAX_AGE"
+
+	// EnvPodmonNfsMetricsMaxAge specifies how old a filesystem metric may be to still count as IO in progress, e.g. "90s"
+	EnvPodmonNfsMetricsMaxAge = "X_CSI_PODMON_NFS_METRICS_MAX_AGE"
+
+	// EnvPodmonMetricsInterval specifies the interval of the performance metrics queried for IO in progress,
+	// "5s" or "20s", also named "FiveSec" and "TwentySec"
+	EnvPodmonMetricsInterval = "X_CSI_PODMON_METRICS_INTERVAL"
+
+	// EnvPodmonReportIOOnCheckTimeout when set to "true" reports IO in progress when all IO checks of a request time out,
+	// so that a node isn't fenced while the activity of its volumes is unknown
+	EnvPodmonReportIOOnCheckTimeout = "X_CSI_PODMON_REPORT_IO_ON_CHECK_TIMEOUT"
+
+	// EnvMaxConcurrentLocalVolumeDeletes specifies the max number of local volumes deleted concurrently by DeleteLocalVolumes
+	EnvMaxConcurrentLocalVolumeDeletes = "X_CSI_REPLICATION_MAX_CONCURRENT_LOCAL_VOLUME_DELETES"
+
+	// EnvVGSSnapshotReadyTimeout specifies how long to wait for volume group snapshot members to leave a transient state, e.g. "30s"
+	EnvVGSSnapshotReadyTimeout = "X_CSI_VGS_SNAPSHOT_READY_TIMEOUT"
+
+	// EnvReplicationActionTimeout specifies how long ExecuteAction waits for a replication session to reach the final state
+	// of the executed action, e.g. "60s". The last observed state is reported when it expires.
+	EnvReplicationActionTimeout = "X_CSI_REPLICATION_ACTION_TIMEOUT"
+
+	// EnvReplicationActionPollInterval specifies how often ExecuteAction polls the replication session while waiting
+	// for the final state of the executed action, e.g. "2s"
+	EnvReplicationActionPollInterval = "X_CSI_REPLICATION_ACTION_POLL_INTERVAL"
+
+	// EnvReplicationVerifyReprotect specifies whether ExecuteAction fails a REPROTECT_LOCAL action when the local
+	// protection group isn't the source of the replication session afterwards
+	EnvReplicationVerifyReprotect = "X_CSI_REPLICATION_VERIFY_REPROTECT"
+
+	// EnvMetricsAddress specifies the address, e.g. ":9090", the controller serves its metrics on. Disabled when not set.
+	EnvMetricsAddress = "X_CSI_POWERSTORE_METRICS_ADDRESS"
+
+	// EnvDefaultFsType specifies the filesystem type, e.g. "xfs", used to format volumes when no fsType is requested
+	EnvDefaultFsType = "X_CSI_POWERSTORE_DEFAULT_FS_TYPE"
+
+	// EnvUserAgent overrides the User-Agent header sent to PowerStore arrays, which defaults to "<driver name>/<version>"
+	EnvUserAgent = "X_CSI_POWERSTORE_USER_AGENT"
+
+	// EnvPodmonDataPathCheck when set to "true" makes the node connectivity probe also count the active
+	// iSCSI/FC/NVMe data paths to each array, which is more expensive than the control-plane check alone
+	EnvPodmonDataPathCheck = "X_CSI_PODMON_DATA_PATH_CHECK"
+
+	// EnvPodmonNodeIPSource selects how the controller finds the IP of the node status endpoint: "kubernetes" looks up
+	// the addresses of the Kubernetes node, falling back to the IP in the node ID, which is used by default
+	EnvPodmonNodeIPSource = "X_CSI_PODMON_NODE_IP_SOURCE"
+
+	// EnvPodmonMaxConnectivityMessages specifies the max number of messages retained in a ValidateVolumeHostConnectivity
+	// response; not-connected and timeout messages are always retained and the other ones are summarized beyond the limit
+	EnvPodmonMaxConnectivityMessages = "X_CSI_PODMON_MAX_CONNECTIVITY_MESSAGES"
+
+	// EnvPodmonArrayStatusPathPrefix specifies a path prefix, e.g. "/node-status", prepended to the array status path
+	// of the node status endpoints, for endpoints fronted by an ingress routing on path prefixes
+	EnvPodmonArrayStatusPathPrefix = "X_CSI_PODMON_ARRAY_STATUS_PATH_PREFIX"
+
+	// EnvMaxConcurrentMetricsRequests specifies the max number of concurrent performance metric requests to each array,
+	// bounded independently of the other requests. Unbounded, except by EnvThrottlingRateLimit, when not set.
+	EnvMaxConcurrentMetricsRequests = "X_CSI_POWERSTORE_MAX_CONCURRENT_METRICS_REQUESTS"
+
+	// EnvMaxConcurrentControlRequests specifies the max number of concurrent requests other than performance metric
+	// requests to each array. Unbounded, except by EnvThrottlingRateLimit, when not set.
+	EnvMaxConcurrentControlRequests = "X_CSI_POWERSTORE_MAX_CONCURRENT_CONTROL_REQUESTS"
+
+	// EnvLegacyVolumeProbeOrder specifies which kind of volume is looked up first when resolving the protocol of a
+	// legacy volume handle: "block-first", the default, or "file-first" for deployments mostly using NFS volumes
+	EnvLegacyVolumeProbeOrder = "X_CSI_POWERSTORE_LEGACY_VOLUME_PROBE_ORDER"
+
+	// EnvPodmonMetricsSampleCount specifies how many of the most recent performance metric samples of a volume are
+	// inspected for IO in progress, e.g. more on arrays aggregating metrics slowly
+	EnvPodmonMetricsSampleCount = "X_CSI_PODMON_METRICS_SAMPLE_COUNT"
+
+	// EnvArrayTLSMinVersion specifies the min TLS version, "1.2" or "1.3", negotiated with the arrays by the
+	// PowerStore clients. TLS 1.2 is required when not set.
+	EnvArrayTLSMinVersion = "X_CSI_POWERSTORE_TLS_MIN_VERSION"
+
+	// EnvPodmonArrayStatusScheme specifies the scheme the node status endpoints are served on, "http", the default,
+	// or "https"
+	EnvPodmonArrayStatusScheme = "X_CSI_PODMON_ARRAY_STATUS_SCHEME"
+
+	// EnvPodmonArrayStatusCAFile specifies the path of a PEM bundle of the CAs the certificates of node status
+	// endpoints served on HTTPS are verified against, instead of the system CAs
+	EnvPodmonArrayStatusCAFile = "X_CSI_PODMON_ARRAY_STATUS_CA_FILE"
+
+	// EnvPodmonArrayStatusTokenFile specifies the path of a file holding a bearer token sent to the node status
+	// endpoints. The file is read for every query, so that the token can be rotated.
+	EnvPodmonArrayStatusTokenFile = "X_CSI_PODMON_ARRAY_STATUS_TOKEN_FILE"
+
+	// EnvPodmonValidationBudget specifies the overall time, e.g. "20s", a ValidateVolumeHostConnectivity call may take.
+	// When it runs out, the determination made so far is returned. Bounded by the deadline of the call only when not set.
+	EnvPodmonValidationBudget = "X_CSI_PODMON_VALIDATION_BUDGET"
+
+	// EnvProbeArrayConnectivity specifies whether ProbeController reports the controller as not ready
+	// while any configured array is unreachable from it
+	EnvProbeArrayConnectivity = "X_CSI_PROBE_ARRAY_CONNECTIVITY"
 )
@@ -26,6 +26,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"regexp"
 	"sort"
@@ -65,6 +66,8 @@ type key int
 type ArrayConnectivityStatus struct {
 	LastSuccess int64 `json:"lastSuccess"` // connectivity status
 	LastAttempt int64 `json:"lastAttempt"` // last timestamp attempted to check connectivity
+	// ActiveDataPaths is the number of active block data paths to the array, nil when the data path check is disabled
+	ActiveDataPaths *int `json:"activeDataPaths,omitempty"`
 }
 
 const (
@@ -195,10 +198,77 @@ const (
 	// DefaultPodmonPollRate is the default polling frequency to check for array connectivity
 	DefaultPodmonPollRate = 60
 
+	// DefaultPodmonMaxConcurrentIOChecks is the default max number of concurrent IO metric queries for volume activity checks
+	DefaultPodmonMaxConcurrentIOChecks = 10
+
+	// DefaultPodmonMaxConcurrentConnectivityChecks is the default max number of arrays checked concurrently for node connectivity
+	DefaultPodmonMaxConcurrentConnectivityChecks = 10
+
+	// DefaultPodmonMaxConnectivityMessages is the default max number of messages retained in a connectivity response
+	DefaultPodmonMaxConnectivityMessages = 50
+
+	// DefaultPodmonMetricsMaxAge is the default max age of a performance metric to count as IO in progress
+	DefaultPodmonMetricsMaxAge = 60 * time.Second
+
+	// DefaultPodmonMetricsInterval is the default interval of the performance metrics queried for IO in progress
+	DefaultPodmonMetricsInterval = gopowerstore.TwentySec
+
+	// DefaultPodmonMetricsSampleCount is the default number of the most recent performance metric samples inspected for IO in progress
+	DefaultPodmonMetricsSampleCount = 4
+
+	// DefaultVGSSnapshotReadyTimeout is the default time to wait for volume group snapshot members to leave a transient state
+	DefaultVGSSnapshotReadyTimeout = 30 * time.Second
+
+	// DefaultVGSSnapshotPollInterval is the interval between volume group snapshot member state checks
+	DefaultVGSSnapshotPollInterval = time.Second
+
+	// DefaultReplicationActionTimeout is the default time to wait for a replication session to complete an executed action
+	DefaultReplicationActionTimeout = 60 * time.Second
+
+	// DefaultReplicationActionPollInterval is the interval between replication session state checks after an action
+	DefaultReplicationActionPollInterval = 2 * time.Second
+
+	// DefaultVGSMemberBatchSize is the default max number of volumes added to a volume group in a single request
+	DefaultVGSMemberBatchSize = 100
+
+	// ArrayReachabilityCacheTTL is how long the summary of the arrays reachable from the controller is reused
+	ArrayReachabilityCacheTTL = 30 * time.Second
+
+	// DefaultMaxConcurrentLocalVolumeDeletes is the default max number of local volumes deleted concurrently
+	DefaultMaxConcurrentLocalVolumeDeletes = 10
+
+	// PodmonArrayStatusAttempts is the max number of attempts to query a node status endpoint failing transiently
+	PodmonArrayStatusAttempts = 3
+
+	// DefaultPodmonArrayStatusRetryBackoff is the time to wait before the first retry of a node status query,
+	// doubled before every further retry
+	DefaultPodmonArrayStatusRetryBackoff = 500 * time.Millisecond
+
+	// PodmonArrayStatusMaxRetryBackoff is the max time to wait between two attempts to query a node status endpoint
+	PodmonArrayStatusMaxRetryBackoff = 2 * time.Second
+
+	// NodeIPSourceKubernetes is the EnvPodmonNodeIPSource value looking up node IPs through the Kubernetes API
+	NodeIPSourceKubernetes = "kubernetes"
+
+	// LegacyVolumeProbeBlockFirst is the default EnvLegacyVolumeProbeOrder value, looking up block volumes first
+	LegacyVolumeProbeBlockFirst = "block-first"
+
+	// LegacyVolumeProbeFileFirst is the EnvLegacyVolumeProbeOrder value looking up file systems first
+	LegacyVolumeProbeFileFirst = "file-first"
+
 	// ArrayStatus is the endPoint for polling to check array status
 	ArrayStatus = "/array-status"
+
+	// MetricsEndpoint is the endPoint serving the controller metrics
+	MetricsEndpoint = "/metrics"
 )
 
+// ArrayStatusEndpoint returns the path of the node connectivity status endpoint for the given array.
+// The arrayID is escaped so it always stays a single path segment.
+func ArrayStatusEndpoint(arrayID string) string {
+	return ArrayStatus + "/" + url.PathEscape(arrayID)
+}
+
 // PodmonArrayConnectivityTimeout specifies timeout for making http requests to node services by podmon
 var PodmonArrayConnectivityTimeout = GetPodmonArrayConnectivityTimeout()
 
@@ -245,6 +315,44 @@ func GetIPListFromString(input string) []string {
 	return re.FindAllString(input, -1)
 }
 
+// NodeID represents the components of a csi-powerstore node ID, <prefix>-<uuid>-<ip>
+type NodeID struct {
+	// Node name prefix, e.g. csi-node
+	Prefix string
+	// Host ID of the node, read from the node ID file
+	UUID string
+	// Outbound IP address of the node, either IPv4 or IPv6
+	IP string
+}
+
+var dashedUUIDSuffix = regexp.MustCompile(`-([0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// ParseNodeID parses a node ID of the form <prefix>-<uuid>-<ip>, e.g. csi-node-003c684ccb0c4ca0a9c99423563dfd2c-127.0.0.1
+func ParseNodeID(nodeID string) (NodeID, error) {
+	var parsed NodeID
+
+	i := strings.LastIndex(nodeID, "-")
+	if i < 0 {
+		return parsed, fmt.Errorf("invalid node ID %s: expected format <prefix>-<uuid>-<ip>", nodeID)
+	}
+	rest, ip := nodeID[:i], nodeID[i+1:]
+	if net.ParseIP(ip) == nil {
+		return parsed, fmt.Errorf("invalid node ID %s: %s is not a valid IP address", nodeID, ip)
+	}
+	parsed.IP = ip
+
+	// host IDs are usually plain hex strings, but may also be dashed UUIDs
+	if m := dashedUUIDSuffix.FindStringSubmatchIndex(rest); m != nil {
+		parsed.Prefix, parsed.UUID = rest[:m[0]], rest[m[2]:m[3]]
+	} else if j := strings.LastIndex(rest, "-"); j >= 0 {
+		parsed.Prefix, parsed.UUID = rest[:j], rest[j+1:]
+	}
+	if parsed.Prefix == "" || parsed.UUID == "" {
+		return NodeID{}, fmt.Errorf("invalid node ID %s: expected format <prefix>-<uuid>-<ip>", nodeID)
+	}
+	return parsed, nil
+}
+
 func parseMask(ipaddr string) (mask string, err error) {
 	removeExtra := regexp.MustCompile("^(.*[\\/])")
 	asd := ipaddr[len(ipaddr)-3:]
@@ -488,6 +488,47 @@ func TestGetIPListFromString(t *testing.T) {
 	}
 }
 
+func TestParseNodeID(t *testing.T) {
+	tests := []struct {
+		name    string
+		nodeID  string
+		want    identifiers.NodeID
+		wantErr bool
+	}{
+		{
+			name:   "valid IPv4",
+			nodeID: "csi-node-003c684ccb0c4ca0a9c99423563dfd2c-127.0.0.1",
+			want:   identifiers.NodeID{Prefix: "csi-node", UUID: "003c684ccb0c4ca0a9c99423563dfd2c", IP: "127.0.0.1"},
+		},
+		{
+			name:   "valid IPv6",
+			nodeID: "csi-node-003c684ccb0c4ca0a9c99423563dfd2c-fd00::1",
+			want:   identifiers.NodeID{Prefix: "csi-node", UUID: "003c684ccb0c4ca0a9c99423563dfd2c", IP: "fd00::1"},
+		},
+		{
+			name:   "dashed UUID",
+			nodeID: "node-1a47a1b9-1c44-4a8a-9019-3d8066669603-10.0.0.1",
+			want:   identifiers.NodeID{Prefix: "node", UUID: "1a47a1b9-1c44-4a8a-9019-3d8066669603", IP: "10.0.0.1"},
+		},
+		{name: "invalid IP", nodeID: "csi-node-003c684ccb0c4ca0a9c99423563dfd2c-@@@", wantErr: true},
+		{name: "missing prefix", nodeID: "003c684ccb0c4ca0a9c99423563dfd2c-127.0.0.1", wantErr: true},
+		{name: "no separators", nodeID: "127.0.0.1", wantErr: true},
+		{name: "empty", nodeID: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := identifiers.ParseNodeID(tt.nodeID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseNodeID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNodeID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestReachableEndPoint(t *testing.T) {
 	type args struct {
 		endpoint string
@@ -666,3 +707,23 @@ func TestGetPodmonArrayConnectivityTimeout(t *testing.T) {
 		})
 	}
 }
+
+func TestArrayStatusEndpoint(t *testing.T) {
+	tests := []struct {
+		arrayID  string
+		expected string
+	}{
+		{arrayID: "PS000000000001", expected: "/array-status/PS000000000001"},
+		{arrayID: "globalvolid1", expected: "/array-status/globalvolid1"},
+		{arrayID: "array id", expected: "/array-status/array%20id"},
+		{arrayID: "a/b", expected: "/array-status/a%2Fb"},
+		{arrayID: "../other", expected: "/array-status/..%2Fother"},
+		{arrayID: "", expected: "/array-status/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arrayID, func(t *testing.T) {
+			assert.Equal(t, tt.expected, identifiers.ArrayStatusEndpoint(tt.arrayID))
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -202,3 +203,17 @@ func GetNVMeUUIDs(ctx context.Context, kubeConfigPath string) (map[string]string
 
 	return NodeLabelsRetriever.GetNVMeUUIDs(ctx)
 }
+
+// ListNodes returns all the nodes of the k8s cluster
+func ListNodes(ctx context.Context, kubeConfigPath string) ([]corev1.Node, error) {
+	err := CreateKubeClientSet(kubeConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := Clientset.CoreV1().Nodes().List(ctx, v1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node list: %v", err.Error())
+	}
+	return nodes.Items, nil
+}
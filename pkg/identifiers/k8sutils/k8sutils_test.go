@@ -84,6 +84,16 @@ func TestUtilFunctions(t *testing.T) {
 	})
 }
 
+func TestListNodes(t *testing.T) {
+	k8sutils.Clientset = fake.NewClientset(GetMockNodeWithLabels())
+
+	nodes, err := k8sutils.ListNodes(context.Background(), "")
+
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	assert.Equal(t, "node1", nodes[0].Name)
+}
+
 func TestUtilFunctions_Error(t *testing.T) {
 	nodeLabelsRetriever := &k8sutils.NodeLabelsRetrieverImpl{}
 	nodeLabelsModifier := &k8sutils.NodeLabelsModifierImpl{}
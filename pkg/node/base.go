@@ -26,6 +26,7 @@ import (
 	"net"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -51,12 +52,16 @@ const (
 
 	// default opts values
 	defaultTmpDir = "tmp"
+	defaultFsType = "ext4"
 
 	ephemeralStagingMountPath = "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/ephemeral/"
 
 	commonNfsVolumeFolder = "common_folder"
 )
 
+// supportedFsTypes are the filesystem types block volumes can be formatted with
+var supportedFsTypes = []string{"ext3", "ext4", "xfs"}
+
 // ISCSIConnector is wrapper of gobrcik.ISCSIConnector interface.
 // It allows to connect iSCSI volumes to the node.
 type ISCSIConnector interface {
@@ -135,6 +140,17 @@ func getNodeOptions() Opts {
 		opts.FCPortsFilterFilePath = fcPortsFilterFilePath
 	}
 
+	opts.DefaultFsType = defaultFsType
+	if fsType, ok := csictx.LookupEnv(ctx, identifiers.EnvDefaultFsType); ok && fsType != "" {
+		fsType = strings.ToLower(fsType)
+		if slices.Contains(supportedFsTypes, fsType) {
+			opts.DefaultFsType = fsType
+		} else {
+			log.Warnf("unsupported default filesystem type %s, supported types are %v, using default value %s",
+				fsType, supportedFsTypes, defaultFsType)
+		}
+	}
+
 	// pb parses an environment variable into a boolean value. If an error
 	// is encountered, default is set to false, and error is logged
 	pb := func(n string) bool {
@@ -150,6 +166,7 @@ func getNodeOptions() Opts {
 	}
 
 	opts.EnableCHAP = pb(identifiers.EnvEnableCHAP)
+	opts.DataPathCheck = pb(identifiers.EnvPodmonDataPathCheck)
 
 	if opts.EnableCHAP {
 		opts.CHAPUsername = "admin"
@@ -313,12 +330,52 @@ func getMapping(volID, tmpDir string, fs fs.Interface) (string, error) {
 	return string(data), nil
 }
 
-func deleteMapping(volID, tmpDir string, fs fs.Interface) error {
+// stagingCleanupSummary reports which artifacts were removed by cleanupMappingAndStaging
+type stagingCleanupSummary struct {
+	Unmounted         bool
+	StagingDirRemoved bool
+	MappingRemoved    bool
+}
+
+// cleanupMappingAndStaging removes the staging mount, the staging directory and the device mapping of the volume.
+// It is called once the device is disconnected, so the mapping is removed even when the staging path can't be
+// cleaned: a stale mapping would make a later unstage disconnect whatever device reuses the name.
+// Artifacts that are already gone are skipped.
+func cleanupMappingAndStaging(ctx context.Context, volID, stagingPath, tmpDir string, fs fs.Interface) (stagingCleanupSummary, error) {
+	var summary stagingCleanupSummary
+
+	stagingErr := cleanupStaging(ctx, stagingPath, fs, &summary)
+
 	err := fs.Remove(path.Join(tmpDir, volID))
-	if fs.IsNotExist(err) {
-		return nil
+	if err != nil && !fs.IsNotExist(err) {
+		return summary, status.Errorf(codes.Internal, "failed to delete device mapping for volume %s: %s", volID, err.Error())
+	}
+	summary.MappingRemoved = err == nil
+
+	return summary, stagingErr
+}
+
+// cleanupStaging unmounts and removes the staging path, recording the removed artifacts in summary
+func cleanupStaging(ctx context.Context, stagingPath string, fs fs.Interface, summary *stagingCleanupSummary) error {
+	_, found, err := getTargetMount(ctx, stagingPath, fs)
+	if err != nil {
+		return status.Errorf(codes.Internal,
+			"could not reliably determine existing mount for path %s: %s", stagingPath, err.Error())
+	}
+	if found {
+		if err := fs.GetUtil().Unmount(ctx, stagingPath); err != nil {
+			return status.Errorf(codes.Internal,
+				"could not unmount staging path %s: %s", stagingPath, err.Error())
+		}
+		summary.Unmounted = true
+	}
+
+	err = fs.Remove(stagingPath)
+	if err != nil && !fs.IsNotExist(err) {
+		return status.Errorf(codes.Internal, "failed to delete staging path %s: %s", stagingPath, err.Error())
 	}
-	return err
+	summary.StagingDirRemoved = err == nil
+	return nil
 }
 
 func isBlock(vc *csi.VolumeCapability) bool {
@@ -359,6 +416,34 @@ func getRWModeString(isRO bool) string {
 	return "rw"
 }
 
+// probeFsType returns the filesystem type found on the device at source with blkid, or "" when the device is empty.
+// A device holding a partition table rather than a filesystem is reported by its partition table type.
+func probeFsType(source string, fs fs.Interface) (string, error) {
+	out, err := fs.ExecCommand("blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", source)
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		// blkid fails without output when nothing is found on the device
+		if output == "" {
+			return "", nil
+		}
+		return "", errors.New(output)
+	}
+	var fsType, ptType string
+	for _, line := range strings.Split(output, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "TYPE":
+			fsType = value
+		case "PTTYPE":
+			ptType = value
+		}
+	}
+	if fsType == "" && ptType != "" {
+		return "partition table " + ptType, nil
+	}
+	return fsType, nil
+}
+
 func format(_ context.Context, source, fsType string, fs fs.Interface, opts ...string) error {
 	f := log.Fields{
 		"source":  source,
@@ -366,9 +451,24 @@ func format(_ context.Context, source, fsType string, fs fs.Interface, opts ...s
 		"options": opts,
 	}
 
-	// Use 'ext4' as the default
 	if fsType == "" {
-		fsType = "ext4"
+		fsType = defaultFsType
+	}
+
+	// never reformat a device that already holds data, e.g. when re-staging with a different fsType
+	existingFsType, err := probeFsType(source, fs)
+	if err != nil {
+		log.WithFields(f).WithError(err).Error("probing existing filesystem failed")
+		return status.Errorf(codes.Internal, "can't probe existing filesystem of %s: %s", source, err.Error())
+	}
+	if existingFsType != "" {
+		if existingFsType != fsType {
+			return status.Errorf(codes.FailedPrecondition,
+				"device %s is already formatted to %s, requested fsType is %s; refusing to reformat it",
+				source, existingFsType, fsType)
+		}
+		log.WithFields(f).Infof("device already formatted to %s, skipping format", fsType)
+		return nil
 	}
 
 	mkfsCmd := fmt.Sprintf("mkfs.%s", fsType)
@@ -71,7 +71,9 @@ type Opts struct {
 	CHAPUsername          string
 	CHAPPassword          string
 	TmpDir                string
+	DefaultFsType         string
 	EnableCHAP            bool
+	DataPathCheck         bool
 }
 
 // Service is a controller service that contains scsi connectors and implements NodeServer API
@@ -319,12 +321,21 @@ func (s *Service) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeR
 			array: arr,
 		}
 	} else {
+		var hostName string
+		if s.useFC[arr.GlobalID] {
+			hostName, err = s.hostName()
+			if err != nil {
+				log.WithFields(logFields).Warnf("skipping FC zoning validation: %s", err.Error())
+			}
+		}
 		stager = &SCSIStager{
 			useFC:          s.useFC[arr.GlobalID],
 			useNVME:        s.useNVME[arr.GlobalID],
 			iscsiConnector: s.iscsiConnector,
 			nvmeConnector:  s.nvmeConnector,
 			fcConnector:    s.fcConnector,
+			array:          arr,
+			hostName:       hostName,
 		}
 	}
 
@@ -336,9 +347,13 @@ func (s *Service) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeR
 	if remoteVolumeID != "" { // For Remote Metro volume
 		log.Info("Staging remote metro volume")
 		// need to change the staging path for nfs
-		if nfs.IsNFSVolumeID(req.VolumeId) {
+		if volumeHandle.IsHostBasedNFS() {
 			req.StagingTargetPath = nfs.NfsExportDirectory
 		}
+		if scsiStager, ok := stager.(*SCSIStager); ok {
+			// the remote volume is exposed by the remote array, FC zoning is validated against it when it's managed by the driver
+			scsiStager.array = s.Arrays()[volumeHandle.RemoteArrayGlobalID]
+		}
 		response, err = stager.Stage(ctx, req, logFields, s.Fs, remoteVolumeID, true)
 	}
 
@@ -390,7 +405,7 @@ func (s *Service) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVol
 		log.Info("Unstaging remote metro volume")
 		_, remoteStagingPath := getStagingPath(ctx, req.GetStagingTargetPath(), remoteVolumeID)
 		// need to change the staging path for nfs
-		if nfs.IsNFSVolumeID(req.VolumeId) {
+		if volumeHandle.IsHostBasedNFS() {
 			_, remoteStagingPath = getStagingPath(ctx, nfs.NfsExportDirectory, remoteVolumeID)
 		}
 		_, err = unstageVolume(ctx, remoteStagingPath, remoteVolumeID, logFields, err, s.Fs)
@@ -433,9 +448,12 @@ func (s *Service) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVol
 	}
 	log.WithFields(logFields).WithFields(f).Info("block device removal complete")
 
-	err = deleteMapping(id, s.opts.TmpDir, s.Fs)
+	// the device is gone now, drop its mapping along with any staging artifacts left behind
+	summary, err := cleanupMappingAndStaging(ctx, id, stagingPath, s.opts.TmpDir, s.Fs)
 	if err != nil {
-		log.WithFields(logFields).Warningf("failed to remove vol to Dev mapping: %s", err.Error())
+		log.WithFields(logFields).Warningf("failed to clean up staging artifacts and vol to Dev mapping: %s", err.Error())
+	} else {
+		log.WithFields(logFields).Debugf("staging cleanup summary: %+v", summary)
 	}
 
 	return &csi.NodeUnstageVolumeResponse{}, nil
@@ -579,7 +597,8 @@ func (s *Service) NodePublishVolume(ctx context.Context, req *csi.NodePublishVol
 		publisher = &NFSPublisher{}
 	} else {
 		publisher = &SCSIPublisher{
-			isBlock: isBlock(req.VolumeCapability),
+			isBlock:       isBlock(req.VolumeCapability),
+			defaultFsType: s.opts.DefaultFsType,
 		}
 	}
 
@@ -901,6 +920,77 @@ func (s *Service) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolume
 	return resp, nil
 }
 
+// IOInProgressFunc reports whether IO is currently flowing to the given volume, e.g. by asking the controller
+type IOInProgressFunc func(ctx context.Context, volumeID string) (bool, error)
+
+// VolumeHealthReport is the combined health verdict of a volume published on the node
+type VolumeHealthReport struct {
+	VolumeID      string
+	TargetPath    string
+	Mounted       bool
+	Device        string
+	DevicePresent bool
+	// IOChecked is false when no IO check was requested or the check failed
+	IOChecked    bool
+	IOInProgress bool
+	// IOError holds the failure of the IO check, which is optional and doesn't affect the health of the volume
+	IOError string
+	// Errors holds the failures of the mount and device checks, each check is evaluated independently
+	Errors []string
+}
+
+// Healthy returns true when the volume is mounted and backed by a present device
+func (r VolumeHealthReport) Healthy() bool {
+	return r.Mounted && r.DevicePresent && len(r.Errors) == 0
+}
+
+// GetVolumeHealth checks whether the volume is mounted at targetPath, whether the backing device is present in
+// /sys/block and, when ioCheck is not nil, whether IO is in progress. A failing check doesn't stop the others.
+func (s *Service) GetVolumeHealth(ctx context.Context, volumeID, targetPath string, ioCheck IOInProgressFunc) VolumeHealthReport {
+	logFields := identifiers.GetLogFields(ctx)
+	report := VolumeHealthReport{VolumeID: volumeID, TargetPath: targetPath}
+
+	mount, found, err := getTargetMount(ctx, targetPath, s.Fs)
+	switch {
+	case err != nil:
+		report.Errors = append(report.Errors, fmt.Sprintf("mount check failed: %s", err.Error()))
+	case !found:
+		report.Errors = append(report.Errors, fmt.Sprintf("volume is not mounted at %s", targetPath))
+	default:
+		report.Mounted = true
+		report.Device = mount.Device
+	}
+
+	if report.Device != "" {
+		devName := filepath.Base(report.Device)
+		if resolved, err := filepath.EvalSymlinks(report.Device); err == nil {
+			devName = filepath.Base(resolved)
+		}
+		if _, err := s.Fs.Stat(sysBlock + devName); err != nil {
+			if s.Fs.IsNotExist(err) {
+				report.Errors = append(report.Errors, fmt.Sprintf("device %s is not present", devName))
+			} else {
+				report.Errors = append(report.Errors, fmt.Sprintf("device check failed: %s", err.Error()))
+			}
+		} else {
+			report.DevicePresent = true
+		}
+	}
+
+	if ioCheck != nil {
+		inProgress, err := ioCheck(ctx, volumeID)
+		if err != nil {
+			report.IOError = fmt.Sprintf("IO check failed: %s", err.Error())
+		} else {
+			report.IOChecked = true
+			report.IOInProgress = inProgress
+		}
+	}
+
+	log.WithFields(logFields).Debugf("volume %s health: %+v", volumeID, report)
+	return report
+}
+
 // NodeExpandVolume expands the volume by re-scanning and resizes filesystem if needed
 func (s *Service) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
 	var reqID string
@@ -1253,7 +1343,7 @@ func (s *Service) NodeGetInfo(ctx context.Context, _ *csi.NodeGetInfoRequest) (*
 			log.Info("NFS service is enabled on the array ", arr.GetGlobalID())
 			// we will chop off port from the host if present.
 			port, err := ExtractPort(arr.Endpoint)
-			_, err = getOutboundIP(arr.GetIP(), port, s.Fs)
+			_, err = getOutboundIP(arr.GetResolvedIP(), port, s.Fs)
 			if err == nil {
 				resp.AccessibleTopology.Segments[identifiers.Name+"/"+arr.GetIP()+"-nfs"] = "true"
 			} else {
@@ -1330,15 +1420,10 @@ func (s *Service) NodeGetInfo(ctx context.Context, _ *csi.NodeGetInfoRequest) (*
 				}
 			} else if s.useFC[arr.GlobalID] {
 				// Check node initiators connection to array
-				nodeID := s.nodeID
-				if s.reusedHost {
-					ipList := identifiers.GetIPListFromString(nodeID)
-					if ipList == nil || len(ipList) == 0 {
-						log.Errorf("can't find ip in nodeID %s", nodeID)
-						continue
-					}
-					ip := ipList[len(ipList)-1]
-					nodeID = nodeID[:len(nodeID)-len(ip)-1]
+				nodeID, err := s.hostName()
+				if err != nil {
+					log.Error(err.Error())
+					continue
 				}
 
 				host, err := arr.GetClient().GetHostByName(ctx, nodeID)
@@ -1479,7 +1564,7 @@ func (s *Service) updateNodeID() error {
 		}
 		// we will chop off port from the host if present.
 		port, err := ExtractPort(defaultArray.Endpoint)
-		ip, err := getOutboundIP(defaultArray.GetIP(), port, s.Fs)
+		ip, err := getOutboundIP(defaultArray.GetResolvedIP(), port, s.Fs)
 		log.Debug("Outbound IP address: ", ip.String())
 
 		// When Authorization v2 is enabled the host IP address will be localhost. We should get the actual IP else volume will not mount
@@ -1697,6 +1782,20 @@ func (s *Service) setupHost(initiators []string, client gopowerstore.Client, arr
 	return nil
 }
 
+// hostName returns the name of the node host on the array. A reused host is renamed to the node ID without
+// its IP suffix, see NodeGetInfo.
+func (s *Service) hostName() (string, error) {
+	if !s.reusedHost {
+		return s.nodeID, nil
+	}
+	ipList := identifiers.GetIPListFromString(s.nodeID)
+	if len(ipList) == 0 {
+		return "", fmt.Errorf("can't find ip in nodeID %s", s.nodeID)
+	}
+	ip := ipList[len(ipList)-1]
+	return s.nodeID[:len(s.nodeID)-len(ip)-1], nil
+}
+
 func (s *Service) modifyHostName(ctx context.Context, client gopowerstore.Client, nodeID string, hostID string) error {
 	modifyParams := gopowerstore.HostModify{}
 	modifyParams.Name = &nodeID
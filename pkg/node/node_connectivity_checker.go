@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -56,23 +57,28 @@ func (s *Service) startAPIService(ctx context.Context) {
 // apiRouter serves http requests
 func (s *Service) apiRouter(_ context.Context) {
 	log.Infof("starting http server on port %s", identifiers.APIPort)
+	server := newStatusServer(identifiers.APIPort)
+	err := server.ListenAndServe()
+	if err != nil {
+		log.Errorf("unable to start http server to serve status requests due to %s", err)
+	}
+}
+
+// newStatusServer returns the array status server listening on addr. Every server gets its own router
+// rather than the default mux, so the handlers can be registered again when the server is restarted.
+func newStatusServer(addr string) *http.Server {
 	// create a new mux router
 	router := mux.NewRouter()
 	// route to connectivity status
 	// connectivityStatus is the handlers
 	router.HandleFunc(identifiers.ArrayStatus, connectivityStatus).Methods("GET")
 	router.HandleFunc(identifiers.ArrayStatus+"/"+"{arrayId}", getArrayConnectivityStatus).Methods("GET")
-	// start http server to serve requests
-	server := &http.Server{
-		Addr:         identifiers.APIPort,
+	return &http.Server{
+		Addr:         addr,
 		Handler:      router,
 		ReadTimeout:  identifiers.PodmonArrayConnectivityTimeout,
 		WriteTimeout: identifiers.PodmonArrayConnectivityTimeout,
 	}
-	err := server.ListenAndServe()
-	if err != nil {
-		log.Errorf("unable to start http server to serve status requests due to %s", err)
-	}
 }
 
 // connectivityStatus handler returns array connectivity status
@@ -202,6 +208,12 @@ func (s *Service) testConnectivityAndUpdateStatus(ctx context.Context, array *ar
 			log.Debugf("Probe failed for array '%s' error:'%s'", array.GlobalID, err)
 		}
 		status.LastAttempt = time.Now().Unix()
+		status.ActiveDataPaths = nil
+		if s.opts.DataPathCheck {
+			if paths, ok := s.countActiveDataPaths(timeOutCtx, array); ok {
+				status.ActiveDataPaths = &paths
+			}
+		}
 		log.Debugf("array %s , storing status %+v", array.GlobalID, status)
 		probeStatus.Store(array.GlobalID, status)
 		cancel()
@@ -278,6 +290,50 @@ func (s *Service) nodeProbe(_ context.Context, array *array.PowerStoreArray) err
 	return fmt.Errorf("no active iscsi sessions")
 }
 
+// countActiveDataPaths returns the number of active data paths from the node to the block data ports of the array.
+// The second return value is false when the paths can't be determined, e.g. the node has no host on the array
+// because it only uses NFS.
+func (s *Service) countActiveDataPaths(ctx context.Context, array *array.PowerStoreArray) (int, bool) {
+	host, err := array.GetClient().GetHostByName(ctx, s.nodeID)
+	if err != nil {
+		log.Debugf("Skipping data path check for array %s: %s", array.GlobalID, err.Error())
+		return 0, false
+	}
+	s.populateTargetsInCache(array)
+
+	paths := 0
+	switch {
+	case s.useNVME[array.GlobalID]:
+		sessions, err := s.nvmeLib.GetSessions()
+		if err != nil {
+			log.Errorf("couldn't get nvme sessions: %s", err.Error())
+			return 0, false
+		}
+		for _, session := range sessions {
+			if session.NVMESessionState == gonvme.NVMESessionStateLive && slices.Contains(s.nvmeTargets[array.GlobalID], session.Target) {
+				paths++
+			}
+		}
+	case s.useFC[array.GlobalID]:
+		for _, initiator := range host.Initiators {
+			paths += len(initiator.ActiveSessions)
+		}
+	default:
+		sessions, err := s.iscsiLib.GetSessions()
+		if err != nil {
+			log.Errorf("couldn't get iscsi sessions: %s", err.Error())
+			return 0, false
+		}
+		for _, session := range sessions {
+			if session.ISCSISessionState == goiscsi.ISCSISessionStateLOGGEDIN && slices.Contains(s.iscsiTargets[array.GlobalID], session.Target) {
+				paths++
+			}
+		}
+	}
+	log.Debugf("Found %d active data paths to array %s", paths, array.GlobalID)
+	return paths, true
+}
+
 // populateTargetsInCache checks if nvmeTargets or iscsiTargets in cache is empty, try to fetch the targets from array and populate the cache
 func (s *Service) populateTargetsInCache(array *array.PowerStoreArray) {
 	// if nvmeTargets in cache is empty
@@ -22,6 +22,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
@@ -91,6 +92,27 @@ func TestApiRouter(t *testing.T) {
 	}
 }
 
+func TestNewStatusServerTwice(t *testing.T) {
+	var status identifiers.ArrayConnectivityStatus
+	status.LastSuccess = time.Now().Unix()
+	status.LastAttempt = time.Now().Unix()
+	probeStatus = new(sync.Map)
+	probeStatus.Store("GlobalID", status)
+
+	for i := 0; i < 2; i++ {
+		server := newStatusServer(identifiers.APIPort)
+		if server.Handler == http.DefaultServeMux {
+			t.Fatalf("status server %d must not use the default mux", i)
+		}
+
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, identifiers.ArrayStatusEndpoint("GlobalID"), nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("status server %d returned %d, want %d", i, rec.Code, http.StatusOK)
+		}
+	}
+}
+
 func TestMarshalSyncMapToJSON(t *testing.T) {
 	type args struct {
 		m *sync.Map
@@ -27,6 +27,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	// "github.com/onsi/ginkgo/reporters"
@@ -37,6 +38,7 @@ import (
 	"github.com/dell/csi-powerstore/v2/pkg/controller"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers/k8sutils"
+	"github.com/dell/csm-sharednfs/nfs"
 	"github.com/dell/gobrick"
 	csictx "github.com/dell/gocsi/context"
 	"github.com/dell/gofsutil"
@@ -50,6 +52,8 @@ import (
 	gomega "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -258,6 +262,10 @@ func setVariables() {
 	k8sutils.NodeLabelsModifier = nodeLabelsModifierMock
 	arrays := getTestArrays()
 
+	// devices are empty unless a test says otherwise
+	fsMock.On("ExecCommand", "blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", mock.Anything).
+		Return([]byte{}, errors.New("exit status 2")).Maybe()
+
 	nodeSvc = &Service{
 		Fs:              fsMock,
 		ctrlSvc:         ctrlMock,
@@ -1205,6 +1213,70 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 		})
 	})
 
+	ginkgo.Describe("calling countActiveDataPaths", func() {
+		ginkgo.When("the node has no host on the array", func() {
+			ginkgo.It("should not report any paths", func() {
+				clientMock.On("GetHostByName", mock.Anything, mock.AnythingOfType("string")).
+					Return(gopowerstore.Host{}, gopowerstore.APIError{
+						ErrorMsg: &api.ErrorMsg{
+							StatusCode: http.StatusNotFound,
+						},
+					})
+				arrays := getTestArrays()
+				_, ok := nodeSvc.countActiveDataPaths(context.Background(), arrays["gid1"])
+				gomega.Expect(ok).To(gomega.BeFalse())
+			})
+		})
+
+		ginkgo.When("iscsi sessions to the array targets are logged in", func() {
+			ginkgo.It("should count them as active paths", func() {
+				clientMock.On("GetHostByName", mock.Anything, mock.AnythingOfType("string")).
+					Return(gopowerstore.Host{ID: "host-id", Name: "host-name"}, nil)
+				arrays := getTestArrays()
+				nodeSvc.iscsiTargets[firstGlobalID] = []string{"iqn.2015-10.com.dell:dellemc-foobar-123-a-7ceb34a0"}
+				paths, ok := nodeSvc.countActiveDataPaths(context.Background(), arrays["gid1"])
+				gomega.Expect(ok).To(gomega.BeTrue())
+				gomega.Expect(paths).To(gomega.Equal(1))
+			})
+		})
+
+		ginkgo.When("no iscsi session matches the array targets", func() {
+			ginkgo.It("should report zero active paths", func() {
+				clientMock.On("GetHostByName", mock.Anything, mock.AnythingOfType("string")).
+					Return(gopowerstore.Host{ID: "host-id", Name: "host-name"}, nil)
+				arrays := getTestArrays()
+				nodeSvc.iscsiTargets[firstGlobalID] = []string{"iqn.2015-10.com.dell:dellemc-other-target"}
+				paths, ok := nodeSvc.countActiveDataPaths(context.Background(), arrays["gid1"])
+				gomega.Expect(ok).To(gomega.BeTrue())
+				gomega.Expect(paths).To(gomega.Equal(0))
+			})
+		})
+
+		ginkgo.When("fc initiators have active sessions", func() {
+			ginkgo.It("should count every active session", func() {
+				clientMock.On("GetHostByName", mock.Anything, mock.AnythingOfType("string")).Return(
+					gopowerstore.Host{
+						ID: "host-id",
+						Initiators: []gopowerstore.InitiatorInstance{
+							{
+								ActiveSessions: []gopowerstore.ActiveSessionInstance{{PortName: "port-1"}, {PortName: "port-2"}},
+								PortType:       gopowerstore.InitiatorProtocolTypeEnumFC,
+							},
+							{
+								PortType: gopowerstore.InitiatorProtocolTypeEnumFC,
+							},
+						},
+					}, nil)
+				arrays := getTestArrays()
+				nodeSvc.useFC[firstGlobalID] = true
+				paths, ok := nodeSvc.countActiveDataPaths(context.Background(), arrays["gid1"])
+				nodeSvc.useFC[firstGlobalID] = false
+				gomega.Expect(ok).To(gomega.BeTrue())
+				gomega.Expect(paths).To(gomega.Equal(2))
+			})
+		})
+	})
+
 	ginkgo.Describe("calling NodeStage()", func() {
 		stagingPath := filepath.Join(nodeStagePrivateDir, validBaseVolumeID)
 
@@ -1253,6 +1325,110 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 		ginkgo.When("using FC", func() {
 			ginkgo.It("should successfully stage FC volume", func() {
 				nodeSvc.useFC[firstGlobalID] = true
+				fcConnectorMock.On("GetInitiatorPorts", mock.Anything).
+					Return(validFCTargetsWWPN, nil)
+				clientMock.On("GetHostByName", mock.Anything, validNodeID).Return(
+					gopowerstore.Host{
+						Initiators: []gopowerstore.InitiatorInstance{
+							{
+								ActiveSessions: []gopowerstore.ActiveSessionInstance{{}},
+								PortName:       validFCTargetsWWPNPowerstore[0],
+								PortType:       gopowerstore.InitiatorProtocolTypeEnumFC,
+							},
+						},
+					}, nil)
+				fcConnectorMock.On("ConnectVolume", mock.Anything, gobrick.FCVolumeInfo{
+					Targets: validFCTargetsInfo,
+					Lun:     validLUNIDINT,
+				}).Return(gobrick.Device{}, nil)
+
+				scsiStageVolumeOK(utilMock, fsMock)
+				res, err := nodeSvc.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+					VolumeId:          validBlockVolumeID,
+					PublishContext:    getValidPublishContext(),
+					StagingTargetPath: nodeStagePrivateDir,
+					VolumeCapability: getCapabilityWithVoltypeAccessFstype(
+						"mount", "single-writer", "ext4"),
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(&csi.NodeStageVolumeResponse{}))
+			})
+
+			ginkgo.It("should fail when no node initiator is zoned to the array", func() {
+				nodeSvc.useFC[firstGlobalID] = true
+				fcConnectorMock.On("GetInitiatorPorts", mock.Anything).
+					Return(validFCTargetsWWPN, nil)
+				clientMock.On("GetHostByName", mock.Anything, validNodeID).Return(
+					gopowerstore.Host{
+						Initiators: []gopowerstore.InitiatorInstance{
+							{
+								// initiator is registered on the array but has no login session
+								ActiveSessions: []gopowerstore.ActiveSessionInstance{},
+								PortName:       validFCTargetsWWPNPowerstore[0],
+								PortType:       gopowerstore.InitiatorProtocolTypeEnumFC,
+							},
+							{
+								// initiator has a login session but doesn't belong to this node
+								ActiveSessions: []gopowerstore.ActiveSessionInstance{{}},
+								PortName:       "58:cc:f0:93:48:a0:ff:ff",
+								PortType:       gopowerstore.InitiatorProtocolTypeEnumFC,
+							},
+						},
+					}, nil)
+
+				scsiStageVolumeOK(utilMock, fsMock)
+				res, err := nodeSvc.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+					VolumeId:          validBlockVolumeID,
+					PublishContext:    getValidPublishContext(),
+					StagingTargetPath: nodeStagePrivateDir,
+					VolumeCapability: getCapabilityWithVoltypeAccessFstype(
+						"mount", "single-writer", "ext4"),
+				})
+				gomega.Expect(res).To(gomega.BeNil())
+				gomega.Expect(err).ToNot(gomega.BeNil())
+				gomega.Expect(err.Error()).To(gomega.ContainSubstring("are zoned to array " + firstGlobalID))
+				fcConnectorMock.AssertNotCalled(ginkgo.GinkgoT(), "ConnectVolume", mock.Anything, mock.Anything)
+			})
+
+			ginkgo.It("should still stage FC volume when host can't be retrieved from the array", func() {
+				nodeSvc.useFC[firstGlobalID] = true
+				fcConnectorMock.On("GetInitiatorPorts", mock.Anything).
+					Return(validFCTargetsWWPN, nil)
+				clientMock.On("GetHostByName", mock.Anything, validNodeID).
+					Return(gopowerstore.Host{}, errors.New("api error"))
+				fcConnectorMock.On("ConnectVolume", mock.Anything, gobrick.FCVolumeInfo{
+					Targets: validFCTargetsInfo,
+					Lun:     validLUNIDINT,
+				}).Return(gobrick.Device{}, nil)
+
+				scsiStageVolumeOK(utilMock, fsMock)
+				res, err := nodeSvc.NodeStageVolume(context.Background(), &csi.NodeStageVolumeRequest{
+					VolumeId:          validBlockVolumeID,
+					PublishContext:    getValidPublishContext(),
+					StagingTargetPath: nodeStagePrivateDir,
+					VolumeCapability: getCapabilityWithVoltypeAccessFstype(
+						"mount", "single-writer", "ext4"),
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(&csi.NodeStageVolumeResponse{}))
+			})
+
+			ginkgo.It("should look up a reused host by its name without the IP suffix", func() {
+				nodeSvc.useFC[firstGlobalID] = true
+				nodeSvc.nodeID = validNodeID + "-" + "192.168.0.1"
+				nodeSvc.reusedHost = true
+				fcConnectorMock.On("GetInitiatorPorts", mock.Anything).
+					Return(validFCTargetsWWPN, nil)
+				clientMock.On("GetHostByName", mock.Anything, validNodeID).Return(
+					gopowerstore.Host{
+						Initiators: []gopowerstore.InitiatorInstance{
+							{
+								ActiveSessions: []gopowerstore.ActiveSessionInstance{{}},
+								PortName:       validFCTargetsWWPNPowerstore[0],
+								PortType:       gopowerstore.InitiatorProtocolTypeEnumFC,
+							},
+						},
+					}, nil)
 				fcConnectorMock.On("ConnectVolume", mock.Anything, gobrick.FCVolumeInfo{
 					Targets: validFCTargetsInfo,
 					Lun:     validLUNIDINT,
@@ -1848,7 +2024,7 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 				}
 
 				fsMock.On("GetUtil").Return(utilMock)
-				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(2)
+				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(4)
 				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
 
 				utilMock.On("Unmount", mock.Anything, stagingPath).Return(nil)
@@ -1867,6 +2043,40 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 				})
 				gomega.Expect(err).To(gomega.BeNil())
 				gomega.Expect(res).To(gomega.Equal(&csi.NodeUnstageVolumeResponse{}))
+				// the staging mount still reported after the disconnect is cleaned up before the mapping is removed
+				utilMock.AssertNumberOfCalls(ginkgo.GinkgoT(), "Unmount", 2)
+				fsMock.AssertCalled(ginkgo.GinkgoT(), "Remove", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID))
+			})
+			ginkgo.It("should remove the mapping when the staging cleanup fails after the disconnect [iSCSI]", func() {
+				mountInfo := []gofsutil.Info{
+					{
+						Device: validDevName,
+						Path:   stagingPath,
+					},
+				}
+
+				fsMock.On("GetUtil").Return(utilMock)
+				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(4)
+				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
+
+				utilMock.On("Unmount", mock.Anything, stagingPath).Return(nil).Once()
+				utilMock.On("Unmount", mock.Anything, stagingPath).Return(errors.New("device busy")).Once()
+
+				fsMock.On("Remove", stagingPath).Return(nil)
+				fsMock.On("WriteFile", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID), []byte(validDevName), os.FileMode(0o640)).Return(nil)
+
+				iscsiConnectorMock.On("DisconnectVolumeByDeviceName", mock.Anything, validDevName).Return(nil)
+
+				fsMock.On("Remove", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID)).Return(nil)
+				fsMock.On("IsNotExist", mock.Anything).Return(false)
+
+				res, err := nodeSvc.NodeUnstageVolume(context.Background(), &csi.NodeUnstageVolumeRequest{
+					VolumeId:          validBlockVolumeID,
+					StagingTargetPath: nodeStagePrivateDir,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(&csi.NodeUnstageVolumeResponse{}))
+				fsMock.AssertCalled(ginkgo.GinkgoT(), "Remove", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID))
 			})
 			ginkgo.It("should fail, no targetPath [iSCSI]", func() {
 				mountInfo := []gofsutil.Info{
@@ -1995,9 +2205,10 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 				remoteMountInfo := []gofsutil.Info{{Device: validDevName, Path: remoteStagingPath}}
 
 				fsMock.On("GetUtil").Return(utilMock)
-				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(4)
+				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(6)
 				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil).Once()
 				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(remoteMountInfo, nil).Once()
+				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return([]gofsutil.Info{}, nil).Once()
 
 				utilMock.On("Unmount", mock.Anything, stagingPath).Return(nil).Once()
 				utilMock.On("Unmount", mock.Anything, remoteStagingPath).Return(nil).Once()
@@ -2031,7 +2242,7 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 				}
 
 				fsMock.On("GetUtil").Return(utilMock)
-				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(2)
+				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(4)
 				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
 
 				utilMock.On("Unmount", mock.Anything, stagingPath).Return(nil)
@@ -2062,7 +2273,7 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 				}
 
 				fsMock.On("GetUtil").Return(utilMock)
-				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(2)
+				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(4)
 				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
 
 				utilMock.On("Unmount", mock.Anything, stagingPath).Return(nil)
@@ -2095,7 +2306,7 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 				}
 
 				fsMock.On("GetUtil").Return(utilMock)
-				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(4)
+				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(6)
 				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
 
 				utilMock.On("Unmount", mock.Anything, stagingPath).Return(nil)
@@ -2103,7 +2314,7 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 				fsMock.On("Remove", stagingPath).Return(errors.New("remove " + stagingPath + ": device or resource busy")).Once()
 				fsMock.On("IsDeviceOrResourceBusy", mock.Anything).Return(true)
 				utilMock.On("Unmount", mock.Anything, remnantStagingPath).Return(nil)
-				fsMock.On("Remove", stagingPath).Return(nil).Once()
+				fsMock.On("Remove", stagingPath).Return(nil)
 				fsMock.On("IsNotExist", mock.Anything).Return(false)
 
 				fsMock.On("WriteFile", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID), []byte(validDevName), os.FileMode(0o640)).Return(nil)
@@ -2173,6 +2384,52 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 				gomega.Expect(res).To(gomega.Equal(&csi.NodePublishVolumeResponse{}))
 			})
 		})
+		ginkgo.When("publishing block volume as mount without fsType", func() {
+			ginkgo.It("should format the volume with the configured default fsType", func() {
+				nodeSvc.opts.DefaultFsType = "xfs"
+				fsMock.On("GetUtil").Return(utilMock)
+				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(2)
+				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return([]gofsutil.Info{}, nil)
+
+				fsMock.On("MkdirAll", validTargetPath, mock.Anything).Return(nil)
+				utilMock.On("GetDiskFormat", mock.Anything, stagingPath).Return("", nil)
+				fsMock.On("ExecCommand", "mkfs.xfs", "-K", stagingPath, "-m", "crc=0,finobt=0").Return([]byte{}, nil)
+				utilMock.On("Mount", mock.Anything, stagingPath, validTargetPath, "", "nouuid").Return(nil)
+
+				res, err := nodeSvc.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+					VolumeId:          validBlockVolumeID,
+					PublishContext:    getValidPublishContext(),
+					StagingTargetPath: validStagingPath,
+					TargetPath:        validTargetPath,
+					VolumeCapability:  getCapabilityWithVoltypeAccessFstype("mount", "single-writer", ""),
+					Readonly:          false,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(&csi.NodePublishVolumeResponse{}))
+				fsMock.AssertCalled(ginkgo.GinkgoT(), "ExecCommand", "mkfs.xfs", "-K", stagingPath, "-m", "crc=0,finobt=0")
+			})
+
+			ginkgo.It("should mount an already formatted xfs volume with nouuid", func() {
+				fsMock.On("GetUtil").Return(utilMock)
+				fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil).Times(2)
+				fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return([]gofsutil.Info{}, nil)
+
+				fsMock.On("MkdirAll", validTargetPath, mock.Anything).Return(nil)
+				utilMock.On("GetDiskFormat", mock.Anything, stagingPath).Return("xfs", nil)
+				utilMock.On("Mount", mock.Anything, stagingPath, validTargetPath, "", "nouuid").Return(nil)
+
+				res, err := nodeSvc.NodePublishVolume(context.Background(), &csi.NodePublishVolumeRequest{
+					VolumeId:          validBlockVolumeID,
+					PublishContext:    getValidPublishContext(),
+					StagingTargetPath: validStagingPath,
+					TargetPath:        validTargetPath,
+					VolumeCapability:  getCapabilityWithVoltypeAccessFstype("mount", "single-writer", ""),
+					Readonly:          false,
+				})
+				gomega.Expect(err).To(gomega.BeNil())
+				gomega.Expect(res).To(gomega.Equal(&csi.NodePublishVolumeResponse{}))
+			})
+		})
 		ginkgo.When("publishing block volume as mount with RO", func() {
 			ginkgo.It("should fail", func() {
 				fsMock.On("GetUtil").Return(utilMock)
@@ -4788,6 +5045,172 @@ var _ = ginkgo.Describe("CSINodeService", func() {
 			})
 		})
 	})
+	ginkgo.Describe("calling GetVolumeHealth()", func() {
+		mountInfo := []gofsutil.Info{
+			{
+				Device: validDevPath,
+				Path:   validTargetPath,
+			},
+		}
+		ioActive := func(_ context.Context, _ string) (bool, error) { return true, nil }
+
+		ginkgo.BeforeEach(func() {
+			fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil)
+		})
+
+		ginkgo.It("should report a healthy volume with IO in progress", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
+			fsMock.On("Stat", "/sys/block/"+validDevName).Return(&mocks.FileInfo{}, nil)
+
+			report := nodeSvc.GetVolumeHealth(context.Background(), validBlockVolumeID, validTargetPath, ioActive)
+
+			gomega.Expect(report.Healthy()).To(gomega.BeTrue())
+			gomega.Expect(report.Mounted).To(gomega.BeTrue())
+			gomega.Expect(report.Device).To(gomega.Equal(validDevPath))
+			gomega.Expect(report.DevicePresent).To(gomega.BeTrue())
+			gomega.Expect(report.IOChecked).To(gomega.BeTrue())
+			gomega.Expect(report.IOInProgress).To(gomega.BeTrue())
+			gomega.Expect(report.Errors).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("should skip the IO check when no checker is given", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
+			fsMock.On("Stat", "/sys/block/"+validDevName).Return(&mocks.FileInfo{}, nil)
+
+			report := nodeSvc.GetVolumeHealth(context.Background(), validBlockVolumeID, validTargetPath, nil)
+
+			gomega.Expect(report.Healthy()).To(gomega.BeTrue())
+			gomega.Expect(report.IOChecked).To(gomega.BeFalse())
+		})
+
+		ginkgo.It("should report a missing device", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
+			fsMock.On("Stat", "/sys/block/"+validDevName).Return(&mocks.FileInfo{}, os.ErrNotExist)
+			fsMock.On("IsNotExist", os.ErrNotExist).Return(true)
+
+			report := nodeSvc.GetVolumeHealth(context.Background(), validBlockVolumeID, validTargetPath, ioActive)
+
+			gomega.Expect(report.Healthy()).To(gomega.BeFalse())
+			gomega.Expect(report.Mounted).To(gomega.BeTrue())
+			gomega.Expect(report.DevicePresent).To(gomega.BeFalse())
+			gomega.Expect(report.IOInProgress).To(gomega.BeTrue())
+			gomega.Expect(report.Errors).To(gomega.ConsistOf("device " + validDevName + " is not present"))
+		})
+
+		ginkgo.It("should report an unmounted volume and a failing IO check", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return([]gofsutil.Info{}, nil)
+			ioFailing := func(_ context.Context, _ string) (bool, error) { return false, errors.New("controller unavailable") }
+
+			report := nodeSvc.GetVolumeHealth(context.Background(), validBlockVolumeID, validTargetPath, ioFailing)
+
+			gomega.Expect(report.Healthy()).To(gomega.BeFalse())
+			gomega.Expect(report.Mounted).To(gomega.BeFalse())
+			gomega.Expect(report.DevicePresent).To(gomega.BeFalse())
+			gomega.Expect(report.IOChecked).To(gomega.BeFalse())
+			gomega.Expect(report.Errors).To(gomega.ConsistOf("volume is not mounted at " + validTargetPath))
+			gomega.Expect(report.IOError).To(gomega.ContainSubstring("controller unavailable"))
+			fsMock.AssertNotCalled(ginkgo.GinkgoT(), "Stat", mock.Anything)
+		})
+
+		ginkgo.It("should report a mounted volume with a present device as healthy when the IO check fails", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(mountInfo, nil)
+			fsMock.On("Stat", "/sys/block/"+validDevName).Return(&mocks.FileInfo{}, nil)
+			ioFailing := func(_ context.Context, _ string) (bool, error) { return false, errors.New("controller unavailable") }
+
+			report := nodeSvc.GetVolumeHealth(context.Background(), validBlockVolumeID, validTargetPath, ioFailing)
+
+			gomega.Expect(report.Healthy()).To(gomega.BeTrue())
+			gomega.Expect(report.IOChecked).To(gomega.BeFalse())
+			gomega.Expect(report.IOError).To(gomega.ContainSubstring("controller unavailable"))
+			gomega.Expect(report.Errors).To(gomega.BeEmpty())
+		})
+
+		ginkgo.It("should still check IO when mounts can't be read", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).
+				Return([]gofsutil.Info{}, errors.New("parse error"))
+
+			report := nodeSvc.GetVolumeHealth(context.Background(), validBlockVolumeID, validTargetPath, ioActive)
+
+			gomega.Expect(report.Healthy()).To(gomega.BeFalse())
+			gomega.Expect(report.Errors[0]).To(gomega.ContainSubstring("mount check failed"))
+			gomega.Expect(report.IOChecked).To(gomega.BeTrue())
+			gomega.Expect(report.IOInProgress).To(gomega.BeTrue())
+		})
+	})
+
+	ginkgo.Describe("calling cleanupMappingAndStaging()", func() {
+		stagingPath := filepath.Join(nodeStagePrivateDir, validBaseVolumeID)
+
+		ginkgo.BeforeEach(func() {
+			fsMock.On("ReadFile", "/proc/self/mountinfo").Return([]byte{}, nil)
+			fsMock.On("GetUtil").Return(utilMock)
+		})
+
+		ginkgo.It("should unmount and remove the staging path and the mapping", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return([]gofsutil.Info{
+				{Device: validDevPath, Path: stagingPath},
+			}, nil)
+			utilMock.On("Unmount", mock.Anything, stagingPath).Return(nil).Once()
+			fsMock.On("Remove", stagingPath).Return(nil).Once()
+			fsMock.On("Remove", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID)).Return(nil).Once()
+
+			summary, err := cleanupMappingAndStaging(context.Background(), validBaseVolumeID, stagingPath, nodeSvc.opts.TmpDir, fsMock)
+
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(summary).To(gomega.Equal(stagingCleanupSummary{Unmounted: true, StagingDirRemoved: true, MappingRemoved: true}))
+		})
+
+		ginkgo.It("should only remove the mapping when the staging path is already gone", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return([]gofsutil.Info{}, nil)
+			fsMock.On("Remove", stagingPath).Return(os.ErrNotExist).Once()
+			fsMock.On("IsNotExist", os.ErrNotExist).Return(true)
+			fsMock.On("Remove", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID)).Return(nil).Once()
+
+			summary, err := cleanupMappingAndStaging(context.Background(), validBaseVolumeID, stagingPath, nodeSvc.opts.TmpDir, fsMock)
+
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(summary).To(gomega.Equal(stagingCleanupSummary{MappingRemoved: true}))
+			utilMock.AssertNotCalled(ginkgo.GinkgoT(), "Unmount", mock.Anything, stagingPath)
+		})
+
+		ginkgo.It("should succeed when there is nothing to clean", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return([]gofsutil.Info{}, nil)
+			fsMock.On("Remove", mock.Anything).Return(os.ErrNotExist).Twice()
+			fsMock.On("IsNotExist", os.ErrNotExist).Return(true)
+
+			summary, err := cleanupMappingAndStaging(context.Background(), validBaseVolumeID, stagingPath, nodeSvc.opts.TmpDir, fsMock)
+
+			gomega.Expect(err).To(gomega.BeNil())
+			gomega.Expect(summary).To(gomega.Equal(stagingCleanupSummary{}))
+		})
+
+		ginkgo.It("should still remove the mapping when the unmount fails", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return([]gofsutil.Info{
+				{Device: validDevPath, Path: stagingPath},
+			}, nil)
+			utilMock.On("Unmount", mock.Anything, stagingPath).Return(errors.New("device busy")).Once()
+			fsMock.On("Remove", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID)).Return(nil).Once()
+
+			summary, err := cleanupMappingAndStaging(context.Background(), validBaseVolumeID, stagingPath, nodeSvc.opts.TmpDir, fsMock)
+
+			gomega.Expect(err).ToNot(gomega.BeNil())
+			gomega.Expect(err.Error()).To(gomega.ContainSubstring("could not unmount staging path"))
+			gomega.Expect(summary).To(gomega.Equal(stagingCleanupSummary{MappingRemoved: true}))
+			fsMock.AssertNotCalled(ginkgo.GinkgoT(), "Remove", stagingPath)
+		})
+
+		ginkgo.It("should still remove the mapping when the mounts can't be read", func() {
+			fsMock.On("ParseProcMounts", context.Background(), mock.Anything).Return(nil, errors.New("parse error"))
+			fsMock.On("Remove", path.Join(nodeSvc.opts.TmpDir, validBaseVolumeID)).Return(nil).Once()
+
+			summary, err := cleanupMappingAndStaging(context.Background(), validBaseVolumeID, stagingPath, nodeSvc.opts.TmpDir, fsMock)
+
+			gomega.Expect(err).ToNot(gomega.BeNil())
+			gomega.Expect(err.Error()).To(gomega.ContainSubstring("could not reliably determine existing mount"))
+			gomega.Expect(summary).To(gomega.Equal(stagingCleanupSummary{MappingRemoved: true}))
+		})
+	})
+
 	ginkgo.Describe("calling NodeGetVolumeStats()", func() {
 		ginkgo.When("volume ID is missing", func() {
 			ginkgo.It("should fail", func() {
@@ -5274,6 +5697,103 @@ func TestGetNodeOptions(t *testing.T) {
 	})
 }
 
+func TestGetStagingPathMatchesVolumeHandle(t *testing.T) {
+	stagingDir := "/var/lib/kubelet/plugins/staging"
+	tests := []struct {
+		name         string
+		volumeHandle string
+	}{
+		{"scsi", validBlockVolumeID},
+		{"nfs", validNfsVolumeID},
+		{"host-based nfs", nfs.CsiNfsPrefixDash + validBlockVolumeID},
+		{"metro", validBlockVolumeID + ":" + validRemoteVolID + "/" + secondValidIP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle, err := array.ParseVolumeHandle(tt.volumeHandle)
+			if err != nil {
+				t.Fatalf("ParseVolumeHandle() error = %v", err)
+			}
+
+			id, stagingPath := getStagingPath(context.Background(), stagingDir, handle.LocalUUID)
+			if id != handle.ArrayLocalUUID() {
+				t.Errorf("getStagingPath() id = %v, want %v", id, handle.ArrayLocalUUID())
+			}
+			wantStagingPath := path.Join(stagingDir, handle.LocalUUID)
+			if handle.IsHostBasedNFS() {
+				wantStagingPath = stagingDir
+			}
+			if stagingPath != wantStagingPath {
+				t.Errorf("getStagingPath() stagingPath = %v, want %v", stagingPath, wantStagingPath)
+			}
+		})
+	}
+}
+
+func TestGetNodeOptionsDefaultFsType(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"not set", "", "ext4"},
+		{"xfs", "xfs", "xfs"},
+		{"upper case", "XFS", "xfs"},
+		{"ext3", "ext3", "ext3"},
+		{"unsupported", "btrfs", "ext4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(identifiers.EnvDefaultFsType, tt.value)
+			if got := getNodeOptions().DefaultFsType; got != tt.want {
+				t.Errorf("getNodeOptions().DefaultFsType = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatExistingFilesystem(t *testing.T) {
+	const source = "/dev/sdx"
+	blkid := []interface{}{"blkid", "-p", "-s", "TYPE", "-s", "PTTYPE", "-o", "export", source}
+	tests := []struct {
+		name       string
+		fsType     string
+		probeOut   string
+		probeErr   error
+		wantCode   codes.Code
+		wantFormat bool
+	}{
+		{"empty device", "ext4", "", errors.New("exit status 2"), codes.OK, true},
+		{"matching filesystem", "xfs", "DEVNAME=/dev/sdx\nTYPE=xfs\n", nil, codes.OK, false},
+		{"matching default filesystem", "", "TYPE=ext4", nil, codes.OK, false},
+		{"conflicting filesystem", "xfs", "TYPE=ext4\n", nil, codes.FailedPrecondition, false},
+		{"partition table", "ext4", "PTTYPE=gpt\n", nil, codes.FailedPrecondition, false},
+		{"probe failure", "ext4", "blkid: error: /dev/sdx: Permission denied", errors.New("exit status 4"), codes.Internal, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsMock := new(mocks.FsInterface)
+			fsMock.On("ExecCommand", blkid...).Return([]byte(tt.probeOut), tt.probeErr)
+			fsMock.On("ExecCommand", mock.MatchedBy(func(cmd string) bool { return strings.HasPrefix(cmd, "mkfs.") }),
+				mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]byte{}, nil).Maybe()
+
+			err := format(context.Background(), source, tt.fsType, fsMock)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("format() code = %v, want %v, err: %v", got, tt.wantCode, err)
+			}
+			formatted := false
+			for _, call := range fsMock.Calls {
+				if cmd, _ := call.Arguments.Get(0).(string); strings.HasPrefix(cmd, "mkfs.") {
+					formatted = true
+				}
+			}
+			if formatted != tt.wantFormat {
+				t.Errorf("format() formatted = %v, want %v", formatted, tt.wantFormat)
+			}
+		})
+	}
+}
+
 func getNodeVolumeExpandValidRequest(volid string, isBlock bool) *csi.NodeExpandVolumeRequest {
 	var size int64 = controller.MaxVolumeSizeBytes / 100
 	if !isBlock {
@@ -38,6 +38,8 @@ type VolumePublisher interface {
 // SCSIPublisher implementation of NodeVolumePublisher for SCSI based (FC, iSCSI) volumes
 type SCSIPublisher struct {
 	isBlock bool
+	// defaultFsType is used to format the volume when the mount capability has no fsType
+	defaultFsType string
 }
 
 // Publish publishes volume as either raw block or mount by mounting it to the target path
@@ -92,13 +94,13 @@ func (sp *SCSIPublisher) publishMount(ctx context.Context, logFields log.Fields,
 
 	var opts []string
 	mountCap := vc.GetMount()
-	mountFsType := mountCap.GetFsType()
 	mntFlags := identifiers.GetMountFlags(vc)
-	if mountFsType == "xfs" {
-		mntFlags = append(mntFlags, "nouuid")
-	}
 	targetFS := mountCap.GetFsType()
-	if targetFS == "xfs" {
+	formatFS := targetFS
+	if formatFS == "" {
+		formatFS = sp.defaultFsType
+	}
+	if formatFS == "xfs" {
 		opts = []string{"-m", "crc=0,finobt=0"}
 	}
 	if err := fs.MkdirAll(targetPath, 0o750); err != nil {
@@ -127,11 +129,19 @@ func (sp *SCSIPublisher) publishMount(ctx context.Context, logFields log.Fields,
 				"RO mount required but no fs detected on staged volume %s", stagingPath)
 		}
 
-		if err := format(ctx, stagingPath, targetFS, fs, opts...); err != nil {
+		if err := format(ctx, stagingPath, formatFS, fs, opts...); err != nil {
+			if status.Code(err) == codes.FailedPrecondition {
+				return nil, err
+			}
 			return nil, status.Errorf(codes.Internal,
 				"can't format staged device %s: %s", stagingPath, err.Error())
 		}
-		log.WithFields(logFields).Infof("staged disk %s successfully formatted to %s", stagingPath, targetFS)
+		log.WithFields(logFields).Infof("staged disk %s successfully formatted to %s", stagingPath, formatFS)
+		curFS = formatFS
+	}
+	// snapshots of an xfs volume share its UUID, so xfs is always mounted with nouuid whatever the requested fsType
+	if curFS == "xfs" {
+		mntFlags = append(mntFlags, "nouuid")
 	}
 	if isRO {
 		mntFlags = append(mntFlags, "ro")
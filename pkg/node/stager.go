@@ -59,6 +59,11 @@ type SCSIStager struct {
 	iscsiConnector ISCSIConnector
 	nvmeConnector  NVMEConnector
 	fcConnector    FcConnector
+
+	// array and hostName are used to validate FC zoning before connecting FC volumes, the validation is skipped when
+	// either of them is empty
+	array    *array.PowerStoreArray
+	hostName string
 }
 
 // Stage stages volume by connecting it through either FC or iSCSI and creating bind mount to staging path
@@ -412,6 +417,9 @@ func (s *SCSIStager) connectDevice(ctx context.Context, data scsiPublishContextD
 	if s.useNVME {
 		device, err = s.connectNVMEDevice(ctx, wwn, data, s.useFC)
 	} else if s.useFC {
+		if err := s.validateFCZoning(ctx); err != nil {
+			return "", err
+		}
 		device, err = s.connectFCDevice(ctx, lun, data)
 	} else {
 		device, err = s.connectISCSIDevice(ctx, lun, data)
@@ -491,6 +499,53 @@ func (s *SCSIStager) connectFCDevice(ctx context.Context,
 	})
 }
 
+// validateFCZoning makes sure at least one of the node FC initiators is zoned to the array, by matching the node WWPNs
+// against the host initiators reported by the array that have active sessions.
+// It allows to fail fast with a clear error instead of timing out while discovering the device.
+func (s *SCSIStager) validateFCZoning(ctx context.Context) error {
+	if s.array == nil || s.hostName == "" {
+		return nil
+	}
+	logFields := identifiers.GetLogFields(ctx)
+
+	// only a confirmed zoning gap fails the staging, lookup errors don't prove the node isn't zoned
+	ports, err := s.fcConnector.GetInitiatorPorts(ctx)
+	if err != nil {
+		log.WithFields(logFields).Warnf("skipping FC zoning validation, failed to get FC initiators of the node: %s", err.Error())
+		return nil
+	}
+	nodeWWPNs := make(map[string]bool, len(ports))
+	for _, port := range ports {
+		wwpn, err := formatWWPN(strings.TrimPrefix(port, "0x"))
+		if err != nil {
+			log.WithFields(logFields).Warnf("skipping FC zoning validation, failed to format FC initiator %s: %s", port, err.Error())
+			return nil
+		}
+		nodeWWPNs[strings.ToLower(wwpn)] = true
+	}
+
+	host, err := s.array.GetClient().GetHostByName(ctx, s.hostName)
+	if err != nil {
+		log.WithFields(logFields).Warnf("skipping FC zoning validation, failed to get host %s from array %s: %s",
+			s.hostName, s.array.GlobalID, err.Error())
+		return nil
+	}
+	for _, initiator := range host.Initiators {
+		if initiator.PortType != gopowerstore.InitiatorProtocolTypeEnumFC || !nodeWWPNs[strings.ToLower(initiator.PortName)] {
+			continue
+		}
+		if len(initiator.ActiveSessions) > 0 {
+			log.WithFields(logFields).Debugf("FC initiator %s is zoned to array %s", initiator.PortName, s.array.GlobalID)
+			return nil
+		}
+	}
+
+	msg := fmt.Sprintf("none of the FC initiators %v of host %s are zoned to array %s, check the FC zoning configuration",
+		ports, s.hostName, s.array.GlobalID)
+	log.WithFields(logFields).Error(msg)
+	return status.Error(codes.FailedPrecondition, msg)
+}
+
 func isReadyToPublish(ctx context.Context, stagingPath string, fs fs.Interface) (bool, bool, error) {
 	logFields := identifiers.GetLogFields(ctx)
 	stageInfo, found, err := getTargetMount(ctx, stagingPath, fs)
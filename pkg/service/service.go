@@ -22,6 +22,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/controller"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 	"github.com/dell/csi-powerstore/v2/pkg/node"
@@ -174,11 +175,26 @@ func updateDriverConfigParams(v *viper.Viper) {
 }
 
 // VolumeIDToArrayID returns the array ID for a given volume.
-// Example: abc-123 returns abc
+// Example: abc-123 returns abc, unless the controller service has a default array,
+// which legacy volume IDs like this one belong to.
 func (s *service) VolumeIDToArrayID(volumeID string) string {
+	var defaultArray *array.PowerStoreArray
+	if controllerSvc != nil && volumeID != "" && array.IsLegacyVolumeHandle(volumeID) {
+		defaultArray = controllerSvc.DefaultArray()
+	}
+	return VolumeIDToArrayIDWithDefault(volumeID, defaultArray)
+}
+
+// VolumeIDToArrayIDWithDefault returns the array ID for a given volume. Legacy volume IDs, which
+// consist of only the volume ID, belong to defaultArray and return its global ID.
+// Without a default array the first segment of the volume ID is returned, e.g. abc for abc-123.
+func VolumeIDToArrayIDWithDefault(volumeID string, defaultArray *array.PowerStoreArray) string {
 	if volumeID == "" {
 		return ""
 	}
+	if defaultArray != nil && array.IsLegacyVolumeHandle(volumeID) {
+		return defaultArray.GetGlobalID()
+	}
 	fields := strings.Split(volumeID, "-")
 	return fields[0]
 }
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/dell/csi-powerstore/v2/mocks"
+	"github.com/dell/csi-powerstore/v2/pkg/array"
 	"github.com/dell/csi-powerstore/v2/pkg/identifiers"
 	nfsmock "github.com/dell/csm-sharednfs/nfs/mocks"
 	"github.com/dell/gocsi"
@@ -39,6 +40,8 @@ func TestNew(t *testing.T) {
 }
 
 func TestVolumeIDToArrayID(t *testing.T) {
+	PutControllerService(nil)
+
 	t.Run("empty volume id", func(t *testing.T) {
 		resp := New().VolumeIDToArrayID("")
 		assert.Empty(t, resp)
@@ -48,6 +51,52 @@ func TestVolumeIDToArrayID(t *testing.T) {
 		resp := New().VolumeIDToArrayID("123-456")
 		assert.Equal(t, "123", resp)
 	})
+
+	t.Run("legacy volume id with a default array", func(t *testing.T) {
+		mockController := new(mocks.ControllerInterface)
+		mockController.On("DefaultArray").Return(&array.PowerStoreArray{GlobalID: "PS000000000001"})
+		PutControllerService(mockController)
+		defer PutControllerService(nil)
+
+		resp := New().VolumeIDToArrayID("123-456")
+		assert.Equal(t, "PS000000000001", resp)
+	})
+
+	t.Run("legacy volume id without a default array", func(t *testing.T) {
+		mockController := new(mocks.ControllerInterface)
+		mockController.On("DefaultArray").Return(nil)
+		PutControllerService(mockController)
+		defer PutControllerService(nil)
+
+		resp := New().VolumeIDToArrayID("123-456")
+		assert.Equal(t, "123", resp)
+	})
+}
+
+func TestVolumeIDToArrayIDWithDefault(t *testing.T) {
+	defaultArray := &array.PowerStoreArray{GlobalID: "PS000000000001"}
+
+	tests := []struct {
+		name         string
+		volumeID     string
+		defaultArray *array.PowerStoreArray
+		want         string
+	}{
+		{name: "empty volume id", volumeID: "", defaultArray: defaultArray, want: ""},
+		{name: "legacy volume id", volumeID: "39bb1b5f-5624-490d-9ece-18f7b28a904e", defaultArray: defaultArray, want: "PS000000000001"},
+		{name: "legacy volume id without default array", volumeID: "39bb1b5f-5624-490d-9ece-18f7b28a904e", want: "39bb1b5f"},
+		{
+			name:         "volume handle",
+			volumeID:     "39bb1b5f-5624-490d-9ece-18f7b28a904e/PS000000000002/scsi",
+			defaultArray: defaultArray,
+			want:         "39bb1b5f",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, VolumeIDToArrayIDWithDefault(tt.volumeID, tt.defaultArray))
+		})
+	}
 }
 
 func TestRegisterAdditionalServers(t *testing.T) {